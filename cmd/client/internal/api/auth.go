@@ -1,15 +1,71 @@
 package api
 
+import "errors"
+
+// ErrAuthorizationPending is returned by PollDeviceLogin until the login is
+// approved in the browser.
+var ErrAuthorizationPending = errors.New("authorization pending")
+
 // Login authenticates with existing credentials and returns a token.
+// The client keeps the session and refreshes it as needed. If the account
+// has 2FA the response only carries a challenge for LoginTwoFactor.
 func (c *Client) Login(req AuthRequest) (AuthResponse, error) {
 	var res AuthResponse
 	err := c.do("POST", "/api/v1/auth/login", req, &res)
+	if err == nil && !res.TwoFactorRequired {
+		c.SetSession(res)
+	}
+	return res, err
+}
+
+// LoginTwoFactor completes a 2FA login with a TOTP or recovery code.
+func (c *Client) LoginTwoFactor(challenge, code string) (AuthResponse, error) {
+	var res AuthResponse
+	err := c.do("POST", "/api/v1/auth/login/2fa", TwoFactorRequest{ChallengeToken: challenge, Code: code}, &res)
+	if err == nil {
+		c.SetSession(res)
+	}
 	return res, err
 }
 
 // Register creates a new account and returns a token.
+// The client keeps the session and refreshes it as needed.
 func (c *Client) Register(req AuthRequest) (AuthResponse, error) {
 	var res AuthResponse
 	err := c.do("POST", "/api/v1/auth/register", req, &res)
+	if err == nil {
+		c.SetSession(res)
+	}
+	return res, err
+}
+
+// Logout revokes the current session on the server and forgets its tokens.
+func (c *Client) Logout() error {
+	err := c.do("POST", "/api/v1/auth/logout", nil, nil)
+	c.SetToken("")
+	return err
+}
+
+// StartDeviceLogin starts a single sign-on login. The user opens the
+// returned URL in a browser while the client polls PollDeviceLogin.
+func (c *Client) StartDeviceLogin() (DeviceLoginResponse, error) {
+	var res DeviceLoginResponse
+	err := c.do("POST", "/api/v1/auth/oidc/device", nil, &res)
+	return res, err
+}
+
+// PollDeviceLogin returns ErrAuthorizationPending until the device login is
+// approved, then keeps the session like Login. If the account has 2FA the
+// response only carries a challenge for LoginTwoFactor.
+func (c *Client) PollDeviceLogin(deviceCode string) (AuthResponse, error) {
+	var res AuthResponse
+	err := c.do("POST", "/api/v1/auth/oidc/device/token", DeviceTokenRequest{DeviceCode: deviceCode}, &res)
+	var se *StatusError
+	if errors.As(err, &se) && se.Code == "authorization_pending" {
+		return res, ErrAuthorizationPending
+	}
+	if err == nil && !res.TwoFactorRequired {
+		c.SetSession(res)
+	}
 	return res, err
 }
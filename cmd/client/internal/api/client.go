@@ -8,14 +8,35 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// refreshMargin is how long before expiry the access token is refreshed.
+const refreshMargin = 30 * time.Second
+
 // Client is an HTTP client for the chat server API.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
-	token      string
 	logger     *slog.Logger
+
+	mu           sync.Mutex // guards the session fields below
+	token        string
+	expiresAt    time.Time
+	refreshToken string
+}
+
+// StatusError is an error response from the server. Code is the server's
+// machine-readable error code, e.g. "authorization_pending".
+type StatusError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
 }
 
 // New creates a new API client for the given base URL.
@@ -27,9 +48,65 @@ func New(baseURL string, logger *slog.Logger) *Client {
 	}
 }
 
-// SetToken sets the JWT token used for authenticated requests.
+// SetToken sets the JWT token used for authenticated requests. The token
+// is not refreshed; use SetSession for tokens obtained by logging in.
 func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.token = token
+	c.expiresAt = time.Time{}
+	c.refreshToken = ""
+}
+
+// SetSession stores the token pair from a login, register or refresh response.
+func (c *Client) SetSession(res AuthResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = res.Token
+	c.expiresAt = time.Unix(res.ExpiresAt, 0)
+	c.refreshToken = res.RefreshToken
+}
+
+// AccessToken returns a valid access token, refreshing it first if it is
+// about to expire. Use it when the token leaves the client, e.g. for the
+// WebSocket handshake.
+func (c *Client) AccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refreshIfNeeded(); err != nil {
+		return "", err
+	}
+	return c.token, nil
+}
+
+// refreshIfNeeded swaps the refresh token for a new pair when the access
+// token is close to expiring. c.mu must be held.
+func (c *Client) refreshIfNeeded() error {
+	if c.refreshToken == "" || time.Until(c.expiresAt) > refreshMargin {
+		return nil
+	}
+
+	data, err := json.Marshal(RefreshRequest{RefreshToken: c.refreshToken})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequest("POST", c.baseURL+"/api/v1/auth/refresh", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.logger.Debug("refreshing access token")
+
+	var res AuthResponse
+	if err := c.exchange(req, &res); err != nil {
+		// the session is gone (expired, revoked or reused): stop trying
+		c.refreshToken = ""
+		return fmt.Errorf("session expired, log in again: %w", err)
+	}
+	c.token = res.Token
+	c.expiresAt = time.Unix(res.ExpiresAt, 0)
+	c.refreshToken = res.RefreshToken
+	return nil
 }
 
 func (c *Client) do(method, path string, body any, result any) error {
@@ -50,12 +127,24 @@ func (c *Client) do(method, path string, body any, result any) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
-	}
-
 	c.logger.Debug("api request", "method", method, "path", path)
+	return c.send(req, result)
+}
+
+// send performs req with the auth header set and decodes the JSON response into result.
+func (c *Client) send(req *http.Request, result any) error {
+	token, err := c.AccessToken()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.exchange(req, result)
+}
 
+// exchange performs req and decodes the JSON response into result.
+func (c *Client) exchange(req *http.Request, result any) error {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("do request: %w", err)
@@ -72,7 +161,7 @@ func (c *Client) do(method, path string, body any, result any) error {
 	if resp.StatusCode >= 400 {
 		var apiErr Error
 		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
-			return fmt.Errorf("%s", apiErr.Error)
+			return &StatusError{Status: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Error}
 		}
 		return fmt.Errorf("request failed with status %d", resp.StatusCode)
 	}
@@ -0,0 +1,37 @@
+package api
+
+import "fmt"
+
+// ListPins returns the pinned messages of a room, most recently pinned first.
+func (c *Client) ListPins(roomID int64) ([]PinnedMessageResponse, error) {
+	var pins []PinnedMessageResponse
+	err := c.do("GET", fmt.Sprintf("/api/v1/rooms/%d/pins", roomID), nil, &pins)
+	return pins, err
+}
+
+// PinMessage pins a room message. Only moderators can do it.
+func (c *Client) PinMessage(roomID, messageID int64) error {
+	return c.do("PUT", fmt.Sprintf("/api/v1/rooms/%d/pins/%d", roomID, messageID), nil, nil)
+}
+
+// UnpinMessage unpins a room message. Only moderators can do it.
+func (c *Client) UnpinMessage(roomID, messageID int64) error {
+	return c.do("DELETE", fmt.Sprintf("/api/v1/rooms/%d/pins/%d", roomID, messageID), nil, nil)
+}
+
+// ListSaved returns the user's saved messages, most recently saved first.
+func (c *Client) ListSaved(limit int) ([]SavedMessageResponse, error) {
+	var saved []SavedMessageResponse
+	err := c.do("GET", fmt.Sprintf("/api/v1/users/me/saved?limit=%d", limit), nil, &saved)
+	return saved, err
+}
+
+// SaveMessage adds a message to the user's saved list.
+func (c *Client) SaveMessage(messageID int64) error {
+	return c.do("PUT", fmt.Sprintf("/api/v1/users/me/saved/%d", messageID), nil, nil)
+}
+
+// UnsaveMessage removes a message from the user's saved list.
+func (c *Client) UnsaveMessage(messageID int64) error {
+	return c.do("DELETE", fmt.Sprintf("/api/v1/users/me/saved/%d", messageID), nil, nil)
+}
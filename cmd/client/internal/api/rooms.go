@@ -2,10 +2,14 @@ package api
 
 import "fmt"
 
-// ListRooms returns all available rooms.
-func (c *Client) ListRooms() ([]RoomResponse, error) {
+// ListRooms returns all available rooms. Archived rooms are only included if includeArchived is set.
+func (c *Client) ListRooms(includeArchived bool) ([]RoomResponse, error) {
 	var rooms []RoomResponse
-	err := c.do("GET", "/api/v1/rooms", nil, &rooms)
+	path := "/api/v1/rooms"
+	if includeArchived {
+		path += "?include_archived=true"
+	}
+	err := c.do("GET", path, nil, &rooms)
 	return rooms, err
 }
 
@@ -0,0 +1,12 @@
+package api
+
+import "time"
+
+// ScheduleMessage asks the server to send body at sendAt, to a room when
+// roomID is set and as a DM to toUserID otherwise.
+func (c *Client) ScheduleMessage(roomID, toUserID int64, body string, sendAt time.Time) (ScheduledMessageResponse, error) {
+	var res ScheduledMessageResponse
+	req := ScheduleMessageRequest{RoomID: roomID, ToUserID: toUserID, Body: body, SendAt: sendAt}
+	err := c.do("POST", "/api/v1/scheduled-messages", req, &res)
+	return res, err
+}
@@ -10,9 +10,40 @@ type AuthRequest struct {
 
 // AuthResponse represents the response from login or register endpoints.
 type AuthResponse struct {
-	User      UserResponse `json:"user"`
-	Token     string       `json:"token"`
-	ExpiresAt int64        `json:"expires_at"`
+	User             UserResponse `json:"user"`
+	Token            string       `json:"token"`
+	ExpiresAt        int64        `json:"expires_at"`
+	RefreshToken     string       `json:"refresh_token"`
+	RefreshExpiresAt int64        `json:"refresh_expires_at"`
+	// set instead of the tokens when the account has 2FA
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+// TwoFactorRequest represents the second step of a login with 2FA.
+type TwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// DeviceLoginResponse represents a started single sign-on device login.
+type DeviceLoginResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceTokenRequest represents a poll for the tokens of a device login.
+type DeviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// RefreshRequest represents the payload for exchanging a refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // UserResponse represents a user in API responses.
@@ -22,23 +53,111 @@ type UserResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ProfileResponse represents a user's public profile in API responses.
+type ProfileResponse struct {
+	ID                 int64     `json:"id"`
+	Username           string    `json:"username"`
+	IsBot              bool      `json:"is_bot,omitempty"`
+	DisplayName        string    `json:"display_name,omitempty"`
+	Bio                string    `json:"bio,omitempty"`
+	AvatarAttachmentID int64     `json:"avatar_attachment_id,omitempty"`
+	AvatarURL          string    `json:"avatar_url,omitempty"`
+	StatusText         string    `json:"status_text,omitempty"`
+	StatusEmoji        string    `json:"status_emoji,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// UserSearchResponse represents a page of user search results.
+type UserSearchResponse struct {
+	Users      []ProfileResponse `json:"users"`
+	NextOffset int32             `json:"next_offset,omitempty"`
+}
+
+// BlockedUserResponse represents a user the current user blocked.
+type BlockedUserResponse struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	IsBot     bool      `json:"is_bot,omitempty"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
 // RoomResponse represents a room in API responses.
 type RoomResponse struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Slug            string    `json:"slug"`
+	Mode            string    `json:"mode"`
+	SlowModeSeconds int32     `json:"slow_mode_seconds"`
+	Topic           string    `json:"topic"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
+// Room modes returned in RoomResponse.Mode.
+const (
+	RoomModeNormal       = "normal"
+	RoomModeAnnouncement = "announcement"
+	RoomModeArchived     = "archived"
+)
+
+// MessageKindAction marks /me messages in MessageResponse.Kind.
+const MessageKindAction = "action"
+
 // MessageResponse represents a message in API responses.
 type MessageResponse struct {
-	ID             int64     `json:"id"`
-	RoomID         *int64    `json:"room_id,omitempty"`
-	ConversationID *int64    `json:"conversation_id,omitempty"`
-	SenderID       int64     `json:"sender_id"`
-	SenderUsername string    `json:"sender_username"`
-	Body           string    `json:"body"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             int64                  `json:"id"`
+	RoomID         *int64                 `json:"room_id,omitempty"`
+	ConversationID *int64                 `json:"conversation_id,omitempty"`
+	SenderID       int64                  `json:"sender_id"`
+	SenderUsername string                 `json:"sender_username"`
+	SenderIsBot    bool                   `json:"sender_is_bot,omitempty"`
+	Kind           string                 `json:"kind,omitempty"`
+	Body           string                 `json:"body"`
+	Attachments    []AttachmentResponse   `json:"attachments,omitempty"`
+	Poll           *PollResponse          `json:"poll,omitempty"`
+	ForwardedFrom  *ForwardedFromResponse `json:"forwarded_from,omitempty"`
+	Quote          *QuoteResponse         `json:"quote,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// ForwardedFromResponse is where a forwarded message first came from.
+// RoomID is 0 for DMs.
+type ForwardedFromResponse struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	RoomID         int64  `json:"room_id,omitempty"`
+	RoomName       string `json:"room_name,omitempty"`
+}
+
+// QuoteResponse is the message quoted by a reply.
+type QuoteResponse struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	Body           string `json:"body"`
+}
+
+// PollResponse represents the poll of a room message with its tally.
+// MyVotes holds the options we voted.
+type PollResponse struct {
+	Options     []string   `json:"options"`
+	Multiple    bool       `json:"multiple,omitempty"`
+	Anonymous   bool       `json:"anonymous,omitempty"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+	Closed      bool       `json:"closed,omitempty"`
+	Counts      []int      `json:"counts"`
+	Voters      [][]string `json:"voters,omitempty"`
+	TotalVoters int        `json:"total_voters"`
+	MyVotes     []int      `json:"my_votes"`
+}
+
+// AttachmentResponse represents an uploaded file in API responses.
+type AttachmentResponse struct {
+	ID          int64     `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // ConversationResponse represents a DM conversation in API responses.
@@ -59,3 +178,52 @@ type Error struct {
 	Error   string `json:"error"`
 	Details any    `json:"details,omitempty"`
 }
+
+// ScheduleMessageRequest represents the request body for scheduling a message.
+// Exactly one of RoomID and ToUserID is set.
+type ScheduleMessageRequest struct {
+	RoomID   int64     `json:"room_id,omitempty"`
+	ToUserID int64     `json:"to_user_id,omitempty"`
+	Body     string    `json:"body"`
+	SendAt   time.Time `json:"send_at"`
+}
+
+// ScheduledMessageResponse represents a scheduled message in API responses.
+type ScheduledMessageResponse struct {
+	ID        int64     `json:"id"`
+	RoomID    int64     `json:"room_id,omitempty"`
+	ToUserID  int64     `json:"to_user_id,omitempty"`
+	Body      string    `json:"body"`
+	SendAt    time.Time `json:"send_at"`
+	Status    string    `json:"status"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PinnedMessageResponse represents a pinned room message in API responses.
+type PinnedMessageResponse struct {
+	ID             int64     `json:"id"`
+	SenderID       int64     `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	SenderIsBot    bool      `json:"sender_is_bot,omitempty"`
+	Kind           string    `json:"kind"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+	PinnedBy       string    `json:"pinned_by"`
+	PinnedAt       time.Time `json:"pinned_at"`
+}
+
+// SavedMessageResponse represents a message in the user's saved list. One
+// of RoomID and ConversationID is set.
+type SavedMessageResponse struct {
+	ID             int64     `json:"id"`
+	RoomID         int64     `json:"room_id,omitempty"`
+	RoomName       string    `json:"room_name,omitempty"`
+	ConversationID int64     `json:"conversation_id,omitempty"`
+	SenderID       int64     `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	Kind           string    `json:"kind"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+	SavedAt        time.Time `json:"saved_at"`
+}
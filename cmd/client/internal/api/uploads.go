@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// UploadFile streams the file at path to the server and returns the stored attachment.
+func (c *Client) UploadFile(path string) (AttachmentResponse, error) {
+	var att AttachmentResponse
+
+	f, err := os.Open(path)
+	if err != nil {
+		return att, fmt.Errorf("open file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// stream the multipart body instead of buffering the whole file in memory
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, f)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest("POST", c.baseURL+"/api/v1/uploads", pr)
+	if err != nil {
+		_ = pr.Close()
+		return att, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	c.logger.Debug("api upload", "path", path)
+	err = c.send(req, &att)
+	return att, err
+}
@@ -14,3 +14,40 @@ func (c *Client) GetUserByUsername(username string) (UserResponse, error) {
 	}
 	return user, nil
 }
+
+// GetUser fetches the public profile of a user.
+func (c *Client) GetUser(id int64) (ProfileResponse, error) {
+	var profile ProfileResponse
+	if err := c.do("GET", fmt.Sprintf("/api/v1/users/%d", id), nil, &profile); err != nil {
+		return ProfileResponse{}, err
+	}
+	return profile, nil
+}
+
+// SearchUsers finds users by username or display name, best matches first.
+func (c *Client) SearchUsers(query string, limit int) (UserSearchResponse, error) {
+	var res UserSearchResponse
+	path := fmt.Sprintf("/api/v1/users?q=%s&limit=%d", url.QueryEscape(query), limit)
+	if err := c.do("GET", path, nil, &res); err != nil {
+		return UserSearchResponse{}, err
+	}
+	return res, nil
+}
+
+// ListBlocked returns the users the current user blocked.
+func (c *Client) ListBlocked() ([]BlockedUserResponse, error) {
+	var blocked []BlockedUserResponse
+	err := c.do("GET", "/api/v1/users/me/blocks", nil, &blocked)
+	return blocked, err
+}
+
+// BlockUser blocks a user: they can't DM the current user and their room
+// messages are hidden.
+func (c *Client) BlockUser(id int64) error {
+	return c.do("PUT", fmt.Sprintf("/api/v1/users/%d/block", id), nil, nil)
+}
+
+// UnblockUser lifts a block.
+func (c *Client) UnblockUser(id int64) error {
+	return c.do("DELETE", fmt.Sprintf("/api/v1/users/%d/block", id), nil, nil)
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/chat"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/dm"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/dmchat"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/profile"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/rooms"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/saved"
 )
 
 type screen int
@@ -22,6 +24,7 @@ const (
 	screenChat
 	screenDM
 	screenDMChat
+	screenSaved
 )
 
 // AppState holds shared state across UI screens.
@@ -29,10 +32,10 @@ type AppState struct {
 	Config      config.Config
 	Logger      *slog.Logger
 	APIClient   *api.Client
-	Token       string
 	UserID      int64
 	Username    string
 	CurrentRoom *api.RoomResponse
+	Profiles    *profile.Cache
 }
 
 // App is the top-level Bubble Tea model that manages screen transitions.
@@ -48,6 +51,7 @@ type App struct {
 	chat   chat.Model
 	dm     dm.Model
 	dmChat dmchat.Model
+	saved  saved.Model
 }
 
 // NewApp creates a new App with the given configuration and logger.
@@ -55,7 +59,7 @@ func NewApp(cfg config.Config, logger *slog.Logger) App {
 	apiClient := api.New(cfg.ServerURL, logger)
 
 	return App{
-		state:  &AppState{Config: cfg, Logger: logger, APIClient: apiClient},
+		state:  &AppState{Config: cfg, Logger: logger, APIClient: apiClient, Profiles: profile.NewCache()},
 		active: screenAuth,
 		auth:   auth.New(apiClient),
 	}
@@ -66,6 +70,16 @@ func (a *App) SetProgram(p *tea.Program) {
 	a.program = p
 }
 
+// Logout ends the server session if the user logged in.
+func (a *App) Logout() {
+	if a.state.UserID == 0 {
+		return
+	}
+	if err := a.state.APIClient.Logout(); err != nil {
+		a.state.Logger.Warn("logout failed", "error", err)
+	}
+}
+
 // Init initializes the app model.
 func (a *App) Init() tea.Cmd {
 	return a.auth.Init()
@@ -86,15 +100,24 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.width = msg.Width
 		a.height = msg.Height
 
+	case profile.LoadedMsg:
+		// stored here so a fetch finishing after its screen closed isn't lost
+		a.state.Profiles.Store(msg)
+
 	case auth.SuccessMsg:
-		a.state.Token = msg.Token
 		a.state.UserID = msg.UserID
 		a.state.Username = msg.Username
-		a.state.APIClient.SetToken(msg.Token)
 		a.state.Logger.Info("authenticated", "user_id", msg.UserID, "username", msg.Username)
 		a.active = screenRooms
 		a.rooms = rooms.New(a.state.APIClient, a.width, a.height)
-		return a, a.rooms.Init()
+		return a, tea.Batch(a.rooms.Init(), a.state.Profiles.LoadBlocks(a.state.APIClient))
+
+	case profile.BlocksLoadedMsg:
+		if msg.Err != nil {
+			a.state.Logger.Warn("failed to load blocked users", "error", msg.Err)
+		}
+		a.state.Profiles.StoreBlocks(msg)
+		return a, nil
 
 	case rooms.RoomSelectedMsg:
 		a.state.CurrentRoom = &msg.Room
@@ -104,11 +127,11 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.state.APIClient,
 			a.program,
 			a.state.Logger,
+			a.state.Profiles,
 			msg.Room,
 			a.state.UserID,
 			a.state.Username,
 			a.state.Config.WSURL,
-			a.state.Token,
 			a.width,
 			a.height,
 		)
@@ -126,19 +149,29 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.dm = dm.New(a.state.APIClient, a.width, a.height)
 		return a, a.dm.Init()
 
+	case rooms.ShowSavedMsg:
+		a.active = screenSaved
+		a.saved = saved.New(a.state.APIClient, a.width, a.height)
+		return a, a.saved.Init()
+
+	case saved.LeaveSavedMsg:
+		a.active = screenRooms
+		a.rooms = rooms.New(a.state.APIClient, a.width, a.height)
+		return a, a.rooms.Init()
+
 	case dm.ConvSelectedMsg:
 		a.active = screenDMChat
 		a.dmChat = dmchat.New(
 			a.state.APIClient,
 			a.program,
 			a.state.Logger,
+			a.state.Profiles,
 			msg.Conv.ID,
 			msg.Conv.PeerID,
 			msg.Conv.PeerUsername,
 			a.state.UserID,
 			a.state.Username,
 			a.state.Config.WSURL,
-			a.state.Token,
 			a.width,
 			a.height,
 		)
@@ -150,13 +183,13 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.state.APIClient,
 			a.program,
 			a.state.Logger,
+			a.state.Profiles,
 			0, // no conversation yet
 			msg.PeerID,
 			msg.PeerUsername,
 			a.state.UserID,
 			a.state.Username,
 			a.state.Config.WSURL,
-			a.state.Token,
 			a.width,
 			a.height,
 		)
@@ -194,6 +227,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var cmd tea.Cmd
 		a.dmChat, cmd = a.dmChat.Update(msg)
 		return a, cmd
+	case screenSaved:
+		var cmd tea.Cmd
+		a.saved, cmd = a.saved.Update(msg)
+		return a, cmd
 	}
 
 	return a, nil
@@ -212,6 +249,8 @@ func (a *App) View() string {
 		return a.dm.View()
 	case screenDMChat:
 		return a.dmChat.View()
+	case screenSaved:
+		return a.saved.View()
 	}
 	return ""
 }
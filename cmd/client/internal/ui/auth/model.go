@@ -2,8 +2,10 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,15 +19,37 @@ type mode int
 const (
 	modeLogin mode = iota
 	modeRegister
+	modeCode // second step of a login with 2FA
+	modeSSO  // waiting for a single sign-on login in the browser
 )
 
-// SuccessMsg signals a successful authentication with token and user info.
+// SuccessMsg signals a successful authentication with the user info. The
+// API client already holds the session tokens.
 type SuccessMsg struct {
-	Token    string
 	UserID   int64
 	Username string
 }
 
+// twoFactorMsg signals that the password was accepted and a code is needed.
+type twoFactorMsg struct {
+	challenge string
+}
+
+// deviceMsg signals that a single sign-on login was started.
+type deviceMsg struct {
+	login api.DeviceLoginResponse
+}
+
+// pollMsg asks to check whether the device login was approved.
+type pollMsg struct {
+	deviceCode string
+}
+
+// pendingMsg signals the device login isn't approved yet.
+type pendingMsg struct {
+	deviceCode string
+}
+
 // ErrorMsg signals a failed authentication attempt.
 type ErrorMsg struct {
 	Err error
@@ -36,6 +60,9 @@ type Model struct {
 	apiClient     *api.Client
 	usernameInput textinput.Model
 	passwordInput textinput.Model
+	codeInput     textinput.Model
+	challenge     string
+	device        api.DeviceLoginResponse
 	mode          mode
 	focusIndex    int
 	err           string
@@ -56,10 +83,15 @@ func New(apiClient *api.Client) Model {
 	password.EchoMode = textinput.EchoPassword
 	password.CharLimit = 64
 
+	code := textinput.New()
+	code.Placeholder = "123456 or recovery code"
+	code.CharLimit = 16
+
 	return Model{
 		apiClient:     apiClient,
 		usernameInput: username,
 		passwordInput: password,
+		codeInput:     code,
 		mode:          modeLogin,
 	}
 }
@@ -76,12 +108,26 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.err = ""
 		switch msg.String() {
 		case "tab", "shift+tab":
+			if m.mode == modeCode {
+				return m, nil
+			}
 			return m.cycleFocus(), nil
 		case "ctrl+t":
 			m.toggleMode()
 			return m, nil
+		case "esc":
+			if m.mode == modeSSO {
+				m.toggleMode()
+				return m, nil
+			}
+		case "ctrl+o":
+			if m.mode == modeSSO || m.loading {
+				return m, nil
+			}
+			m.loading = true
+			return m, m.startSSO()
 		case "enter":
-			if m.loading {
+			if m.loading || m.mode == modeSSO {
 				return m, nil
 			}
 			return m, m.submit()
@@ -90,9 +136,44 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case SuccessMsg:
 		m.loading = false
 
+	case twoFactorMsg:
+		m.loading = false
+		m.mode = modeCode
+		m.challenge = msg.challenge
+		m.usernameInput.Blur()
+		m.passwordInput.Blur()
+		m.codeInput.SetValue("")
+		m.codeInput.Focus()
+		return m, nil
+
+	case deviceMsg:
+		m.loading = false
+		m.mode = modeSSO
+		m.device = msg.login
+		m.usernameInput.Blur()
+		m.passwordInput.Blur()
+		return m, m.schedulePoll()
+
+	case pollMsg:
+		// ignore polls of a login that was cancelled
+		if m.mode != modeSSO || msg.deviceCode != m.device.DeviceCode {
+			return m, nil
+		}
+		return m, m.poll()
+
+	case pendingMsg:
+		if m.mode != modeSSO || msg.deviceCode != m.device.DeviceCode {
+			return m, nil
+		}
+		return m, m.schedulePoll()
+
 	case ErrorMsg:
 		m.loading = false
 		m.err = msg.Err.Error()
+		if m.mode == modeSSO {
+			m.toggleMode()
+			m.err = msg.Err.Error()
+		}
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -132,10 +213,15 @@ func (m Model) View() string {
 		Bold(true)
 
 	var title string
-	if m.mode == modeLogin {
+	switch m.mode {
+	case modeLogin:
 		title = "Login"
-	} else {
+	case modeRegister:
 		title = "Register"
+	case modeCode:
+		title = "Two-factor authentication"
+	case modeSSO:
+		title = "Single sign-on"
 	}
 
 	var b strings.Builder
@@ -144,14 +230,33 @@ func (m Model) View() string {
 	b.WriteString("\n\n")
 	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
-	b.WriteString(labelStyle.Render("Username"))
-	b.WriteString("\n")
-	b.WriteString(m.usernameInput.View())
-	b.WriteString("\n\n")
-	b.WriteString(labelStyle.Render("Password"))
-	b.WriteString("\n")
-	b.WriteString(m.passwordInput.View())
-	b.WriteString("\n\n")
+	switch m.mode {
+	case modeCode:
+		b.WriteString(labelStyle.Render("Code from your authenticator app"))
+		b.WriteString("\n")
+		b.WriteString(m.codeInput.View())
+		b.WriteString("\n\n")
+	case modeSSO:
+		b.WriteString(labelStyle.Render("Open this page in a browser"))
+		b.WriteString("\n")
+		b.WriteString(m.device.VerificationURIComplete)
+		b.WriteString("\n\n")
+		b.WriteString(labelStyle.Render("and check that it shows the code"))
+		b.WriteString("\n")
+		b.WriteString(brandStyle.Render(m.device.UserCode))
+		b.WriteString("\n\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(t.Gold).Render("Waiting for the login in the browser..."))
+		b.WriteString("\n\n")
+	default:
+		b.WriteString(labelStyle.Render("Username"))
+		b.WriteString("\n")
+		b.WriteString(m.usernameInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(labelStyle.Render("Password"))
+		b.WriteString("\n")
+		b.WriteString(m.passwordInput.View())
+		b.WriteString("\n\n")
+	}
 
 	if m.loading {
 		b.WriteString(lipgloss.NewStyle().Foreground(t.Gold).Render("Authenticating..."))
@@ -161,23 +266,36 @@ func (m Model) View() string {
 
 	b.WriteString("\n\n")
 
-	var modeToggle string
-	if m.mode == modeLogin {
-		modeToggle = "Don't have an account? ctrl+t to register"
-	} else {
-		modeToggle = "Already have an account? ctrl+t to login"
+	switch m.mode {
+	case modeLogin:
+		b.WriteString(helpStyle.Render("Don't have an account? ctrl+t to register\ntab: switch fields  enter: submit  ctrl+o: single sign-on"))
+	case modeRegister:
+		b.WriteString(helpStyle.Render("Already have an account? ctrl+t to login\ntab: switch fields  enter: submit"))
+	case modeCode:
+		b.WriteString(helpStyle.Render("enter: verify  ctrl+t: back to login"))
+	case modeSSO:
+		b.WriteString(helpStyle.Render("esc: cancel"))
 	}
-	b.WriteString(helpStyle.Render(fmt.Sprintf("%s\ntab: switch fields  enter: submit", modeToggle)))
 
 	form := formStyle.Render(b.String())
 	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, form)
 }
 
 func (m *Model) toggleMode() {
-	if m.mode == modeLogin {
+	switch m.mode {
+	case modeLogin:
 		m.mode = modeRegister
-	} else {
+	case modeRegister:
 		m.mode = modeLogin
+	case modeCode, modeSSO:
+		// give up on the challenge or the SSO login and start over
+		m.mode = modeLogin
+		m.challenge = ""
+		m.device = api.DeviceLoginResponse{}
+		m.codeInput.Blur()
+		m.passwordInput.Blur()
+		m.focusIndex = 0
+		m.usernameInput.Focus()
 	}
 	m.err = ""
 }
@@ -196,6 +314,10 @@ func (m Model) cycleFocus() Model {
 }
 
 func (m Model) submit() tea.Cmd {
+	if m.mode == modeCode {
+		return m.submitCode()
+	}
+
 	username := strings.TrimSpace(m.usernameInput.Value())
 	password := m.passwordInput.Value()
 
@@ -221,9 +343,74 @@ func (m Model) submit() tea.Cmd {
 		if err != nil {
 			return ErrorMsg{Err: err}
 		}
+		if res.TwoFactorRequired {
+			return twoFactorMsg{challenge: res.ChallengeToken}
+		}
+
+		return SuccessMsg{
+			UserID:   res.User.ID,
+			Username: res.User.Username,
+		}
+	}
+}
+
+func (m Model) submitCode() tea.Cmd {
+	code := strings.TrimSpace(m.codeInput.Value())
+	if code == "" {
+		return func() tea.Msg {
+			return ErrorMsg{Err: fmt.Errorf("enter the code from your authenticator app")}
+		}
+	}
+	challenge := m.challenge
+
+	return func() tea.Msg {
+		res, err := m.apiClient.LoginTwoFactor(challenge, code)
+		if err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return SuccessMsg{
+			UserID:   res.User.ID,
+			Username: res.User.Username,
+		}
+	}
+}
+
+func (m Model) startSSO() tea.Cmd {
+	return func() tea.Msg {
+		res, err := m.apiClient.StartDeviceLogin()
+		if err != nil {
+			return ErrorMsg{Err: err}
+		}
+		return deviceMsg{login: res}
+	}
+}
 
+// schedulePoll waits the interval the server asked for before polling.
+func (m Model) schedulePoll() tea.Cmd {
+	interval := time.Duration(m.device.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	code := m.device.DeviceCode
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return pollMsg{deviceCode: code}
+	})
+}
+
+func (m Model) poll() tea.Cmd {
+	code := m.device.DeviceCode
+	return func() tea.Msg {
+		res, err := m.apiClient.PollDeviceLogin(code)
+		if errors.Is(err, api.ErrAuthorizationPending) {
+			return pendingMsg{deviceCode: code}
+		}
+		if err != nil {
+			return ErrorMsg{Err: err}
+		}
+		if res.TwoFactorRequired {
+			return twoFactorMsg{challenge: res.ChallengeToken}
+		}
 		return SuccessMsg{
-			Token:    res.Token,
 			UserID:   res.User.ID,
 			Username: res.User.Username,
 		}
@@ -240,5 +427,8 @@ func (m Model) updateInputs(msg tea.Msg) (Model, tea.Cmd) {
 	m.passwordInput, cmd = m.passwordInput.Update(msg)
 	cmds = append(cmds, cmd)
 
+	m.codeInput, cmd = m.codeInput.Update(msg)
+	cmds = append(cmds, cmd)
+
 	return m, tea.Batch(cmds...)
 }
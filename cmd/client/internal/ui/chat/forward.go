@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ws"
+)
+
+// forwardTargetMsg carries the room or user a /fwd resolved to.
+type forwardTargetMsg struct {
+	messageID int64
+	target    string // as typed, e.g. #general or @ann
+	roomID    int64
+	userID    int64
+	err       error
+}
+
+// quoteRef is the message quoted by a chat message.
+type quoteRef struct {
+	senderID       int64
+	senderUsername string
+	body           string
+}
+
+func historyRefs(m api.MessageResponse) (string, *quoteRef) {
+	var forwarded string
+	if f := m.ForwardedFrom; f != nil {
+		forwarded = render.Forwarded(f.SenderUsername, f.RoomName)
+	}
+	if q := m.Quote; q != nil {
+		return forwarded, &quoteRef{senderID: q.SenderID, senderUsername: q.SenderUsername, body: q.Body}
+	}
+	return forwarded, nil
+}
+
+func liveRefs(f *ws.ForwardedFrom, q *ws.QuoteInfo) (string, *quoteRef) {
+	var forwarded string
+	if f != nil {
+		forwarded = render.Forwarded(f.SenderUsername, f.RoomName)
+	}
+	if q != nil {
+		return forwarded, &quoteRef{senderID: q.SenderID, senderUsername: q.SenderUsername, body: q.Body}
+	}
+	return forwarded, nil
+}
+
+// parseForward splits the arguments of /fwd into the message number, empty
+// for the latest, and the target: #room-slug or @username.
+func parseForward(args string) (string, string, error) {
+	fields := strings.Fields(args)
+	var n, target string
+	switch len(fields) {
+	case 1:
+		target = fields[0]
+	case 2:
+		n, target = fields[0], fields[1]
+	}
+	if len(target) < 2 || target[0] != '#' && target[0] != '@' {
+		return "", "", errors.New("usage: /fwd [n] #room or /fwd [n] @user")
+	}
+	return n, target, nil
+}
+
+// parseQuote splits the arguments of /quote into the message number, empty
+// for the latest, and the reply text.
+func parseQuote(args string) (string, string, error) {
+	first, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	var n string
+	if _, err := position(first); err == nil && first != "" {
+		n, args = first, rest
+	}
+	text := strings.TrimSpace(args)
+	if text == "" {
+		return "", "", errors.New("usage: /quote [n] <text>")
+	}
+	return n, text, nil
+}
+
+// resolveForward looks up the room or user a message is forwarded to.
+func (m Model) resolveForward(messageID int64, target string) tea.Cmd {
+	return func() tea.Msg {
+		res := forwardTargetMsg{messageID: messageID, target: target}
+		if username, ok := strings.CutPrefix(target, "@"); ok {
+			u, err := m.apiClient.GetUserByUsername(username)
+			res.userID, res.err = u.ID, err
+			return res
+		}
+		rooms, err := m.apiClient.ListRooms(false)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		for _, r := range rooms {
+			if "#"+r.Slug == target {
+				res.roomID = r.ID
+				return res
+			}
+		}
+		res.err = errors.New("no room " + target)
+		return res
+	}
+}
+
+// forward sends the forward once its target is known.
+func (m Model) forward(msg forwardTargetMsg) error {
+	if msg.err != nil {
+		return msg.err
+	}
+	if m.wsClient == nil {
+		return errors.New("not connected")
+	}
+	if msg.userID != 0 {
+		return m.wsClient.ForwardToUser(msg.userID, msg.messageID)
+	}
+	return m.wsClient.ForwardToRoom(msg.roomID, msg.messageID)
+}
@@ -4,15 +4,22 @@ package chat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/profile"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/theme"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ws"
 )
@@ -28,31 +35,62 @@ type wsConnectedMsg struct {
 	client *ws.Client
 }
 
+type uploadedMsg struct {
+	attachment api.AttachmentResponse
+	err        error
+}
+
+// scheduledMsg reports the result of /schedule.
+type scheduledMsg struct {
+	scheduled api.ScheduledMessageResponse
+	err       error
+}
+
+// blockedMsg reports the result of /block or /unblock.
+type blockedMsg struct {
+	username string
+	userID   int64
+	blocked  bool
+	err      error
+}
+
 // Model is the Bubble Tea model for the chat room screen.
 type Model struct {
 	apiClient *api.Client
 	wsClient  *ws.Client
 	program   *tea.Program
 	logger    *slog.Logger
+	profiles  *profile.Cache
 
 	room     api.RoomResponse
 	userID   int64
 	username string
 	wsURL    string
-	token    string
-
-	viewport viewport.Model
-	input    textinput.Model
-	messages []chatMessage
-	err      string
-	width    int
-	height   int
+
+	viewport  viewport.Model
+	input     textinput.Model
+	messages  []chatMessage
+	err       string
+	uploading string // filename of the upload in progress, if any
+	pins      []api.PinnedMessageResponse
+	showPins  bool
+	pollFocus int64 // message ID of the poll taking number keys, 0 for the input
+	width     int
+	height    int
 }
 
 type chatMessage struct {
+	id             int64
 	senderID       int64
 	senderUsername string
+	senderIsBot    bool
+	kind           string
+	notice         bool // a command reply only this user sees
 	content        string
+	files          []string
+	poll           *pollState
+	forwarded      string // origin line of a forward, empty otherwise
+	quote          *quoteRef
 	timestamp      string
 }
 
@@ -61,28 +99,31 @@ func New(
 	apiClient *api.Client,
 	program *tea.Program,
 	logger *slog.Logger,
+	profiles *profile.Cache,
 	room api.RoomResponse,
 	userID int64,
-	username, wsURL, token string,
+	username, wsURL string,
 	width, height int,
 ) Model {
 	vp := viewport.New(width, height-4)
 
 	input := textinput.New()
 	input.Placeholder = "type a message..."
-	input.Focus()
 	input.CharLimit = 500
 	input.Width = width - 6
+	if room.Mode != api.RoomModeArchived {
+		input.Focus()
+	}
 
 	return Model{
 		apiClient: apiClient,
 		program:   program,
 		logger:    logger,
+		profiles:  profiles,
 		room:      room,
 		userID:    userID,
 		username:  username,
 		wsURL:     wsURL,
-		token:     token,
 		viewport:  vp,
 		input:     input,
 		width:     width,
@@ -94,6 +135,7 @@ func New(
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.loadHistory(),
+		m.loadPins(),
 		m.connectWS(),
 		textinput.Blink,
 	)
@@ -103,25 +145,61 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.pollFocus != 0 {
+			return m.handlePollKey(msg)
+		}
 		switch msg.String() {
+		case "tab":
+			if m.room.Mode == api.RoomModeArchived {
+				break
+			}
+			if m.pollFocus = m.nextPoll(); m.pollFocus != 0 {
+				m.input.Blur()
+				m.updateViewport()
+			}
+			return m, nil
 		case "esc":
 			m.cleanup()
 			return m, func() tea.Msg { return LeaveRoomMsg{} }
 		case "enter":
 			return m.sendMessage()
+		case "ctrl+p":
+			m.showPins = !m.showPins
+			m.resize()
+			return m, nil
+		}
+		if m.room.Mode == api.RoomModeArchived {
+			// read-only: keep scrolling but don't feed keys to the input
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
 		}
 
 	case historyLoadedMsg:
 		// Messages come from the API in DESC order (newest first), reverse for display.
+		senders := make([]int64, 0, len(msg.messages))
 		for i := len(msg.messages) - 1; i >= 0; i-- {
 			m2 := msg.messages[i]
+			senders = append(senders, m2.SenderID)
+			forwarded, quote := historyRefs(m2)
 			m.messages = append(m.messages, chatMessage{
+				id:             m2.ID,
 				senderID:       m2.SenderID,
 				senderUsername: m2.SenderUsername,
+				senderIsBot:    m2.SenderIsBot,
+				kind:           m2.Kind,
 				content:        m2.Body,
+				files:          historyFiles(m2.Attachments),
+				poll:           historyPoll(m2.Poll),
+				forwarded:      forwarded,
+				quote:          quote,
 				timestamp:      m2.CreatedAt.Format("15:04"),
 			})
 		}
+		m.updateViewport()
+		return m, m.profiles.Load(m.apiClient, senders...)
+
+	case profile.LoadedMsg:
 		m.updateViewport()
 		return m, nil
 
@@ -130,6 +208,77 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.logger.Info("ws connected for chat", "room_id", m.room.ID)
 		return m, nil
 
+	case uploadedMsg:
+		m.uploading = ""
+		if msg.err != nil {
+			m.err = "upload failed: " + msg.err.Error()
+			return m, nil
+		}
+		if m.wsClient == nil {
+			return m, nil
+		}
+		if err := m.wsClient.SendRoomMessage(m.room.ID, "", msg.attachment.ID); err != nil {
+			m.err = err.Error()
+		}
+		return m, nil
+
+	case blockedMsg:
+		if msg.err != nil {
+			m.err = msg.err.Error()
+			return m, nil
+		}
+		m.profiles.SetBlocked(msg.userID, msg.blocked)
+		text := "blocked " + msg.username + ", their messages are hidden"
+		if !msg.blocked {
+			text = "unblocked " + msg.username
+		}
+		m.messages = append(m.messages, chatMessage{notice: true, content: text, timestamp: time.Now().Format("15:04")})
+		m.updateViewport()
+		return m, nil
+
+	case scheduledMsg:
+		if msg.err != nil {
+			m.err = "schedule failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.messages = append(m.messages, chatMessage{
+			notice:    true,
+			content:   "scheduled for " + msg.scheduled.SendAt.Local().Format("Jan 2 15:04") + ": " + msg.scheduled.Body,
+			timestamp: time.Now().Format("15:04"),
+		})
+		m.updateViewport()
+		return m, nil
+
+	case forwardTargetMsg:
+		if err := m.forward(msg); err != nil {
+			m.err = "/fwd: " + err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.messages = append(m.messages, chatMessage{notice: true, content: "forwarded to " + msg.target, timestamp: time.Now().Format("15:04")})
+		m.updateViewport()
+		return m, nil
+
+	case pinsLoadedMsg:
+		if msg.err != nil {
+			m.logger.Warn("failed to load pins", "room_id", m.room.ID, "error", msg.err)
+			return m, nil
+		}
+		m.pins = msg.pins
+		m.resize()
+		return m, nil
+
+	case actionDoneMsg:
+		if msg.err != nil {
+			m.err = msg.err.Error()
+			return m, nil
+		}
+		if msg.text != "" {
+			m.messages = append(m.messages, chatMessage{notice: true, content: msg.text, timestamp: time.Now().Format("15:04")})
+			m.updateViewport()
+		}
+		return m, nil
+
 	case ws.IncomingMsg:
 		return m.handleWSMessage(msg)
 
@@ -141,8 +290,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.viewport.Width = msg.Width
-		m.viewport.Height = msg.Height - 4
+		m.resize()
 		m.input.Width = msg.Width - 6
 		m.updateViewport()
 	}
@@ -184,31 +332,145 @@ func (m Model) View() string {
 	var b strings.Builder
 
 	header := fmt.Sprintf("#%s  %s", m.room.Slug, lipgloss.NewStyle().Foreground(t.Subtle).Render(m.room.Name))
+	if m.room.Topic != "" {
+		header += lipgloss.NewStyle().Foreground(t.Subtle).Italic(true).Render("  — " + m.room.Topic)
+	}
 	b.WriteString(headerStyle.Render(header))
 	b.WriteString("\n")
+	if m.showPins {
+		b.WriteString(m.pinsView())
+		b.WriteString("\n")
+	}
 	b.WriteString(m.viewport.View())
 	b.WriteString("\n")
-	b.WriteString(inputBoxStyle.Render(m.input.View()))
+	if banner := m.modeBanner(); banner != "" {
+		b.WriteString(inputBoxStyle.Render(banner))
+	} else {
+		b.WriteString(inputBoxStyle.Render(m.input.View()))
+	}
 	b.WriteString("\n")
 
 	var statusParts []string
 	if m.err != "" {
 		statusParts = append(statusParts, lipgloss.NewStyle().Foreground(t.Error).Render(m.err))
 	}
-	statusParts = append(statusParts, statusStyle.Render("esc: leave  enter: send"))
+	if m.room.SlowModeSeconds > 0 {
+		statusParts = append(statusParts, lipgloss.NewStyle().Foreground(t.Gold).Render(fmt.Sprintf("slow mode: %ds", m.room.SlowModeSeconds)))
+	}
+	if m.room.Mode == api.RoomModeAnnouncement {
+		// moderators can still post, so the input stays and we only hint at the mode
+		statusParts = append(statusParts, lipgloss.NewStyle().Foreground(t.Gold).Render("announcements: only moderators can post"))
+	}
+	if m.uploading != "" {
+		statusParts = append(statusParts, statusStyle.Render("uploading "+m.uploading+"..."))
+	}
+	statusParts = append(statusParts, statusStyle.Render("esc: leave  enter: send  ctrl+p: pins  tab: vote  /upload <path>: attach file  /poll  /pin [n]  /save [n]  /fwd [n] #room|@user  /quote [n] <text>  /block <user>  /help: commands"))
 	b.WriteString(strings.Join(statusParts, "  "))
 
 	return b.String()
 }
 
+// modeBanner returns the read-only banner shown in place of the input for archived rooms.
+func (m Model) modeBanner() string {
+	if m.room.Mode != api.RoomModeArchived {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(theme.Current.Subtle).Italic(true).
+		Render("this room is archived — history is read-only")
+}
+
 func (m Model) sendMessage() (Model, tea.Cmd) {
 	content := strings.TrimSpace(m.input.Value())
-	if content == "" || m.wsClient == nil {
+	if content == "" || m.wsClient == nil || m.room.Mode == api.RoomModeArchived {
 		return m, nil
 	}
 
 	m.input.SetValue("")
 
+	if path, ok := strings.CutPrefix(content, "/upload "); ok {
+		return m.upload(strings.TrimSpace(path))
+	}
+	if rest, ok := strings.CutPrefix(content, "/schedule "); ok {
+		delay, text, err := parseSchedule(rest)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		return m, m.schedule(text, time.Now().Add(delay))
+	}
+	if rest, ok := strings.CutPrefix(content, "/poll "); ok {
+		question, poll, err := parsePoll(rest)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		if err := m.wsClient.SendPoll(m.room.ID, question, poll); err != nil {
+			m.err = err.Error()
+		}
+		return m, nil
+	}
+	if rest, ok := strings.CutPrefix(content, "/ttl "); ok {
+		ttl, text, err := parseTTL(rest)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		if err := m.wsClient.SendEphemeralRoomMessage(m.room.ID, text, ttl); err != nil {
+			m.err = err.Error()
+		}
+		return m, nil
+	}
+	if cmd, arg, _ := strings.Cut(content, " "); cmd == "/pin" || cmd == "/unpin" || cmd == "/save" {
+		find := m.recentMessage
+		if cmd == "/unpin" {
+			find = m.pinnedMessage
+		}
+		id, err := find(arg)
+		if err != nil {
+			m.err = cmd + ": " + err.Error()
+			return m, nil
+		}
+		m.err = ""
+		if cmd == "/save" {
+			return m, m.save(id)
+		}
+		return m, m.pin(id, cmd == "/pin")
+	}
+	if rest, ok := strings.CutPrefix(content, "/fwd "); ok {
+		n, target, err := parseForward(rest)
+		if err == nil {
+			var id int64
+			if id, err = m.recentMessage(n); err == nil {
+				m.err = ""
+				return m, m.resolveForward(id, target)
+			}
+		}
+		m.err = "/fwd: " + err.Error()
+		return m, nil
+	}
+	if rest, ok := strings.CutPrefix(content, "/quote "); ok {
+		n, text, err := parseQuote(rest)
+		if err == nil {
+			var id int64
+			if id, err = m.recentMessage(n); err == nil {
+				m.err = ""
+				if err := m.wsClient.SendRoomQuote(m.room.ID, text, id); err != nil {
+					m.err = err.Error()
+				}
+				return m, nil
+			}
+		}
+		m.err = "/quote: " + err.Error()
+		return m, nil
+	}
+	if name, ok := strings.CutPrefix(content, "/block "); ok {
+		return m, m.block(strings.TrimSpace(name), true)
+	}
+	if name, ok := strings.CutPrefix(content, "/unblock "); ok {
+		return m, m.block(strings.TrimSpace(name), false)
+	}
+
 	if err := m.wsClient.SendRoomMessage(m.room.ID, content); err != nil {
 		m.err = err.Error()
 	}
@@ -225,13 +487,136 @@ func (m Model) handleWSMessage(msg ws.IncomingMsg) (Model, tea.Cmd) {
 			return m, nil
 		}
 
+		forwarded, quote := liveRefs(payload.ForwardedFrom, payload.Quote)
 		m.messages = append(m.messages, chatMessage{
+			id:             payload.MessageID,
 			senderID:       payload.SenderID,
 			senderUsername: payload.SenderUsername,
+			senderIsBot:    payload.SenderIsBot,
+			kind:           payload.Kind,
 			content:        payload.Content,
+			files:          liveFiles(payload.Attachments),
+			poll:           livePoll(payload.Poll),
+			forwarded:      forwarded,
+			quote:          quote,
 			timestamp:      msg.Message.Timestamp.Format("15:04"),
 		})
 		m.updateViewport()
+		return m, m.profiles.Load(m.apiClient, payload.SenderID)
+
+	case ws.TypePollUpdated:
+		var payload ws.PollUpdatedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		if i := m.pollIndex(payload.MessageID); i >= 0 {
+			m.messages[i].poll.applyUpdate(payload, m.username)
+			m.updateViewport()
+		}
+
+	case ws.TypeProfileUpdated:
+		var payload ws.ProfileUpdatedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil {
+			return m, nil
+		}
+		m.profiles.Apply(payload)
+		m.updateViewport()
+
+	case ws.TypeRoomUpdated:
+		var payload ws.RoomUpdatedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		m.room.Mode = payload.Mode
+		m.room.SlowModeSeconds = payload.SlowModeSeconds
+		m.room.Topic = payload.Topic
+		if m.room.Mode == api.RoomModeArchived {
+			m.pollFocus = 0
+			m.input.Blur()
+			m.updateViewport()
+		} else if m.pollFocus == 0 {
+			return m, m.input.Focus()
+		}
+
+	case ws.TypeRoomDeleted:
+		var payload ws.RoomDeletedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		// nothing can be posted anymore; keep the scrollback until the user leaves
+		m.room.Mode = api.RoomModeArchived
+		m.pollFocus = 0
+		m.input.Blur()
+		m.messages = append(m.messages, chatMessage{
+			notice:    true,
+			content:   "this room was deleted by an admin",
+			timestamp: msg.Message.Timestamp.Format("15:04"),
+		})
+		m.updateViewport()
+
+	case ws.TypeMessageDeleted:
+		var payload ws.MessageDeletedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		m.messages = slices.DeleteFunc(m.messages, func(cm chatMessage) bool { return cm.id == payload.MessageID })
+		m.dropPollFocus(payload.MessageID)
+		m.updateViewport()
+
+	case ws.TypeMessageExpired:
+		var payload ws.MessageExpiredPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		m.messages = slices.DeleteFunc(m.messages, func(cm chatMessage) bool { return cm.id == payload.MessageID })
+		m.dropPollFocus(payload.MessageID)
+		m.updateViewport()
+
+	case ws.TypeMessagePinned:
+		var payload ws.MessagePinnedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		text := payload.By + " pinned a message, ctrl+p shows the pins"
+		if !payload.Pinned {
+			text = payload.By + " unpinned a message"
+		}
+		m.messages = append(m.messages, chatMessage{
+			notice:    true,
+			content:   text,
+			timestamp: msg.Message.Timestamp.Format("15:04"),
+		})
+		m.updateViewport()
+		return m, m.loadPins()
+
+	case ws.TypeAnnouncement:
+		var payload ws.AnnouncementPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil {
+			return m, nil
+		}
+		m.messages = append(m.messages, chatMessage{
+			notice:    true,
+			content:   render.Announcement(payload.From, payload.Text),
+			timestamp: msg.Message.Timestamp.Format("15:04"),
+		})
+		m.updateViewport()
+
+	case ws.TypeCommandReply:
+		var payload ws.CommandReplyPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil {
+			return m, nil
+		}
+		if payload.RoomID != 0 && payload.RoomID != m.room.ID {
+			return m, nil
+		}
+		for _, line := range strings.Split(payload.Text, "\n") {
+			m.messages = append(m.messages, chatMessage{
+				notice:    true,
+				content:   line,
+				timestamp: msg.Message.Timestamp.Format("15:04"),
+			})
+		}
+		m.updateViewport()
 
 	case ws.TypeError:
 		var payload ws.ErrorPayload
@@ -253,19 +638,99 @@ func (m *Model) updateViewport() {
 	var lines []string
 	for _, msg := range m.messages {
 		ts := timeStyle.Render(fmt.Sprintf("[%s]", msg.timestamp))
+		if msg.notice {
+			lines = append(lines, fmt.Sprintf("%s %s", ts, timeStyle.Italic(true).Render(msg.content)))
+			continue
+		}
+		if m.profiles.IsBlocked(msg.senderID) {
+			continue
+		}
 		var name string
 		if msg.senderID == m.userID {
 			name = ownStyle.Render("you")
 		} else {
-			name = otherStyle.Render(msg.senderUsername)
+			name = otherStyle.Render(m.profiles.Name(msg.senderID, msg.senderUsername))
 		}
-		lines = append(lines, fmt.Sprintf("%s %s: %s", ts, name, contentStyle.Render(msg.content)))
+		if msg.senderIsBot {
+			name += " " + timeStyle.Render(render.BotTag)
+		}
+		if msg.forwarded != "" {
+			lines = append(lines, "    "+timeStyle.Italic(true).Render(msg.forwarded))
+		}
+		if q := msg.quote; q != nil && !m.profiles.IsBlocked(q.senderID) {
+			lines = append(lines, timeStyle.Render(render.Quote(m.profiles.Name(q.senderID, q.senderUsername), q.body, m.width)))
+		}
+		body := strings.TrimSpace(strings.Join(append([]string{msg.content}, msg.files...), " "))
+		if msg.poll != nil {
+			focused := msg.id == m.pollFocus
+			question := contentStyle.Bold(true).Render("poll: " + msg.content)
+			if focused {
+				question = lipgloss.NewStyle().Foreground(t.Accent).Bold(true).Render("▸ poll: " + msg.content)
+			}
+			lines = append(lines, fmt.Sprintf("%s %s: %s", ts, name, question))
+			lines = append(lines, m.pollLines(msg.poll, focused)...)
+			continue
+		}
+		if msg.kind == api.MessageKindAction {
+			lines = append(lines, fmt.Sprintf("%s * %s %s", ts, name, contentStyle.Italic(true).Render(body)))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", ts, name, contentStyle.Render(body)))
 	}
 
 	m.viewport.SetContent(strings.Join(lines, "\n"))
 	m.viewport.GotoBottom()
 }
 
+// block blocks or unblocks a user by username.
+func (m Model) block(username string, blocked bool) tea.Cmd {
+	username = strings.TrimPrefix(username, "@")
+	return func() tea.Msg {
+		u, err := m.apiClient.GetUserByUsername(username)
+		if err != nil {
+			return blockedMsg{err: err}
+		}
+		if blocked {
+			err = m.apiClient.BlockUser(u.ID)
+		} else {
+			err = m.apiClient.UnblockUser(u.ID)
+		}
+		return blockedMsg{username: u.Username, userID: u.ID, blocked: blocked, err: err}
+	}
+}
+
+// upload sends the file at path in the background; the message referencing it
+// goes out once the server has stored it.
+func (m Model) upload(path string) (Model, tea.Cmd) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	m.err = ""
+	m.uploading = filepath.Base(path)
+	return m, func() tea.Msg {
+		att, err := m.apiClient.UploadFile(path)
+		return uploadedMsg{attachment: att, err: err}
+	}
+}
+
+func historyFiles(atts []api.AttachmentResponse) []string {
+	files := make([]string, len(atts))
+	for i, a := range atts {
+		files[i] = render.FileTag(a.Filename, a.SizeBytes)
+	}
+	return files
+}
+
+func liveFiles(atts []ws.AttachmentInfo) []string {
+	files := make([]string, len(atts))
+	for i, a := range atts {
+		files[i] = render.FileTag(a.Filename, a.SizeBytes)
+	}
+	return files
+}
+
 func (m *Model) cleanup() {
 	if m.wsClient != nil {
 		m.wsClient.Close()
@@ -285,10 +750,52 @@ func (m Model) loadHistory() tea.Cmd {
 
 func (m Model) connectWS() tea.Cmd {
 	return func() tea.Msg {
-		client, err := ws.Connect(context.Background(), m.wsURL, m.token, m.program, m.logger)
+		token, err := m.apiClient.AccessToken()
+		if err != nil {
+			return ws.ErrorMsg{Err: err}
+		}
+		client, err := ws.Connect(context.Background(), m.wsURL, token, m.program, m.logger)
 		if err != nil {
 			return ws.ErrorMsg{Err: err}
 		}
 		return wsConnectedMsg{client: client}
 	}
 }
+
+// schedule asks the server to send text at sendAt.
+func (m Model) schedule(text string, sendAt time.Time) tea.Cmd {
+	return func() tea.Msg {
+		res, err := m.apiClient.ScheduleMessage(m.room.ID, 0, text, sendAt)
+		return scheduledMsg{scheduled: res, err: err}
+	}
+}
+
+// parseSchedule splits the arguments of /schedule into a delay such as 15m or
+// 2h and the message text.
+func parseSchedule(args string) (time.Duration, string, error) {
+	dur, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	delay, err := time.ParseDuration(dur)
+	if err != nil || delay < time.Minute {
+		return 0, "", errors.New("usage: /schedule <delay> <text>, e.g. /schedule 15m standup time")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, "", errors.New("usage: /schedule <delay> <text>")
+	}
+	return delay, text, nil
+}
+
+// parseTTL splits the arguments of /ttl into a duration such as 30s or 1h and
+// the message text.
+func parseTTL(args string) (time.Duration, string, error) {
+	dur, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	ttl, err := time.ParseDuration(dur)
+	if err != nil || ttl < time.Second {
+		return 0, "", errors.New("usage: /ttl <duration> <text>, e.g. /ttl 30s see you")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, "", errors.New("usage: /ttl <duration> <text>")
+	}
+	return ttl, text, nil
+}
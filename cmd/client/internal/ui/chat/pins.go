@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/theme"
+)
+
+// maxPinLines is how many pins the panel shows at once.
+const maxPinLines = 5
+
+type pinsLoadedMsg struct {
+	pins []api.PinnedMessageResponse
+	err  error
+}
+
+// actionDoneMsg reports the result of /pin, /unpin or /save. An empty text
+// means there is nothing to tell the user, e.g. a message_pinned frame will.
+type actionDoneMsg struct {
+	text string
+	err  error
+}
+
+func (m Model) loadPins() tea.Cmd {
+	return func() tea.Msg {
+		pins, err := m.apiClient.ListPins(m.room.ID)
+		return pinsLoadedMsg{pins: pins, err: err}
+	}
+}
+
+// pin pins or unpins a message of the room.
+func (m Model) pin(messageID int64, pinned bool) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if pinned {
+			err = m.apiClient.PinMessage(m.room.ID, messageID)
+		} else {
+			err = m.apiClient.UnpinMessage(m.room.ID, messageID)
+		}
+		return actionDoneMsg{err: err}
+	}
+}
+
+// save adds a message to the user's saved list.
+func (m Model) save(messageID int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.apiClient.SaveMessage(messageID); err != nil {
+			return actionDoneMsg{err: err}
+		}
+		return actionDoneMsg{text: "message saved, see it from the rooms list with s"}
+	}
+}
+
+// recentMessage returns the ID of the nth most recent message in the
+// scrollback, counting from 1 and skipping notices. An empty arg means 1.
+func (m Model) recentMessage(arg string) (int64, error) {
+	n, err := position(arg)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].notice || m.messages[i].id == 0 {
+			continue
+		}
+		if n--; n == 0 {
+			return m.messages[i].id, nil
+		}
+	}
+	return 0, errors.New("no such message")
+}
+
+// pinnedMessage returns the ID of the nth pin as numbered in the panel.
+func (m Model) pinnedMessage(arg string) (int64, error) {
+	n, err := position(arg)
+	if err != nil {
+		return 0, err
+	}
+	if n > len(m.pins) {
+		return 0, errors.New("no such pin, ctrl+p shows them")
+	}
+	return m.pins[n-1].ID, nil
+}
+
+func position(arg string) (int, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%q is not a message number", arg)
+	}
+	return n, nil
+}
+
+// pinsHeight is how many lines the pins panel takes, zero when hidden.
+func (m Model) pinsHeight() int {
+	if !m.showPins {
+		return 0
+	}
+	return min(max(len(m.pins), 1), maxPinLines) + 1
+}
+
+// resize fits the viewport between the header, the pins panel and the input.
+func (m *Model) resize() {
+	m.viewport.Width = m.width
+	m.viewport.Height = m.height - 4 - m.pinsHeight()
+}
+
+// pinsView renders the pins panel, numbered for /unpin.
+func (m Model) pinsView() string {
+	t := theme.Current
+	titleStyle := lipgloss.NewStyle().Foreground(t.Gold).Bold(true)
+	subtleStyle := lipgloss.NewStyle().Foreground(t.Subtle)
+
+	lines := []string{titleStyle.Render(fmt.Sprintf("pinned (%d)", len(m.pins)))}
+	if len(m.pins) == 0 {
+		lines = append(lines, subtleStyle.Italic(true).Render("  nothing pinned yet"))
+	}
+	for i, p := range m.pins {
+		if i == maxPinLines {
+			break
+		}
+		line := fmt.Sprintf("%d. %s: %s", i+1, m.profiles.Name(p.SenderID, p.SenderUsername), p.Body)
+		lines = append(lines, "  "+subtleStyle.Render(render.Line(line, m.width-2)))
+	}
+	return strings.Join(lines, "\n")
+}
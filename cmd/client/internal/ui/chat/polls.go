@@ -0,0 +1,275 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/theme"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ws"
+)
+
+// pollBarWidth is how many cells the tally bar of an option takes.
+const pollBarWidth = 10
+
+// pollState is the poll of a chat message and its latest tally.
+type pollState struct {
+	options   []string
+	multiple  bool
+	anonymous bool
+	closesAt  *time.Time
+	closed    bool
+	counts    []int
+	voters    [][]string
+	total     int
+	mine      []int // options we voted, 0-based
+}
+
+func historyPoll(p *api.PollResponse) *pollState {
+	if p == nil {
+		return nil
+	}
+	return &pollState{
+		options:   p.Options,
+		multiple:  p.Multiple,
+		anonymous: p.Anonymous,
+		closesAt:  p.ClosesAt,
+		closed:    p.Closed,
+		counts:    p.Counts,
+		voters:    p.Voters,
+		total:     p.TotalVoters,
+		mine:      p.MyVotes,
+	}
+}
+
+func livePoll(p *ws.PollInfo) *pollState {
+	if p == nil {
+		return nil
+	}
+	return &pollState{
+		options:   p.Options,
+		multiple:  p.Multiple,
+		anonymous: p.Anonymous,
+		closesAt:  p.ClosesAt,
+		counts:    make([]int, len(p.Options)),
+	}
+}
+
+func (p *pollState) isClosed() bool {
+	return p.closed || p.closesAt != nil && !time.Now().Before(*p.closesAt)
+}
+
+// toggle returns our votes after pressing option n: a single choice poll
+// switches to it, a multiple choice one adds or removes it. Pressing the
+// current single choice again retracts it.
+func (p *pollState) toggle(n int) []int {
+	if slices.Contains(p.mine, n) {
+		return slices.DeleteFunc(slices.Clone(p.mine), func(o int) bool { return o == n })
+	}
+	if !p.multiple {
+		return []int{n}
+	}
+	votes := append(slices.Clone(p.mine), n)
+	slices.Sort(votes)
+	return votes
+}
+
+// applyUpdate takes the tally of a poll_updated frame. Named polls tell us
+// our own votes too; for anonymous ones we keep what we last sent.
+func (p *pollState) applyUpdate(u ws.PollUpdatedPayload, username string) {
+	p.counts = u.Counts
+	p.voters = u.Voters
+	p.total = u.TotalVoters
+	if p.anonymous {
+		return
+	}
+	p.mine = []int{}
+	for i, names := range u.Voters {
+		if slices.Contains(names, username) {
+			p.mine = append(p.mine, i)
+		}
+	}
+}
+
+// pollIndex returns the position in the scrollback of the poll with the
+// given message ID, -1 if it isn't there.
+func (m Model) pollIndex(messageID int64) int {
+	return slices.IndexFunc(m.messages, func(cm chatMessage) bool {
+		return cm.poll != nil && cm.id == messageID
+	})
+}
+
+// nextPoll returns the message ID of the open poll above the focused one,
+// wrapping around to the latest; 0 if there are no open polls.
+func (m Model) nextPoll() int64 {
+	var open []int64
+	for _, cm := range m.messages {
+		if cm.poll != nil && cm.id != 0 && !cm.poll.isClosed() && !m.profiles.IsBlocked(cm.senderID) {
+			open = append(open, cm.id)
+		}
+	}
+	if len(open) == 0 {
+		return 0
+	}
+	i := slices.Index(open, m.pollFocus)
+	if i <= 0 {
+		return open[len(open)-1]
+	}
+	return open[i-1]
+}
+
+// dropPollFocus gives the focus back to the input if the focused poll was
+// removed from the scrollback.
+func (m *Model) dropPollFocus(messageID int64) {
+	if m.pollFocus == messageID {
+		m.pollFocus = 0
+		m.input.Focus()
+	}
+}
+
+// handlePollKey handles keys while a poll has the focus: number keys vote (0
+// for the tenth option), tab moves to the previous open poll and esc or
+// enter go back to the input.
+func (m Model) handlePollKey(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "esc", "enter":
+		m.pollFocus = 0
+		m.updateViewport()
+		return m, m.input.Focus()
+	case "tab":
+		m.pollFocus = m.nextPoll()
+		m.updateViewport()
+		return m, nil
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9", "0":
+		i := m.pollIndex(m.pollFocus)
+		if i < 0 || m.wsClient == nil {
+			return m, nil
+		}
+		p := m.messages[i].poll
+		n := int(key[0] - '1')
+		if key == "0" {
+			n = 9
+		}
+		if n >= len(p.options) {
+			return m, nil
+		}
+		if p.isClosed() {
+			m.err = "this poll is closed"
+			return m, nil
+		}
+		votes := p.toggle(n)
+		if err := m.wsClient.VotePoll(m.pollFocus, votes); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		p.mine = votes
+		m.updateViewport()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// pollLines renders the options and footer of a poll, indented under the
+// question. The focused poll is highlighted and shows the keys to vote.
+func (m Model) pollLines(p *pollState, focused bool) []string {
+	t := theme.Current
+	subtleStyle := lipgloss.NewStyle().Foreground(t.Subtle)
+	optionStyle := lipgloss.NewStyle().Foreground(t.Text)
+	barStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	if focused {
+		optionStyle = optionStyle.Foreground(t.Accent).Bold(true)
+	}
+
+	var lines []string
+	for i, o := range p.options {
+		mark := "[ ]"
+		if slices.Contains(p.mine, i) {
+			mark = "[x]"
+		}
+		count := 0
+		if i < len(p.counts) {
+			count = p.counts[i]
+		}
+		filled := 0
+		if p.total > 0 {
+			filled = count * pollBarWidth / p.total
+		}
+		bar := barStyle.Render(strings.Repeat("█", filled)) + subtleStyle.Render(strings.Repeat("░", pollBarWidth-filled))
+		line := fmt.Sprintf("    %d. %s %s %s %d", i+1, mark, optionStyle.Render(o), bar, count)
+		if !p.anonymous && i < len(p.voters) && len(p.voters[i]) > 0 {
+			line += subtleStyle.Render(" " + render.Line(strings.Join(p.voters[i], ", "), max(m.width-lipgloss.Width(line)-1, 10)))
+		}
+		lines = append(lines, line)
+	}
+
+	footer := []string{"single choice"}
+	if p.multiple {
+		footer[0] = "multiple choice"
+	}
+	if p.anonymous {
+		footer = append(footer, "anonymous")
+	}
+	footer = append(footer, fmt.Sprintf("%d voted", p.total))
+	switch {
+	case p.isClosed():
+		footer = append(footer, "closed")
+	case p.closesAt != nil:
+		footer = append(footer, "closes "+p.closesAt.Local().Format("Jan 2 15:04"))
+	}
+	if focused {
+		footer = append(footer, "1-9, 0: vote  tab: next poll  esc: back")
+	}
+	return append(lines, "    "+subtleStyle.Italic(true).Render(strings.Join(footer, " · ")))
+}
+
+// parsePoll splits the arguments of /poll into the question and the poll:
+// optional -multi, -anon and -for <duration> flags, then the question and
+// the options separated by |.
+func parsePoll(args string) (string, ws.PollInfo, error) {
+	usage := errors.New("usage: /poll [-multi] [-anon] [-for 1h] question | option | option")
+	var poll ws.PollInfo
+	rest := strings.TrimSpace(args)
+	for strings.HasPrefix(rest, "-") {
+		flag, after, _ := strings.Cut(rest, " ")
+		rest = strings.TrimSpace(after)
+		switch flag {
+		case "-multi":
+			poll.Multiple = true
+		case "-anon":
+			poll.Anonymous = true
+		case "-for":
+			dur, after, _ := strings.Cut(rest, " ")
+			d, err := time.ParseDuration(dur)
+			if err != nil || d < time.Minute {
+				return "", poll, errors.New("-for takes a duration of at least 1m, e.g. -for 2h")
+			}
+			closesAt := time.Now().Add(d)
+			poll.ClosesAt = &closesAt
+			rest = strings.TrimSpace(after)
+		default:
+			return "", poll, usage
+		}
+	}
+
+	parts := strings.Split(rest, "|")
+	question := strings.TrimSpace(parts[0])
+	for _, o := range parts[1:] {
+		if o = strings.TrimSpace(o); o != "" {
+			poll.Options = append(poll.Options, o)
+		}
+	}
+	if question == "" || len(poll.Options) < 2 {
+		return "", poll, usage
+	}
+	return question, poll, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -40,6 +41,22 @@ type dmErrorMsg struct {
 	err error
 }
 
+// searchTickMsg fires once typing pauses; seq tells whether the input changed since.
+type searchTickMsg struct {
+	seq   int
+	query string
+}
+
+type suggestionsMsg struct {
+	seq   int
+	users []api.ProfileResponse
+}
+
+const (
+	searchDelay    = 200 * time.Millisecond
+	maxSuggestions = 5
+)
+
 type convItem struct {
 	conv api.ConversationResponse
 }
@@ -74,6 +91,9 @@ type Model struct {
 	list         list.Model
 	creating     bool
 	createInput  textinput.Model
+	suggestions  []api.ProfileResponse
+	suggestIdx   int
+	searchSeq    int
 	err          string
 	width        int
 	height       int
@@ -127,6 +147,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.creating = true
 			m.createInput.SetValue("")
 			m.createInput.Focus()
+			m.suggestions = nil
 			return m, textinput.Blink
 		case "enter":
 			if item, ok := m.list.SelectedItem().(convItem); ok {
@@ -142,6 +163,19 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.list.SetItems(items)
 		return m, nil
 
+	case searchTickMsg:
+		if !m.creating || msg.seq != m.searchSeq {
+			return m, nil
+		}
+		return m, m.search(msg.seq, msg.query)
+
+	case suggestionsMsg:
+		if m.creating && msg.seq == m.searchSeq {
+			m.suggestions = msg.users
+			m.suggestIdx = 0
+		}
+		return m, nil
+
 	case peerFoundMsg:
 		return m, func() tea.Msg {
 			return NewDMMsg{PeerID: msg.user.ID, PeerUsername: msg.user.Username}
@@ -179,6 +213,10 @@ func (m Model) View() string {
 		b.WriteString(promptStyle.Render("New DM with: "))
 		b.WriteString(m.createInput.View())
 		b.WriteString("\n")
+		for i, u := range m.suggestions {
+			b.WriteString(suggestionLine(u, i == m.suggestIdx))
+			b.WriteString("\n")
+		}
 	}
 
 	if m.err != "" {
@@ -186,7 +224,11 @@ func (m Model) View() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(helpStyle.Render("enter: open  n: new DM  esc: back to rooms"))
+	if m.creating && len(m.suggestions) > 0 {
+		b.WriteString(helpStyle.Render("up/down: choose  enter: open  esc: cancel"))
+	} else {
+		b.WriteString(helpStyle.Render("enter: open  n: new DM  esc: back to rooms"))
+	}
 
 	return b.String()
 }
@@ -194,21 +236,64 @@ func (m Model) View() string {
 func (m Model) updateCreating(msg tea.KeyMsg) (Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
+		m.creating = false
+		if len(m.suggestions) > 0 {
+			u := m.suggestions[m.suggestIdx]
+			m.suggestions = nil
+			return m, func() tea.Msg { return NewDMMsg{PeerID: u.ID, PeerUsername: u.Username} }
+		}
 		username := strings.TrimSpace(m.createInput.Value())
 		if username == "" {
-			m.creating = false
 			return m, nil
 		}
-		m.creating = false
 		return m, m.lookupUser(username)
 	case "esc":
 		m.creating = false
+		m.suggestions = nil
+		return m, nil
+	case "up", "ctrl+p":
+		if n := len(m.suggestions); n > 0 {
+			m.suggestIdx = (m.suggestIdx + n - 1) % n
+		}
+		return m, nil
+	case "down", "ctrl+n", "tab":
+		if n := len(m.suggestions); n > 0 {
+			m.suggestIdx = (m.suggestIdx + 1) % n
+		}
 		return m, nil
 	}
 
+	prev := m.createInput.Value()
 	var cmd tea.Cmd
 	m.createInput, cmd = m.createInput.Update(msg)
-	return m, cmd
+	query := strings.TrimSpace(m.createInput.Value())
+	if query == strings.TrimSpace(prev) {
+		return m, cmd
+	}
+
+	// wait for a pause in typing before asking the server
+	m.searchSeq++
+	if query == "" {
+		m.suggestions = nil
+		return m, cmd
+	}
+	seq := m.searchSeq
+	return m, tea.Batch(cmd, tea.Tick(searchDelay, func(time.Time) tea.Msg {
+		return searchTickMsg{seq: seq, query: query}
+	}))
+}
+
+func suggestionLine(u api.ProfileResponse, selected bool) string {
+	t := theme.Current
+	label := u.Username
+	if u.DisplayName != "" {
+		label = fmt.Sprintf("%s (%s)", u.DisplayName, u.Username)
+	}
+	if selected {
+		return lipgloss.NewStyle().Foreground(t.Accent).Render(">") + " " +
+			lipgloss.NewStyle().Foreground(t.Accent).Bold(true).Render(label)
+	}
+	return "  " + lipgloss.NewStyle().Foreground(t.Subtle).Render(label)
 }
 
 func (m Model) fetchConvs() tea.Cmd {
@@ -221,6 +306,17 @@ func (m Model) fetchConvs() tea.Cmd {
 	}
 }
 
+func (m Model) search(seq int, query string) tea.Cmd {
+	return func() tea.Msg {
+		res, err := m.apiClient.SearchUsers(query, maxSuggestions)
+		if err != nil {
+			// suggestions are optional; enter still does an exact lookup
+			return suggestionsMsg{seq: seq}
+		}
+		return suggestionsMsg{seq: seq, users: res.Users}
+	}
+}
+
 func (m Model) lookupUser(username string) tea.Cmd {
 	return func() tea.Msg {
 		user, err := m.apiClient.GetUserByUsername(username)
@@ -4,15 +4,22 @@ package dmchat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/profile"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/theme"
 	"github.com/sleklere/realtime-chat/cmd/client/internal/ws"
 )
@@ -28,10 +35,27 @@ type wsConnectedMsg struct {
 	client *ws.Client
 }
 
+type uploadedMsg struct {
+	attachment api.AttachmentResponse
+	err        error
+}
+
+// scheduledMsg reports the result of /schedule.
+type scheduledMsg struct {
+	scheduled api.ScheduledMessageResponse
+	err       error
+}
+
 type dmMessage struct {
+	id             int64
 	senderID       int64
 	senderUsername string
+	senderIsBot    bool
+	notice         bool // an announcement or command reply, not part of the conversation
 	content        string
+	files          []string
+	forwarded      string // origin line of a forward, empty otherwise
+	quote          *quoteRef
 	timestamp      string
 }
 
@@ -41,6 +65,7 @@ type Model struct {
 	wsClient  *ws.Client
 	program   *tea.Program
 	logger    *slog.Logger
+	profiles  *profile.Cache
 
 	conversationID int64 // 0 if conversation not yet created
 	peerID         int64
@@ -48,14 +73,14 @@ type Model struct {
 	myUserID       int64
 	myUsername     string
 	wsURL          string
-	token          string
-
-	viewport viewport.Model
-	input    textinput.Model
-	messages []dmMessage
-	err      string
-	width    int
-	height   int
+
+	viewport  viewport.Model
+	input     textinput.Model
+	messages  []dmMessage
+	err       string
+	uploading string // filename of the upload in progress, if any
+	width     int
+	height    int
 }
 
 // New creates a new DM chat Model.
@@ -63,10 +88,11 @@ func New(
 	apiClient *api.Client,
 	program *tea.Program,
 	logger *slog.Logger,
+	profiles *profile.Cache,
 	conversationID, peerID int64,
 	peerUsername string,
 	myUserID int64,
-	myUsername, wsURL, token string,
+	myUsername, wsURL string,
 	width, height int,
 ) Model {
 	vp := viewport.New(width, height-4)
@@ -81,13 +107,13 @@ func New(
 		apiClient:      apiClient,
 		program:        program,
 		logger:         logger,
+		profiles:       profiles,
 		conversationID: conversationID,
 		peerID:         peerID,
 		peerUsername:   peerUsername,
 		myUserID:       myUserID,
 		myUsername:     myUsername,
 		wsURL:          wsURL,
-		token:          token,
 		viewport:       vp,
 		input:          input,
 		width:          width,
@@ -97,7 +123,7 @@ func New(
 
 // Init initializes the DM chat model.
 func (m Model) Init() tea.Cmd {
-	cmds := []tea.Cmd{m.connectWS(), textinput.Blink}
+	cmds := []tea.Cmd{m.connectWS(), textinput.Blink, m.profiles.Load(m.apiClient, m.peerID)}
 	if m.conversationID != 0 {
 		cmds = append(cmds, m.loadHistory())
 	}
@@ -123,21 +149,67 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			if m2.SenderID == m.myUserID {
 				senderUsername = m.myUsername
 			}
+			forwarded, quote := historyRefs(m2)
 			m.messages = append(m.messages, dmMessage{
+				id:             m2.ID,
 				senderID:       m2.SenderID,
 				senderUsername: senderUsername,
 				content:        m2.Body,
+				files:          historyFiles(m2.Attachments),
+				forwarded:      forwarded,
+				quote:          quote,
 				timestamp:      m2.CreatedAt.Format("15:04"),
 			})
 		}
 		m.updateViewport()
 		return m, nil
 
+	case profile.LoadedMsg:
+		m.updateViewport()
+		return m, nil
+
 	case wsConnectedMsg:
 		m.wsClient = msg.client
 		m.logger.Info("ws connected for dm", "peer_id", m.peerID)
 		return m, nil
 
+	case uploadedMsg:
+		m.uploading = ""
+		if msg.err != nil {
+			m.err = "upload failed: " + msg.err.Error()
+			return m, nil
+		}
+		if m.wsClient == nil {
+			return m, nil
+		}
+		if err := m.wsClient.SendDirectMessage(m.peerID, "", msg.attachment.ID); err != nil {
+			m.err = err.Error()
+		}
+		return m, nil
+
+	case scheduledMsg:
+		if msg.err != nil {
+			m.err = "schedule failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.messages = append(m.messages, dmMessage{
+			notice:    true,
+			content:   "scheduled for " + msg.scheduled.SendAt.Local().Format("Jan 2 15:04") + ": " + msg.scheduled.Body,
+			timestamp: time.Now().Format("15:04"),
+		})
+		m.updateViewport()
+		return m, nil
+
+	case forwardTargetMsg:
+		if err := m.forward(msg); err != nil {
+			m.err = "/fwd: " + err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.messages = append(m.messages, dmMessage{notice: true, content: "forwarded to " + msg.target, timestamp: time.Now().Format("15:04")})
+		m.updateViewport()
+		return m, nil
+
 	case ws.IncomingMsg:
 		return m.handleWSMessage(msg)
 
@@ -192,6 +264,12 @@ func (m Model) View() string {
 	var b strings.Builder
 
 	header := fmt.Sprintf("@ %s", m.peerUsername)
+	if name := m.profiles.Name(m.peerID, m.peerUsername); name != m.peerUsername {
+		header = fmt.Sprintf("@ %s (%s)", name, m.peerUsername)
+	}
+	if status := m.profiles.Status(m.peerID); status != "" {
+		header += lipgloss.NewStyle().Foreground(t.Subtle).Italic(true).Render("  — " + status)
+	}
 	b.WriteString(headerStyle.Render(header))
 	b.WriteString("\n")
 	b.WriteString(m.viewport.View())
@@ -203,7 +281,10 @@ func (m Model) View() string {
 	if m.err != "" {
 		statusParts = append(statusParts, lipgloss.NewStyle().Foreground(t.Error).Render(m.err))
 	}
-	statusParts = append(statusParts, statusStyle.Render("esc: back  enter: send"))
+	if m.uploading != "" {
+		statusParts = append(statusParts, statusStyle.Render("uploading "+m.uploading+"..."))
+	}
+	statusParts = append(statusParts, statusStyle.Render("esc: back  enter: send  /upload <path>: attach file  /ttl <duration> <text>: disappearing message  /schedule <delay> <text>  /fwd [n] #room|@user  /quote [n] <text>"))
 	b.WriteString(strings.Join(statusParts, "  "))
 
 	return b.String()
@@ -217,6 +298,56 @@ func (m Model) sendMessage() (Model, tea.Cmd) {
 
 	m.input.SetValue("")
 
+	if path, ok := strings.CutPrefix(content, "/upload "); ok {
+		return m.upload(strings.TrimSpace(path))
+	}
+	if rest, ok := strings.CutPrefix(content, "/schedule "); ok {
+		delay, text, err := parseSchedule(rest)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		return m, m.schedule(text, time.Now().Add(delay))
+	}
+	if rest, ok := strings.CutPrefix(content, "/ttl "); ok {
+		ttl, text, err := parseTTL(rest)
+		if err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		if err := m.wsClient.SendEphemeralDirectMessage(m.peerID, text, ttl); err != nil {
+			m.err = err.Error()
+		}
+		return m, nil
+	}
+	if rest, ok := strings.CutPrefix(content, "/fwd "); ok {
+		n, target, err := parseForward(rest)
+		if err == nil {
+			var id int64
+			if id, err = m.recentMessage(n); err == nil {
+				m.err = ""
+				return m, m.resolveForward(id, target)
+			}
+		}
+		m.err = "/fwd: " + err.Error()
+		return m, nil
+	}
+	if rest, ok := strings.CutPrefix(content, "/quote "); ok {
+		n, text, err := parseQuote(rest)
+		if err == nil {
+			var id int64
+			if id, err = m.recentMessage(n); err == nil {
+				m.err = ""
+				if err := m.wsClient.SendDirectQuote(m.peerID, text, id); err != nil {
+					m.err = err.Error()
+				}
+				return m, nil
+			}
+		}
+		m.err = "/quote: " + err.Error()
+		return m, nil
+	}
+
 	if err := m.wsClient.SendDirectMessage(m.peerID, content); err != nil {
 		m.err = err.Error()
 	}
@@ -250,14 +381,56 @@ func (m Model) handleWSMessage(msg ws.IncomingMsg) (Model, tea.Cmd) {
 			senderUsername = m.myUsername
 		}
 
+		forwarded, quote := liveRefs(payload.ForwardedFrom, payload.Quote)
 		m.messages = append(m.messages, dmMessage{
+			id:             payload.MessageID,
 			senderID:       payload.FromUserID,
 			senderUsername: senderUsername,
+			senderIsBot:    payload.FromIsBot,
 			content:        payload.Content,
+			files:          liveFiles(payload.Attachments),
+			forwarded:      forwarded,
+			quote:          quote,
 			timestamp:      msg.Message.Timestamp.Format("15:04"),
 		})
 		m.updateViewport()
 
+	case ws.TypeProfileUpdated:
+		var payload ws.ProfileUpdatedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil {
+			return m, nil
+		}
+		m.profiles.Apply(payload)
+		m.updateViewport()
+
+	case ws.TypeMessageDeleted:
+		var payload ws.MessageDeletedPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.ConversationID == 0 || payload.ConversationID != m.conversationID {
+			return m, nil
+		}
+		m.messages = slices.DeleteFunc(m.messages, func(dm dmMessage) bool { return dm.id == payload.MessageID })
+		m.updateViewport()
+
+	case ws.TypeMessageExpired:
+		var payload ws.MessageExpiredPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil || payload.ConversationID == 0 || payload.ConversationID != m.conversationID {
+			return m, nil
+		}
+		m.messages = slices.DeleteFunc(m.messages, func(dm dmMessage) bool { return dm.id == payload.MessageID })
+		m.updateViewport()
+
+	case ws.TypeAnnouncement:
+		var payload ws.AnnouncementPayload
+		if err := json.Unmarshal(msg.Message.Payload, &payload); err != nil {
+			return m, nil
+		}
+		m.messages = append(m.messages, dmMessage{
+			notice:    true,
+			content:   render.Announcement(payload.From, payload.Text),
+			timestamp: msg.Message.Timestamp.Format("15:04"),
+		})
+		m.updateViewport()
+
 	case ws.TypeError:
 		var payload ws.ErrorPayload
 		if err := json.Unmarshal(msg.Message.Payload, &payload); err == nil {
@@ -278,19 +451,65 @@ func (m *Model) updateViewport() {
 	var lines []string
 	for _, msg := range m.messages {
 		ts := timeStyle.Render(fmt.Sprintf("[%s]", msg.timestamp))
+		if msg.notice {
+			lines = append(lines, fmt.Sprintf("%s %s", ts, timeStyle.Italic(true).Render(msg.content)))
+			continue
+		}
 		var name string
 		if msg.senderID == m.myUserID {
 			name = ownStyle.Render("you")
 		} else {
-			name = otherStyle.Render(msg.senderUsername)
+			name = otherStyle.Render(m.profiles.Name(msg.senderID, msg.senderUsername))
+		}
+		if msg.senderIsBot {
+			name += " " + timeStyle.Render(render.BotTag)
 		}
-		lines = append(lines, fmt.Sprintf("%s %s: %s", ts, name, contentStyle.Render(msg.content)))
+		if msg.forwarded != "" {
+			lines = append(lines, "    "+timeStyle.Italic(true).Render(msg.forwarded))
+		}
+		if q := msg.quote; q != nil && !m.profiles.IsBlocked(q.senderID) {
+			lines = append(lines, timeStyle.Render(render.Quote(m.profiles.Name(q.senderID, q.senderUsername), q.body, m.width)))
+		}
+		body := strings.TrimSpace(strings.Join(append([]string{msg.content}, msg.files...), " "))
+		lines = append(lines, fmt.Sprintf("%s %s: %s", ts, name, contentStyle.Render(body)))
 	}
 
 	m.viewport.SetContent(strings.Join(lines, "\n"))
 	m.viewport.GotoBottom()
 }
 
+// upload sends the file at path in the background; the message referencing it
+// goes out once the server has stored it.
+func (m Model) upload(path string) (Model, tea.Cmd) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	m.err = ""
+	m.uploading = filepath.Base(path)
+	return m, func() tea.Msg {
+		att, err := m.apiClient.UploadFile(path)
+		return uploadedMsg{attachment: att, err: err}
+	}
+}
+
+func historyFiles(atts []api.AttachmentResponse) []string {
+	files := make([]string, len(atts))
+	for i, a := range atts {
+		files[i] = render.FileTag(a.Filename, a.SizeBytes)
+	}
+	return files
+}
+
+func liveFiles(atts []ws.AttachmentInfo) []string {
+	files := make([]string, len(atts))
+	for i, a := range atts {
+		files[i] = render.FileTag(a.Filename, a.SizeBytes)
+	}
+	return files
+}
+
 func (m *Model) cleanup() {
 	if m.wsClient != nil {
 		m.wsClient.Close()
@@ -310,10 +529,52 @@ func (m Model) loadHistory() tea.Cmd {
 
 func (m Model) connectWS() tea.Cmd {
 	return func() tea.Msg {
-		client, err := ws.Connect(context.Background(), m.wsURL, m.token, m.program, m.logger)
+		token, err := m.apiClient.AccessToken()
+		if err != nil {
+			return ws.ErrorMsg{Err: err}
+		}
+		client, err := ws.Connect(context.Background(), m.wsURL, token, m.program, m.logger)
 		if err != nil {
 			return ws.ErrorMsg{Err: err}
 		}
 		return wsConnectedMsg{client: client}
 	}
 }
+
+// schedule asks the server to send text at sendAt.
+func (m Model) schedule(text string, sendAt time.Time) tea.Cmd {
+	return func() tea.Msg {
+		res, err := m.apiClient.ScheduleMessage(0, m.peerID, text, sendAt)
+		return scheduledMsg{scheduled: res, err: err}
+	}
+}
+
+// parseSchedule splits the arguments of /schedule into a delay such as 15m or
+// 2h and the message text.
+func parseSchedule(args string) (time.Duration, string, error) {
+	dur, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	delay, err := time.ParseDuration(dur)
+	if err != nil || delay < time.Minute {
+		return 0, "", errors.New("usage: /schedule <delay> <text>, e.g. /schedule 15m standup time")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, "", errors.New("usage: /schedule <delay> <text>")
+	}
+	return delay, text, nil
+}
+
+// parseTTL splits the arguments of /ttl into a duration such as 30s or 1h and
+// the message text.
+func parseTTL(args string) (time.Duration, string, error) {
+	dur, text, _ := strings.Cut(strings.TrimSpace(args), " ")
+	ttl, err := time.ParseDuration(dur)
+	if err != nil || ttl < time.Second {
+		return 0, "", errors.New("usage: /ttl <duration> <text>, e.g. /ttl 30s see you")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, "", errors.New("usage: /ttl <duration> <text>")
+	}
+	return ttl, text, nil
+}
@@ -0,0 +1,137 @@
+// Package profile keeps the public profiles of the users shown on screen, so
+// chat views can print display names instead of usernames, and the set of
+// users the current user blocked, whose messages are hidden.
+package profile
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ws"
+)
+
+// LoadedMsg carries a profile fetched by Cache.Load. The app stores it in the
+// cache before passing it on, so screens only need to redraw.
+type LoadedMsg struct {
+	UserID  int64
+	Profile api.ProfileResponse
+	Err     error
+}
+
+// BlocksLoadedMsg carries the blocked users fetched by Cache.LoadBlocks.
+type BlocksLoadedMsg struct {
+	Blocked []api.BlockedUserResponse
+	Err     error
+}
+
+// Cache holds profiles by user id. It is only touched from Update, so it
+// needs no locking; fetches report back through LoadedMsg.
+type Cache struct {
+	profiles map[int64]api.ProfileResponse
+	pending  map[int64]bool
+	blocked  map[int64]bool
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		profiles: make(map[int64]api.ProfileResponse),
+		pending:  make(map[int64]bool),
+		blocked:  make(map[int64]bool),
+	}
+}
+
+// Load returns a command fetching the profiles of ids that are neither cached
+// nor already being fetched, or nil if there is nothing to fetch.
+func (c *Cache) Load(client *api.Client, ids ...int64) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, id := range ids {
+		if id == 0 || c.pending[id] {
+			continue
+		}
+		if _, ok := c.profiles[id]; ok {
+			continue
+		}
+		c.pending[id] = true
+		cmds = append(cmds, func() tea.Msg {
+			p, err := client.GetUser(id)
+			return LoadedMsg{UserID: id, Profile: p, Err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// Store records the result of a fetch. Failed fetches are forgotten so the
+// next Load tries again.
+func (c *Cache) Store(msg LoadedMsg) {
+	delete(c.pending, msg.UserID)
+	if msg.Err == nil {
+		c.profiles[msg.UserID] = msg.Profile
+	}
+}
+
+// Apply updates a cached profile from a profile_updated event.
+func (c *Cache) Apply(p ws.ProfileUpdatedPayload) {
+	prev := c.profiles[p.UserID]
+	c.profiles[p.UserID] = api.ProfileResponse{
+		ID:                 p.UserID,
+		Username:           p.Username,
+		IsBot:              prev.IsBot,
+		DisplayName:        p.DisplayName,
+		Bio:                p.Bio,
+		AvatarAttachmentID: p.AvatarAttachmentID,
+		AvatarURL:          p.AvatarURL,
+		StatusText:         p.StatusText,
+		StatusEmoji:        p.StatusEmoji,
+		CreatedAt:          prev.CreatedAt,
+	}
+}
+
+// Name returns the display name of a user, or username while it is unknown
+// or unset.
+func (c *Cache) Name(id int64, username string) string {
+	if p, ok := c.profiles[id]; ok && p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return username
+}
+
+// Status returns the status emoji and text of a user, or "" if unset.
+func (c *Cache) Status(id int64) string {
+	p := c.profiles[id]
+	return strings.TrimSpace(p.StatusEmoji + " " + p.StatusText)
+}
+
+// LoadBlocks returns a command fetching the users the current user blocked.
+func (c *Cache) LoadBlocks(client *api.Client) tea.Cmd {
+	return func() tea.Msg {
+		blocked, err := client.ListBlocked()
+		return BlocksLoadedMsg{Blocked: blocked, Err: err}
+	}
+}
+
+// StoreBlocks replaces the blocked set with the result of LoadBlocks.
+func (c *Cache) StoreBlocks(msg BlocksLoadedMsg) {
+	if msg.Err != nil {
+		return
+	}
+	clear(c.blocked)
+	for _, u := range msg.Blocked {
+		c.blocked[u.ID] = true
+	}
+}
+
+// SetBlocked records that the user blocked or unblocked id.
+func (c *Cache) SetBlocked(id int64, blocked bool) {
+	if blocked {
+		c.blocked[id] = true
+	} else {
+		delete(c.blocked, id)
+	}
+}
+
+// IsBlocked reports whether the current user blocked id.
+func (c *Cache) IsBlocked(id int64) bool {
+	return c.blocked[id]
+}
@@ -0,0 +1,65 @@
+// Package render holds text formatting helpers shared by the chat screens.
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// BotTag is appended to the name of bot senders.
+const BotTag = "[bot]"
+
+// FileTag formats an attachment as it appears inline in a chat line.
+func FileTag(filename string, sizeBytes int64) string {
+	return fmt.Sprintf("[file: %s (%s)]", filename, Size(sizeBytes))
+}
+
+// Announcement formats a server-wide notice from an admin as a single line.
+func Announcement(from, text string) string {
+	return fmt.Sprintf("announcement from %s: %s", from, strings.Join(strings.Fields(text), " "))
+}
+
+// Forwarded formats the origin of a forwarded message. room is empty for
+// messages first sent as a DM.
+func Forwarded(sender, room string) string {
+	if room == "" {
+		return "forwarded from " + sender
+	}
+	return fmt.Sprintf("forwarded from %s in %s", sender, room)
+}
+
+// Quote formats a quoted message as an indented block line that fits in
+// width cells.
+func Quote(sender, body string, width int) string {
+	return "    │ " + Line(sender+": "+body, width-6)
+}
+
+// Line flattens text to a single line that fits in width cells, cutting it
+// with an ellipsis if needed.
+func Line(text string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if width < 1 || lipgloss.Width(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// Size formats a byte count using binary units.
+func Size(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
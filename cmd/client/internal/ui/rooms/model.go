@@ -22,6 +22,9 @@ type RoomSelectedMsg struct {
 // ShowDMsMsg signals that the user wants to navigate to the DM screen.
 type ShowDMsMsg struct{}
 
+// ShowSavedMsg signals that the user wants to see their saved messages.
+type ShowSavedMsg struct{}
+
 // RoomErrorMsg signals an error in room operations.
 type RoomErrorMsg struct {
 	Err error
@@ -59,21 +62,34 @@ func (d roomItemDelegate) Render(w io.Writer, m list.Model, index int, item list
 	t := theme.Current
 	name := i.room.Name
 	slug := i.room.Slug
+	tag := modeTag(i.room.Mode)
 
 	if index == m.Index() {
 		nameStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
 		slugStyle := lipgloss.NewStyle().Foreground(t.Subtle)
 		indicator := lipgloss.NewStyle().Foreground(t.Accent).Render(">")
-		str := fmt.Sprintf("%s %s %s", indicator, nameStyle.Render(name), slugStyle.Render("#"+slug))
+		str := fmt.Sprintf("%s %s %s%s", indicator, nameStyle.Render(name), slugStyle.Render("#"+slug), tag)
 		_, _ = fmt.Fprint(w, str)
 	} else {
 		nameStyle := lipgloss.NewStyle().Foreground(t.Text)
 		slugStyle := lipgloss.NewStyle().Foreground(t.Subtle)
-		str := fmt.Sprintf("  %s %s", nameStyle.Render(name), slugStyle.Render("#"+slug))
+		str := fmt.Sprintf("  %s %s%s", nameStyle.Render(name), slugStyle.Render("#"+slug), tag)
 		_, _ = fmt.Fprint(w, str)
 	}
 }
 
+// modeTag renders a short label for non-normal room modes.
+func modeTag(mode string) string {
+	t := theme.Current
+	switch mode {
+	case api.RoomModeArchived:
+		return " " + lipgloss.NewStyle().Foreground(t.Subtle).Italic(true).Render("[archived]")
+	case api.RoomModeAnnouncement:
+		return " " + lipgloss.NewStyle().Foreground(t.Gold).Render("[announcements]")
+	}
+	return ""
+}
+
 // Model is the Bubble Tea model for the room list screen.
 type Model struct {
 	apiClient    *api.Client
@@ -82,6 +98,7 @@ type Model struct {
 	createInput  textinput.Model
 	pickingTheme bool
 	themeIndex   int
+	showArchived bool
 	err          string
 	width        int
 	height       int
@@ -148,8 +165,13 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			return m, nil
 		case "d":
 			return m, func() tea.Msg { return ShowDMsMsg{} }
+		case "s":
+			return m, func() tea.Msg { return ShowSavedMsg{} }
 		case "r":
 			return m, m.fetchRooms()
+		case "a":
+			m.showArchived = !m.showArchived
+			return m, m.fetchRooms()
 		case "enter":
 			if item, ok := m.list.SelectedItem().(roomItem); ok {
 				return m, m.joinAndSelect(item.room)
@@ -220,7 +242,11 @@ func (m Model) View() string {
 	if m.pickingTheme {
 		b.WriteString(helpStyle.Render("j/k: navigate  enter: apply  esc: cancel"))
 	} else {
-		b.WriteString(helpStyle.Render("enter: join  n: new room  d: DMs  t: theme  r: refresh  esc: quit"))
+		archivedHelp := "a: show archived"
+		if m.showArchived {
+			archivedHelp = "a: hide archived"
+		}
+		b.WriteString(helpStyle.Render("enter: join  n: new room  d: DMs  s: saved  t: theme  r: refresh  " + archivedHelp + "  esc: quit"))
 	}
 
 	return b.String()
@@ -310,7 +336,7 @@ func (m Model) updateCreating(msg tea.KeyMsg) (Model, tea.Cmd) {
 
 func (m Model) fetchRooms() tea.Cmd {
 	return func() tea.Msg {
-		rooms, err := m.apiClient.ListRooms()
+		rooms, err := m.apiClient.ListRooms(m.showArchived)
 		if err != nil {
 			return RoomErrorMsg{Err: err}
 		}
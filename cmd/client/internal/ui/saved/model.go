@@ -0,0 +1,196 @@
+// Package saved provides the saved messages UI model.
+package saved
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/render"
+	"github.com/sleklere/realtime-chat/cmd/client/internal/ui/theme"
+)
+
+// LeaveSavedMsg signals that the user wants to go back to rooms.
+type LeaveSavedMsg struct{}
+
+type savedLoadedMsg struct {
+	saved []api.SavedMessageResponse
+	err   error
+}
+
+type unsavedMsg struct {
+	messageID int64
+	err       error
+}
+
+const pageSize = 100
+
+type savedItem struct {
+	msg api.SavedMessageResponse
+}
+
+func (i savedItem) FilterValue() string { return i.msg.Body }
+
+type savedItemDelegate struct{}
+
+func (d savedItemDelegate) Height() int                             { return 2 }
+func (d savedItemDelegate) Spacing() int                            { return 1 }
+func (d savedItemDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d savedItemDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	i, ok := item.(savedItem)
+	if !ok {
+		return
+	}
+
+	t := theme.Current
+	where := "DM"
+	if i.msg.RoomID != 0 {
+		where = "#" + i.msg.RoomName
+	}
+	meta := fmt.Sprintf("%s · %s · %s", i.msg.SenderUsername, where, i.msg.CreatedAt.Local().Format("Jan 2 15:04"))
+	body := render.Line(i.msg.Body, m.Width()-2)
+
+	if index == m.Index() {
+		indicator := lipgloss.NewStyle().Foreground(t.Accent).Render(">")
+		_, _ = fmt.Fprintf(w, "%s %s\n  %s", indicator,
+			lipgloss.NewStyle().Foreground(t.Accent).Bold(true).Render(meta),
+			lipgloss.NewStyle().Foreground(t.Text).Render(body))
+	} else {
+		_, _ = fmt.Fprintf(w, "  %s\n  %s",
+			lipgloss.NewStyle().Foreground(t.Subtle).Render(meta),
+			lipgloss.NewStyle().Foreground(t.Subtle).Render(body))
+	}
+}
+
+// Model is the Bubble Tea model for the saved messages screen.
+type Model struct {
+	apiClient *api.Client
+	list      list.Model
+	err       string
+	width     int
+	height    int
+}
+
+// New creates a new saved messages Model.
+func New(apiClient *api.Client, width, height int) Model {
+	t := theme.Current
+
+	l := list.New([]list.Item{}, savedItemDelegate{}, width, height-4)
+	l.Title = "Saved Messages"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.Title = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(t.Accent).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder(), false, false, true, false).
+		BorderForeground(t.Surface)
+
+	return Model{
+		apiClient: apiClient,
+		list:      l,
+		width:     width,
+		height:    height,
+	}
+}
+
+// Init initializes the saved messages model.
+func (m Model) Init() tea.Cmd {
+	return m.fetchSaved()
+}
+
+// Update handles messages for the saved messages model.
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return LeaveSavedMsg{} }
+		case "r":
+			return m, m.fetchSaved()
+		case "x":
+			if item, ok := m.list.SelectedItem().(savedItem); ok {
+				return m, m.unsave(item.msg.ID)
+			}
+			return m, nil
+		}
+
+	case savedLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err.Error()
+			return m, nil
+		}
+		m.err = ""
+		items := make([]list.Item, len(msg.saved))
+		for i, s := range msg.saved {
+			items[i] = savedItem{msg: s}
+		}
+		m.list.SetItems(items)
+		return m, nil
+
+	case unsavedMsg:
+		if msg.err != nil {
+			m.err = msg.err.Error()
+			return m, nil
+		}
+		for i, item := range m.list.Items() {
+			if s, ok := item.(savedItem); ok && s.msg.ID == msg.messageID {
+				m.list.RemoveItem(i)
+				break
+			}
+		}
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.list.SetSize(msg.Width, msg.Height-4)
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+// View renders the saved messages model.
+func (m Model) View() string {
+	t := theme.Current
+	helpStyle := lipgloss.NewStyle().Foreground(t.Subtle).Italic(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error)
+
+	var b strings.Builder
+
+	b.WriteString(m.list.View())
+	b.WriteString("\n")
+
+	if len(m.list.Items()) == 0 && m.err == "" {
+		b.WriteString(helpStyle.Render("nothing saved yet, use /save in a room"))
+		b.WriteString("\n")
+	}
+	if m.err != "" {
+		b.WriteString(errorStyle.Render(m.err))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(helpStyle.Render("x: remove  r: refresh  esc: back to rooms"))
+
+	return b.String()
+}
+
+func (m Model) fetchSaved() tea.Cmd {
+	return func() tea.Msg {
+		saved, err := m.apiClient.ListSaved(pageSize)
+		return savedLoadedMsg{saved: saved, err: err}
+	}
+}
+
+func (m Model) unsave(messageID int64) tea.Cmd {
+	return func() tea.Msg {
+		return unsavedMsg{messageID: messageID, err: m.apiClient.UnsaveMessage(messageID)}
+	}
+}
@@ -71,11 +71,13 @@ func (c *Client) Send(msg Message) {
 	c.sendCh <- msg
 }
 
-// SendDirectMessage sends a direct message to the specified user.
-func (c *Client) SendDirectMessage(toUserID int64, content string) error {
+// SendDirectMessage sends a direct message to the specified user, optionally
+// referencing previously uploaded attachments.
+func (c *Client) SendDirectMessage(toUserID int64, content string, attachmentIDs ...int64) error {
 	payload, err := json.Marshal(DirectMessagePayload{
-		ToUserID: toUserID,
-		Content:  content,
+		ToUserID:      toUserID,
+		Content:       content,
+		AttachmentIDs: attachmentIDs,
 	})
 	if err != nil {
 		return err
@@ -88,11 +90,69 @@ func (c *Client) SendDirectMessage(toUserID int64, content string) error {
 	return nil
 }
 
-// SendRoomMessage sends a message to the specified room.
-func (c *Client) SendRoomMessage(roomID int64, content string) error {
+// SendRoomMessage sends a message to the specified room, optionally
+// referencing previously uploaded attachments.
+func (c *Client) SendRoomMessage(roomID int64, content string, attachmentIDs ...int64) error {
+	payload, err := json.Marshal(RoomMessagePayload{
+		RoomID:        roomID,
+		Content:       content,
+		AttachmentIDs: attachmentIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeRoomMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// SendEphemeralDirectMessage sends a direct message that disappears ttl
+// after it is sent.
+func (c *Client) SendEphemeralDirectMessage(toUserID int64, content string, ttl time.Duration) error {
+	payload, err := json.Marshal(DirectMessagePayload{
+		ToUserID:   toUserID,
+		Content:    content,
+		TTLSeconds: int32(ttl / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeDirectMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// SendEphemeralRoomMessage sends a room message that disappears ttl after
+// it is sent.
+func (c *Client) SendEphemeralRoomMessage(roomID int64, content string, ttl time.Duration) error {
+	payload, err := json.Marshal(RoomMessagePayload{
+		RoomID:     roomID,
+		Content:    content,
+		TTLSeconds: int32(ttl / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeRoomMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// SendPoll posts a poll to a room. question is the message content.
+func (c *Client) SendPoll(roomID int64, question string, poll PollInfo) error {
 	payload, err := json.Marshal(RoomMessagePayload{
 		RoomID:  roomID,
-		Content: content,
+		Content: question,
+		Poll:    &poll,
 	})
 	if err != nil {
 		return err
@@ -105,6 +165,92 @@ func (c *Client) SendRoomMessage(roomID int64, content string) error {
 	return nil
 }
 
+// VotePoll replaces our votes on a poll with options.
+func (c *Client) VotePoll(messageID int64, options []int) error {
+	payload, err := json.Marshal(PollVotePayload{MessageID: messageID, Options: options})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypePollVote,
+		Payload: payload,
+	})
+	return nil
+}
+
+// ForwardToRoom forwards the message with the given ID to a room.
+func (c *Client) ForwardToRoom(roomID, messageID int64) error {
+	payload, err := json.Marshal(RoomMessagePayload{
+		RoomID:        roomID,
+		ForwardedFrom: &ForwardedFrom{MessageID: messageID},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeRoomMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// ForwardToUser forwards the message with the given ID to a user as a DM.
+func (c *Client) ForwardToUser(toUserID, messageID int64) error {
+	payload, err := json.Marshal(DirectMessagePayload{
+		ToUserID:      toUserID,
+		ForwardedFrom: &ForwardedFrom{MessageID: messageID},
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeDirectMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// SendRoomQuote sends a room message that quotes the message with the
+// given ID.
+func (c *Client) SendRoomQuote(roomID int64, content string, quoteID int64) error {
+	payload, err := json.Marshal(RoomMessagePayload{
+		RoomID:         roomID,
+		Content:        content,
+		QuoteMessageID: quoteID,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeRoomMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
+// SendDirectQuote sends a direct message that quotes the message with the
+// given ID.
+func (c *Client) SendDirectQuote(toUserID int64, content string, quoteID int64) error {
+	payload, err := json.Marshal(DirectMessagePayload{
+		ToUserID:       toUserID,
+		Content:        content,
+		QuoteMessageID: quoteID,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Send(Message{
+		Type:    TypeDirectMessage,
+		Payload: payload,
+	})
+	return nil
+}
+
 // Close cancels the connection context and closes the WebSocket.
 func (c *Client) Close() {
 	c.cancel()
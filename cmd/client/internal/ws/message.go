@@ -10,13 +10,28 @@ const (
 	TypeRoomMessage   = "room_message"
 	TypeDirectMessage = "direct_message"
 
-	TypeJoinRoom  = "join_room"
-	TypeLeaveRoom = "leave_room"
+	TypeJoinRoom    = "join_room"
+	TypeLeaveRoom   = "leave_room"
+	TypeRoomUpdated = "room_updated"
+	TypeRoomDeleted = "room_deleted"
+
+	TypeMessageDeleted = "message_deleted"
+	TypeMessageExpired = "message_expired"
+	TypeMessagePinned  = "message_pinned"
+
+	TypePollVote    = "poll_vote"
+	TypePollUpdated = "poll_updated"
+
+	TypeCommandReply = "command_reply"
 
 	TypeUserOnline  = "user_online"
 	TypeUserOffline = "user_offline"
 	TypeUserTyping  = "user_typing"
 
+	TypeProfileUpdated = "profile_updated"
+
+	TypeAnnouncement = "announcement"
+
 	TypeLoadRoomHistory  = "load_room_history"
 	TypeLoadConversation = "load_conversation"
 
@@ -35,21 +50,91 @@ type Message struct {
 
 // RoomMessagePayload is the payload for room_message messages.
 type RoomMessagePayload struct {
-	RoomID         int64  `json:"room_id"`
-	Content        string `json:"content"`
-	SenderID       int64  `json:"sender_id,omitempty"`
-	SenderUsername string `json:"sender_username,omitempty"`
-	MessageID      int64  `json:"message_id,omitempty"`
+	RoomID         int64            `json:"room_id"`
+	Content        string           `json:"content"`
+	AttachmentIDs  []int64          `json:"attachment_ids,omitempty"`
+	TTLSeconds     int32            `json:"ttl_seconds,omitempty"`
+	Poll           *PollInfo        `json:"poll,omitempty"`
+	ForwardedFrom  *ForwardedFrom   `json:"forwarded_from,omitempty"`
+	QuoteMessageID int64            `json:"quote_message_id,omitempty"`
+	SenderID       int64            `json:"sender_id,omitempty"`
+	SenderUsername string           `json:"sender_username,omitempty"`
+	SenderIsBot    bool             `json:"sender_is_bot,omitempty"`
+	MessageID      int64            `json:"message_id,omitempty"`
+	Kind           string           `json:"kind,omitempty"`
+	Attachments    []AttachmentInfo `json:"attachments,omitempty"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"`
+	Quote          *QuoteInfo       `json:"quote,omitempty"`
 }
 
 // DirectMessagePayload is the payload for direct_message messages.
 type DirectMessagePayload struct {
-	ToUserID       int64  `json:"to_user_id"`
-	Content        string `json:"content"`
-	FromUserID     int64  `json:"from_user_id,omitempty"`
-	FromUsername   string `json:"from_username,omitempty"`
-	ConversationID int64  `json:"conversation_id,omitempty"`
-	MessageID      int64  `json:"message_id,omitempty"`
+	ToUserID       int64            `json:"to_user_id"`
+	Content        string           `json:"content"`
+	AttachmentIDs  []int64          `json:"attachment_ids,omitempty"`
+	TTLSeconds     int32            `json:"ttl_seconds,omitempty"`
+	ForwardedFrom  *ForwardedFrom   `json:"forwarded_from,omitempty"`
+	QuoteMessageID int64            `json:"quote_message_id,omitempty"`
+	FromUserID     int64            `json:"from_user_id,omitempty"`
+	FromUsername   string           `json:"from_username,omitempty"`
+	FromIsBot      bool             `json:"from_is_bot,omitempty"`
+	ConversationID int64            `json:"conversation_id,omitempty"`
+	MessageID      int64            `json:"message_id,omitempty"`
+	Attachments    []AttachmentInfo `json:"attachments,omitempty"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"`
+	Quote          *QuoteInfo       `json:"quote,omitempty"`
+}
+
+// ForwardedFrom is where a forwarded message first came from. To forward a
+// message we send only its MessageID; the server fills in the rest.
+type ForwardedFrom struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id,omitempty"`
+	SenderUsername string `json:"sender_username,omitempty"`
+	RoomID         int64  `json:"room_id,omitempty"`
+	RoomName       string `json:"room_name,omitempty"`
+}
+
+// QuoteInfo is the message quoted by a reply.
+type QuoteInfo struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	Body           string `json:"body"`
+}
+
+// PollInfo describes the poll of a room message; its question is the
+// message content.
+type PollInfo struct {
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple,omitempty"`
+	Anonymous bool       `json:"anonymous,omitempty"`
+	ClosesAt  *time.Time `json:"closes_at,omitempty"`
+}
+
+// PollVotePayload is the payload for poll_vote messages. It replaces our
+// previous votes; an empty Options retracts them.
+type PollVotePayload struct {
+	MessageID int64 `json:"message_id"`
+	Options   []int `json:"options"`
+}
+
+// PollUpdatedPayload is the payload for poll_updated messages, sent to a
+// room after every vote. Voters is empty for anonymous polls.
+type PollUpdatedPayload struct {
+	MessageID   int64      `json:"message_id"`
+	RoomID      int64      `json:"room_id"`
+	Counts      []int      `json:"counts"`
+	Voters      [][]string `json:"voters,omitempty"`
+	TotalVoters int        `json:"total_voters"`
+}
+
+// AttachmentInfo describes a file attached to a message.
+type AttachmentInfo struct {
+	ID          int64  `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
 }
 
 // JoinRoomPayload is the payload for join_room and leave_room messages.
@@ -64,8 +149,77 @@ type UserTypingPayload struct {
 	IsTyping bool   `json:"is_typing"`
 }
 
+// RoomUpdatedPayload is the payload for room_updated messages.
+type RoomUpdatedPayload struct {
+	RoomID          int64  `json:"room_id"`
+	Mode            string `json:"mode"`
+	SlowModeSeconds int32  `json:"slow_mode_seconds"`
+	Topic           string `json:"topic"`
+}
+
+// RoomDeletedPayload is the payload for room_deleted messages, sent when an
+// admin deletes a room we are a member of.
+type RoomDeletedPayload struct {
+	RoomID int64 `json:"room_id"`
+}
+
+// MessageDeletedPayload is the payload for message_deleted messages. One of
+// RoomID and ConversationID is set.
+type MessageDeletedPayload struct {
+	MessageID      int64 `json:"message_id"`
+	RoomID         int64 `json:"room_id,omitempty"`
+	ConversationID int64 `json:"conversation_id,omitempty"`
+}
+
+// MessageExpiredPayload is the payload for message_expired messages, sent
+// when a disappearing message runs out. One of RoomID and ConversationID is
+// set.
+type MessageExpiredPayload struct {
+	MessageID      int64 `json:"message_id"`
+	RoomID         int64 `json:"room_id,omitempty"`
+	ConversationID int64 `json:"conversation_id,omitempty"`
+}
+
+// MessagePinnedPayload is the payload for message_pinned messages, sent to a
+// room when a moderator pins a message or, with Pinned false, unpins it.
+type MessagePinnedPayload struct {
+	MessageID int64  `json:"message_id"`
+	RoomID    int64  `json:"room_id"`
+	Pinned    bool   `json:"pinned"`
+	By        string `json:"by"`
+}
+
+// AnnouncementPayload is the payload for announcement messages: a notice
+// from an instance admin sent to everyone connected.
+type AnnouncementPayload struct {
+	Text string `json:"text"`
+	From string `json:"from"`
+}
+
+// ProfileUpdatedPayload is the payload for profile_updated messages, sent
+// when someone sharing a room or a conversation with us edits their profile.
+type ProfileUpdatedPayload struct {
+	UserID             int64  `json:"user_id"`
+	Username           string `json:"username"`
+	DisplayName        string `json:"display_name"`
+	Bio                string `json:"bio"`
+	AvatarAttachmentID int64  `json:"avatar_attachment_id,omitempty"`
+	AvatarURL          string `json:"avatar_url,omitempty"`
+	StatusText         string `json:"status_text"`
+	StatusEmoji        string `json:"status_emoji"`
+}
+
+// CommandReplyPayload is the payload for command_reply messages: the result
+// of a slash command, shown only to whoever ran it.
+type CommandReplyPayload struct {
+	RoomID  int64  `json:"room_id"`
+	Command string `json:"command"`
+	Text    string `json:"text"`
+}
+
 // ErrorPayload is the payload for error messages from the server.
 type ErrorPayload struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
 }
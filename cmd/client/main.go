@@ -32,7 +32,9 @@ func main() {
 	p := tea.NewProgram(&app, tea.WithAltScreen())
 	app.SetProgram(p)
 
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	app.Logout()
+	if err != nil {
 		logger.Error("program exited with error", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,4 @@
+// Package admin contains the instance operator actions: listing and
+// deactivating accounts, force-deleting rooms and messages and reading the
+// audit log. Callers are expected to have checked users.is_admin already.
+package admin
@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Store defines the persistence methods the admin Service needs.
+type Store interface {
+	ListUsers(ctx context.Context, arg dbstore.ListUsersParams) ([]dbstore.ListUsersRow, error)
+	GetAccount(ctx context.Context, id int64) (dbstore.GetAccountRow, error)
+	DeactivateUser(ctx context.Context, id int64) (int64, error)
+	ReactivateUser(ctx context.Context, id int64) (int64, error)
+	RevokeOtherSessions(ctx context.Context, arg dbstore.RevokeOtherSessionsParams) ([]int64, error)
+	ListBotsByOwner(ctx context.Context, ownerID pgtype.Int8) ([]dbstore.User, error)
+
+	ListRoomAttachmentKeys(ctx context.Context, roomID pgtype.Int8) ([]string, error)
+	DeleteRoom(ctx context.Context, id int64) (int64, error)
+	ListMessageAttachmentKeys(ctx context.Context, messageID pgtype.Int8) ([]string, error)
+	DeleteMessage(ctx context.Context, id int64) (dbstore.DeleteMessageRow, error)
+
+	audit.Writer
+	ListAuditEvents(ctx context.Context, arg dbstore.ListAuditEventsParams) ([]dbstore.AuditEvent, error)
+}
+
+// Disconnector closes the live connection of a user, e.g. the ws.Hub.
+type Disconnector interface {
+	DisconnectUser(userID int64)
+}
+
+// Service implements the admin actions.
+type Service struct {
+	store  Store
+	conns  Disconnector
+	blobs  attachment.BlobDeleter
+	logger *slog.Logger
+}
+
+// NewService creates a Service. blobs may be nil to leave upload contents in place.
+func NewService(s Store, conns Disconnector, blobs attachment.BlobDeleter, l *slog.Logger) *Service {
+	return &Service{store: s, conns: conns, blobs: blobs, logger: l}
+}
+
+// ListUsers returns a page of accounts ordered by id, only the deactivated
+// ones if deactivatedOnly is set.
+func (s *Service) ListUsers(ctx context.Context, deactivatedOnly bool, limit, offset int32) ([]dbstore.ListUsersRow, error) {
+	return s.store.ListUsers(ctx, dbstore.ListUsersParams{DeactivatedOnly: deactivatedOnly, Lim: limit, Off: offset})
+}
+
+// DeactivateUser locks an account out until an admin reactivates it. Its
+// sessions are revoked and the connections of the user and of their bots
+// closed; the bots can't authenticate again, nor post through incoming
+// webhooks, while the owner is deactivated.
+func (s *Service) DeactivateUser(ctx context.Context, actorID, userID int64) error {
+	if actorID == userID {
+		return httpx.BadRequest("invalid_user", "you can't deactivate your own account", nil)
+	}
+	n, err := s.store.DeactivateUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return s.missingOr(ctx, userID, httpx.New(http.StatusConflict, "already_deactivated", "account is already deactivated", nil))
+	}
+
+	if _, err := s.store.RevokeOtherSessions(ctx, dbstore.RevokeOtherSessionsParams{UserID: userID}); err != nil {
+		return err
+	}
+	s.conns.DisconnectUser(userID)
+	bots, err := s.store.ListBotsByOwner(ctx, pgtype.Int8{Int64: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+	for _, b := range bots {
+		s.conns.DisconnectUser(b.ID)
+	}
+
+	s.record(ctx, audit.Event{Action: audit.ActionUserDeactivated, ActorID: actorID, TargetType: audit.TargetUser, TargetID: userID})
+	s.logger.Info("account deactivated by admin", "user_id", userID, "admin_id", actorID)
+	return nil
+}
+
+// ReactivateUser lifts a deactivation, also cancelling a deletion the user
+// asked for. Accounts already deleted can't come back.
+func (s *Service) ReactivateUser(ctx context.Context, actorID, userID int64) error {
+	n, err := s.store.ReactivateUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return s.missingOr(ctx, userID, httpx.New(http.StatusConflict, "not_deactivated", "account is active or already deleted", nil))
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionUserReactivated, ActorID: actorID, TargetType: audit.TargetUser, TargetID: userID})
+	s.logger.Info("account reactivated by admin", "user_id", userID, "admin_id", actorID)
+	return nil
+}
+
+// missingOr returns a 404 if the user doesn't exist and conflict otherwise.
+func (s *Service) missingOr(ctx context.Context, userID int64, conflict error) error {
+	if _, err := s.store.GetAccount(ctx, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httpx.New(http.StatusNotFound, "not_found", "user not found", err)
+		}
+		return err
+	}
+	return conflict
+}
+
+// DeleteRoom deletes a room with its members, messages and uploads.
+func (s *Service) DeleteRoom(ctx context.Context, actorID, roomID int64) error {
+	keys, err := s.store.ListRoomAttachmentKeys(ctx, pgtype.Int8{Int64: roomID, Valid: true})
+	if err != nil {
+		return err
+	}
+	n, err := s.store.DeleteRoom(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "room not found", nil)
+	}
+	attachment.DeleteBlobs(ctx, s.blobs, keys, s.logger)
+
+	s.record(ctx, audit.Event{
+		Action:     audit.ActionRoomDeleted,
+		ActorID:    actorID,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID,
+		Details:    map[string]any{"attachments": len(keys)},
+	})
+	s.logger.Info("room deleted by admin", "room_id", roomID, "admin_id", actorID, "attachments", len(keys))
+	return nil
+}
+
+// DeleteMessage deletes a room message or DM with its uploads and returns
+// where it was.
+func (s *Service) DeleteMessage(ctx context.Context, actorID, messageID int64) (dbstore.DeleteMessageRow, error) {
+	keys, err := s.store.ListMessageAttachmentKeys(ctx, pgtype.Int8{Int64: messageID, Valid: true})
+	if err != nil {
+		return dbstore.DeleteMessageRow{}, err
+	}
+	msg, err := s.store.DeleteMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.DeleteMessageRow{}, httpx.New(http.StatusNotFound, "not_found", "message not found", err)
+		}
+		return dbstore.DeleteMessageRow{}, err
+	}
+	attachment.DeleteBlobs(ctx, s.blobs, keys, s.logger)
+
+	details := map[string]any{}
+	if msg.RoomID.Valid {
+		details["room_id"] = msg.RoomID.Int64
+	} else {
+		details["conversation_id"] = msg.ConversationID.Int64
+	}
+	s.record(ctx, audit.Event{
+		Action:     audit.ActionMessageDeleted,
+		ActorID:    actorID,
+		TargetType: audit.TargetMessage,
+		TargetID:   messageID,
+		Details:    details,
+	})
+	s.logger.Info("message deleted by admin", "message_id", messageID, "admin_id", actorID)
+	return msg, nil
+}
+
+// MaxAnnouncementRunes caps the length of a server-wide announcement.
+const MaxAnnouncementRunes = 1000
+
+// Announce validates the text of a server-wide announcement and records it.
+// Delivering it is up to the caller.
+func (s *Service) Announce(ctx context.Context, actorID int64, text string) (string, error) {
+	text, err := content.Validate(text, MaxAnnouncementRunes)
+	if err != nil {
+		return "", err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionAnnouncement, ActorID: actorID, Details: map[string]any{"text": text}})
+	return text, nil
+}
+
+// AuditFilter narrows down ListAuditEvents. Zero fields match everything.
+type AuditFilter struct {
+	Action     string
+	ActorID    int64
+	TargetType string
+	TargetID   int64
+	IP         string
+	Since      time.Time
+	Until      time.Time
+	BeforeID   int64
+}
+
+// ListAuditEvents returns up to limit audit events matching f, newest first.
+func (s *Service) ListAuditEvents(ctx context.Context, f AuditFilter, limit int32) ([]dbstore.AuditEvent, error) {
+	return s.store.ListAuditEvents(ctx, dbstore.ListAuditEventsParams{
+		Action:     f.Action,
+		ActorID:    f.ActorID,
+		TargetType: f.TargetType,
+		TargetID:   f.TargetID,
+		Ip:         f.IP,
+		Since:      pgtype.Timestamptz{Time: f.Since, Valid: !f.Since.IsZero()},
+		Until:      pgtype.Timestamptz{Time: f.Until, Valid: !f.Until.IsZero()},
+		BeforeID:   f.BeforeID,
+		Lim:        limit,
+	})
+}
+
+// record appends an event to the audit log.
+func (s *Service) record(ctx context.Context, e audit.Event) {
+	audit.Record(ctx, s.store, s.logger, e)
+}
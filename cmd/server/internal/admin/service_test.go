@@ -0,0 +1,189 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeStore keeps users and rooms in memory; unused methods panic through
+// the nil embedded interface.
+type fakeStore struct {
+	Store
+	users    map[int64]*dbstore.GetAccountRow
+	bots     map[int64][]int64 // owner → bots
+	revoked  []int64
+	rooms    map[int64]bool
+	roomKeys map[int64][]string
+	audit    []dbstore.CreateAuditEventParams
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users: map[int64]*dbstore.GetAccountRow{
+			1: {ID: 1, Username: "root"},
+			2: {ID: 2, Username: "alice"},
+		},
+		bots:     map[int64][]int64{2: {3}},
+		rooms:    map[int64]bool{10: true},
+		roomKeys: map[int64][]string{10: {"a", "b"}},
+	}
+}
+
+func (f *fakeStore) GetAccount(_ context.Context, id int64) (dbstore.GetAccountRow, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return dbstore.GetAccountRow{}, pgx.ErrNoRows
+	}
+	return *u, nil
+}
+
+func (f *fakeStore) DeactivateUser(_ context.Context, id int64) (int64, error) {
+	u, ok := f.users[id]
+	if !ok || u.DeactivatedAt.Valid {
+		return 0, nil
+	}
+	u.DeactivatedAt = pgtype.Timestamptz{Valid: true}
+	return 1, nil
+}
+
+func (f *fakeStore) ReactivateUser(_ context.Context, id int64) (int64, error) {
+	u, ok := f.users[id]
+	if !ok || !u.DeactivatedAt.Valid {
+		return 0, nil
+	}
+	u.DeactivatedAt = pgtype.Timestamptz{}
+	return 1, nil
+}
+
+func (f *fakeStore) RevokeOtherSessions(_ context.Context, arg dbstore.RevokeOtherSessionsParams) ([]int64, error) {
+	f.revoked = append(f.revoked, arg.UserID)
+	return nil, nil
+}
+
+func (f *fakeStore) ListBotsByOwner(_ context.Context, ownerID pgtype.Int8) ([]dbstore.User, error) {
+	var out []dbstore.User
+	for _, id := range f.bots[ownerID.Int64] {
+		out = append(out, dbstore.User{ID: id, IsBot: true})
+	}
+	return out, nil
+}
+
+func (f *fakeStore) ListRoomAttachmentKeys(_ context.Context, roomID pgtype.Int8) ([]string, error) {
+	return f.roomKeys[roomID.Int64], nil
+}
+
+func (f *fakeStore) DeleteRoom(_ context.Context, id int64) (int64, error) {
+	if !f.rooms[id] {
+		return 0, nil
+	}
+	delete(f.rooms, id)
+	return 1, nil
+}
+
+func (f *fakeStore) CreateAuditEvent(_ context.Context, arg dbstore.CreateAuditEventParams) error {
+	f.audit = append(f.audit, arg)
+	return nil
+}
+
+type fakeConns struct{ users []int64 }
+
+func (f *fakeConns) DisconnectUser(userID int64) { f.users = append(f.users, userID) }
+
+type fakeBlobs struct{ deleted []string }
+
+func (f *fakeBlobs) Delete(_ context.Context, key string) error {
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func newTestService() (*Service, *fakeStore, *fakeConns, *fakeBlobs) {
+	store, conns, blobs := newFakeStore(), &fakeConns{}, &fakeBlobs{}
+	return NewService(store, conns, blobs, slog.New(slog.NewTextHandler(io.Discard, nil))), store, conns, blobs
+}
+
+func statusOf(err error) int {
+	var he *httpx.HTTPError
+	if errors.As(err, &he) {
+		return he.Status
+	}
+	return 0
+}
+
+func TestDeactivateUser(t *testing.T) {
+	svc, store, conns, _ := newTestService()
+
+	if err := svc.DeactivateUser(context.Background(), 1, 2); err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+	if !store.users[2].DeactivatedAt.Valid {
+		t.Fatal("expected alice to be deactivated")
+	}
+	if !slices.Equal(store.revoked, []int64{2}) {
+		t.Fatalf("expected alice's sessions revoked, got %v", store.revoked)
+	}
+	// alice and her bot
+	if !slices.Equal(conns.users, []int64{2, 3}) {
+		t.Fatalf("expected alice and her bot disconnected, got %v", conns.users)
+	}
+
+	if err := svc.DeactivateUser(context.Background(), 1, 2); statusOf(err) != http.StatusConflict {
+		t.Fatalf("expected 409 deactivating twice, got %v", err)
+	}
+	if err := svc.DeactivateUser(context.Background(), 1, 42); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown user, got %v", err)
+	}
+	if err := svc.DeactivateUser(context.Background(), 1, 1); statusOf(err) != http.StatusBadRequest {
+		t.Fatalf("expected 400 deactivating yourself, got %v", err)
+	}
+
+	if err := svc.ReactivateUser(context.Background(), 1, 2); err != nil {
+		t.Fatalf("ReactivateUser: %v", err)
+	}
+	if store.users[2].DeactivatedAt.Valid {
+		t.Fatal("expected alice to be active again")
+	}
+	if err := svc.ReactivateUser(context.Background(), 1, 2); statusOf(err) != http.StatusConflict {
+		t.Fatalf("expected 409 reactivating an active user, got %v", err)
+	}
+
+	// refused attempts leave no trace in the audit log
+	var actions []string
+	for _, e := range store.audit {
+		if e.ActorID.Int64 != 1 || e.TargetType != audit.TargetUser || e.TargetID.Int64 != 2 {
+			t.Errorf("unexpected audit event %+v", e)
+		}
+		actions = append(actions, e.Action)
+	}
+	if want := []string{audit.ActionUserDeactivated, audit.ActionUserReactivated}; !slices.Equal(actions, want) {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestDeleteRoom_DeletesUploads(t *testing.T) {
+	svc, store, _, blobs := newTestService()
+
+	if err := svc.DeleteRoom(context.Background(), 1, 10); err != nil {
+		t.Fatalf("DeleteRoom: %v", err)
+	}
+	if store.rooms[10] {
+		t.Fatal("expected the room to be deleted")
+	}
+	if !slices.Equal(blobs.deleted, []string{"a", "b"}) {
+		t.Fatalf("expected the room's uploads deleted, got %v", blobs.deleted)
+	}
+
+	if err := svc.DeleteRoom(context.Background(), 1, 10); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing room, got %v", err)
+	}
+}
@@ -3,10 +3,13 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
 )
@@ -24,6 +27,80 @@ func (a *API) handle(h AppHandler) http.HandlerFunc {
 	}
 }
 
+// realIP replaces RemoteAddr with the client IP from X-Forwarded-For or
+// X-Real-IP, but only for requests from one of the trusted proxies. Anyone
+// else could put any address in those headers, e.g. to dodge the login
+// throttle.
+func realIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedIP(r, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client address a trusted proxy forwarded r for,
+// or "" if r didn't come from one. X-Forwarded-For is read from the right,
+// skipping our own proxies, since only the entries they added can be
+// believed.
+func forwardedIP(r *http.Request, trusted []netip.Prefix) string {
+	isTrusted := func(s string) bool {
+		addr, err := netip.ParseAddr(strings.TrimSpace(s))
+		if err != nil {
+			return false
+		}
+		for _, p := range trusted {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !isTrusted(peer) {
+		return ""
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				return ""
+			}
+			if !isTrusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if xrip := strings.TrimSpace(r.Header.Get("X-Real-IP")); xrip != "" {
+		if _, err := netip.ParseAddr(xrip); err == nil {
+			return xrip
+		}
+	}
+	return ""
+}
+
+// clientIP puts the client IP in the request context for the audit log. It
+// must run after realIP.
+func clientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithClientIP(r.Context(), ip)))
+	})
+}
+
+// validateJWT authenticates the bearer token of the request and, for user
+// tokens, checks that their session is still active.
 func (a *API) validateJWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h := r.Header.Get("Authorization")
@@ -34,18 +111,54 @@ func (a *API) validateJWT(next http.Handler) http.Handler {
 		}
 		tokenStr := strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
 
-		claims, err := auth.ParseToken(tokenStr, a.AuthConfig)
+		claims, err := auth.Authenticate(r.Context(), tokenStr, a.AuthConfig, a.BotService)
 		if err != nil {
 			herr := httpx.New(http.StatusUnauthorized, "invalid_token", "invalid bearer token", err)
 			a.writeError(w, r, herr)
 			return
 		}
 
+		// a revoked session (logout, password change, deactivation...) loses
+		// access at once instead of when its access token expires
+		if claims.SessionID != 0 {
+			active, err := a.Queries.IsSessionActive(r.Context(), claims.SessionID)
+			if err != nil {
+				a.writeError(w, r, httpx.New(http.StatusInternalServerError, "session_error", "error checking session", err))
+				return
+			}
+			if !active {
+				a.writeError(w, r, httpx.New(http.StatusUnauthorized, "session_revoked", "session revoked or expired", errors.New("inactive session")))
+				return
+			}
+		}
+
 		ctx := auth.NewClaimsContext(r.Context(), claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// requireAdmin lets the request through only for instance administrators.
+// It must run after validateJWT.
+func (a *API) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.ClaimsFromCtx(r.Context())
+		if !ok {
+			a.writeError(w, r, httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil))
+			return
+		}
+		isAdmin, err := a.Queries.IsUserAdmin(r.Context(), claims.UserID)
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		if !isAdmin || claims.IsBot {
+			a.writeError(w, r, httpx.New(http.StatusForbidden, "forbidden", "admin only", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (a *API) writeError(w http.ResponseWriter, r *http.Request, err error) {
 	var he *httpx.HTTPError
 	if !errors.As(err, &he) {
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestForwardedIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name, remote, xff, xrip, want string
+	}{
+		{"untrusted peer", "203.0.113.9:5000", "198.51.100.1", "198.51.100.2", ""},
+		{"trusted peer", "10.0.0.2:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed entries left of ours", "10.0.0.2:5000", "1.2.3.4, 198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"all hops trusted", "10.0.0.2:5000", "10.0.0.7, 10.0.0.3", "", "10.0.0.7"},
+		{"garbage", "10.0.0.2:5000", "not-an-ip", "", ""},
+		{"real ip header", "10.0.0.2:5000", "", "198.51.100.1", "198.51.100.1"},
+		{"no headers", "10.0.0.2:5000", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xrip != "" {
+				r.Header.Set("X-Real-IP", tt.xrip)
+			}
+			if got := forwardedIP(r, trusted); got != tt.want {
+				t.Fatalf("forwardedIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
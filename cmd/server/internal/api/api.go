@@ -3,15 +3,23 @@
 package api
 
 import (
+	"expvar"
 	"log/slog"
+	"net/http"
+	"net/netip"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/admin"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/api/handlers"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/bot"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/conversation"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/schedule"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/user"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
 )
 
@@ -21,11 +29,19 @@ type API struct {
 	AuthConfig *auth.Config
 	Queries    *dbstore.Queries
 	Hub        *ws.Hub
+	// TrustedProxies are the addresses whose X-Forwarded-For and X-Real-IP
+	// headers are believed.
+	TrustedProxies []netip.Prefix
 
 	AuthService         *auth.Service
 	RoomService         *room.Service
 	UserService         *user.Service
 	ConversationService *conversation.Service
+	AttachmentService   *attachment.Service
+	WebhookService      *webhook.Service
+	BotService          *bot.Service
+	AdminService        *admin.Service
+	ScheduleService     *schedule.Service
 }
 
 // RegisterAuthRoutes registers all authentication-related endpoints under /auth
@@ -34,39 +50,151 @@ func (a *API) registerAuthRoutes(r chi.Router) {
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/register", a.handle(h.Register))
 		r.Post("/login", a.handle(h.Login))
+		r.Post("/login/2fa", a.handle(h.LoginTwoFactor))
+		r.Post("/refresh", a.handle(h.Refresh))
+		r.Post("/password/forgot", a.handle(h.ForgotPassword))
+		r.Post("/password/reset", a.handle(h.ResetPassword))
+		r.Get("/oidc/login", a.handle(h.OIDCLogin))
+		r.Get("/oidc/callback", a.handle(h.OIDCCallback))
+		r.Post("/oidc/device", a.handle(h.DeviceLogin))
+		r.Post("/oidc/device/token", a.handle(h.DeviceToken))
+		r.Group(func(r chi.Router) {
+			r.Use(a.validateJWT)
+			r.Post("/logout", a.handle(h.Logout))
+			r.Post("/password", a.handle(h.ChangePassword))
+			r.Get("/sessions", a.handle(h.Sessions))
+			r.Delete("/sessions/{sessionID}", a.handle(h.RevokeSession))
+			r.Post("/2fa/enroll", a.handle(h.EnrollTOTP))
+			r.Post("/2fa/verify", a.handle(h.ConfirmTOTP))
+			r.Post("/2fa/disable", a.handle(h.DisableTOTP))
+			r.Post("/oidc/link", a.handle(h.OIDCLink))
+		})
 	})
 }
 
 // registerRoomRoutes registers all room-related endpoints under /rooms
 func (a *API) registerRoomRoutes(r chi.Router) {
-	h := handlers.NewRoomHandler(a.Logger, a.Hub, a.RoomService)
+	h := handlers.NewRoomHandler(a.Logger, a.Hub, a.RoomService, a.AttachmentService)
+	wh := handlers.NewWebhookHandler(a.Logger, a.WebhookService)
+	bh := handlers.NewBotHandler(a.Logger, a.Hub, a.Queries, a.BotService)
 	r.Route("/rooms", func(r chi.Router) {
 		r.Post("/", a.handle(h.Create))
 		r.Get("/", a.handle(h.List))
 		r.Get("/{slug}", a.handle(h.GetBySlug))
 		r.Post("/{roomID}/join", a.handle(h.Join))
 		r.Delete("/{roomID}/leave", a.handle(h.Leave))
+		r.Patch("/{roomID}/mode", a.handle(h.SetMode))
+		r.Patch("/{roomID}/slow-mode", a.handle(h.SetSlowMode))
+		r.Patch("/{roomID}/retention", a.handle(h.SetRetention))
+		r.Put("/{roomID}/members/{userID}/role", a.handle(h.SetMemberRole))
 		r.Get("/{roomID}/messages", a.handle(h.Messages))
+		r.Get("/{roomID}/pins", a.handle(h.Pins))
+		r.Put("/{roomID}/pins/{messageID}", a.handle(h.Pin))
+		r.Delete("/{roomID}/pins/{messageID}", a.handle(h.Unpin))
+		r.Route("/{roomID}/webhooks", func(r chi.Router) {
+			r.Post("/", a.handle(wh.Create))
+			r.Get("/", a.handle(wh.List))
+			r.Delete("/{webhookID}", a.handle(wh.Delete))
+			r.Get("/{webhookID}/deliveries", a.handle(wh.Deliveries))
+		})
+		r.Route("/{roomID}/incoming-webhooks", func(r chi.Router) {
+			r.Post("/", a.handle(bh.CreateIncomingWebhook))
+			r.Get("/", a.handle(bh.ListIncomingWebhooks))
+			r.Delete("/{webhookID}", a.handle(bh.DeleteIncomingWebhook))
+		})
 	})
 }
 
 func (a *API) registerUserRoutes(r chi.Router) {
-	h := handlers.NewUserHandler(a.Logger, a.UserService)
+	h := handlers.NewUserHandler(a.Logger, a.Hub, a.UserService)
+	ah := handlers.NewAuthHandler(a.AuthService, a.Logger)
 	r.Route("/users", func(r chi.Router) {
-		r.Get("/", a.handle(h.GetByUsername))
+		r.Get("/", a.handle(h.Search))
+		r.Get("/me", a.handle(h.Me))
+		r.Patch("/me", a.handle(h.UpdateMe))
+		r.Delete("/me", a.handle(ah.DeleteAccount))
+		r.Get("/me/export", a.handle(h.Export))
+		r.Get("/me/blocks", a.handle(h.Blocks))
+		r.Get("/me/privacy", a.handle(h.Privacy))
+		r.Put("/me/privacy", a.handle(h.SetPrivacy))
+		r.Get("/me/saved", a.handle(h.Saved))
+		r.Put("/me/saved/{messageID}", a.handle(h.SaveMessage))
+		r.Delete("/me/saved/{messageID}", a.handle(h.UnsaveMessage))
+		r.Get("/{userID}", a.handle(h.Get))
+		r.Put("/{userID}/block", a.handle(h.Block))
+		r.Delete("/{userID}/block", a.handle(h.Unblock))
 	})
 }
 
 func (a *API) registerConversationRoutes(r chi.Router) {
-	h := handlers.NewConversationHandler(a.Logger, a.ConversationService)
+	h := handlers.NewConversationHandler(a.Logger, a.ConversationService, a.AttachmentService)
 	r.Route("/conversations", func(r chi.Router) {
 		r.Get("/", a.handle(h.List))
 		r.Get("/{conversationID}/messages", a.handle(h.ListMessages))
+		r.Patch("/{conversationID}/ttl", a.handle(h.SetTTL))
+	})
+}
+
+// registerScheduledMessageRoutes registers the caller's scheduled messages
+// under /scheduled-messages
+func (a *API) registerScheduledMessageRoutes(r chi.Router) {
+	h := handlers.NewScheduledMessageHandler(a.Logger, a.ScheduleService)
+	r.Route("/scheduled-messages", func(r chi.Router) {
+		r.Post("/", a.handle(h.Create))
+		r.Get("/", a.handle(h.List))
+		r.Patch("/{scheduledID}", a.handle(h.Update))
+		r.Delete("/{scheduledID}", a.handle(h.Cancel))
+	})
+}
+
+// registerUploadRoutes registers file upload and download endpoints under /uploads
+func (a *API) registerUploadRoutes(r chi.Router) {
+	h := handlers.NewUploadHandler(a.Logger, a.AttachmentService)
+	r.Route("/uploads", func(r chi.Router) {
+		r.Post("/", a.handle(h.Upload))
+		r.Get("/{attachmentID}", a.handle(h.Download))
+	})
+}
+
+// registerBotRoutes registers bot account endpoints under /bots
+func (a *API) registerBotRoutes(r chi.Router) {
+	h := handlers.NewBotHandler(a.Logger, a.Hub, a.Queries, a.BotService)
+	r.Route("/bots", func(r chi.Router) {
+		r.Post("/", a.handle(h.Create))
+		r.Get("/", a.handle(h.List))
+		r.Post("/{botID}/token", a.handle(h.RotateToken))
+	})
+}
+
+// registerHookRoutes registers the public incoming webhook endpoint. The token
+// in the path authenticates the request, so it sits outside the JWT group.
+func (a *API) registerHookRoutes(r chi.Router) {
+	h := handlers.NewBotHandler(a.Logger, a.Hub, a.Queries, a.BotService)
+	r.Post("/hooks/{token}", a.handle(h.PostIncoming))
+}
+
+// registerAdminRoutes registers instance administration endpoints under /admin.
+// Callers must be authenticated and have users.is_admin set.
+func (a *API) registerAdminRoutes(r chi.Router) {
+	h := handlers.NewAdminHandler(a.Logger, a.Hub, a.AuthService, a.AdminService)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(a.requireAdmin)
+		r.Delete("/lockouts/{username}", a.handle(h.Unlock))
+		r.Get("/users", a.handle(h.ListUsers))
+		r.Post("/users/{userID}/deactivate", a.handle(h.DeactivateUser))
+		r.Post("/users/{userID}/reactivate", a.handle(h.ReactivateUser))
+		r.Delete("/rooms/{roomID}", a.handle(h.DeleteRoom))
+		r.Delete("/messages/{messageID}", a.handle(h.DeleteMessage))
+		r.Get("/stats", a.handle(h.Stats))
+		r.Post("/announcements", a.handle(h.Announce))
+		r.Get("/audit", a.handle(h.AuditLog))
+		r.Method(http.MethodGet, "/metrics", expvar.Handler())
 	})
 }
 
 // registerSystemRoutes registers system-level endpoints such as health checks
 func (a *API) registerSystemRoutes(r chi.Router) {
-	h := handlers.NewSystemHandler(a.Logger)
+	h := handlers.NewSystemHandler(a.Logger, a.AuthConfig.Keys)
 	r.Get("/healthz", a.handle(h.Health))
+	r.Get("/.well-known/jwks.json", a.handle(h.JWKS))
 }
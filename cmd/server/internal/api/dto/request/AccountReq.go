@@ -0,0 +1,7 @@
+package request
+
+// DeleteAccountReq represents the request payload for deleting the caller's account
+type DeleteAccountReq struct {
+	// Password is required unless the account only logs in through SSO.
+	Password string `json:"password"`
+}
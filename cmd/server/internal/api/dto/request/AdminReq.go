@@ -0,0 +1,6 @@
+package request
+
+// AnnouncementReq is the request body for a server-wide announcement
+type AnnouncementReq struct {
+	Text string `json:"text"`
+}
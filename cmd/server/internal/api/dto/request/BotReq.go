@@ -0,0 +1,17 @@
+package request
+
+// CreateBotReq is the request body for registering a bot account.
+type CreateBotReq struct {
+	Username string `json:"username"`
+}
+
+// CreateIncomingWebhookReq is the request body for creating an incoming webhook.
+// Username names the bot the webhook posts as.
+type CreateIncomingWebhookReq struct {
+	Username string `json:"username"`
+}
+
+// PostIncomingWebhookReq is the request body external services send to an incoming webhook.
+type PostIncomingWebhookReq struct {
+	Content string `json:"content"`
+}
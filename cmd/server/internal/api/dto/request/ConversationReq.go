@@ -0,0 +1,7 @@
+package request
+
+// SetConversationTTLReq is the request body for setting how many seconds new
+// direct messages in a conversation live. 0 turns it off.
+type SetConversationTTLReq struct {
+	Seconds int32 `json:"seconds"`
+}
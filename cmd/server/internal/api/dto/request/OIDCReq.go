@@ -0,0 +1,6 @@
+package request
+
+// DeviceTokenReq represents a poll for the tokens of a device login
+type DeviceTokenReq struct {
+	DeviceCode string `json:"device_code"`
+}
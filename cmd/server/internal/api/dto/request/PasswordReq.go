@@ -0,0 +1,18 @@
+package request
+
+// ChangePasswordReq represents the request payload for changing the caller's password
+type ChangePasswordReq struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ForgotPasswordReq represents a request for a password reset link
+type ForgotPasswordReq struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordReq represents the request payload for setting a new password with a reset token
+type ResetPasswordReq struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
@@ -0,0 +1,18 @@
+package request
+
+// UpdateProfileReq represents the request payload for editing the caller's profile.
+// Omitted fields keep their value; an empty string (or 0 for the avatar
+// attachment) clears the field.
+type UpdateProfileReq struct {
+	DisplayName        *string `json:"display_name"`
+	Bio                *string `json:"bio"`
+	AvatarAttachmentID *int64  `json:"avatar_attachment_id"`
+	AvatarURL          *string `json:"avatar_url"`
+	StatusText         *string `json:"status_text"`
+	StatusEmoji        *string `json:"status_emoji"`
+}
+
+// PrivacyReq represents the caller's privacy settings.
+type PrivacyReq struct {
+	DMPolicy string `json:"dm_policy"`
+}
@@ -0,0 +1,6 @@
+package request
+
+// RefreshReq represents the request payload for exchanging a refresh token
+type RefreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
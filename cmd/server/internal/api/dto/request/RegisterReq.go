@@ -1,7 +1,9 @@
 package request
 
 // RegisterReq represents the request payload for registering a new user.
+// Email is optional and only used for password resets.
 type RegisterReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
 }
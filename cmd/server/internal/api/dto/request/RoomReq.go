@@ -4,3 +4,24 @@ package request
 type CreateRoomReq struct {
 	Name string `json:"name"`
 }
+
+// SetRoomModeReq is the request body for changing a room's mode.
+type SetRoomModeReq struct {
+	Mode string `json:"mode"`
+}
+
+// SetSlowModeReq is the request body for configuring a room's slow mode.
+type SetSlowModeReq struct {
+	Seconds int32 `json:"seconds"`
+}
+
+// SetRetentionReq is the request body for configuring how long a room keeps
+// its messages. Null uses the server default; 0 keeps them forever.
+type SetRetentionReq struct {
+	Days *int32 `json:"days"`
+}
+
+// SetMemberRoleReq is the request body for changing a room member's role.
+type SetMemberRoleReq struct {
+	Role string `json:"role"`
+}
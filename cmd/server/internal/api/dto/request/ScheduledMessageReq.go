@@ -0,0 +1,19 @@
+package request
+
+import "time"
+
+// CreateScheduledMessageReq is the request body for scheduling a message.
+// Exactly one of RoomID and ToUserID is set.
+type CreateScheduledMessageReq struct {
+	RoomID   int64     `json:"room_id,omitempty"`
+	ToUserID int64     `json:"to_user_id,omitempty"`
+	Body     string    `json:"body"`
+	SendAt   time.Time `json:"send_at"`
+}
+
+// UpdateScheduledMessageReq is the request body for editing a scheduled
+// message. Omitted fields are left as they are.
+type UpdateScheduledMessageReq struct {
+	Body   *string    `json:"body"`
+	SendAt *time.Time `json:"send_at"`
+}
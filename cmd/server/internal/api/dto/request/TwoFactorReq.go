@@ -0,0 +1,12 @@
+package request
+
+// TOTPCodeReq represents a request carrying a TOTP or recovery code
+type TOTPCodeReq struct {
+	Code string `json:"code"`
+}
+
+// LoginTwoFactorReq represents the second step of a login with 2FA
+type LoginTwoFactorReq struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
@@ -0,0 +1,8 @@
+package request
+
+// CreateWebhookReq is the request body for subscribing a URL to room events.
+type CreateWebhookReq struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+}
@@ -0,0 +1,8 @@
+package response
+
+import "time"
+
+// AccountDeletionRes tells when a deactivated account will be deleted
+type AccountDeletionRes struct {
+	DeleteAfter time.Time `json:"delete_after"`
+}
@@ -0,0 +1,59 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AdminUserRes represents an account as seen by an instance admin
+type AdminUserRes struct {
+	ID            int64      `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email,omitempty"`
+	IsBot         bool       `json:"is_bot,omitempty"`
+	IsAdmin       bool       `json:"is_admin,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	DeleteAfter   *time.Time `json:"delete_after,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+// AdminUserListRes is a page of accounts. NextOffset is set when there are
+// more to fetch.
+type AdminUserListRes struct {
+	Users      []AdminUserRes `json:"users"`
+	NextOffset int32          `json:"next_offset,omitempty"`
+}
+
+// AdminStatsRes represents the live connections of the server
+type AdminStatsRes struct {
+	Connections int             `json:"connections"`
+	Bots        int             `json:"bots"`
+	Rooms       []RoomOnlineRes `json:"rooms"`
+}
+
+// RoomOnlineRes tells how many members of a room are connected
+type RoomOnlineRes struct {
+	RoomID int64 `json:"room_id"`
+	Online int   `json:"online"`
+}
+
+// AuditEventRes represents one entry of the audit log
+type AuditEventRes struct {
+	ID         int64           `json:"id"`
+	Action     string          `json:"action"`
+	ActorID    int64           `json:"actor_id,omitempty"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   int64           `json:"target_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditEventListRes is a page of audit events, newest first. NextBeforeID
+// is set when there are older events to fetch.
+type AuditEventListRes struct {
+	Events       []AuditEventRes `json:"events"`
+	NextBeforeID int64           `json:"next_before_id,omitempty"`
+}
@@ -0,0 +1,12 @@
+package response
+
+import "time"
+
+// AttachmentRes is the response body for an uploaded file.
+type AttachmentRes struct {
+	ID          int64     `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -1,9 +1,18 @@
 package response
 
-// AuthRes represents the response for a successful registration or login,
-// including the issued JWT access token and its expiration time.
+// AuthRes represents the response for a successful registration, login or
+// refresh, including the issued JWT access token and its expiration time,
+// plus the refresh token that replaces it when it expires.
+//
+// When the account has two-factor authentication, login answers with only
+// TwoFactorRequired, ChallengeToken and ExpiresAt (the challenge's); the
+// tokens come from POST /auth/login/2fa.
 type AuthRes struct {
-	User      UserRes `json:"user"`
-	Token     string  `json:"token"`
-	ExpiresAt int64   `json:"expires_at"`
+	User              UserRes `json:"user"`
+	Token             string  `json:"token,omitempty"`
+	ExpiresAt         int64   `json:"expires_at"`
+	RefreshToken      string  `json:"refresh_token,omitempty"`
+	RefreshExpiresAt  int64   `json:"refresh_expires_at,omitempty"`
+	TwoFactorRequired bool    `json:"two_factor_required,omitempty"`
+	ChallengeToken    string  `json:"challenge_token,omitempty"`
 }
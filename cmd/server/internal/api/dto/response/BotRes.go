@@ -0,0 +1,33 @@
+package response
+
+import "time"
+
+// BotRes is the response body for a bot account. Token is only included when
+// the bot is created or its token is rotated.
+type BotRes struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BotTokenRes is the response body for a token rotation.
+type BotTokenRes struct {
+	Token string `json:"token"`
+}
+
+// IncomingWebhookRes is the response body for an incoming webhook. Path is
+// only included on creation, since it embeds the secret token.
+type IncomingWebhookRes struct {
+	ID          int64     `json:"id"`
+	RoomID      int64     `json:"room_id"`
+	BotID       int64     `json:"bot_id"`
+	BotUsername string    `json:"bot_username"`
+	Path        string    `json:"path,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IncomingMessageRes is returned to services posting through an incoming webhook.
+type IncomingMessageRes struct {
+	MessageID int64 `json:"message_id"`
+}
@@ -2,7 +2,10 @@ package response
 
 // ConversationRes is the response body for a conversation.
 type ConversationRes struct {
-	ID            int64  `json:"id"`
-	PeerID        int64  `json:"peer_id"`
-	PeerUsername  string `json:"peer_username"`
+	ID           int64  `json:"id"`
+	PeerID       int64  `json:"peer_id"`
+	PeerUsername string `json:"peer_username"`
+	// DefaultTTLSeconds is how long new DMs live unless they set their own
+	// ttl_seconds; 0 keeps them.
+	DefaultTTLSeconds int32 `json:"default_ttl_seconds"`
 }
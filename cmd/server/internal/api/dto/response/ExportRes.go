@@ -0,0 +1,28 @@
+package response
+
+import "time"
+
+// ExportProfileRes is profile.json in a personal data export.
+type ExportProfileRes struct {
+	ProfileRes
+	Email    string `json:"email,omitempty"`
+	DMPolicy string `json:"dm_policy"`
+}
+
+// ExportMessageRes is a message in messages.json of a personal data export.
+// Exactly one of RoomID and ConversationID is set.
+type ExportMessageRes struct {
+	MessageRes
+	RoomID         int64  `json:"room_id,omitempty"`
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Kind           string `json:"kind"`
+}
+
+// ExportRoomRes is a room membership in rooms.json of a personal data export.
+type ExportRoomRes struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	Slug     string    `json:"slug"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
@@ -0,0 +1,18 @@
+package response
+
+// DeviceLoginRes represents a started device login (RFC 8628 style). The
+// client shows UserCode and VerificationURI and polls with DeviceCode.
+type DeviceLoginRes struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// OIDCLinkRes is the provider URL to open in a browser to link the account
+// with an SSO identity.
+type OIDCLinkRes struct {
+	URL string `json:"url"`
+}
@@ -0,0 +1,38 @@
+package response
+
+import "time"
+
+// ProfileRes represents a user's public profile. The avatar is either an
+// upload (fetched from /uploads/{avatar_attachment_id}) or an external URL.
+type ProfileRes struct {
+	ID                 int64     `json:"id"`
+	Username           string    `json:"username"`
+	IsBot              bool      `json:"is_bot,omitempty"`
+	DisplayName        string    `json:"display_name,omitempty"`
+	Bio                string    `json:"bio,omitempty"`
+	AvatarAttachmentID int64     `json:"avatar_attachment_id,omitempty"`
+	AvatarURL          string    `json:"avatar_url,omitempty"`
+	StatusText         string    `json:"status_text,omitempty"`
+	StatusEmoji        string    `json:"status_emoji,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// UserSearchRes is a page of user search results. NextOffset is set when
+// there are more results to fetch.
+type UserSearchRes struct {
+	Users      []ProfileRes `json:"users"`
+	NextOffset int32        `json:"next_offset,omitempty"`
+}
+
+// PrivacyRes represents the caller's privacy settings.
+type PrivacyRes struct {
+	DMPolicy string `json:"dm_policy"`
+}
+
+// BlockedUserRes represents a user the caller blocked.
+type BlockedUserRes struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	IsBot     bool      `json:"is_bot,omitempty"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
@@ -4,25 +4,70 @@ import "time"
 
 // RoomRes is the response body for a room.
 type RoomRes struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Slug            string    `json:"slug"`
+	Mode            string    `json:"mode"`
+	SlowModeSeconds int32     `json:"slow_mode_seconds"`
+	Topic           string    `json:"topic"`
+	RetentionDays   *int32    `json:"retention_days,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 // MessageRes is the base response body for a message.
 type MessageRes struct {
-	ID        int64     `json:"id"`
-	SenderID  int64     `json:"sender_id"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          int64           `json:"id"`
+	SenderID    int64           `json:"sender_id"`
+	Body        string          `json:"body"`
+	Attachments []AttachmentRes `json:"attachments,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// ExpiresAt is set on disappearing messages.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ForwardedFrom is set on forwards, Quote on replies quoting a message.
+	ForwardedFrom *ForwardedFromRes `json:"forwarded_from,omitempty"`
+	Quote         *QuoteRes         `json:"quote,omitempty"`
+}
+
+// ForwardedFromRes is where a forwarded message first came from. MessageID
+// is 0 once the original is gone; RoomID is 0 for DMs and deleted rooms.
+type ForwardedFromRes struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	RoomID         int64  `json:"room_id,omitempty"`
+	RoomName       string `json:"room_name,omitempty"`
+}
+
+// QuoteRes is the message quoted by a reply.
+type QuoteRes struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	Body           string `json:"body"`
 }
 
 // RoomMessageRes is the response body for a room message.
 type RoomMessageRes struct {
 	MessageRes
-	RoomID         int64  `json:"room_id"`
-	SenderUsername string `json:"sender_username"`
+	RoomID         int64    `json:"room_id"`
+	SenderUsername string   `json:"sender_username"`
+	SenderIsBot    bool     `json:"sender_is_bot,omitempty"`
+	Kind           string   `json:"kind"`
+	Poll           *PollRes `json:"poll,omitempty"`
+}
+
+// PollRes is the poll of a room message with its current tally. MyVotes
+// holds the options voted by the caller.
+type PollRes struct {
+	Options     []string   `json:"options"`
+	Multiple    bool       `json:"multiple,omitempty"`
+	Anonymous   bool       `json:"anonymous,omitempty"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+	Closed      bool       `json:"closed,omitempty"`
+	Counts      []int      `json:"counts"`
+	Voters      [][]string `json:"voters,omitempty"`
+	TotalVoters int        `json:"total_voters"`
+	MyVotes     []int      `json:"my_votes"`
 }
 
 // ConversationMessageRes is the response body for a direct message.
@@ -30,3 +75,31 @@ type ConversationMessageRes struct {
 	MessageRes
 	ConversationID int64 `json:"conversation_id"`
 }
+
+// PinnedMessageRes is the response body for a pinned room message.
+type PinnedMessageRes struct {
+	ID             int64     `json:"id"`
+	SenderID       int64     `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	SenderIsBot    bool      `json:"sender_is_bot,omitempty"`
+	Kind           string    `json:"kind"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+	PinnedBy       string    `json:"pinned_by"`
+	PinnedAt       time.Time `json:"pinned_at"`
+}
+
+// SavedMessageRes is the response body for a message in the caller's saved
+// list. One of RoomID and ConversationID is set.
+type SavedMessageRes struct {
+	ID             int64     `json:"id"`
+	RoomID         int64     `json:"room_id,omitempty"`
+	RoomName       string    `json:"room_name,omitempty"`
+	ConversationID int64     `json:"conversation_id,omitempty"`
+	SenderID       int64     `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	Kind           string    `json:"kind"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+	SavedAt        time.Time `json:"saved_at"`
+}
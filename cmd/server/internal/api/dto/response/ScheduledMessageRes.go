@@ -0,0 +1,16 @@
+package response
+
+import "time"
+
+// ScheduledMessageRes is the response body for a scheduled message.
+// LastError says why a failed message couldn't be sent.
+type ScheduledMessageRes struct {
+	ID        int64     `json:"id"`
+	RoomID    int64     `json:"room_id,omitempty"`
+	ToUserID  int64     `json:"to_user_id,omitempty"`
+	Body      string    `json:"body"`
+	SendAt    time.Time `json:"send_at"`
+	Status    string    `json:"status"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package response
+
+// TOTPEnrollRes represents a new TOTP secret, as text and as an otpauth URI
+// for authenticator apps
+type TOTPEnrollRes struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesRes represents the one-time recovery codes, shown only once
+type RecoveryCodesRes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
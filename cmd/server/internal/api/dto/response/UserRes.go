@@ -6,5 +6,6 @@ import "time"
 type UserRes struct {
 	ID        int64     `json:"id"`
 	Username  string    `json:"username"`
+	IsBot     bool      `json:"is_bot,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,31 @@
+package response
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookRes is the response body for a webhook subscription. Secret is only
+// included when the webhook is created.
+type WebhookRes struct {
+	ID        int64     `json:"id"`
+	RoomID    int64     `json:"room_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDeliveryRes is one entry of a webhook's delivery log.
+type WebhookDeliveryRes struct {
+	ID             int64           `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	LastStatusCode *int32          `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
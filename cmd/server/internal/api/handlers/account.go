@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+)
+
+// DeleteAccount deactivates the caller's account and schedules its deletion.
+// Logging in again before delete_after cancels it.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+	var req reqdto.DeleteAccountReq
+	// the body is optional for accounts without a password
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	deleteAfter, err := h.authSvc.DeleteAccount(r.Context(), claims, req, sessionMeta(r))
+	if err != nil {
+		var throttled *auth.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			return httpx.New(http.StatusTooManyRequests, "too_many_attempts", "too many failed attempts, try again later", err)
+		}
+		if errors.Is(err, auth.ErrWrongPassword) {
+			return httpx.New(http.StatusForbidden, "wrong_password", "password is wrong", err)
+		}
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusAccepted, resdto.AccountDeletionRes{DeleteAfter: deleteAfter})
+}
+
+// accountDeactivated is the response to logins into deactivated accounts.
+func accountDeactivated(err error) error {
+	return httpx.New(http.StatusForbidden, "account_deactivated", "this account has been deactivated", err)
+}
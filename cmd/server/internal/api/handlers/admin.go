@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/admin"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// AdminHandler handles instance administration requests.
+type AdminHandler struct {
+	logger   *slog.Logger
+	hub      *ws.Hub
+	authSvc  *auth.Service
+	adminSvc *admin.Service
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(l *slog.Logger, hub *ws.Hub, authSvc *auth.Service, adminSvc *admin.Service) *AdminHandler {
+	return &AdminHandler{logger: l, hub: hub, authSvc: authSvc, adminSvc: adminSvc}
+}
+
+// Unlock clears the failed login counter of a username, lifting any lockout.
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+	username := chi.URLParam(r, "username")
+	if username == "" {
+		return httpx.BadRequest("invalid_username", "username is required", nil)
+	}
+
+	cleared, err := h.authSvc.AdminUnlock(r.Context(), claims.UserID, username)
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		return httpx.New(http.StatusNotFound, "not_locked", "no failed logins recorded for this username", nil)
+	}
+	h.logger.Info("login lockout cleared", "username", username)
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// ListUsers handles listing every account, paged with limit and offset.
+// ?deactivated=true only lists deactivated and deleted accounts.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) error {
+	deactivated, _ := strconv.ParseBool(r.URL.Query().Get("deactivated"))
+	limit, offset := parseLimit(r), parseOffset(r)
+	// one extra row tells whether there is a next page
+	rows, err := h.adminSvc.ListUsers(r.Context(), deactivated, limit+1, offset)
+	if err != nil {
+		return err
+	}
+
+	res := response.AdminUserListRes{Users: make([]response.AdminUserRes, 0, len(rows))}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		res.NextOffset = offset + limit
+	}
+	for _, u := range rows {
+		res.Users = append(res.Users, toAdminUserRes(u))
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// DeactivateUser handles locking an account out and closing its connections.
+func (h *AdminHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) error {
+	claims, userID, err := adminUserPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.adminSvc.DeactivateUser(r.Context(), claims.UserID, userID); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// ReactivateUser handles lifting a deactivation.
+func (h *AdminHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) error {
+	claims, userID, err := adminUserPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.adminSvc.ReactivateUser(r.Context(), claims.UserID, userID); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// DeleteRoom handles deleting a room. Connected members get a room_deleted
+// frame.
+func (h *AdminHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	if err := h.adminSvc.DeleteRoom(r.Context(), claims.UserID, roomID); err != nil {
+		return err
+	}
+
+	msg, err := ws.NewMessage(ws.TypeRoomDeleted, ws.RoomDeletedPayload{RoomID: roomID})
+	if err != nil {
+		return err
+	}
+	h.hub.CloseRoom(roomID, msg)
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// DeleteMessage handles deleting a room message or DM. Whoever could see it
+// and is connected gets a message_deleted frame.
+func (h *AdminHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	messageID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_message_id", "invalid message id", err)
+	}
+
+	deleted, err := h.adminSvc.DeleteMessage(r.Context(), claims.UserID, messageID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := ws.NewMessage(ws.TypeMessageDeleted, ws.MessageDeletedPayload{
+		MessageID:      deleted.ID,
+		RoomID:         deleted.RoomID.Int64,
+		ConversationID: deleted.ConversationID.Int64,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.RoomID.Valid {
+		h.hub.BroadcastToRoom(deleted.RoomID.Int64, msg)
+	} else {
+		h.hub.BroadcastToUsers(deleted.ParticipantIds, msg)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// Stats handles reporting the live connections of the server.
+func (h *AdminHandler) Stats(w http.ResponseWriter, r *http.Request) error {
+	st := h.hub.Stats()
+
+	res := response.AdminStatsRes{
+		Connections: st.Connections,
+		Bots:        st.Bots,
+		Rooms:       make([]response.RoomOnlineRes, 0, len(st.RoomsOnline)),
+	}
+	for roomID, n := range st.RoomsOnline {
+		res.Rooms = append(res.Rooms, response.RoomOnlineRes{RoomID: roomID, Online: n})
+	}
+	// busiest rooms first
+	sort.Slice(res.Rooms, func(i, j int) bool {
+		if res.Rooms[i].Online != res.Rooms[j].Online {
+			return res.Rooms[i].Online > res.Rooms[j].Online
+		}
+		return res.Rooms[i].RoomID < res.Rooms[j].RoomID
+	})
+
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Announce handles sending an announcement frame to every connected client.
+func (h *AdminHandler) Announce(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	var req reqdto.AnnouncementReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+	text, err := h.adminSvc.Announce(r.Context(), claims.UserID, req.Text)
+	if err != nil {
+		if errors.Is(err, content.ErrTooLong) {
+			return httpx.BadRequest(ws.ErrCodeTooLong, fmt.Sprintf("announcement is longer than %d characters", admin.MaxAnnouncementRunes), err)
+		}
+		return httpx.BadRequest("missing_text", "text is required", err)
+	}
+
+	msg, err := ws.NewMessage(ws.TypeAnnouncement, ws.AnnouncementPayload{Text: text, From: claims.Username})
+	if err != nil {
+		return err
+	}
+	h.hub.BroadcastToAll(msg)
+	h.logger.Info("announcement sent", "admin_id", claims.UserID)
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// AuditLog handles querying the audit log, newest first. Filters: action,
+// actor_id, target_type, target_id, ip and since/until as RFC 3339 times.
+// Older pages are fetched with before_id.
+func (h *AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	f := admin.AuditFilter{
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		IP:         q.Get("ip"),
+	}
+	ids := []struct {
+		name string
+		dst  *int64
+	}{
+		{"actor_id", &f.ActorID},
+		{"target_id", &f.TargetID},
+		{"before_id", &f.BeforeID},
+	}
+	for _, p := range ids {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return httpx.BadRequest("invalid_"+p.name, p.name+" must be a positive integer", err)
+		}
+		*p.dst = n
+	}
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"since", &f.Since},
+		{"until", &f.Until},
+	}
+	for _, p := range times {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return httpx.BadRequest("invalid_"+p.name, p.name+" must be an RFC 3339 time", err)
+		}
+		*p.dst = t
+	}
+
+	limit := parseLimit(r)
+	// one extra row tells whether there is a next page
+	events, err := h.adminSvc.ListAuditEvents(r.Context(), f, limit+1)
+	if err != nil {
+		return err
+	}
+
+	res := response.AuditEventListRes{Events: make([]response.AuditEventRes, 0, len(events))}
+	if len(events) > int(limit) {
+		events = events[:limit]
+		res.NextBeforeID = events[len(events)-1].ID
+	}
+	for _, e := range events {
+		res.Events = append(res.Events, response.AuditEventRes{
+			ID:         e.ID,
+			Action:     e.Action,
+			ActorID:    e.ActorID.Int64,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID.Int64,
+			IP:         e.Ip,
+			RequestID:  e.RequestID,
+			Details:    e.Details,
+			CreatedAt:  e.CreatedAt.Time,
+		})
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// adminUserPath returns the caller's claims and the {userID} path param.
+func adminUserPath(r *http.Request) (*auth.Claims, int64, error) {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return nil, 0, httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return nil, 0, httpx.BadRequest("invalid_user_id", "invalid user id", err)
+	}
+	return claims, userID, nil
+}
+
+func toAdminUserRes(u dbstore.ListUsersRow) response.AdminUserRes {
+	res := response.AdminUserRes{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email.String,
+		IsBot:     u.IsBot,
+		IsAdmin:   u.IsAdmin,
+		CreatedAt: u.CreatedAt.Time,
+	}
+	if u.DeactivatedAt.Valid {
+		res.DeactivatedAt = &u.DeactivatedAt.Time
+	}
+	if u.DeleteAfter.Valid {
+		res.DeleteAfter = &u.DeleteAfter.Time
+	}
+	if u.DeletedAt.Valid {
+		res.DeletedAt = &u.DeletedAt.Time
+	}
+	return res
+}
@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
@@ -33,18 +37,21 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) error {
 		return httpx.BadRequest("invalid_json", "invalid json", err)
 	}
 
-	authRes, err := h.authSvc.Register(r.Context(), req)
+	authRes, err := h.authSvc.Register(r.Context(), req, sessionMeta(r))
 	if err != nil {
-		if errors.Is(err, auth.ErrUsernameTaken) {
+		switch {
+		case errors.Is(err, auth.ErrUsernameTaken):
 			return httpx.New(http.StatusConflict, "username_taken", "username already in use", err)
+		case errors.Is(err, auth.ErrEmailTaken):
+			return httpx.New(http.StatusConflict, "email_taken", "email already in use", err)
 		}
-		return err
+		return passwordError(err)
 	}
 
 	return httpx.JSON(w, http.StatusOK, authRes)
 }
 
-// Login handles user login requests (not yet implemented).
+// Login handles user login requests. Wrong usernames and wrong passwords get the same response.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Debug("login handler")
 
@@ -53,10 +60,276 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) error {
 		return httpx.BadRequest("invalid_json", "invalid json", err)
 	}
 
-	authRes, err := h.authSvc.Login(r.Context(), req)
+	authRes, err := h.authSvc.Login(r.Context(), req, sessionMeta(r))
+	if err != nil {
+		var throttled *auth.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			return httpx.New(http.StatusTooManyRequests, "too_many_attempts", "too many failed login attempts, try again later", err)
+		case errors.Is(err, auth.ErrInvalidCreds):
+			return httpx.New(http.StatusUnauthorized, "invalid_credentials", "invalid username or password", err)
+		case errors.Is(err, auth.ErrAccountDeactivated):
+			return accountDeactivated(err)
+		}
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, authRes)
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) error {
+	var req reqdto.RefreshReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+	if req.RefreshToken == "" {
+		return httpx.BadRequest("missing_refresh_token", "refresh_token is required", nil)
+	}
+
+	authRes, err := h.authSvc.Refresh(r.Context(), req.RefreshToken)
 	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefresh):
+			return httpx.New(http.StatusUnauthorized, "invalid_refresh_token", "invalid or expired refresh token", err)
+		case errors.Is(err, auth.ErrRefreshReused):
+			return httpx.New(http.StatusUnauthorized, "refresh_token_reused", "refresh token already used, session revoked", err)
+		}
 		return err
 	}
 
 	return httpx.JSON(w, http.StatusOK, authRes)
 }
+
+// Logout revokes the session of the access token used for the request.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	if err := h.authSvc.Logout(r.Context(), claims); err != nil {
+		return sessionError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// Sessions lists the caller's active sessions.
+func (h *AuthHandler) Sessions(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	sessions, err := h.authSvc.ListSessions(r.Context(), claims.UserID, claims.SessionID)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession revokes one of the caller's sessions and closes its WebSocket connections.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_session_id", "invalid session id", err)
+	}
+
+	if err := h.authSvc.RevokeSession(r.Context(), claims.UserID, sessionID); err != nil {
+		return sessionError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+func sessionError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrSessionNotFound):
+		return httpx.New(http.StatusNotFound, "session_not_found", "session not found", err)
+	case errors.Is(err, auth.ErrNoSession):
+		return httpx.BadRequest("no_session", "token is not tied to a session", err)
+	}
+	return err
+}
+
+// sessionMeta describes the client of r for the session list.
+func sessionMeta(r *http.Request) auth.SessionMeta {
+	// the realIP middleware has already replaced RemoteAddr with the client IP
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return auth.SessionMeta{UserAgent: r.UserAgent(), IP: ip}
+}
+
+// LoginTwoFactor completes a login for an account with 2FA.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	var req reqdto.LoginTwoFactorReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	authRes, err := h.authSvc.LoginTwoFactor(r.Context(), req.ChallengeToken, req.Code, sessionMeta(r))
+	if err != nil {
+		var throttled *auth.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			return httpx.New(http.StatusTooManyRequests, "too_many_attempts", "too many failed login attempts, try again later", err)
+		case errors.Is(err, auth.ErrInvalidChallenge):
+			return httpx.New(http.StatusUnauthorized, "invalid_challenge", "login challenge expired, log in again", err)
+		case errors.Is(err, auth.ErrAccountDeactivated):
+			return accountDeactivated(err)
+		}
+		return twoFactorError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, authRes)
+}
+
+// EnrollTOTP starts 2FA enrollment and returns the secret to add to an authenticator app.
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.authSvc.EnrollTOTP(r.Context(), claims.UserID, claims.Username)
+	if err != nil {
+		return twoFactorError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// ConfirmTOTP turns 2FA on after checking the first code and returns the recovery codes.
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+	var req reqdto.TOTPCodeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	res, err := h.authSvc.ConfirmTOTP(r.Context(), claims.UserID, req.Code)
+	if err != nil {
+		return twoFactorError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// DisableTOTP turns 2FA off; it needs a current code or a recovery code.
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+	var req reqdto.TOTPCodeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.authSvc.DisableTOTP(r.Context(), claims, req.Code, sessionMeta(r)); err != nil {
+		var throttled *auth.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			return httpx.New(http.StatusTooManyRequests, "too_many_attempts", "too many failed attempts, try again later", err)
+		}
+		return twoFactorError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+func twoFactorError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		return httpx.New(http.StatusConflict, "two_factor_enabled", "two-factor authentication is already enabled", err)
+	case errors.Is(err, auth.ErrTwoFactorNotEnrolled):
+		return httpx.New(http.StatusNotFound, "two_factor_not_enrolled", "two-factor authentication is not set up", err)
+	case errors.Is(err, auth.ErrInvalidCode):
+		return httpx.New(http.StatusUnauthorized, "invalid_code", "invalid or already used code", err)
+	}
+	return err
+}
+
+// ChangePassword changes the caller's password and signs out their other sessions.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+	var req reqdto.ChangePasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.authSvc.ChangePassword(r.Context(), claims, req, sessionMeta(r)); err != nil {
+		var throttled *auth.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((throttled.RetryAfter+time.Second-1)/time.Second)))
+			return httpx.New(http.StatusTooManyRequests, "too_many_attempts", "too many failed attempts, try again later", err)
+		}
+		return passwordError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// ForgotPassword mails a reset link if the email belongs to an account. The
+// response is the same either way.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	var req reqdto.ForgotPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.authSvc.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		return passwordError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// ResetPassword sets a new password with a token from a reset link.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	var req reqdto.ResetPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+	if req.Token == "" {
+		return httpx.BadRequest("missing_token", "token is required", nil)
+	}
+
+	if err := h.authSvc.ResetPassword(r.Context(), req); err != nil {
+		return passwordError(err)
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+func passwordError(err error) error {
+	var policy *auth.PolicyError
+	switch {
+	case errors.As(err, &policy):
+		return httpx.BadRequest(policy.Code, policy.Message, err)
+	case errors.Is(err, auth.ErrInvalidEmail):
+		return httpx.BadRequest("invalid_email", "invalid email address", err)
+	case errors.Is(err, auth.ErrWrongPassword):
+		return httpx.New(http.StatusForbidden, "wrong_password", "current password is wrong", err)
+	case errors.Is(err, auth.ErrInvalidResetToken):
+		return httpx.BadRequest("invalid_token", "invalid or expired reset token", err)
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/bot"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// maxIncomingBodyBytes caps the JSON body an incoming webhook accepts.
+const maxIncomingBodyBytes = 64 << 10
+
+// BotHandler handles bot accounts and incoming webhook requests.
+type BotHandler struct {
+	logger  *slog.Logger
+	hub     *ws.Hub
+	queries ws.Store
+	botSvc  *bot.Service
+}
+
+// NewBotHandler creates a new BotHandler. queries is used to post incoming
+// webhook messages through the hub.
+func NewBotHandler(l *slog.Logger, h *ws.Hub, q ws.Store, s *bot.Service) *BotHandler {
+	return &BotHandler{logger: l, hub: h, queries: q, botSvc: s}
+}
+
+// Create handles registering a bot owned by the caller. The token is only returned here.
+func (h *BotHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	var req reqdto.CreateBotReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	b, token, err := h.botSvc.Create(r.Context(), claims.UserID, req.Username)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusCreated, response.BotRes{
+		ID:        b.ID,
+		Username:  b.Username,
+		Token:     token,
+		CreatedAt: b.CreatedAt.Time,
+	})
+}
+
+// List handles listing the bots owned by the caller.
+func (h *BotHandler) List(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	bots, err := h.botSvc.List(r.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.BotRes, len(bots))
+	for i, b := range bots {
+		res[i] = response.BotRes{ID: b.ID, Username: b.Username, CreatedAt: b.CreatedAt.Time}
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// RotateToken handles revoking a bot's tokens and issuing a new one.
+func (h *BotHandler) RotateToken(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	botID, err := strconv.ParseInt(chi.URLParam(r, "botID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_bot_id", "invalid bot id", err)
+	}
+
+	token, err := h.botSvc.RotateToken(r.Context(), claims.UserID, botID)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, response.BotTokenRes{Token: token})
+}
+
+// CreateIncomingWebhook handles creating an incoming webhook for a room. The
+// path containing its token is only returned here.
+func (h *BotHandler) CreateIncomingWebhook(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	var req reqdto.CreateIncomingWebhookReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	hook, b, token, err := h.botSvc.CreateIncomingWebhook(r.Context(), roomID, claims.UserID, req.Username)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusCreated, response.IncomingWebhookRes{
+		ID:          hook.ID,
+		RoomID:      hook.RoomID,
+		BotID:       b.ID,
+		BotUsername: b.Username,
+		Path:        "/api/v1/hooks/" + token,
+		CreatedAt:   hook.CreatedAt.Time,
+	})
+}
+
+// ListIncomingWebhooks handles listing the incoming webhooks of a room.
+func (h *BotHandler) ListIncomingWebhooks(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	hooks, err := h.botSvc.ListIncomingWebhooks(r.Context(), roomID, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.IncomingWebhookRes, len(hooks))
+	for i, hook := range hooks {
+		res[i] = toIncomingWebhookRes(hook)
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// DeleteIncomingWebhook handles invalidating an incoming webhook.
+func (h *BotHandler) DeleteIncomingWebhook(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, webhookID, err := parseWebhookPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.botSvc.DeleteIncomingWebhook(r.Context(), roomID, claims.UserID, webhookID); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// PostIncoming handles a message sent by an external service to an incoming
+// webhook. The token in the path is the only credential.
+func (h *BotHandler) PostIncoming(w http.ResponseWriter, r *http.Request) error {
+	hook, err := h.botSvc.ResolveIncomingWebhook(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		return err
+	}
+
+	var req reqdto.PostIncomingWebhookReq
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIncomingBodyBytes)).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	sender := ws.Sender{ID: hook.BotID, Username: hook.BotUsername, IsBot: true}
+	msg, err := h.hub.PostRoomMessage(r.Context(), h.queries, h.logger, sender, hook.RoomID, req.Content)
+	if err != nil {
+		return postError(w, err)
+	}
+
+	return httpx.JSON(w, http.StatusCreated, response.IncomingMessageRes{MessageID: msg.MessageID})
+}
+
+// humanClaims returns the caller's claims, refusing bots: bots can't own bots
+// or create webhooks.
+func humanClaims(r *http.Request) (*auth.Claims, error) {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return nil, httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+	if claims.IsBot {
+		return nil, httpx.New(http.StatusForbidden, "forbidden", "bots can't manage bots", nil)
+	}
+	return claims, nil
+}
+
+// postError maps the errors of ws.Hub.PostRoomMessage to HTTP errors.
+func postError(w http.ResponseWriter, err error) error {
+	var retry *ws.RetryError
+	switch {
+	case errors.As(err, &retry):
+		w.Header().Set("Retry-After", strconv.Itoa(int((retry.Wait+time.Second-1)/time.Second)))
+		return httpx.New(http.StatusTooManyRequests, retry.Code, retry.Error(), err)
+	case errors.Is(err, content.ErrTooLong):
+		return httpx.BadRequest(ws.ErrCodeTooLong, "message is too long", err)
+	case errors.Is(err, ws.ErrInvalidMessage):
+		return httpx.BadRequest("invalid_message", "message is empty", err)
+	case errors.Is(err, ws.ErrNotMember):
+		return httpx.New(http.StatusForbidden, "not_member", "not a member of this room", err)
+	case errors.Is(err, room.ErrRoomArchived):
+		return httpx.New(http.StatusConflict, ws.ErrCodeRoomArchived, "room is archived", err)
+	case errors.Is(err, room.ErrRoomReadOnly):
+		return httpx.New(http.StatusForbidden, ws.ErrCodeRoomReadOnly, "only moderators can post in this room", err)
+	}
+	return err
+}
+
+func toIncomingWebhookRes(hook dbstore.ListIncomingWebhooksByRoomRow) response.IncomingWebhookRes {
+	return response.IncomingWebhookRes{
+		ID:          hook.ID,
+		RoomID:      hook.RoomID,
+		BotID:       hook.BotID,
+		BotUsername: hook.BotUsername,
+		CreatedAt:   hook.CreatedAt.Time,
+	}
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/conversation"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
@@ -16,11 +19,12 @@ import (
 type ConversationHandler struct {
 	logger          *slog.Logger
 	conversationSvc *conversation.Service
+	attachmentSvc   *attachment.Service
 }
 
 // NewConversationHandler creates a new ConversationHandler.
-func NewConversationHandler(l *slog.Logger, s *conversation.Service) *ConversationHandler {
-	return &ConversationHandler{logger: l, conversationSvc: s}
+func NewConversationHandler(l *slog.Logger, s *conversation.Service, as *attachment.Service) *ConversationHandler {
+	return &ConversationHandler{logger: l, conversationSvc: s, attachmentSvc: as}
 }
 
 // List handles listing all conversations for the authenticated user.
@@ -35,9 +39,10 @@ func (h *ConversationHandler) List(w http.ResponseWriter, r *http.Request) error
 	res := make([]response.ConversationRes, len(convs))
 	for i, c := range convs {
 		res[i] = response.ConversationRes{
-			ID:           c.ID,
-			PeerID:       c.PeerID,
-			PeerUsername: c.PeerUsername,
+			ID:                c.ID,
+			PeerID:            c.PeerID,
+			PeerUsername:      c.PeerUsername,
+			DefaultTTLSeconds: c.DefaultTtlSeconds,
 		}
 	}
 	return httpx.JSON(w, http.StatusOK, res)
@@ -55,17 +60,58 @@ func (h *ConversationHandler) ListMessages(w http.ResponseWriter, r *http.Reques
 		return err
 	}
 
+	ids := make([]int64, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+	}
+	atts, err := h.attachmentSvc.ListForMessages(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+	refs, err := h.conversationSvc.RefsForMessages(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
 	res := make([]response.ConversationMessageRes, len(msgs))
 	for i, m := range msgs {
 		res[i] = response.ConversationMessageRes{
 			MessageRes: response.MessageRes{
-				ID:        m.ID,
-				SenderID:  m.SenderID,
-				Body:      m.Body,
-				CreatedAt: m.CreatedAt.Time,
+				ID:          m.ID,
+				SenderID:    m.SenderID,
+				Body:        m.Body,
+				Attachments: toAttachmentResList(atts[m.ID]),
+				CreatedAt:   m.CreatedAt.Time,
+				ExpiresAt:   optionalTime(m.ExpiresAt),
 			},
 			ConversationID: m.ConversationID.Int64,
 		}
+		setRefs(&res[i].MessageRes, refs[m.ID])
 	}
 	return httpx.JSON(w, http.StatusOK, res)
 }
+
+// SetTTL handles setting the default TTL of new direct messages in a
+// conversation the caller is part of.
+func (h *ConversationHandler) SetTTL(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	conversationID, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_conversation_id", "invalid conversation id", err)
+	}
+
+	var req reqdto.SetConversationTTLReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.conversationSvc.SetDefaultTTL(r.Context(), conversationID, claims.UserID, req.Seconds); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
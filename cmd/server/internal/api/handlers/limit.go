@@ -19,3 +19,15 @@ func parseLimit(r *http.Request) int32 {
 	}
 	return defaultMessageLimit
 }
+
+// parseOffset reads the offset query param used by ranked listings that
+// can't page by id. Invalid or negative values count as 0.
+func parseOffset(r *http.Request) int32 {
+	if o := r.URL.Query().Get("offset"); o != "" {
+		n, err := strconv.ParseInt(o, 10, 32)
+		if err == nil && n > 0 {
+			return int32(n)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// Pins handles listing the pinned messages of a room.
+func (h *RoomHandler) Pins(w http.ResponseWriter, r *http.Request) error {
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	pins, err := h.roomSvc.ListPins(r.Context(), roomID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.PinnedMessageRes, len(pins))
+	for i, p := range pins {
+		res[i] = response.PinnedMessageRes{
+			ID:             p.ID,
+			SenderID:       p.SenderID,
+			SenderUsername: p.SenderUsername,
+			SenderIsBot:    p.SenderIsBot,
+			Kind:           p.Kind,
+			Body:           p.Body,
+			CreatedAt:      p.CreatedAt.Time,
+			PinnedBy:       p.PinnedByUsername,
+			PinnedAt:       p.PinnedAt.Time,
+		}
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Pin handles pinning a room message. Connected members get a
+// message_pinned frame.
+func (h *RoomHandler) Pin(w http.ResponseWriter, r *http.Request) error {
+	return h.setPinned(w, r, true)
+}
+
+// Unpin handles unpinning a room message. Connected members get a
+// message_pinned frame with pinned false.
+func (h *RoomHandler) Unpin(w http.ResponseWriter, r *http.Request) error {
+	return h.setPinned(w, r, false)
+}
+
+func (h *RoomHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+	messageID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_message_id", "invalid message id", err)
+	}
+
+	if pinned {
+		err = h.roomSvc.Pin(r.Context(), roomID, claims.UserID, messageID)
+	} else {
+		err = h.roomSvc.Unpin(r.Context(), roomID, claims.UserID, messageID)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg, err := ws.NewMessage(ws.TypeMessagePinned, ws.MessagePinnedPayload{
+		MessageID: messageID,
+		RoomID:    roomID,
+		Pinned:    pinned,
+		By:        claims.Username,
+	})
+	if err != nil {
+		return err
+	}
+	h.hub.BroadcastToRoom(roomID, msg)
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
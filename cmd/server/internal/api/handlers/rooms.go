@@ -5,30 +5,43 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/forward"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/poll"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
 )
 
 // RoomHandler handles room-related HTTP requests.
 type RoomHandler struct {
-	logger  *slog.Logger
-	hub     *ws.Hub
-	roomSvc *room.Service
+	logger        *slog.Logger
+	hub           *ws.Hub
+	roomSvc       *room.Service
+	attachmentSvc *attachment.Service
 }
 
 // NewRoomHandler creates a new RoomHandler with the given queries and logger.
-func NewRoomHandler(l *slog.Logger, h *ws.Hub, s *room.Service) *RoomHandler {
-	return &RoomHandler{logger: l, hub: h, roomSvc: s}
+func NewRoomHandler(l *slog.Logger, h *ws.Hub, s *room.Service, as *attachment.Service) *RoomHandler {
+	return &RoomHandler{logger: l, hub: h, roomSvc: s, attachmentSvc: as}
 }
 
-// Create handles room creation requests.
+// Create handles room creation requests. The creator becomes the room owner.
 func (h *RoomHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
 	var req reqdto.CreateRoomReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return httpx.BadRequest("invalid_json", "invalid json", err)
@@ -37,34 +50,26 @@ func (h *RoomHandler) Create(w http.ResponseWriter, r *http.Request) error {
 		return httpx.BadRequest("missing_name", "room name is required", nil)
 	}
 
-	room, err := h.roomSvc.Create(r.Context(), req.Name)
+	room, err := h.roomSvc.Create(r.Context(), req.Name, claims.UserID)
 	if err != nil {
 		return err
 	}
 
-	return httpx.JSON(w, http.StatusCreated, response.RoomRes{
-		ID:        room.ID,
-		Name:      room.Name,
-		Slug:      room.Slug,
-		CreatedAt: room.CreatedAt.Time,
-	})
+	return httpx.JSON(w, http.StatusCreated, toRoomRes(room))
 }
 
-// List handles listing all rooms.
+// List handles listing all rooms. Archived rooms are only included with ?include_archived=true.
 func (h *RoomHandler) List(w http.ResponseWriter, r *http.Request) error {
-	rooms, err := h.roomSvc.ListRooms(r.Context())
+	includeArchived, _ := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+
+	rooms, err := h.roomSvc.ListRooms(r.Context(), includeArchived)
 	if err != nil {
 		return err
 	}
 
 	res := make([]response.RoomRes, len(rooms))
 	for i, room := range rooms {
-		res[i] = response.RoomRes{
-			ID:        room.ID,
-			Name:      room.Name,
-			Slug:      room.Slug,
-			CreatedAt: room.CreatedAt.Time,
-		}
+		res[i] = toRoomRes(room)
 	}
 	return httpx.JSON(w, http.StatusOK, res)
 }
@@ -77,13 +82,126 @@ func (h *RoomHandler) GetBySlug(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	return httpx.JSON(w, http.StatusOK,
-		response.RoomRes{
-			ID:        room.ID,
-			Name:      room.Name,
-			Slug:      room.Slug,
-			CreatedAt: room.CreatedAt.Time,
-		})
+	return httpx.JSON(w, http.StatusOK, toRoomRes(room))
+}
+
+// SetMode handles changing a room's mode (normal, announcement or archived).
+func (h *RoomHandler) SetMode(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	var req reqdto.SetRoomModeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	room, err := h.roomSvc.SetMode(r.Context(), roomID, claims.UserID, req.Mode)
+	if err != nil {
+		return err
+	}
+
+	return h.broadcastRoomUpdated(w, room)
+}
+
+// SetSlowMode handles configuring the per-member message interval of a room.
+func (h *RoomHandler) SetSlowMode(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	var req reqdto.SetSlowModeReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	room, err := h.roomSvc.SetSlowMode(r.Context(), roomID, claims.UserID, req.Seconds)
+	if err != nil {
+		return err
+	}
+
+	return h.broadcastRoomUpdated(w, room)
+}
+
+// SetRetention handles configuring how long a room keeps its messages.
+func (h *RoomHandler) SetRetention(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	var req reqdto.SetRetentionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	room, err := h.roomSvc.SetRetention(r.Context(), roomID, claims.UserID, req.Days)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, toRoomRes(room))
+}
+
+// broadcastRoomUpdated notifies connected members of new room settings and writes the room as response.
+func (h *RoomHandler) broadcastRoomUpdated(w http.ResponseWriter, room dbstore.Room) error {
+	msg, err := ws.NewMessage(ws.TypeRoomUpdated, ws.RoomUpdatedPayload{
+		RoomID:          room.ID,
+		Mode:            room.Mode,
+		SlowModeSeconds: room.SlowModeSeconds,
+		Topic:           room.Topic,
+	})
+	if err != nil {
+		return err
+	}
+	h.hub.BroadcastToRoom(room.ID, msg)
+
+	return httpx.JSON(w, http.StatusOK, toRoomRes(room))
+}
+
+// SetMemberRole handles promoting or demoting a room member.
+func (h *RoomHandler) SetMemberRole(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_user_id", "invalid user id", err)
+	}
+
+	var req reqdto.SetMemberRoleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.roomSvc.SetMemberRole(r.Context(), roomID, claims.UserID, userID, req.Role); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
 }
 
 // Join handles joining a room.
@@ -104,6 +222,11 @@ func (h *RoomHandler) Join(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	h.hub.UpdateUserRoomState(roomID, claims.UserID, true)
+	h.hub.PublishRoomEvent(r.Context(), roomID, webhook.EventMemberJoined, webhook.MemberJoinedData{
+		RoomID:   roomID,
+		UserID:   claims.UserID,
+		Username: claims.Username,
+	})
 
 	return httpx.JSON(w, http.StatusNoContent, nil)
 }
@@ -132,6 +255,10 @@ func (h *RoomHandler) Leave(w http.ResponseWriter, r *http.Request) error {
 
 // Messages handles fetching paginated message history for a room.
 func (h *RoomHandler) Messages(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
 	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
 	if err != nil {
 		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
@@ -142,18 +269,103 @@ func (h *RoomHandler) Messages(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	ids := make([]int64, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+	}
+	atts, err := h.attachmentSvc.ListForMessages(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+	polls, err := h.roomSvc.PollsForMessages(r.Context(), ids, claims.UserID)
+	if err != nil {
+		return err
+	}
+	refs, err := h.roomSvc.RefsForMessages(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
 	res := make([]response.RoomMessageRes, len(msgs))
 	for i, m := range msgs {
 		res[i] = response.RoomMessageRes{
 			MessageRes: response.MessageRes{
-				ID:        m.ID,
-				SenderID:  m.SenderID,
-				Body:      m.Body,
-				CreatedAt: m.CreatedAt.Time,
+				ID:          m.ID,
+				SenderID:    m.SenderID,
+				Body:        m.Body,
+				Attachments: toAttachmentResList(atts[m.ID]),
+				CreatedAt:   m.CreatedAt.Time,
+				ExpiresAt:   optionalTime(m.ExpiresAt),
 			},
 			RoomID:         m.RoomID.Int64,
 			SenderUsername: m.SenderUsername,
+			SenderIsBot:    m.SenderIsBot,
+			Kind:           m.Kind,
+		}
+		if p, ok := polls[m.ID]; ok {
+			res[i].Poll = toPollRes(p, now)
 		}
+		setRefs(&res[i].MessageRes, refs[m.ID])
 	}
 	return httpx.JSON(w, http.StatusOK, res)
 }
+
+// setRefs fills in the forward origin and quote of a message, if any.
+func setRefs(res *response.MessageRes, refs forward.Refs) {
+	if f := refs.Forward; f != nil {
+		res.ForwardedFrom = &response.ForwardedFromRes{
+			MessageID:      f.MessageID,
+			SenderID:       f.SenderID,
+			SenderUsername: f.SenderUsername,
+			RoomID:         f.RoomID,
+			RoomName:       f.RoomName,
+		}
+	}
+	if q := refs.Quote; q != nil {
+		res.Quote = &response.QuoteRes{
+			MessageID:      q.MessageID,
+			SenderID:       q.SenderID,
+			SenderUsername: q.SenderUsername,
+			Body:           q.Body,
+		}
+	}
+}
+
+func toPollRes(p poll.Result, now time.Time) *response.PollRes {
+	return &response.PollRes{
+		Options:     p.Options,
+		Multiple:    p.Multiple,
+		Anonymous:   p.Anonymous,
+		ClosesAt:    optionalTime(p.ClosesAt),
+		Closed:      poll.Closed(p.Poll, now),
+		Counts:      p.Counts,
+		Voters:      p.Voters,
+		TotalVoters: p.TotalVoters,
+		MyVotes:     p.Mine,
+	}
+}
+
+func toRoomRes(room dbstore.Room) response.RoomRes {
+	res := response.RoomRes{
+		ID:              room.ID,
+		Name:            room.Name,
+		Slug:            room.Slug,
+		Mode:            room.Mode,
+		SlowModeSeconds: room.SlowModeSeconds,
+		Topic:           room.Topic,
+		CreatedAt:       room.CreatedAt.Time,
+	}
+	if room.RetentionDays.Valid {
+		res.RetentionDays = &room.RetentionDays.Int32
+	}
+	return res
+}
+
+// optionalTime returns nil for a NULL timestamp.
+func optionalTime(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+)
+
+// Saved handles listing the caller's saved messages.
+func (h *UserHandler) Saved(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	saved, err := h.userSvc.ListSaved(r.Context(), claims.UserID, parseLimit(r))
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.SavedMessageRes, len(saved))
+	for i, m := range saved {
+		res[i] = response.SavedMessageRes{
+			ID:             m.ID,
+			RoomID:         m.RoomID.Int64,
+			RoomName:       m.RoomName.String,
+			ConversationID: m.ConversationID.Int64,
+			SenderID:       m.SenderID,
+			SenderUsername: m.SenderUsername,
+			Kind:           m.Kind,
+			Body:           m.Body,
+			CreatedAt:      m.CreatedAt.Time,
+			SavedAt:        m.SavedAt.Time,
+		}
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// SaveMessage handles adding a message to the caller's saved list.
+func (h *UserHandler) SaveMessage(w http.ResponseWriter, r *http.Request) error {
+	claims, messageID, err := savedPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.userSvc.SaveMessage(r.Context(), claims.UserID, messageID); err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnsaveMessage handles removing a message from the caller's saved list.
+func (h *UserHandler) UnsaveMessage(w http.ResponseWriter, r *http.Request) error {
+	claims, messageID, err := savedPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.userSvc.UnsaveMessage(r.Context(), claims.UserID, messageID); err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// savedPath returns the caller's claims and the {messageID} path param.
+func savedPath(r *http.Request) (*auth.Claims, int64, error) {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return nil, 0, httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+	messageID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		return nil, 0, httpx.BadRequest("invalid_message_id", "invalid message id", err)
+	}
+	return claims, messageID, nil
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/schedule"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// ScheduledMessageHandler handles requests about the caller's scheduled messages.
+type ScheduledMessageHandler struct {
+	logger      *slog.Logger
+	scheduleSvc *schedule.Service
+}
+
+// NewScheduledMessageHandler creates a new ScheduledMessageHandler.
+func NewScheduledMessageHandler(l *slog.Logger, s *schedule.Service) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{logger: l, scheduleSvc: s}
+}
+
+// Create handles scheduling a room message or DM.
+func (h *ScheduledMessageHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	var req reqdto.CreateScheduledMessageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	msg, err := h.scheduleSvc.Create(r.Context(), claims.UserID, req.RoomID, req.ToUserID, req.Body, req.SendAt)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusCreated, toScheduledMessageRes(msg))
+}
+
+// List handles listing the caller's pending and failed scheduled messages.
+func (h *ScheduledMessageHandler) List(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	msgs, err := h.scheduleSvc.List(r.Context(), claims.UserID, parseLimit(r))
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.ScheduledMessageRes, len(msgs))
+	for i, m := range msgs {
+		res[i] = toScheduledMessageRes(m)
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Update handles editing the body or send time of a scheduled message.
+func (h *ScheduledMessageHandler) Update(w http.ResponseWriter, r *http.Request) error {
+	claims, id, err := scheduledPath(r)
+	if err != nil {
+		return err
+	}
+
+	var req reqdto.UpdateScheduledMessageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	msg, err := h.scheduleSvc.Update(r.Context(), claims.UserID, id, req.Body, req.SendAt)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, toScheduledMessageRes(msg))
+}
+
+// Cancel handles deleting a scheduled message before it is sent.
+func (h *ScheduledMessageHandler) Cancel(w http.ResponseWriter, r *http.Request) error {
+	claims, id, err := scheduledPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.scheduleSvc.Cancel(r.Context(), claims.UserID, id); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// scheduledPath returns the caller's claims and the {scheduledID} path param.
+func scheduledPath(r *http.Request) (*auth.Claims, int64, error) {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return nil, 0, httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "scheduledID"), 10, 64)
+	if err != nil {
+		return nil, 0, httpx.BadRequest("invalid_scheduled_id", "invalid scheduled message id", err)
+	}
+	return claims, id, nil
+}
+
+func toScheduledMessageRes(m dbstore.ScheduledMessage) response.ScheduledMessageRes {
+	return response.ScheduledMessageRes{
+		ID:        m.ID,
+		RoomID:    m.RoomID.Int64,
+		ToUserID:  m.ToUserID.Int64,
+		Body:      m.Body,
+		SendAt:    m.SendAt.Time,
+		Status:    m.Status,
+		LastError: m.LastError.String,
+		CreatedAt: m.CreatedAt.Time,
+	}
+}
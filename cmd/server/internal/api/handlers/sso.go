@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+)
+
+// OIDCLogin redirects the browser to the SSO provider. With ?user_code= it
+// continues a device login started by the TUI.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	target, err := h.authSvc.StartOIDCLogin(r.Context(), r.URL.Query().Get("user_code"))
+	if err != nil {
+		return ssoError(err)
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+	return nil
+}
+
+// OIDCCallback is where the SSO provider sends the browser back. Browser
+// logins get tokens, or a 2FA challenge, as JSON; device and link logins
+// get a page saying it worked.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return httpx.BadRequest("sso_failed", "single sign-on failed: "+e, nil)
+	}
+	if q.Get("code") == "" || q.Get("state") == "" {
+		return httpx.BadRequest("invalid_callback", "code and state are required", nil)
+	}
+
+	authRes, outcome, err := h.authSvc.FinishOIDCLogin(r.Context(), q.Get("code"), q.Get("state"), sessionMeta(r))
+	if err != nil {
+		return ssoError(err)
+	}
+
+	var page string
+	switch outcome {
+	case auth.OIDCDevice:
+		page = fmt.Sprintf("Signed in as %s. You can close this page and go back to the terminal.\n", authRes.User.Username)
+	case auth.OIDCLinked:
+		page = fmt.Sprintf("Your SSO account is now linked to %s. You can close this page.\n", authRes.User.Username)
+	default:
+		return httpx.JSON(w, http.StatusOK, authRes)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.WriteString(w, page)
+	return err
+}
+
+// OIDCLink returns the provider URL that links the caller's account with an
+// SSO identity once they log in there.
+func (h *AuthHandler) OIDCLink(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	target, err := h.authSvc.StartOIDCLink(r.Context(), claims.UserID)
+	if err != nil {
+		return ssoError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, response.OIDCLinkRes{URL: target})
+}
+
+// DeviceLogin starts an SSO login for a client without a browser.
+func (h *AuthHandler) DeviceLogin(w http.ResponseWriter, r *http.Request) error {
+	res, err := h.authSvc.StartDeviceLogin(r.Context())
+	if err != nil {
+		return ssoError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// DeviceToken returns the tokens of a device login once it was approved in
+// the browser, and 400 authorization_pending before that.
+func (h *AuthHandler) DeviceToken(w http.ResponseWriter, r *http.Request) error {
+	var req reqdto.DeviceTokenReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+	if req.DeviceCode == "" {
+		return httpx.BadRequest("missing_device_code", "device_code is required", nil)
+	}
+
+	authRes, err := h.authSvc.PollDeviceLogin(r.Context(), req.DeviceCode, sessionMeta(r))
+	if err != nil {
+		return ssoError(err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, authRes)
+}
+
+func ssoError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrOIDCDisabled):
+		return httpx.New(http.StatusNotFound, "sso_disabled", "single sign-on is not configured", err)
+	case errors.Is(err, auth.ErrInvalidOIDCState):
+		return httpx.BadRequest("invalid_state", "login expired or already used, start again", err)
+	case errors.Is(err, auth.ErrInvalidUserCode):
+		return httpx.BadRequest("invalid_user_code", "invalid or expired code", err)
+	case errors.Is(err, auth.ErrSSOFailed):
+		return httpx.New(http.StatusUnauthorized, "sso_failed", "single sign-on failed", err)
+	case errors.Is(err, auth.ErrSignupDisabled):
+		return httpx.New(http.StatusForbidden, "signup_disabled", "no account is linked to this identity, log in and link it first", err)
+	case errors.Is(err, auth.ErrIdentityLinked):
+		return httpx.New(http.StatusConflict, "identity_linked", "this identity is linked to another account", err)
+	case errors.Is(err, auth.ErrAuthorizationPending):
+		return httpx.BadRequest("authorization_pending", "waiting for the login to be approved in the browser", err)
+	case errors.Is(err, auth.ErrInvalidDeviceCode):
+		return httpx.BadRequest("expired_token", "device login expired or already used", err)
+	case errors.Is(err, auth.ErrAccountDeactivated):
+		return accountDeactivated(err)
+	}
+	return err
+}
@@ -3,16 +3,21 @@ package handlers
 import (
 	"log/slog"
 	"net/http"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
 )
 
 // SystemHandler handles system-related HTTP requests such as health checks.
 type SystemHandler struct {
 	logger *slog.Logger
+	keys   *auth.KeySet
 }
 
-// NewSystemHandler creates a new SystemHandler with the given logger.
-func NewSystemHandler(logger *slog.Logger) *SystemHandler {
-	return &SystemHandler{logger: logger}
+// NewSystemHandler creates a new SystemHandler with the given logger. keys
+// is published as the JWKS and may be nil when tokens are signed with HS256.
+func NewSystemHandler(logger *slog.Logger, keys *auth.KeySet) *SystemHandler {
+	return &SystemHandler{logger: logger, keys: keys}
 }
 
 // Health responds with a simple 200 OK and "ok" body to indicate the service is healthy.
@@ -24,3 +29,14 @@ func (h *SystemHandler) Health(w http.ResponseWriter, _ *http.Request) error {
 	}
 	return nil
 }
+
+// JWKS serves the public keys that verify access tokens. It is empty when
+// tokens are signed with the shared HS256 secret.
+func (h *SystemHandler) JWKS(w http.ResponseWriter, _ *http.Request) error {
+	doc := auth.JWKS{Keys: []auth.JWK{}}
+	if h.keys != nil {
+		doc = h.keys.JWKS()
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return httpx.JSON(w, http.StatusOK, doc)
+}
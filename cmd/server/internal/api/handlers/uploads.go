@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// multipartOverhead is the slack allowed on top of the file size for multipart headers.
+const multipartOverhead = 1 << 20
+
+// transferTimeout replaces the server-wide read/write deadlines for file transfers,
+// which are too short for large files on slow links.
+const transferTimeout = time.Minute
+
+// UploadHandler handles file upload and download requests.
+type UploadHandler struct {
+	logger        *slog.Logger
+	attachmentSvc *attachment.Service
+}
+
+// NewUploadHandler creates a new UploadHandler.
+func NewUploadHandler(l *slog.Logger, s *attachment.Service) *UploadHandler {
+	return &UploadHandler{logger: l, attachmentSvc: s}
+}
+
+// Upload handles a multipart/form-data upload with a "file" field, streaming it to the blob store.
+func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout))
+	r.Body = http.MaxBytesReader(w, r.Body, h.attachmentSvc.MaxBytes()+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return httpx.BadRequest("invalid_multipart", "expected multipart/form-data body", err)
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return httpx.BadRequest("missing_file", "multipart field \"file\" is required", nil)
+		}
+		if err != nil {
+			return uploadReadError(err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		a, err := h.attachmentSvc.Upload(r.Context(), claims.UserID, part.FileName(), part)
+		if err != nil {
+			return uploadReadError(err)
+		}
+		return httpx.JSON(w, http.StatusCreated, toAttachmentRes(a))
+	}
+}
+
+// Download streams an attachment if the authenticated user is allowed to see it.
+func (h *UploadHandler) Download(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_attachment_id", "invalid attachment id", err)
+	}
+
+	a, rc, err := h.attachmentSvc.Open(r.Context(), claims.UserID, attachmentID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout))
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, rc); err != nil {
+		// headers are already sent, so only log
+		h.logger.Warn("attachment download interrupted", "attachment_id", a.ID, "error", err)
+	}
+	return nil
+}
+
+func uploadReadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return httpx.New(http.StatusRequestEntityTooLarge, "file_too_large",
+			fmt.Sprintf("upload exceeds %d bytes", maxErr.Limit-multipartOverhead), err)
+	}
+	return err
+}
+
+func toAttachmentRes(a dbstore.Attachment) response.AttachmentRes {
+	return response.AttachmentRes{
+		ID:          a.ID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		SizeBytes:   a.SizeBytes,
+		CreatedAt:   a.CreatedAt.Time,
+	}
+}
+
+// toAttachmentResList converts the attachments of one message, returning nil when there are none.
+func toAttachmentResList(atts []dbstore.Attachment) []response.AttachmentRes {
+	if len(atts) == 0 {
+		return nil
+	}
+	res := make([]response.AttachmentRes, len(atts))
+	for i, a := range atts {
+		res[i] = toAttachmentRes(a)
+	}
+	return res
+}
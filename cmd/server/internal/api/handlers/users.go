@@ -1,23 +1,35 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/user"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
 )
 
 // UserHandler handles user-related HTTP requests.
 type UserHandler struct {
 	logger  *slog.Logger
+	hub     *ws.Hub
 	userSvc *user.Service
 }
 
 // NewUserHandler creates a new UserHandler.
-func NewUserHandler(l *slog.Logger, s *user.Service) *UserHandler {
-	return &UserHandler{logger: l, userSvc: s}
+func NewUserHandler(l *slog.Logger, hub *ws.Hub, s *user.Service) *UserHandler {
+	return &UserHandler{logger: l, hub: hub, userSvc: s}
 }
 
 // GetByUsername handles searching a user by username query param.
@@ -35,6 +47,258 @@ func (h *UserHandler) GetByUsername(w http.ResponseWriter, r *http.Request) erro
 	return httpx.JSON(w, http.StatusOK, response.UserRes{
 		ID:        u.ID,
 		Username:  u.Username,
+		IsBot:     u.IsBot,
 		CreatedAt: u.CreatedAt.Time,
 	})
 }
+
+// Search handles the user directory: ?q= finds users by username or display
+// name prefix and similarity, paged with limit and offset. ?username= keeps
+// doing an exact lookup.
+func (h *UserHandler) Search(w http.ResponseWriter, r *http.Request) error {
+	if r.URL.Query().Has("username") {
+		return h.GetByUsername(w, r)
+	}
+
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	limit, offset := parseLimit(r), parseOffset(r)
+	// one extra row tells whether there is a next page
+	rows, err := h.userSvc.Search(r.Context(), claims.UserID, r.URL.Query().Get("q"), limit+1, offset)
+	if err != nil {
+		return err
+	}
+
+	res := response.UserSearchRes{Users: make([]response.ProfileRes, 0, len(rows))}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		res.NextOffset = offset + limit
+	}
+	for _, u := range rows {
+		res.Users = append(res.Users, response.ProfileRes{
+			ID:          u.ID,
+			Username:    u.Username,
+			IsBot:       u.IsBot,
+			DisplayName: u.DisplayName,
+			StatusText:  u.StatusText,
+			StatusEmoji: u.StatusEmoji,
+			CreatedAt:   u.CreatedAt.Time,
+		})
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Get handles fetching a user's public profile by id.
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_user_id", "invalid user id", err)
+	}
+
+	p, err := h.userSvc.GetProfile(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, toProfileRes(p))
+}
+
+// Me handles fetching the caller's own profile.
+func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	p, err := h.userSvc.GetProfile(r.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusOK, toProfileRes(p))
+}
+
+// Export sends the caller's personal data as a zip of JSON files.
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) error {
+	claims, err := humanClaims(r)
+	if err != nil {
+		return err
+	}
+
+	// built in memory so a failure can still be reported as an error
+	var buf bytes.Buffer
+	if err := h.userSvc.Export(r.Context(), claims.UserID, &buf); err != nil {
+		return err
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout))
+	name := fmt.Sprintf("%s-export-%s.zip", claims.Username, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := buf.WriteTo(w); err != nil {
+		h.logger.Warn("data export interrupted", "user_id", claims.UserID, "error", err)
+	}
+	return nil
+}
+
+// UpdateMe handles editing the caller's profile. The new profile is pushed to
+// everyone sharing a room or a conversation with the caller.
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	var req reqdto.UpdateProfileReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	p, err := h.userSvc.UpdateProfile(r.Context(), claims.UserID, req)
+	if err != nil {
+		return err
+	}
+
+	if err := h.broadcastProfileUpdated(r, p); err != nil {
+		// the profile is saved; peers will see it the next time they fetch it
+		h.logger.Warn("failed to broadcast profile update", "user_id", p.ID, "error", err)
+	}
+
+	return httpx.JSON(w, http.StatusOK, toProfileRes(p))
+}
+
+// Blocks handles listing the users the caller blocked.
+func (h *UserHandler) Blocks(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	blocked, err := h.userSvc.ListBlocked(r.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.BlockedUserRes, len(blocked))
+	for i, u := range blocked {
+		res[i] = response.BlockedUserRes{
+			ID:        u.ID,
+			Username:  u.Username,
+			IsBot:     u.IsBot,
+			BlockedAt: u.CreatedAt.Time,
+		}
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Block handles blocking a user.
+func (h *UserHandler) Block(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_user_id", "invalid user id", err)
+	}
+
+	if err := h.userSvc.Block(r.Context(), claims.UserID, userID); err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// Unblock handles lifting a block.
+func (h *UserHandler) Unblock(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_user_id", "invalid user id", err)
+	}
+
+	if err := h.userSvc.Unblock(r.Context(), claims.UserID, userID); err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// Privacy handles reading the caller's privacy settings.
+func (h *UserHandler) Privacy(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	policy, err := h.userSvc.DMPolicy(r.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusOK, response.PrivacyRes{DMPolicy: policy})
+}
+
+// SetPrivacy handles changing the caller's privacy settings.
+func (h *UserHandler) SetPrivacy(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	var req reqdto.PrivacyReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	if err := h.userSvc.SetDMPolicy(r.Context(), claims.UserID, req.DMPolicy); err != nil {
+		return err
+	}
+	return httpx.JSON(w, http.StatusOK, response.PrivacyRes{DMPolicy: req.DMPolicy})
+}
+
+// broadcastProfileUpdated notifies connected peers of the user of the new profile.
+func (h *UserHandler) broadcastProfileUpdated(r *http.Request, p dbstore.GetUserProfileRow) error {
+	peers, err := h.userSvc.Peers(r.Context(), p.ID)
+	if err != nil {
+		return err
+	}
+	msg, err := ws.NewMessage(ws.TypeProfileUpdated, ws.ProfileUpdatedPayload{
+		UserID:             p.ID,
+		Username:           p.Username,
+		DisplayName:        p.DisplayName,
+		Bio:                p.Bio,
+		AvatarAttachmentID: p.AvatarAttachmentID.Int64,
+		AvatarURL:          p.AvatarUrl,
+		StatusText:         p.StatusText,
+		StatusEmoji:        p.StatusEmoji,
+	})
+	if err != nil {
+		return err
+	}
+	h.hub.BroadcastToUsers(peers, msg)
+	return nil
+}
+
+func toProfileRes(p dbstore.GetUserProfileRow) response.ProfileRes {
+	return response.ProfileRes{
+		ID:                 p.ID,
+		Username:           p.Username,
+		IsBot:              p.IsBot,
+		DisplayName:        p.DisplayName,
+		Bio:                p.Bio,
+		AvatarAttachmentID: p.AvatarAttachmentID.Int64,
+		AvatarURL:          p.AvatarUrl,
+		StatusText:         p.StatusText,
+		StatusEmoji:        p.StatusEmoji,
+		CreatedAt:          p.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
+)
+
+// WebhookHandler handles room webhook subscription requests.
+type WebhookHandler struct {
+	logger     *slog.Logger
+	webhookSvc *webhook.Service
+}
+
+// NewWebhookHandler creates a new WebhookHandler.
+func NewWebhookHandler(l *slog.Logger, s *webhook.Service) *WebhookHandler {
+	return &WebhookHandler{logger: l, webhookSvc: s}
+}
+
+// Create handles subscribing a URL to events of a room.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	var req reqdto.CreateWebhookReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httpx.BadRequest("invalid_json", "invalid json", err)
+	}
+
+	hook, err := h.webhookSvc.Create(r.Context(), roomID, claims.UserID, req.URL, req.Secret, req.Events)
+	if err != nil {
+		return err
+	}
+
+	res := toWebhookRes(hook)
+	res.Secret = hook.Secret
+	return httpx.JSON(w, http.StatusCreated, res)
+}
+
+// List handles listing the webhooks of a room.
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+
+	hooks, err := h.webhookSvc.List(r.Context(), roomID, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.WebhookRes, len(hooks))
+	for i, hook := range hooks {
+		res[i] = toWebhookRes(hook)
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+// Delete handles removing a webhook.
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, webhookID, err := parseWebhookPath(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.webhookSvc.Delete(r.Context(), roomID, claims.UserID, webhookID); err != nil {
+		return err
+	}
+
+	return httpx.JSON(w, http.StatusNoContent, nil)
+}
+
+// Deliveries handles fetching the delivery log of a webhook, newest first.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) error {
+	claims, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok {
+		return httpx.New(http.StatusUnauthorized, "unauthorized", "missing claims", nil)
+	}
+
+	roomID, webhookID, err := parseWebhookPath(r)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := h.webhookSvc.Deliveries(r.Context(), roomID, claims.UserID, webhookID, parseLimit(r))
+	if err != nil {
+		return err
+	}
+
+	res := make([]response.WebhookDeliveryRes, len(deliveries))
+	for i, d := range deliveries {
+		res[i] = toWebhookDeliveryRes(d)
+	}
+	return httpx.JSON(w, http.StatusOK, res)
+}
+
+func parseWebhookPath(r *http.Request) (roomID, webhookID int64, err error) {
+	roomID, err = strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
+	if err != nil {
+		return 0, 0, httpx.BadRequest("invalid_room_id", "invalid room id", err)
+	}
+	webhookID, err = strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		return 0, 0, httpx.BadRequest("invalid_webhook_id", "invalid webhook id", err)
+	}
+	return roomID, webhookID, nil
+}
+
+func toWebhookRes(hook dbstore.Webhook) response.WebhookRes {
+	return response.WebhookRes{
+		ID:        hook.ID,
+		RoomID:    hook.RoomID,
+		URL:       hook.Url,
+		Events:    hook.EventTypes,
+		CreatedAt: hook.CreatedAt.Time,
+	}
+}
+
+func toWebhookDeliveryRes(d dbstore.WebhookDelivery) response.WebhookDeliveryRes {
+	res := response.WebhookDeliveryRes{
+		ID:        d.ID,
+		Event:     d.EventType,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError.String,
+		CreatedAt: d.CreatedAt.Time,
+		Payload:   d.Payload,
+	}
+	if d.LastStatusCode.Valid {
+		res.LastStatusCode = &d.LastStatusCode.Int32
+	}
+	if d.Status == webhook.StatusPending {
+		res.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+	if d.DeliveredAt.Valid {
+		res.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return res
+}
@@ -19,12 +19,13 @@ type WSHandler struct {
 	hub        *ws.Hub
 	queries    *store.Queries
 	authConfig *auth.Config
+	bots       auth.BotVerifier
 	logger     *slog.Logger
 }
 
 // NewWSHandler creates a new WSHandler.
-func NewWSHandler(hub *ws.Hub, queries *store.Queries, authConfig *auth.Config, logger *slog.Logger) *WSHandler {
-	return &WSHandler{hub: hub, queries: queries, authConfig: authConfig, logger: logger}
+func NewWSHandler(hub *ws.Hub, queries *store.Queries, authConfig *auth.Config, bots auth.BotVerifier, logger *slog.Logger) *WSHandler {
+	return &WSHandler{hub: hub, queries: queries, authConfig: authConfig, bots: bots, logger: logger}
 }
 
 // Upgrade handles the HTTP→WebSocket upgrade, authenticates via query param token, and starts the client pumps.
@@ -40,11 +41,21 @@ func (h *WSHandler) Upgrade(w http.ResponseWriter, r *http.Request) error {
 		token = strings.ReplaceAll(tokenHeader, "Bearer ", "")
 	}
 
-	claims, err := auth.ParseToken(token, h.authConfig)
+	claims, err := auth.Authenticate(r.Context(), token, h.authConfig, h.bots)
 	if err != nil {
 		return httpx.New(http.StatusUnauthorized, "invalid_token", "invalid token", err)
 	}
 
+	if claims.SessionID != 0 {
+		active, err := h.queries.IsSessionActive(r.Context(), claims.SessionID)
+		if err != nil {
+			return httpx.New(http.StatusInternalServerError, "session_error", "error checking session", err)
+		}
+		if !active {
+			return httpx.New(http.StatusUnauthorized, "session_revoked", "session revoked or expired", errors.New("inactive session"))
+		}
+	}
+
 	// important to fetch rooms before accepting ws
 	rooms, err := h.queries.GetRoomsForUser(r.Context(), claims.UserID)
 	if err != nil {
@@ -61,11 +72,14 @@ func (h *WSHandler) Upgrade(w http.ResponseWriter, r *http.Request) error {
 		roomIDs[room.ID] = true
 	}
 
-	client := ws.NewClient(h.hub, conn, h.queries, claims.UserID, claims.Username, roomIDs, h.logger)
+	client := ws.NewClient(h.hub, conn, h.queries, ws.Sender{ID: claims.UserID, Username: claims.Username, IsBot: claims.IsBot, SessionID: claims.SessionID}, roomIDs, h.logger)
 	h.hub.Register(client)
 
-	go client.WritePump(context.Background())
-	client.ReadPump(context.Background())
+	// not cancelled with the request, but keeping its request ID and client
+	// IP for the audit events of slash commands
+	ctx := context.WithoutCancel(r.Context())
+	go client.WritePump(ctx)
+	client.ReadPump(ctx)
 
 	return nil
 }
@@ -12,11 +12,12 @@ import (
 func NewRouter(a *API) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(realIP(a.TrustedProxies))
+	r.Use(clientIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	wsHandler := handlers.NewWSHandler(a.Hub, a.Queries, a.AuthConfig, a.Logger)
+	wsHandler := handlers.NewWSHandler(a.Hub, a.Queries, a.AuthConfig, a.BotService, a.Logger)
 	r.Get("/api/v1/ws", a.handle(wsHandler.Upgrade))
 
 	// group routes to be able to separate timeout middleware from ws endpoint
@@ -27,12 +28,17 @@ func NewRouter(a *API) *chi.Mux {
 
 		r.Route("/api/v1", func(api chi.Router) {
 			a.registerAuthRoutes(api)
+			a.registerHookRoutes(api)
 
 			api.Group(func(protectedRouter chi.Router) {
 				protectedRouter.Use(a.validateJWT)
 				a.registerRoomRoutes(protectedRouter)
 				a.registerUserRoutes(protectedRouter)
 				a.registerConversationRoutes(protectedRouter)
+				a.registerScheduledMessageRoutes(protectedRouter)
+				a.registerUploadRoutes(protectedRouter)
+				a.registerBotRoutes(protectedRouter)
+				a.registerAdminRoutes(protectedRouter)
 			})
 		})
 	})
@@ -0,0 +1,3 @@
+// Package attachment contains the domain logic for uploaded files:
+// validation, storage through a BlobStore and access checks.
+package attachment
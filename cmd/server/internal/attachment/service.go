@@ -0,0 +1,251 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/blob"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Store defines the persistence methods required for attachments.
+type Store interface {
+	CreateAttachment(ctx context.Context, arg dbstore.CreateAttachmentParams) (dbstore.Attachment, error)
+	GetAttachment(ctx context.Context, id int64) (dbstore.Attachment, error)
+	CanAccessAttachment(ctx context.Context, arg dbstore.CanAccessAttachmentParams) (bool, error)
+	ListAttachmentsByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.Attachment, error)
+}
+
+// BlobStore is where file contents are kept. Implementations live in package blob.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobDeleter removes stored upload contents, e.g. a BlobStore.
+type BlobDeleter interface {
+	Delete(ctx context.Context, key string) error
+}
+
+// Config holds upload limits.
+type Config struct {
+	// MaxBytes is the largest accepted upload.
+	MaxBytes int64
+	// AllowedTypes lists accepted content types; entries ending in "/" match a whole family.
+	AllowedTypes []string
+}
+
+// DefaultConfig returns the limits used when none are configured.
+func DefaultConfig() Config {
+	return Config{
+		MaxBytes: 10 << 20,
+		AllowedTypes: []string{
+			"image/",
+			"text/plain",
+			"application/pdf",
+			"application/zip",
+			"application/json",
+		},
+	}
+}
+
+const maxFilenameRunes = 255
+
+// Service provides attachment-related business logic.
+type Service struct {
+	store  Store
+	blobs  BlobStore
+	cfg    Config
+	logger *slog.Logger
+}
+
+// NewService creates a new attachment Service.
+func NewService(s Store, b BlobStore, cfg Config, l *slog.Logger) *Service {
+	return &Service{store: s, blobs: b, cfg: cfg, logger: l}
+}
+
+// MaxBytes returns the configured upload size limit.
+func (s *Service) MaxBytes() int64 {
+	return s.cfg.MaxBytes
+}
+
+// Upload streams r into the blob store and records it as an unlinked
+// attachment owned by uploaderID. The content type is sniffed, not trusted.
+func (s *Service) Upload(ctx context.Context, uploaderID int64, filename string, r io.Reader) (dbstore.Attachment, error) {
+	filename = cleanFilename(filename)
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return dbstore.Attachment{}, err
+	}
+	head = head[:n]
+	if n == 0 {
+		return dbstore.Attachment{}, httpx.BadRequest("empty_file", "file is empty", nil)
+	}
+
+	contentType := http.DetectContentType(head)
+	if !s.allowed(contentType) {
+		return dbstore.Attachment{}, httpx.New(http.StatusUnsupportedMediaType, "unsupported_type", "file type not allowed: "+contentType, nil)
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return dbstore.Attachment{}, err
+	}
+
+	// read one byte past the limit so oversized files can be detected
+	counter := &countingReader{r: io.LimitReader(io.MultiReader(bytes.NewReader(head), r), s.cfg.MaxBytes+1)}
+	if err := s.blobs.Put(ctx, key, counter, -1, contentType); err != nil {
+		return dbstore.Attachment{}, err
+	}
+	if counter.n > s.cfg.MaxBytes {
+		s.deleteBlob(key)
+		return dbstore.Attachment{}, httpx.New(http.StatusRequestEntityTooLarge, "file_too_large", "file exceeds the upload limit", nil)
+	}
+
+	a, err := s.store.CreateAttachment(ctx, dbstore.CreateAttachmentParams{
+		UploaderID:  uploaderID,
+		StorageKey:  key,
+		Filename:    filename,
+		ContentType: contentType,
+		SizeBytes:   counter.n,
+	})
+	if err != nil {
+		s.deleteBlob(key)
+		return dbstore.Attachment{}, err
+	}
+	return a, nil
+}
+
+// Open returns an attachment and its contents if userID is allowed to see it:
+// the uploader, members of the room or participants of the conversation.
+// Avatars are visible to everyone. The caller must close the returned reader.
+func (s *Service) Open(ctx context.Context, userID, attachmentID int64) (dbstore.Attachment, io.ReadCloser, error) {
+	ok, err := s.store.CanAccessAttachment(ctx, dbstore.CanAccessAttachmentParams{UserID: userID, AttachmentID: attachmentID})
+	if err != nil {
+		return dbstore.Attachment{}, nil, err
+	}
+	if !ok {
+		// same response as a missing attachment, so ids can't be probed
+		return dbstore.Attachment{}, nil, httpx.New(http.StatusNotFound, "not_found", "attachment not found", nil)
+	}
+
+	a, err := s.store.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.Attachment{}, nil, httpx.New(http.StatusNotFound, "not_found", "attachment not found", err)
+		}
+		return dbstore.Attachment{}, nil, err
+	}
+
+	rc, err := s.blobs.Get(ctx, a.StorageKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return dbstore.Attachment{}, nil, httpx.New(http.StatusNotFound, "not_found", "attachment not found", err)
+		}
+		return dbstore.Attachment{}, nil, err
+	}
+	return a, rc, nil
+}
+
+// ListForMessages returns the attachments of the given messages grouped by message id.
+func (s *Service) ListForMessages(ctx context.Context, messageIDs []int64) (map[int64][]dbstore.Attachment, error) {
+	res := make(map[int64][]dbstore.Attachment)
+	if len(messageIDs) == 0 {
+		return res, nil
+	}
+
+	atts, err := s.store.ListAttachmentsByMessageIDs(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range atts {
+		res[a.MessageID.Int64] = append(res[a.MessageID.Int64], a)
+	}
+	return res, nil
+}
+
+func (s *Service) allowed(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	for _, t := range s.cfg.AllowedTypes {
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return true
+		}
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) deleteBlob(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		s.logger.Warn("failed to delete orphaned blob", "key", key, "error", err)
+	}
+}
+
+// DeleteBlobs removes the contents of uploads whose rows were deleted. blobs
+// may be nil to leave them in place. Failures are only logged: the rows are
+// gone already, so a leftover blob is only wasted space.
+func DeleteBlobs(ctx context.Context, blobs BlobDeleter, keys []string, l *slog.Logger) {
+	if blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			l.Warn("deleting upload", "key", key, "error", err)
+		}
+	}
+}
+
+// newKey returns a random, unguessable storage key.
+func newKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	h := hex.EncodeToString(b)
+	return "uploads/" + h[:2] + "/" + h, nil
+}
+
+// cleanFilename keeps only the base name, strips control characters and caps the length.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(content.Sanitize(strings.ReplaceAll(name, "\n", " ")))
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	for utf8.RuneCountInString(name) > maxFilenameRunes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
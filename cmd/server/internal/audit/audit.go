@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Actions recorded in audit_events.action.
+const (
+	ActionLogin             = "auth.login"
+	ActionLoginFailed       = "auth.login_failed"
+	ActionTokenRefreshed    = "auth.token_refreshed"
+	ActionRefreshReused     = "auth.refresh_reused"
+	ActionPasswordChanged   = "auth.password_changed"
+	ActionPasswordReset     = "auth.password_reset"
+	ActionTwoFactorEnabled  = "auth.2fa_enabled"
+	ActionTwoFactorDisabled = "auth.2fa_disabled"
+	ActionDeletionRequested = "account.deletion_requested"
+	ActionRoomCreated       = "room.created"
+	ActionRoomDeleted       = "room.deleted"
+	ActionRoleChanged       = "room.role_changed"
+	ActionMemberKicked      = "room.member_kicked"
+	ActionRetentionChanged  = "room.retention_changed"
+	ActionMessageDeleted    = "message.deleted"
+	ActionMessagePinned     = "message.pinned"
+	ActionMessageUnpinned   = "message.unpinned"
+	ActionUserDeactivated   = "admin.user_deactivated"
+	ActionUserReactivated   = "admin.user_reactivated"
+	ActionAnnouncement      = "admin.announcement"
+	ActionLockoutCleared    = "admin.lockout_cleared"
+)
+
+// Target types recorded in audit_events.target_type.
+const (
+	TargetUser    = "user"
+	TargetRoom    = "room"
+	TargetMessage = "message"
+	TargetSession = "session"
+)
+
+// Writer persists audit events, e.g. the sqlc Queries.
+type Writer interface {
+	CreateAuditEvent(ctx context.Context, arg dbstore.CreateAuditEventParams) error
+}
+
+// Event is one entry of the audit log. ActorID is 0 when nobody was logged
+// in and TargetID is 0 for events without a target.
+type Event struct {
+	Action     string
+	ActorID    int64
+	TargetType string
+	TargetID   int64
+	Details    map[string]any
+}
+
+type ctxKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the client IP of the request,
+// which Record stores with every event.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// ClientIP returns the IP stored by WithClientIP, or "".
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKey{}).(string)
+	return ip
+}
+
+// Record appends e to the audit log with the client IP and chi request ID
+// found in ctx. Failures are logged and otherwise ignored.
+func Record(ctx context.Context, w Writer, l *slog.Logger, e Event) {
+	if l == nil {
+		l = slog.Default()
+	}
+	details := []byte("{}")
+	if len(e.Details) > 0 {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			l.Warn("encoding audit details", "action", e.Action, "error", err)
+		} else {
+			details = b
+		}
+	}
+
+	err := w.CreateAuditEvent(ctx, dbstore.CreateAuditEventParams{
+		Action:     e.Action,
+		ActorID:    pgtype.Int8{Int64: e.ActorID, Valid: e.ActorID != 0},
+		TargetType: e.TargetType,
+		TargetID:   pgtype.Int8{Int64: e.TargetID, Valid: e.TargetID != 0},
+		Ip:         ClientIP(ctx),
+		RequestID:  middleware.GetReqID(ctx),
+		Details:    details,
+	})
+	if err != nil {
+		l.Error("recording audit event", "action", e.Action, "actor_id", e.ActorID, "error", err)
+	}
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type fakeStore struct {
+	events []dbstore.CreateAuditEventParams
+	// creation times of the stored events, pruned oldest first
+	created []time.Time
+	batches int
+}
+
+func (f *fakeStore) CreateAuditEvent(_ context.Context, arg dbstore.CreateAuditEventParams) error {
+	f.events = append(f.events, arg)
+	return nil
+}
+
+func (f *fakeStore) PruneAuditEvents(_ context.Context, arg dbstore.PruneAuditEventsParams) (int64, error) {
+	f.batches++
+	var n int64
+	kept := f.created[:0]
+	for _, t := range f.created {
+		if n < int64(arg.Lim) && t.Before(arg.Before.Time) {
+			n++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	f.created = kept
+	return n, nil
+}
+
+func TestRecord_RequestContext(t *testing.T) {
+	f := &fakeStore{}
+	var ctx context.Context
+	h := middleware.RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = WithClientIP(r.Context(), "203.0.113.7")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	Record(ctx, f, discard, Event{
+		Action:     ActionRoleChanged,
+		ActorID:    1,
+		TargetType: TargetUser,
+		TargetID:   2,
+		Details:    map[string]any{"room_id": 7, "role": "admin"},
+	})
+
+	if len(f.events) != 1 {
+		t.Fatalf("events = %d, want 1", len(f.events))
+	}
+	e := f.events[0]
+	if e.Action != ActionRoleChanged || e.ActorID.Int64 != 1 || e.TargetID.Int64 != 2 {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Ip != "203.0.113.7" {
+		t.Errorf("ip = %q, want 203.0.113.7", e.Ip)
+	}
+	if e.RequestID == "" || e.RequestID != middleware.GetReqID(ctx) {
+		t.Errorf("request id = %q, want %q", e.RequestID, middleware.GetReqID(ctx))
+	}
+	var details map[string]any
+	if err := json.Unmarshal(e.Details, &details); err != nil || details["role"] != "admin" {
+		t.Errorf("details = %s (%v)", e.Details, err)
+	}
+}
+
+func TestRecord_NoActor(t *testing.T) {
+	f := &fakeStore{}
+	Record(context.Background(), f, nil, Event{Action: ActionLoginFailed})
+
+	e := f.events[0]
+	if e.ActorID.Valid || e.TargetID.Valid {
+		t.Errorf("actor/target should be NULL, got %+v", e)
+	}
+	if string(e.Details) != "{}" {
+		t.Errorf("details = %s, want {}", e.Details)
+	}
+}
+
+func TestPruneOld_Batches(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeStore{}
+	for range 5 {
+		f.created = append(f.created, now.Add(-200*24*time.Hour))
+	}
+	f.created = append(f.created, now.Add(-time.Hour))
+
+	p := NewPruner(f, PrunerConfig{Retention: 180 * 24 * time.Hour, BatchSize: 2}, discard)
+	p.now = func() time.Time { return now }
+
+	n, err := p.PruneOld(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("pruned = %d, want 5", n)
+	}
+	if len(f.created) != 1 {
+		t.Errorf("left = %d, want the recent event only", len(f.created))
+	}
+	if f.batches != 3 {
+		t.Errorf("batches = %d, want 3", f.batches)
+	}
+}
@@ -0,0 +1,5 @@
+// Package audit records security and moderation events in the append-only
+// audit_events table: logins, token refreshes, room and role changes, kicks
+// and admin actions. Recording never fails the action being audited; errors
+// are only logged. A Pruner deletes events older than the retention period.
+package audit
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// PruneStore defines the persistence methods the Pruner needs.
+type PruneStore interface {
+	PruneAuditEvents(ctx context.Context, arg dbstore.PruneAuditEventsParams) (int64, error)
+}
+
+// PrunerConfig tunes how long events are kept and how they are deleted.
+type PrunerConfig struct {
+	Interval  time.Duration
+	Retention time.Duration
+	BatchSize int32
+}
+
+// DefaultPrunerConfig returns the settings used in production.
+func DefaultPrunerConfig() PrunerConfig {
+	return PrunerConfig{Interval: time.Hour, Retention: 180 * 24 * time.Hour, BatchSize: 1000}
+}
+
+// Pruner deletes audit events older than the retention period, in batches
+// so a large backlog doesn't hold one long transaction.
+type Pruner struct {
+	store  PruneStore
+	cfg    PrunerConfig
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewPruner creates a Pruner.
+func NewPruner(s PruneStore, cfg PrunerConfig, l *slog.Logger) *Pruner {
+	return &Pruner{store: s, cfg: cfg, logger: l, now: time.Now}
+}
+
+// Run prunes old events until ctx is cancelled.
+func (p *Pruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.PruneOld(ctx); err != nil {
+			p.logger.Warn("audit log pruning failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PruneOld deletes every event older than the retention period and returns
+// how many it removed.
+func (p *Pruner) PruneOld(ctx context.Context) (int64, error) {
+	before := pgtype.Timestamptz{Time: p.now().Add(-p.cfg.Retention), Valid: true}
+	var total int64
+	for {
+		n, err := p.store.PruneAuditEvents(ctx, dbstore.PruneAuditEventsParams{Before: before, Lim: p.cfg.BatchSize})
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(p.cfg.BatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		p.logger.Info("audit events pruned", "count", total)
+	}
+	return total, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultDeletionGrace is how long a deleted account can still be recovered
+// by logging in.
+const DefaultDeletionGrace = 30 * 24 * time.Hour
+
+// ErrAccountDeactivated indicates a login to an account that was deactivated
+// by an admin or already deleted.
+var ErrAccountDeactivated = errors.New("account deactivated")
+
+// AccountStore is the persistence for account deactivation.
+type AccountStore interface {
+	GetAccount(ctx context.Context, id int64) (dbstore.GetAccountRow, error)
+	ScheduleUserDeletion(ctx context.Context, arg dbstore.ScheduleUserDeletionParams) error
+	CancelUserDeletion(ctx context.Context, id int64) (int64, error)
+}
+
+// DeleteAccount deactivates the caller's account and schedules its deletion
+// once the grace period is over; logging in before then cancels it. Every
+// session is revoked and the user's connection closed. The current password
+// is required when the account has one; wrong ones count as failed logins.
+func (s *Service) DeleteAccount(ctx context.Context, claims *Claims, req reqdto.DeleteAccountReq, meta SessionMeta) (time.Time, error) {
+	if err := s.checkThrottle(ctx, claims.Username, meta.IP); err != nil {
+		return time.Time{}, err
+	}
+	hash, err := s.store.GetUserPasswordHash(ctx, claims.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// accounts created through SSO may have no password to confirm with
+	if hash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)); err != nil {
+			if err := s.recordFailure(ctx, claims.Username, meta.IP); err != nil {
+				return time.Time{}, err
+			}
+			return time.Time{}, ErrWrongPassword
+		}
+	}
+
+	grace := s.auth.DeletionGrace
+	if grace <= 0 {
+		grace = DefaultDeletionGrace
+	}
+	deleteAfter := s.now().UTC().Add(grace)
+	if err := s.store.ScheduleUserDeletion(ctx, dbstore.ScheduleUserDeletionParams{
+		ID:          claims.UserID,
+		DeleteAfter: pgtype.Timestamptz{Time: deleteAfter, Valid: true},
+	}); err != nil {
+		return time.Time{}, err
+	}
+	if _, err := s.store.RevokeOtherSessions(ctx, dbstore.RevokeOtherSessionsParams{UserID: claims.UserID}); err != nil {
+		return time.Time{}, err
+	}
+	s.sessions.DisconnectUser(claims.UserID)
+
+	s.record(ctx, audit.Event{
+		Action:     audit.ActionDeletionRequested,
+		ActorID:    claims.UserID,
+		TargetType: audit.TargetUser,
+		TargetID:   claims.UserID,
+		Details:    map[string]any{"delete_after": deleteAfter},
+	})
+	s.logger.Info("account deletion scheduled", "user_id", claims.UserID, "delete_after", deleteAfter)
+	return deleteAfter, nil
+}
+
+// checkActive refuses logins to deactivated accounts. A login during the
+// grace period of a requested deletion cancels the deletion instead.
+func (s *Service) checkActive(ctx context.Context, userID int64) error {
+	acc, err := s.store.GetAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !acc.DeactivatedAt.Valid {
+		return nil
+	}
+	if !acc.DeleteAfter.Valid {
+		return ErrAccountDeactivated
+	}
+	n, err := s.store.CancelUserDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// the grace period is over, the purger just hasn't run yet
+		return ErrAccountDeactivated
+	}
+	s.logger.Info("account deletion cancelled", "user_id", userID)
+	return nil
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) GetAccount(_ context.Context, id int64) (dbstore.GetAccountRow, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return dbstore.GetAccountRow{
+				ID:            u.ID,
+				Username:      u.Username,
+				Email:         u.Email,
+				CreatedAt:     u.CreatedAt,
+				DeactivatedAt: u.DeactivatedAt,
+				DeleteAfter:   u.DeleteAfter,
+			}, nil
+		}
+	}
+	return dbstore.GetAccountRow{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) ScheduleUserDeletion(_ context.Context, arg dbstore.ScheduleUserDeletionParams) error {
+	for name, u := range f.users {
+		if u.ID == arg.ID {
+			u.DeactivatedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			u.DeleteAfter = arg.DeleteAfter
+			f.users[name] = u
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) CancelUserDeletion(_ context.Context, id int64) (int64, error) {
+	for name, u := range f.users {
+		if u.ID == id && u.DeleteAfter.Valid && u.DeleteAfter.Time.After(time.Now()) {
+			u.DeactivatedAt, u.DeleteAfter = pgtype.Timestamptz{}, pgtype.Timestamptz{}
+			f.users[name] = u
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func TestDeleteAccount_GracePeriod(t *testing.T) {
+	svc, store, closer, _ := newPasswordService(t)
+	ctx := context.Background()
+	meta := SessionMeta{IP: "10.0.0.1"}
+	login := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+
+	for range 2 {
+		if _, err := svc.Login(ctx, login, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claims := &Claims{UserID: 1, Username: "alice", SessionID: 2}
+
+	if _, err := svc.DeleteAccount(ctx, claims, reqdto.DeleteAccountReq{Password: "nope"}, meta); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+	if len(closer.users) != 0 || store.users["alice"].DeactivatedAt.Valid {
+		t.Fatal("expected a wrong password to leave the account alone")
+	}
+
+	deleteAfter, err := svc.DeleteAccount(ctx, claims, reqdto.DeleteAccountReq{Password: "correct horse"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := svc.now().UTC().Add(DefaultDeletionGrace); !deleteAfter.Equal(want) {
+		t.Fatalf("expected deletion at %s, got %s", want, deleteAfter)
+	}
+	if !slices.Equal(closer.users, []int64{1}) {
+		t.Fatalf("expected alice to be disconnected, got %v", closer.users)
+	}
+	if !store.revoked[1] || !store.revoked[2] {
+		t.Fatalf("expected every session to be revoked, got %v", store.revoked)
+	}
+
+	// logging in again within the grace period keeps the account
+	if _, err := svc.Login(ctx, login, meta); err != nil {
+		t.Fatalf("expected login to cancel the deletion: %v", err)
+	}
+	if u := store.users["alice"]; u.DeactivatedAt.Valid || u.DeleteAfter.Valid {
+		t.Fatalf("expected the account to be active again, got %+v", u)
+	}
+}
+
+func TestDeleteAccount_WithoutPassword(t *testing.T) {
+	svc, store, closer, _ := newPasswordService(t)
+	alice := store.users["alice"]
+	alice.Password = "" // created through SSO
+	store.users["alice"] = alice
+
+	claims := &Claims{UserID: 1, Username: "alice"}
+	if _, err := svc.DeleteAccount(context.Background(), claims, reqdto.DeleteAccountReq{}, SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if !store.users["alice"].DeleteAfter.Valid || len(closer.users) != 1 {
+		t.Fatal("expected the deletion to be scheduled")
+	}
+}
+
+func TestLogin_DeactivatedAccount(t *testing.T) {
+	svc, store, _, _ := newPasswordService(t)
+	ctx := context.Background()
+	login := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+
+	alice := store.users["alice"]
+	alice.DeactivatedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	store.users["alice"] = alice
+	if _, err := svc.Login(ctx, login, SessionMeta{}); !errors.Is(err, ErrAccountDeactivated) {
+		t.Fatalf("expected an admin deactivation to refuse logins, got %v", err)
+	}
+
+	// the grace period is over but the purger hasn't run yet
+	alice.DeleteAfter = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+	store.users["alice"] = alice
+	if _, err := svc.Login(ctx, login, SessionMeta{}); !errors.Is(err, ErrAccountDeactivated) {
+		t.Fatalf("expected an expired grace period to refuse logins, got %v", err)
+	}
+	if store.sessions != 0 {
+		t.Fatalf("expected no session to be created, got %d", store.sessions)
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -22,28 +23,107 @@ func NewClaimsContext(ctx context.Context, c *Claims) context.Context {
 }
 
 // Config holds parameters used to issue and validate JWT access tokens.
+// With Keys set, tokens are signed with its active key (RS256 or EdDSA);
+// otherwise they are signed with HS256 and JWTSecret. HS256 tokens keep
+// being accepted while JWTSecret is set, so switching to a key set doesn't
+// log everyone out.
 type Config struct {
 	JWTSecret []byte
+	Keys      *KeySet
 	Issuer    string
 	AccessTTL time.Duration
+	// RefreshTTL is how long a session stays valid without being refreshed.
+	RefreshTTL time.Duration
+	// Login throttles failed password logins.
+	Login LoginLimits
+	// Policy is checked whenever a username or password is chosen.
+	Policy Policy
+	// ResetTTL is how long a password reset link stays valid.
+	ResetTTL time.Duration
+	// ResetURL is the page reset links point to; the token is added as ?token=.
+	ResetURL string
+	// OIDC is the single sign-on provider; nil when SSO is off.
+	OIDC *OIDCProvider
+	// DeletionGrace is how long a deleted account waits before it's purged;
+	// DefaultDeletionGrace when zero.
+	DeletionGrace time.Duration
 }
 
 // Claims contains application-specific JWT claims embedded in access tokens.
 type Claims struct {
 	UserID   int64  `json:"uid"`
 	Username string `json:"un"`
+	// SessionID is the session the token was issued for; 0 for bots.
+	SessionID int64 `json:"sid,omitempty"`
+	// IsBot is set for requests authenticated with a bot token; it's never part of a JWT.
+	IsBot bool `json:"-"`
 	jwt.RegisteredClaims
 }
 
-// ParseToken validates a raw JWT string and returns the embedded claims.
+// BotTokenPrefix marks long-lived bot tokens. Any other bearer token is parsed as a JWT.
+const BotTokenPrefix = "bot_"
+
+// BotVerifier resolves a bot token to the bot's claims.
+type BotVerifier interface {
+	VerifyBotToken(ctx context.Context, token string) (*Claims, error)
+}
+
+// Authenticate validates a bearer token, which is either a bot token checked
+// by bots or a JWT access token.
+func Authenticate(ctx context.Context, token string, cfg *Config, bots BotVerifier) (*Claims, error) {
+	if strings.HasPrefix(token, BotTokenPrefix) {
+		if bots == nil {
+			return nil, errors.New("bot tokens are not accepted")
+		}
+		return bots.VerifyBotToken(ctx, token)
+	}
+	return ParseToken(token, cfg)
+}
+
+// SignToken signs claims as configured in cfg.
+func SignToken(claims jwt.Claims, cfg *Config) (string, error) {
+	if cfg.Keys != nil {
+		return cfg.Keys.sign(claims)
+	}
+	if len(cfg.JWTSecret) == 0 {
+		return "", errors.New("no signing key configured")
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.JWTSecret)
+}
+
+// ParseToken validates a raw JWT access token and returns the embedded claims.
 func ParseToken(tokenStr string, cfg *Config) (*Claims, error) {
+	claims, err := parseClaims(tokenStr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// access tokens have no audience; anything else, e.g. a 2FA challenge, isn't one
+	if len(claims.Audience) > 0 {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+// parseClaims validates the signature, expiry and issuer of a JWT.
+func parseClaims(tokenStr string, cfg *Config, opts ...jwt.ParserOption) (*Claims, error) {
+	var methods []string
+	if len(cfg.JWTSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg())
+	}
+
 	var claims Claims
 	tok, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (any, error) {
-		if t.Method != jwt.SigningMethodHS256 {
+		if t.Method == jwt.SigningMethodHS256 {
+			return cfg.JWTSecret, nil
+		}
+		if cfg.Keys == nil {
 			return nil, errors.New("invalid algo")
 		}
-		return cfg.JWTSecret, nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		return cfg.Keys.verificationKey(t)
+	}, append(opts, jwt.WithValidMethods(methods))...)
 	if err != nil {
 		return nil, err
 	}
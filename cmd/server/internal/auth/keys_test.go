@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims(userID int64) Claims {
+	now := time.Now()
+	return Claims{
+		UserID:   userID,
+		Username: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "test",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func newRSAKey(t *testing.T, kid string) *Key {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}
+}
+
+func newEdKey(t *testing.T, kid string) *Key {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: priv, Public: pub}
+}
+
+func TestKeySet_RotationWindow(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "old"), newEdKey(t, "new")
+
+	before, err := NewKeySet("old", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := SignToken(testClaims(1), &Config{Issuer: "test", Keys: before})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate: the new key signs, the old one only verifies
+	retired := &Key{ID: oldKey.ID, Method: oldKey.Method, Public: oldKey.Public}
+	after, err := NewKeySet("new", newKey, retired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Issuer: "test", Keys: after}
+	newToken, err := SignToken(testClaims(2), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tok := range []string{oldToken, newToken} {
+		if _, err := ParseToken(tok, cfg); err != nil {
+			t.Fatalf("expected token to verify during rotation: %v", err)
+		}
+	}
+	c, _ := ParseToken(newToken, cfg)
+	if c.UserID != 2 {
+		t.Fatalf("unexpected claims %+v", c)
+	}
+
+	// once the old key is dropped its tokens stop verifying
+	final, _ := NewKeySet("new", newKey)
+	if _, err := ParseToken(oldToken, &Config{Issuer: "test", Keys: final}); err == nil {
+		t.Fatal("expected token signed by a dropped key to be rejected")
+	}
+}
+
+func TestKeySet_RejectsAlgMismatch(t *testing.T) {
+	rsaKey := newRSAKey(t, "k1")
+	ks, _ := NewKeySet("k1", rsaKey)
+
+	// an EdDSA token claiming the RSA key's kid
+	ed := newEdKey(t, "k1")
+	tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims(1))
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(ed.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(signed, &Config{Issuer: "test", Keys: ks}); err == nil {
+		t.Fatal("expected alg/kid mismatch to be rejected")
+	}
+}
+
+func TestParseToken_HS256Fallback(t *testing.T) {
+	secret := &Config{Issuer: "test", JWTSecret: []byte("0123456789abcdef")}
+	tok, err := SignToken(testClaims(1), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// still accepted after switching to a key set, as long as the secret is kept
+	ks, _ := NewKeySet("k1", newEdKey(t, "k1"))
+	both := &Config{Issuer: "test", JWTSecret: secret.JWTSecret, Keys: ks}
+	if _, err := ParseToken(tok, both); err != nil {
+		t.Fatalf("expected HS256 token to verify: %v", err)
+	}
+
+	// and rejected once the secret is removed
+	if _, err := ParseToken(tok, &Config{Issuer: "test", Keys: ks}); err == nil {
+		t.Fatal("expected HS256 token to be rejected without a secret")
+	}
+}
+
+func TestLoadKeySet_JWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, "a")
+	ed := newEdKey(t, "b")
+
+	rsaDER := x509.MarshalPKCS1PrivateKey(rsaKey.Private.(*rsa.PrivateKey))
+	edDER, err := x509.MarshalPKIXPublicKey(ed.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, "a.pem"), "RSA PRIVATE KEY", rsaDER)
+	writePEM(t, filepath.Join(dir, "b.pem"), "PUBLIC KEY", edDER)
+
+	if _, err := LoadKeySet(dir, "b"); err == nil {
+		t.Fatal("expected a public-only key to be refused as the active key")
+	}
+	ks, err := LoadKeySet(dir, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := ks.JWKS()
+	if len(doc.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", doc.Keys)
+	}
+	if k := doc.Keys[0]; k.Kid != "a" || k.Kty != "RSA" || k.Alg != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Fatalf("unexpected RSA JWK %+v", k)
+	}
+	if k := doc.Keys[1]; k.Kid != "b" || k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.X == "" {
+		t.Fatalf("unexpected Ed25519 JWK %+v", k)
+	}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
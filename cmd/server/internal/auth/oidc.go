@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshEvery bounds how often an unknown kid makes the provider's key
+// set be fetched again, so forged kids can't be used to hammer it.
+const jwksRefreshEvery = time.Minute
+
+// OIDCConfig describes the OpenID Connect provider used for single sign-on.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL; its discovery document lives
+	// under /.well-known/openid-configuration.
+	Issuer   string
+	ClientID string
+	// ClientSecret is empty for public clients, which rely on PKCE alone.
+	ClientSecret string
+	// RedirectURL is this server's /auth/oidc/callback as the provider sees it.
+	RedirectURL string
+	// LoginURL is this server's /auth/oidc/login, where device logins are opened.
+	LoginURL string
+	Scopes   []string
+	// AllowSignup creates an account the first time an unknown subject logs in.
+	AllowSignup bool
+	HTTPClient  *http.Client
+}
+
+// OIDCProvider talks to an OpenID Connect provider: it builds authorization
+// URLs, redeems codes and verifies ID tokens. The discovery document and the
+// provider's keys are fetched on first use and cached.
+type OIDCProvider struct {
+	cfg OIDCConfig
+
+	mu        sync.Mutex
+	meta      *oidcMetadata
+	keys      *KeySet
+	keysFetch time.Time
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims are the ID token claims used to find or create the user.
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// NewOIDCProvider creates a provider client. Nothing is fetched until the
+// first login, so an unreachable provider doesn't stop the server.
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{cfg: cfg}
+}
+
+// AuthCodeURL returns the provider URL that starts an authorization code
+// login with PKCE (S256) for verifier.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var res struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.fetchJSON(req, &res)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || res.Error != "" {
+		return "", fmt.Errorf("token endpoint: %d %s %s", status, res.Error, res.ErrorDescription)
+	}
+	if res.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return res.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token and returns its claims.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (*IDTokenClaims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var claims IDTokenClaims
+	_, err = jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		return p.verificationKey(ctx, t)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token without sub")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	return &claims, nil
+}
+
+// metadata returns the discovery document, fetching it the first time.
+func (p *OIDCProvider) metadata(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	u := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta oidcMetadata
+	status, err := p.fetchJSON(req, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: status %d", status)
+	}
+	// the issuer in the document must be the one we were configured with
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: missing endpoints")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// verificationKey finds the provider key for t, fetching the key set again
+// when the kid is unknown (the provider may have rotated).
+func (p *OIDCProvider) verificationKey(ctx context.Context, t *jwt.Token) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if k, err := p.keys.verificationKey(t); err == nil {
+			return k, nil
+		}
+		if time.Since(p.keysFetch) < jwksRefreshEvery {
+			return nil, errors.New("unknown signing key")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc JWKS
+	status, err := p.fetchJSON(req, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc jwks: status %d", status)
+	}
+	ks := &KeySet{keys: make(map[string]*Key, len(doc.Keys))}
+	for _, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		// keys we can't use are skipped, the provider may publish other kinds
+		if k, err := j.Key(); err == nil {
+			ks.keys[k.ID] = k
+		}
+	}
+	p.keys, p.keysFetch = ks, time.Now()
+	return p.keys.verificationKey(t)
+}
+
+// fetchJSON performs req and decodes the JSON body into out, whatever the status.
+func (p *OIDCProvider) fetchJSON(req *http.Request, out any) (int, error) {
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return resp.StatusCode, fmt.Errorf("decoding %s: %w", req.URL.Path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// pkceChallenge derives the S256 code challenge for verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) CreateUser(_ context.Context, arg dbstore.CreateUserParams) (dbstore.User, error) {
+	if _, ok := f.users[arg.Username]; ok {
+		return dbstore.User{}, errors.New("duplicate username")
+	}
+	u := dbstore.User{ID: int64(len(f.users) + 1), Username: arg.Username, Password: arg.Password, Email: arg.Email}
+	f.users[arg.Username] = u
+	return u, nil
+}
+
+func (f *fakeStore) CreateOIDCLogin(_ context.Context, arg dbstore.CreateOIDCLoginParams) error {
+	f.oidc = append(f.oidc, &dbstore.OidcLogin{
+		ID:             int64(len(f.oidc) + 1),
+		State:          arg.State,
+		Nonce:          arg.Nonce,
+		CodeVerifier:   arg.CodeVerifier,
+		DeviceCodeHash: arg.DeviceCodeHash,
+		UserCode:       arg.UserCode,
+		ExpiresAt:      arg.ExpiresAt,
+		LinkUserID:     arg.LinkUserID,
+	})
+	return nil
+}
+
+func (f *fakeStore) GetOIDCLoginByUserCode(_ context.Context, code pgtype.Text) (dbstore.OidcLogin, error) {
+	for _, l := range f.oidc {
+		if l.UserCode == code && !l.StateUsedAt.Valid {
+			return *l, nil
+		}
+	}
+	return dbstore.OidcLogin{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) UseOIDCState(_ context.Context, state string) (dbstore.OidcLogin, error) {
+	for _, l := range f.oidc {
+		if l.State == state && !l.StateUsedAt.Valid {
+			l.StateUsedAt.Valid = true
+			return *l, nil
+		}
+	}
+	return dbstore.OidcLogin{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) CompleteOIDCLogin(_ context.Context, arg dbstore.CompleteOIDCLoginParams) error {
+	f.oidc[arg.ID-1].UserID = arg.UserID
+	return nil
+}
+
+func (f *fakeStore) GetOIDCDeviceLogin(_ context.Context, hash []byte) (dbstore.OidcLogin, error) {
+	for _, l := range f.oidc {
+		if bytes.Equal(l.DeviceCodeHash, hash) {
+			return *l, nil
+		}
+	}
+	return dbstore.OidcLogin{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) ConsumeOIDCDeviceLogin(_ context.Context, id int64) (int64, error) {
+	l := f.oidc[id-1]
+	if l.ConsumedAt.Valid || !l.UserID.Valid {
+		return 0, nil
+	}
+	l.ConsumedAt.Valid = true
+	return 1, nil
+}
+
+func (f *fakeStore) GetUserIdentity(_ context.Context, arg dbstore.GetUserIdentityParams) (dbstore.UserIdentity, error) {
+	for _, i := range f.identities {
+		if i.Issuer == arg.Issuer && i.Subject == arg.Subject {
+			return i, nil
+		}
+	}
+	return dbstore.UserIdentity{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) CreateUserIdentity(_ context.Context, arg dbstore.CreateUserIdentityParams) (dbstore.UserIdentity, error) {
+	i := dbstore.UserIdentity{ID: int64(len(f.identities) + 1), UserID: arg.UserID, Issuer: arg.Issuer, Subject: arg.Subject, Email: arg.Email}
+	f.identities = append(f.identities, i)
+	return i, nil
+}
+
+func (f *fakeStore) TouchUserIdentity(context.Context, dbstore.TouchUserIdentityParams) error {
+	return nil
+}
+
+// mockOIDC is a minimal OpenID Connect provider. Its authorize endpoint
+// approves every request at once as the identity in next, and its token
+// endpoint enforces PKCE.
+type mockOIDC struct {
+	*httptest.Server
+	key *Key
+
+	mu    sync.Mutex
+	next  IDTokenClaims
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge, nonce, redirect string
+	claims                     IDTokenClaims
+}
+
+const mockClientID = "chat-test"
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	t.Helper()
+	m := &mockOIDC{key: newRSAKey(t, "idp-1"), codes: make(map[string]mockGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		ks, _ := NewKeySet(m.key.ID, m.key)
+		_ = json.NewEncoder(w).Encode(ks.JWKS())
+	})
+	mux.HandleFunc("GET /authorize", m.authorize)
+	mux.HandleFunc("POST /token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != mockClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	code := rand.Text()
+	m.codes[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirect: q.Get("redirect_uri"), claims: m.next}
+	m.mu.Unlock()
+	http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+}
+
+func (m *mockOIDC) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	g, ok := m.codes[r.FormValue("code")]
+	delete(m.codes, r.FormValue("code"))
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if !ok || r.FormValue("redirect_uri") != g.redirect || pkceChallenge(r.FormValue("code_verifier")) != g.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	claims := g.claims
+	claims.Nonce = g.nonce
+	claims.Issuer = m.URL
+	claims.Audience = jwt.ClaimStrings{mockClientID}
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	ks, _ := NewKeySet(m.key.ID, m.key)
+	idToken, _ := ks.sign(claims)
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+}
+
+// approve plays the browser: it opens authURL and returns the code and state
+// the provider redirects back with.
+func (m *mockOIDC) approve(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loc.String(), "http://chat.test/api/v1/auth/oidc/callback?") {
+		t.Fatalf("unexpected redirect %s", loc)
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func newOIDCService(t *testing.T, allowSignup bool) (*Service, *fakeStore, *mockOIDC) {
+	t.Helper()
+	svc, store, _, _ := newPasswordService(t)
+	idp := newMockOIDC(t)
+	svc.auth.OIDC = NewOIDCProvider(OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://chat.test/api/v1/auth/oidc/callback",
+		LoginURL:    "http://chat.test/api/v1/auth/oidc/login",
+		AllowSignup: allowSignup,
+	})
+	return svc, store, idp
+}
+
+func TestOIDC_BrowserLoginProvisionsOnce(t *testing.T) {
+	svc, store, idp := newOIDCService(t, true)
+	ctx := context.Background()
+	idp.next = IDTokenClaims{PreferredUsername: "bob smith", RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-bob"}}
+
+	var userID int64
+	for i := range 2 {
+		authURL, err := svc.StartOIDCLogin(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := idp.approve(t, authURL)
+		res, outcome, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{})
+		if err != nil || outcome != OIDCSession || res.Token == "" {
+			t.Fatalf("login %d: expected tokens, got %+v %v %v", i+1, res, outcome, err)
+		}
+		if res.User.Username != "bobsmith" {
+			t.Fatalf("expected the username to be derived from preferred_username, got %q", res.User.Username)
+		}
+		if i == 1 && res.User.ID != userID {
+			t.Fatalf("expected the second login to reuse user %d, got %d", userID, res.User.ID)
+		}
+		userID = res.User.ID
+
+		if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Fatalf("expected a replayed state to be rejected, got %v", err)
+		}
+	}
+	if len(store.users) != 2 || len(store.identities) != 1 {
+		t.Fatalf("expected one provisioned user and identity, got %d users and %d identities", len(store.users), len(store.identities))
+	}
+	if store.users["bobsmith"].Password != "" {
+		t.Fatal("expected a provisioned account to have no password")
+	}
+
+	// a code redeemed with another login's verifier is refused by the provider
+	authURL, _ := svc.StartOIDCLogin(ctx, "")
+	code, state := idp.approve(t, authURL)
+	store.oidc[len(store.oidc)-1].CodeVerifier = "not-the-verifier"
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); !errors.Is(err, ErrSSOFailed) {
+		t.Fatalf("expected a PKCE mismatch to fail, got %v", err)
+	}
+}
+
+func TestOIDC_DeviceLoginAfterLink(t *testing.T) {
+	svc, store, idp := newOIDCService(t, false)
+	ctx := context.Background()
+	idp.next = IDTokenClaims{Email: "Alice@example.com", EmailVerified: true, RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-alice"}}
+
+	// alice links the identity from her account first
+	authURL, err := svc.StartOIDCLink(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.approve(t, authURL)
+	res, outcome, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{})
+	if err != nil || outcome != OIDCLinked || res.Token != "" || res.User.ID != 1 {
+		t.Fatalf("expected the identity to be linked to alice, got %+v %v %v", res, outcome, err)
+	}
+
+	dev, err := svc.StartDeviceLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dev.UserCode) != 9 || dev.VerificationURIComplete != dev.VerificationURI+"?user_code="+dev.UserCode {
+		t.Fatalf("unexpected device login %+v", dev)
+	}
+	if _, err := svc.PollDeviceLogin(ctx, dev.DeviceCode, SessionMeta{}); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("expected ErrAuthorizationPending, got %v", err)
+	}
+
+	// the user types the code in lowercase without the dash
+	authURL, err = svc.StartOIDCLogin(ctx, strings.ToLower(strings.ReplaceAll(dev.UserCode, "-", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state = idp.approve(t, authURL)
+	res, outcome, err = svc.FinishOIDCLogin(ctx, code, state, SessionMeta{})
+	if err != nil || outcome != OIDCDevice || res.Token != "" || res.User.Username != "alice" {
+		t.Fatalf("expected the device login to be approved for alice, got %+v %v %v", res, outcome, err)
+	}
+	if _, err := svc.StartOIDCLogin(ctx, dev.UserCode); !errors.Is(err, ErrInvalidUserCode) {
+		t.Fatalf("expected an opened user code to be refused, got %v", err)
+	}
+
+	tokens, err := svc.PollDeviceLogin(ctx, dev.DeviceCode, SessionMeta{})
+	if err != nil || tokens.Token == "" || tokens.User.ID != 1 {
+		t.Fatalf("expected tokens for alice, got %+v %v", tokens, err)
+	}
+	if _, err := svc.PollDeviceLogin(ctx, dev.DeviceCode, SessionMeta{}); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Fatalf("expected the device code to work once, got %v", err)
+	}
+	if len(store.identities) != 1 || store.identities[0].UserID != 1 {
+		t.Fatalf("expected the identity to be linked to alice, got %+v", store.identities)
+	}
+
+	// without signup, an identity matching no account is refused
+	idp.next = IDTokenClaims{Email: "carol@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-carol"}}
+	authURL, _ = svc.StartOIDCLogin(ctx, "")
+	code, state = idp.approve(t, authURL)
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); !errors.Is(err, ErrSignupDisabled) {
+		t.Fatalf("expected ErrSignupDisabled, got %v", err)
+	}
+
+	// and an identity linked to alice can't be linked to another account
+	store.users["bob"] = dbstore.User{ID: 2, Username: "bob"}
+	idp.next = IDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-alice"}}
+	authURL, _ = svc.StartOIDCLink(ctx, 2)
+	code, state = idp.approve(t, authURL)
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); !errors.Is(err, ErrIdentityLinked) {
+		t.Fatalf("expected ErrIdentityLinked, got %v", err)
+	}
+}
+
+func TestOIDC_NoLinkingByEmail(t *testing.T) {
+	svc, store, idp := newOIDCService(t, false)
+	ctx := context.Background()
+	// the provider vouches for alice's address, but the local one was never verified
+	idp.next = IDTokenClaims{Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice", RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-mallory"}}
+
+	authURL, _ := svc.StartOIDCLogin(ctx, "")
+	code, state := idp.approve(t, authURL)
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); !errors.Is(err, ErrSignupDisabled) {
+		t.Fatalf("expected ErrSignupDisabled without signup, got %v", err)
+	}
+
+	svc.auth.OIDC.cfg.AllowSignup = true
+	authURL, _ = svc.StartOIDCLogin(ctx, "")
+	code, state = idp.approve(t, authURL)
+	res, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{})
+	if err != nil || res.User.ID == 1 {
+		t.Fatalf("expected a new account, got %+v %v", res, err)
+	}
+	if u := store.users[res.User.Username]; u.Email.Valid {
+		t.Fatalf("expected the new account not to take alice's email, got %q", u.Email.String)
+	}
+}
+
+func TestOIDC_TwoFactorChallenge(t *testing.T) {
+	svc, store, idp := newOIDCService(t, false)
+	ctx := context.Background()
+	store.totp[1] = &dbstore.UserTotp{UserID: 1, Secret: "JBSWY3DPEHPK3PXP", EnabledAt: pgtype.Timestamptz{Valid: true}}
+	idp.next = IDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-alice"}}
+
+	authURL, _ := svc.StartOIDCLink(ctx, 1)
+	code, state := idp.approve(t, authURL)
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a browser login only gets a challenge
+	authURL, _ = svc.StartOIDCLogin(ctx, "")
+	code, state = idp.approve(t, authURL)
+	res, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{})
+	if err != nil || !res.TwoFactorRequired || res.Token != "" || res.ChallengeToken == "" {
+		t.Fatalf("expected a 2FA challenge, got %+v %v", res, err)
+	}
+
+	// so does the polling client of a device login
+	dev, _ := svc.StartDeviceLogin(ctx)
+	authURL, _ = svc.StartOIDCLogin(ctx, dev.UserCode)
+	code, state = idp.approve(t, authURL)
+	if _, _, err := svc.FinishOIDCLogin(ctx, code, state, SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = svc.PollDeviceLogin(ctx, dev.DeviceCode, SessionMeta{})
+	if err != nil || !res.TwoFactorRequired || res.Token != "" {
+		t.Fatalf("expected a 2FA challenge for the device, got %+v %v", res, err)
+	}
+}
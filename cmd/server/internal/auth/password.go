@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/mail"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrWrongPassword indicates the current password given for a change is wrong.
+// ErrInvalidResetToken indicates an unknown, expired or already used reset token.
+var (
+	ErrWrongPassword     = errors.New("wrong password")
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+)
+
+// PasswordResetStore is the persistence for password reset tokens.
+type PasswordResetStore interface {
+	CreatePasswordReset(ctx context.Context, arg dbstore.CreatePasswordResetParams) error
+	GetPasswordResetUser(ctx context.Context, tokenHash []byte) (int64, error)
+	UsePasswordReset(ctx context.Context, tokenHash []byte) (int64, error)
+	DeletePasswordResets(ctx context.Context, userID int64) error
+}
+
+// ChangePassword replaces the caller's password after checking the current
+// one. Every other session of the user is revoked and disconnected; the one
+// the request came from stays. Wrong current passwords count as failed logins.
+func (s *Service) ChangePassword(ctx context.Context, claims *Claims, req reqdto.ChangePasswordReq, meta SessionMeta) error {
+	if err := s.checkThrottle(ctx, claims.Username, meta.IP); err != nil {
+		return err
+	}
+	hash, err := s.store.GetUserPasswordHash(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.CurrentPassword)); err != nil {
+		if err := s.recordFailure(ctx, claims.Username, meta.IP); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	if err := s.auth.Policy.CheckPassword(req.NewPassword, claims.Username); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, claims.UserID, req.NewPassword, claims.SessionID); err != nil {
+		return err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionPasswordChanged, ActorID: claims.UserID, TargetType: audit.TargetUser, TargetID: claims.UserID})
+	s.logger.Info("password changed", "user_id", claims.UserID)
+	return nil
+}
+
+// RequestPasswordReset mails a reset link to the account registered with
+// email, replacing any earlier link. It returns nil whether or not such an
+// account exists so callers can't probe for addresses.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	addr, err := normalizeEmail(email)
+	if err != nil || !addr.Valid {
+		return ErrInvalidEmail
+	}
+	u, err := s.store.GetUserByEmail(ctx, addr.String)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.logger.Debug("password reset for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	if err := s.store.DeletePasswordResets(ctx, u.ID); err != nil {
+		return err
+	}
+	if err := s.store.CreatePasswordReset(ctx, dbstore.CreatePasswordResetParams{
+		TokenHash: hashToken(token),
+		UserID:    u.ID,
+		ExpiresAt: pgtype.Timestamptz{Time: s.now().UTC().Add(s.auth.ResetTTL), Valid: true},
+	}); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email.String,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"To choose a new one, open this link within %s:\n\n%s\n\n"+
+			"If it wasn't you, ignore this message; your password stays the same.\n",
+			u.Username, s.auth.ResetTTL, s.resetLink(token)),
+	})
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset.
+// All of the user's sessions are revoked and a lockout on the username is
+// lifted, since whoever has the token controls the mailbox.
+func (s *Service) ResetPassword(ctx context.Context, req reqdto.ResetPasswordReq) error {
+	hash := hashToken(req.Token)
+	userID, err := s.store.GetPasswordResetUser(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	u, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// checked before the token is spent so a refused password can be retried
+	if err := s.auth.Policy.CheckPassword(req.NewPassword, u.Username); err != nil {
+		return err
+	}
+	n, err := s.store.UsePasswordReset(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.setPassword(ctx, userID, req.NewPassword, 0); err != nil {
+		return err
+	}
+	if _, err := s.Unlock(ctx, u.Username); err != nil {
+		return err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionPasswordReset, TargetType: audit.TargetUser, TargetID: userID})
+	s.logger.Info("password reset", "user_id", userID)
+	return nil
+}
+
+// setPassword stores the new password, drops pending reset links and
+// revokes every session of the user but keepSession.
+func (s *Service) setPassword(ctx context.Context, userID int64, password string, keepSession int64) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.store.UpdateUserPassword(ctx, dbstore.UpdateUserPasswordParams{ID: userID, Password: string(hash)}); err != nil {
+		return err
+	}
+	if err := s.store.DeletePasswordResets(ctx, userID); err != nil {
+		return err
+	}
+	revoked, err := s.store.RevokeOtherSessions(ctx, dbstore.RevokeOtherSessionsParams{UserID: userID, ID: keepSession})
+	if err != nil {
+		return err
+	}
+	for _, id := range revoked {
+		s.sessions.DisconnectSession(id)
+	}
+	return nil
+}
+
+func (s *Service) resetLink(token string) string {
+	if s.auth.ResetURL == "" {
+		return token
+	}
+	sep := "?"
+	if strings.Contains(s.auth.ResetURL, "?") {
+		sep = "&"
+	}
+	return s.auth.ResetURL + sep + "token=" + url.QueryEscape(token)
+}
+
+// normalizeEmail validates an optional bare email address ("a@b.c", no
+// display name). An empty string gives an invalid pgtype.Text.
+func normalizeEmail(email string) (pgtype.Text, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return pgtype.Text{}, nil
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" || len(email) > 254 {
+		return pgtype.Text{}, ErrInvalidEmail
+	}
+	return pgtype.Text{String: email, Valid: true}, nil
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/mail"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) GetUserByEmail(_ context.Context, email string) (dbstore.User, error) {
+	for _, u := range f.users {
+		if u.Email.Valid && strings.EqualFold(u.Email.String, email) {
+			return u, nil
+		}
+	}
+	return dbstore.User{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) GetUserPasswordHash(_ context.Context, id int64) (string, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return u.Password, nil
+		}
+	}
+	return "", pgx.ErrNoRows
+}
+
+func (f *fakeStore) UpdateUserPassword(_ context.Context, arg dbstore.UpdateUserPasswordParams) error {
+	for name, u := range f.users {
+		if u.ID == arg.ID {
+			u.Password = arg.Password
+			f.users[name] = u
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) RevokeOtherSessions(_ context.Context, arg dbstore.RevokeOtherSessionsParams) ([]int64, error) {
+	var ids []int64
+	for id := int64(1); id <= f.sessions; id++ {
+		if id != arg.ID && !f.revoked[id] {
+			f.revoked[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeStore) CreatePasswordReset(_ context.Context, arg dbstore.CreatePasswordResetParams) error {
+	f.resets[hex.EncodeToString(arg.TokenHash)] = arg.UserID
+	return nil
+}
+
+func (f *fakeStore) GetPasswordResetUser(_ context.Context, tokenHash []byte) (int64, error) {
+	id, ok := f.resets[hex.EncodeToString(tokenHash)]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	return id, nil
+}
+
+func (f *fakeStore) UsePasswordReset(_ context.Context, tokenHash []byte) (int64, error) {
+	k := hex.EncodeToString(tokenHash)
+	if _, ok := f.resets[k]; !ok {
+		return 0, nil
+	}
+	delete(f.resets, k)
+	return 1, nil
+}
+
+func (f *fakeStore) DeletePasswordResets(_ context.Context, userID int64) error {
+	for k, id := range f.resets {
+		if id == userID {
+			delete(f.resets, k)
+		}
+	}
+	return nil
+}
+
+type fakeCloser struct{ closed, users []int64 }
+
+func (c *fakeCloser) DisconnectSession(id int64) { c.closed = append(c.closed, id) }
+
+func (c *fakeCloser) DisconnectUser(id int64) { c.users = append(c.users, id) }
+
+type fakeMailer struct{ sent []mail.Message }
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newPasswordService(t *testing.T) (*Service, *fakeStore, *fakeCloser, *fakeMailer) {
+	t.Helper()
+	svc, store, _ := newThrottleService(t)
+	closer, mailer := &fakeCloser{}, &fakeMailer{}
+	svc.sessions, svc.mailer = closer, mailer
+	svc.auth.Policy = DefaultPolicy()
+	svc.auth.ResetURL = "https://chat.example/reset"
+
+	alice := store.users["alice"]
+	alice.Email = pgtype.Text{String: "alice@example.com", Valid: true}
+	store.users["alice"] = alice
+	return svc, store, closer, mailer
+}
+
+func TestChangePassword_RevokesOtherSessions(t *testing.T) {
+	svc, _, closer, _ := newPasswordService(t)
+	ctx := context.Background()
+	meta := SessionMeta{IP: "10.0.0.1"}
+	login := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+
+	// three sessions; the change is made from the second
+	for range 3 {
+		if _, err := svc.Login(ctx, login, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claims := &Claims{UserID: 1, Username: "alice", SessionID: 2}
+
+	err := svc.ChangePassword(ctx, claims, reqdto.ChangePasswordReq{CurrentPassword: "nope", NewPassword: "Tr0ub4dor&3"}, meta)
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+	var policy *PolicyError
+	err = svc.ChangePassword(ctx, claims, reqdto.ChangePasswordReq{CurrentPassword: "correct horse", NewPassword: "short"}, meta)
+	if !errors.As(err, &policy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	if len(closer.closed) != 0 {
+		t.Fatal("expected no sessions to be touched by failed changes")
+	}
+
+	err = svc.ChangePassword(ctx, claims, reqdto.ChangePasswordReq{CurrentPassword: "correct horse", NewPassword: "Tr0ub4dor&3"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(closer.closed, []int64{1, 3}) {
+		t.Fatalf("expected sessions 1 and 3 to be disconnected, got %v", closer.closed)
+	}
+	if _, err := svc.Login(ctx, login, meta); !errors.Is(err, ErrInvalidCreds) {
+		t.Fatalf("expected the old password to stop working, got %v", err)
+	}
+	if _, err := svc.Login(ctx, reqdto.LoginReq{Username: "alice", Password: "Tr0ub4dor&3"}, meta); err != nil {
+		t.Fatalf("expected the new password to work: %v", err)
+	}
+}
+
+func TestPasswordReset_Flow(t *testing.T) {
+	svc, store, closer, mailer := newPasswordService(t)
+	ctx := context.Background()
+
+	if err := svc.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("expected unknown emails to look like known ones, got %v", err)
+	}
+	if err := svc.RequestPasswordReset(ctx, "not an email"); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("expected ErrInvalidEmail, got %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("expected no mail, got %+v", mailer.sent)
+	}
+
+	if err := svc.RequestPasswordReset(ctx, "Alice@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "alice@example.com" {
+		t.Fatalf("expected one mail to alice, got %+v", mailer.sent)
+	}
+	token := resetToken(t, mailer.sent[0].Body)
+
+	// a refused password doesn't spend the token
+	var policy *PolicyError
+	if err := svc.ResetPassword(ctx, reqdto.ResetPasswordReq{Token: token, NewPassword: "alice-1234567"}); !errors.As(err, &policy) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	store.sessions = 2
+	if err := svc.ResetPassword(ctx, reqdto.ResetPasswordReq{Token: token, NewPassword: "Tr0ub4dor&3"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(closer.closed, []int64{1, 2}) {
+		t.Fatalf("expected every session to be disconnected, got %v", closer.closed)
+	}
+	if err := svc.ResetPassword(ctx, reqdto.ResetPasswordReq{Token: token, NewPassword: "An0ther-one!"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expected a used token to be rejected, got %v", err)
+	}
+	if _, err := svc.Login(ctx, reqdto.LoginReq{Username: "alice", Password: "Tr0ub4dor&3"}, SessionMeta{}); err != nil {
+		t.Fatalf("expected the new password to work: %v", err)
+	}
+}
+
+func TestPolicy_Username(t *testing.T) {
+	p := DefaultPolicy()
+	for name, ok := range map[string]bool{
+		"alice":                 true,
+		"bob_2.x-":              true,
+		"42":                    false, // too short
+		"_alice":                false,
+		"al ice":                false,
+		"álice":                 false,
+		"Admin":                 false,
+		strings.Repeat("a", 33): false,
+	} {
+		if err := p.CheckUsername(name); (err == nil) != ok {
+			t.Errorf("CheckUsername(%q) = %v, want ok=%v", name, err, ok)
+		}
+	}
+}
+
+func TestPolicy_Password(t *testing.T) {
+	p := DefaultPolicy()
+	for pw, ok := range map[string]bool{
+		"Tr0ub4dor&3":            true,
+		"correct horse battery":  true, // lowercase and spaces
+		"short1A":                false,
+		"alllowercaseletters":    false,
+		"xxAlice-2024xx":         false, // contains the username
+		"Password123":            false,
+		strings.Repeat("aB", 37): false, // over bcrypt's 72 bytes
+	} {
+		if err := p.CheckPassword(pw, "alice"); (err == nil) != ok {
+			t.Errorf("CheckPassword(%q) = %v, want ok=%v", pw, err, ok)
+		}
+	}
+}
+
+// resetToken pulls the token out of the link in a reset mail.
+func resetToken(t *testing.T, body string) string {
+	t.Helper()
+	i := strings.Index(body, "https://chat.example/reset?")
+	if i < 0 {
+		t.Fatalf("no reset link in %q", body)
+	}
+	link := strings.Fields(body[i:])[0]
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get("token")
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords would be
+// silently truncated, so they are refused instead.
+const maxPasswordBytes = 72
+
+// Policy holds the rules usernames and passwords must follow when they are
+// chosen: at registration, on a password change and on a reset. Existing
+// accounts are not checked again.
+type Policy struct {
+	UsernameMinLen int
+	UsernameMaxLen int
+	// ReservedUsernames can't be registered, compared case-insensitively.
+	ReservedUsernames []string
+
+	PasswordMinLen int
+	// PasswordMinClasses is how many of lowercase, uppercase, digits and
+	// symbols a password must mix.
+	PasswordMinClasses int
+}
+
+// DefaultPolicy returns the production defaults.
+func DefaultPolicy() Policy {
+	return Policy{
+		UsernameMinLen: 3,
+		UsernameMaxLen: 32,
+		ReservedUsernames: []string{
+			"admin", "administrator", "root", "system", "support", "moderator",
+			"everyone", "here", "me", "null", "undefined",
+		},
+		PasswordMinLen:     10,
+		PasswordMinClasses: 2,
+	}
+}
+
+// PolicyError describes why a username or password was refused. Code is
+// stable and meant for clients; Message is for people.
+type PolicyError struct {
+	Code    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
+
+// commonPasswords are refused whatever the other rules say.
+var commonPasswords = []string{
+	"password", "password1", "password123", "1234567890", "123456789",
+	"12345678", "qwertyuiop", "iloveyou", "letmein123", "welcome123",
+}
+
+// CheckUsername reports whether name may be registered. Usernames are made
+// of ASCII letters, digits, '_', '.' and '-', and start with a letter or a
+// digit so they read well after an '@'.
+func (p Policy) CheckUsername(name string) error {
+	n := utf8.RuneCountInString(name)
+	if n < p.UsernameMinLen || n > p.UsernameMaxLen {
+		return &PolicyError{
+			Code:    "invalid_username",
+			Message: fmt.Sprintf("username must be %d to %d characters long", p.UsernameMinLen, p.UsernameMaxLen),
+		}
+	}
+	for i, r := range name {
+		alnum := r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		if alnum || (i > 0 && strings.ContainsRune("_.-", r)) {
+			continue
+		}
+		return &PolicyError{
+			Code:    "invalid_username",
+			Message: "username may only contain letters, digits, '_', '.' and '-', and must start with a letter or digit",
+		}
+	}
+	if slices.ContainsFunc(p.ReservedUsernames, func(r string) bool { return strings.EqualFold(r, name) }) {
+		return &PolicyError{Code: "username_reserved", Message: "username is reserved"}
+	}
+	return nil
+}
+
+// CheckPassword reports whether password is strong enough for username.
+func (p Policy) CheckPassword(password, username string) error {
+	if utf8.RuneCountInString(password) < p.PasswordMinLen {
+		return &PolicyError{
+			Code:    "weak_password",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.PasswordMinLen),
+		}
+	}
+	if len(password) > maxPasswordBytes {
+		return &PolicyError{
+			Code:    "password_too_long",
+			Message: fmt.Sprintf("password must be at most %d bytes long", maxPasswordBytes),
+		}
+	}
+	if classes := charClasses(password); classes < p.PasswordMinClasses {
+		return &PolicyError{
+			Code:    "weak_password",
+			Message: fmt.Sprintf("password must mix at least %d of lowercase, uppercase, digits and symbols", p.PasswordMinClasses),
+		}
+	}
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return &PolicyError{Code: "weak_password", Message: "password must not contain the username"}
+	}
+	if slices.Contains(commonPasswords, lower) {
+		return &PolicyError{Code: "weak_password", Message: "password is too common"}
+	}
+	return nil
+}
+
+// charClasses counts the kinds of characters in s.
+func charClasses(s string) int {
+	var lower, upper, digit, other bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
@@ -4,13 +4,18 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
 	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/mail"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -18,35 +23,103 @@ import (
 // Store defines the persistence methods required for authentication operations.
 type Store interface {
 	GetUserByUsername(ctx context.Context, username string) (dbstore.User, error)
+	GetUserByID(ctx context.Context, id int64) (dbstore.GetUserByIDRow, error)
 	CreateUser(ctx context.Context, arg dbstore.CreateUserParams) (dbstore.User, error)
+	GetUserByEmail(ctx context.Context, lower string) (dbstore.User, error)
+	GetUserPasswordHash(ctx context.Context, id int64) (string, error)
+	UpdateUserPassword(ctx context.Context, arg dbstore.UpdateUserPasswordParams) error
+
+	CreateSession(ctx context.Context, arg dbstore.CreateSessionParams) (dbstore.Session, error)
+	CreateRefreshToken(ctx context.Context, arg dbstore.CreateRefreshTokenParams) error
+	GetRefreshToken(ctx context.Context, tokenHash []byte) (dbstore.GetRefreshTokenRow, error)
+	UseRefreshToken(ctx context.Context, tokenHash []byte) (int64, error)
+	ExtendSession(ctx context.Context, arg dbstore.ExtendSessionParams) error
+	ListActiveSessions(ctx context.Context, userID int64) ([]dbstore.Session, error)
+	RevokeSession(ctx context.Context, arg dbstore.RevokeSessionParams) (int64, error)
+	RevokeOtherSessions(ctx context.Context, arg dbstore.RevokeOtherSessionsParams) ([]int64, error)
+
+	audit.Writer
+	AccountStore
+	PasswordResetStore
+	OIDCStore
+	ThrottleStore
+	TwoFactorStore
+}
+
+// SessionCloser closes live connections, e.g. the ws.Hub: those opened with
+// a revoked session or by a deactivated user.
+type SessionCloser interface {
+	DisconnectSession(sessionID int64)
+	DisconnectUser(userID int64)
+}
+
+// SessionMeta describes the client a session is created for.
+type SessionMeta struct {
+	UserAgent string
+	IP        string
 }
 
 // Service provides authentication-related business logic using a Store.
 type Service struct {
-	store  Store
-	logger *slog.Logger
-	auth   *Config
+	store    Store
+	sessions SessionCloser
+	mailer   mail.Mailer
+	logger   *slog.Logger
+	auth     *Config
+	now      func() time.Time
 }
 
-// NewService creates a new Service with the given Store and logger.
-func NewService(s Store, l *slog.Logger, authCfg *Config) *Service {
-	return &Service{store: s, logger: l, auth: authCfg}
+// NewService creates a new Service with the given Store and logger. sessions
+// is told about revoked sessions so their connections can be dropped, and
+// mailer delivers password reset links.
+func NewService(s Store, sessions SessionCloser, mailer mail.Mailer, l *slog.Logger, authCfg *Config) *Service {
+	return &Service{store: s, sessions: sessions, mailer: mailer, logger: l, auth: authCfg, now: time.Now}
 }
 
 // ErrUsernameTaken indicates that the username is already registered.
-// ErrInvalidCreds indicates that the provided credentials are invalid.
-// ErrUserNotFound indicates that no user was found in the database.
+// ErrEmailTaken indicates that the email is already registered.
+// ErrInvalidEmail indicates a malformed email address.
+// ErrInvalidCreds indicates that the username or the password is wrong, without saying which.
+// ErrInvalidRefresh indicates an unknown, expired or revoked refresh token.
+// ErrRefreshReused indicates a refresh token was presented twice; the session is revoked.
+// ErrSessionNotFound indicates the session doesn't exist, isn't the user's or is already revoked.
+// ErrNoSession indicates the access token isn't tied to a session (bot tokens).
 var (
-	ErrUsernameTaken = errors.New("username already in use")
-	ErrInvalidCreds  = errors.New("invalid credentials")
-	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken   = errors.New("username already in use")
+	ErrEmailTaken      = errors.New("email already in use")
+	ErrInvalidEmail    = errors.New("invalid email address")
+	ErrInvalidCreds    = errors.New("invalid credentials")
+	ErrInvalidRefresh  = errors.New("invalid refresh token")
+	ErrRefreshReused   = errors.New("refresh token reused")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrNoSession       = errors.New("token has no session")
 )
 
-// Register creates a new user account, hashing the password and checking for duplicates.
-func (s *Service) Register(ctx context.Context, req reqdto.RegisterReq) (resdto.AuthRes, error) {
+const maxUserAgentLen = 255
+
+// Register creates a new user account. The username and password must pass
+// the configured Policy; the email is optional and only used for password
+// resets.
+func (s *Service) Register(ctx context.Context, req reqdto.RegisterReq, meta SessionMeta) (resdto.AuthRes, error) {
+	if err := s.auth.Policy.CheckUsername(req.Username); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if err := s.auth.Policy.CheckPassword(req.Password, req.Username); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+
 	if _, err := s.store.GetUserByUsername(ctx, req.Username); err == nil {
 		return resdto.AuthRes{}, ErrUsernameTaken
 	}
+	if email.Valid {
+		if _, err := s.store.GetUserByEmail(ctx, email.String); err == nil {
+			return resdto.AuthRes{}, ErrEmailTaken
+		}
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return resdto.AuthRes{}, err
@@ -55,42 +128,206 @@ func (s *Service) Register(ctx context.Context, req reqdto.RegisterReq) (resdto.
 	u, err := s.store.CreateUser(ctx, dbstore.CreateUserParams{
 		Username: req.Username,
 		Password: string(hash),
+		Email:    email,
 	})
 	if err != nil {
 		return resdto.AuthRes{}, err
 	}
 
-	token, exp, err := s.generateAccessToken(u)
+	return s.startSession(ctx, u, meta)
+}
+
+// Login checks the username and password and starts a session. Failed
+// attempts are counted per username and per IP; once they pile up Login
+// returns a ThrottledError until the block expires. Unknown usernames are
+// counted and timed like wrong passwords. When the account has 2FA, Login
+// only returns a challenge token for LoginTwoFactor.
+func (s *Service) Login(ctx context.Context, req reqdto.LoginReq, meta SessionMeta) (resdto.AuthRes, error) {
+	if err := s.checkThrottle(ctx, req.Username, meta.IP); err != nil {
+		return resdto.AuthRes{}, err
+	}
+
+	u, err := s.store.GetUserByUsername(ctx, req.Username)
+	found := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return resdto.AuthRes{}, err
+	}
+
+	hash := dummyHash()
+	// bots have no password and authenticate with bot tokens only
+	if found && !u.IsBot {
+		hash = []byte(u.Password)
+	}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(req.Password))
+	if err != nil || !found || u.IsBot {
+		if err := s.recordFailure(ctx, req.Username, meta.IP); err != nil {
+			return resdto.AuthRes{}, err
+		}
+		return resdto.AuthRes{}, ErrInvalidCreds
+	}
+
+	twoFactor, err := s.twoFactorEnabled(ctx, u.ID)
 	if err != nil {
 		return resdto.AuthRes{}, err
 	}
+	if twoFactor {
+		// the failed login counter is cleared once the second step succeeds
+		return s.challengeResponse(u)
+	}
 
-	authRes := resdto.AuthRes{
+	if err := s.clearFailures(ctx, u.Username, meta.IP); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	return s.startSession(ctx, u, meta)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once: presenting a spent one means it was
+// copied, so the whole session is revoked.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (resdto.AuthRes, error) {
+	hash := hashToken(refreshToken)
+	rt, err := s.store.GetRefreshToken(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return resdto.AuthRes{}, ErrInvalidRefresh
+	}
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if rt.RevokedAt.Valid || !rt.ExpiresAt.Time.After(s.now()) {
+		return resdto.AuthRes{}, ErrInvalidRefresh
+	}
+	if rt.UsedAt.Valid {
+		return resdto.AuthRes{}, s.revokeReused(ctx, rt)
+	}
+	n, err := s.store.UseRefreshToken(ctx, hash)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if n == 0 {
+		// another request spent it between the read and the update
+		return resdto.AuthRes{}, s.revokeReused(ctx, rt)
+	}
+
+	u, err := s.store.GetUserByID(ctx, rt.UserID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionTokenRefreshed, ActorID: rt.UserID, TargetType: audit.TargetSession, TargetID: rt.SessionID})
+	refresh, refreshExp, err := s.issueRefreshToken(ctx, rt.SessionID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if err := s.store.ExtendSession(ctx, dbstore.ExtendSessionParams{
+		ID:        rt.SessionID,
+		ExpiresAt: pgtype.Timestamptz{Time: refreshExp, Valid: true},
+	}); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	token, exp, err := s.generateAccessToken(u.ID, u.Username, rt.SessionID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+
+	return resdto.AuthRes{
 		User: resdto.UserRes{
 			ID:        u.ID,
 			Username:  u.Username,
 			CreatedAt: u.CreatedAt.Time,
 		},
-		Token:     token,
-		ExpiresAt: exp.Unix(),
+		Token:            token,
+		ExpiresAt:        exp.Unix(),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExp.Unix(),
+	}, nil
+}
+
+// Logout revokes the session the access token was issued for.
+func (s *Service) Logout(ctx context.Context, claims *Claims) error {
+	if claims.SessionID == 0 {
+		return ErrNoSession
 	}
+	return s.RevokeSession(ctx, claims.UserID, claims.SessionID)
+}
 
-	return authRes, nil
+// ListSessions returns the user's active sessions, flagging the one currentID belongs to.
+func (s *Service) ListSessions(ctx context.Context, userID, currentID int64) ([]resdto.SessionRes, error) {
+	sessions, err := s.store.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]resdto.SessionRes, 0, len(sessions))
+	for _, sess := range sessions {
+		res = append(res, resdto.SessionRes{
+			ID:         sess.ID,
+			UserAgent:  sess.UserAgent,
+			IP:         sess.Ip,
+			CreatedAt:  sess.CreatedAt.Time,
+			LastUsedAt: sess.LastUsedAt.Time,
+			ExpiresAt:  sess.ExpiresAt.Time,
+			Current:    sess.ID == currentID,
+		})
+	}
+	return res, nil
 }
 
-// Login gets the user by it's username, checks if the password matches, and returns the user or an error
-func (s *Service) Login(ctx context.Context, req reqdto.LoginReq) (resdto.AuthRes, error) {
-	u, err := s.store.GetUserByUsername(ctx, req.Username)
+// RevokeSession revokes one of the user's sessions: its refresh token stops
+// working, its WebSocket connections are closed and the access tokens
+// already issued for it are refused right away.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	n, err := s.store.RevokeSession(ctx, dbstore.RevokeSessionParams{ID: sessionID, UserID: userID})
 	if err != nil {
-		return resdto.AuthRes{}, ErrUserNotFound
+		return err
 	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	s.sessions.DisconnectSession(sessionID)
+	return nil
+}
 
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.Password))
+// revokeReused revokes the session of a refresh token that was presented twice.
+func (s *Service) revokeReused(ctx context.Context, rt dbstore.GetRefreshTokenRow) error {
+	s.logger.Warn("refresh token reuse detected, revoking session", "session_id", rt.SessionID, "user_id", rt.UserID)
+	if _, err := s.store.RevokeSession(ctx, dbstore.RevokeSessionParams{ID: rt.SessionID, UserID: rt.UserID}); err != nil {
+		return err
+	}
+	s.sessions.DisconnectSession(rt.SessionID)
+	s.record(ctx, audit.Event{Action: audit.ActionRefreshReused, ActorID: rt.UserID, TargetType: audit.TargetSession, TargetID: rt.SessionID})
+	return ErrRefreshReused
+}
+
+// startSession creates a session for u and issues its first token pair.
+// Every login ends here, so it's also where deactivated accounts are refused.
+func (s *Service) startSession(ctx context.Context, u dbstore.User, meta SessionMeta) (resdto.AuthRes, error) {
+	if err := s.checkActive(ctx, u.ID); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	ua := meta.UserAgent
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	sess, err := s.store.CreateSession(ctx, dbstore.CreateSessionParams{
+		UserID:    u.ID,
+		UserAgent: ua,
+		Ip:        meta.IP,
+		ExpiresAt: pgtype.Timestamptz{Time: s.now().UTC().Add(s.auth.RefreshTTL), Valid: true},
+	})
 	if err != nil {
-		return resdto.AuthRes{}, ErrInvalidCreds
+		return resdto.AuthRes{}, err
+	}
+	s.record(ctx, audit.Event{
+		Action:     audit.ActionLogin,
+		ActorID:    u.ID,
+		TargetType: audit.TargetSession,
+		TargetID:   sess.ID,
+		Details:    map[string]any{"user_agent": ua},
+	})
+	refresh, refreshExp, err := s.issueRefreshToken(ctx, sess.ID)
+	if err != nil {
+		return resdto.AuthRes{}, err
 	}
 
-	token, exp, err := s.generateAccessToken(u)
+	token, exp, err := s.generateAccessToken(u.ID, u.Username, sess.ID)
 	if err != nil {
 		return resdto.AuthRes{}, err
 	}
@@ -101,26 +338,53 @@ func (s *Service) Login(ctx context.Context, req reqdto.LoginReq) (resdto.AuthRe
 			Username:  u.Username,
 			CreatedAt: u.CreatedAt.Time,
 		},
-		Token:     token,
-		ExpiresAt: exp.Unix(),
+		Token:            token,
+		ExpiresAt:        exp.Unix(),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExp.Unix(),
 	}
 
 	return authRes, nil
 }
 
-func (s *Service) generateAccessToken(u dbstore.User) (string, time.Time, error) {
-	now := time.Now().UTC()
+// record appends an event to the audit log.
+func (s *Service) record(ctx context.Context, e audit.Event) {
+	audit.Record(ctx, s.store, s.logger, e)
+}
+
+// issueRefreshToken stores a new refresh token for the session. Only its hash is kept.
+func (s *Service) issueRefreshToken(ctx context.Context, sessionID int64) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.store.CreateRefreshToken(ctx, dbstore.CreateRefreshTokenParams{
+		TokenHash: hashToken(token),
+		SessionID: sessionID,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, s.now().UTC().Add(s.auth.RefreshTTL), nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func (s *Service) generateAccessToken(userID int64, username string, sessionID int64) (string, time.Time, error) {
+	now := s.now().UTC()
 	exp := now.Add(s.auth.AccessTTL)
 	claims := Claims{
-		UserID:   u.ID,
-		Username: u.Username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    s.auth.Issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(exp),
 		},
 	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := t.SignedString(s.auth.JWTSecret)
+	token, err := SignToken(claims, s.auth)
 	return token, exp, err
 }
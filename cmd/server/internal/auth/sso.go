@@ -0,0 +1,462 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+const (
+	// oidcLoginTTL is how long a started SSO login, browser or device, waits
+	// for the provider to redirect back.
+	oidcLoginTTL = 10 * time.Minute
+	// devicePollInterval is how often the TUI is told to poll.
+	devicePollInterval = 5 * time.Second
+	// userCodeAlphabet has no vowels, so codes can't spell words, and no
+	// characters that look alike (RFC 8628 section 6.1).
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLen      = 8
+)
+
+// ErrOIDCDisabled indicates single sign-on is not configured.
+// ErrInvalidOIDCState indicates an unknown, expired or already used login state.
+// ErrInvalidUserCode indicates a device login code that is unknown, expired or already opened.
+// ErrSSOFailed indicates the provider refused the code or returned an ID token that doesn't verify.
+// ErrSignupDisabled indicates the SSO identity isn't linked to an account and signup is off.
+// ErrIdentityLinked indicates the SSO identity is already linked to another account.
+// ErrAuthorizationPending indicates the device login hasn't been approved in the browser yet.
+// ErrInvalidDeviceCode indicates an unknown, expired or already redeemed device code.
+var (
+	ErrOIDCDisabled         = errors.New("single sign-on is not configured")
+	ErrInvalidOIDCState     = errors.New("invalid login state")
+	ErrInvalidUserCode      = errors.New("invalid user code")
+	ErrSSOFailed            = errors.New("single sign-on failed")
+	ErrSignupDisabled       = errors.New("no account linked to this identity")
+	ErrIdentityLinked       = errors.New("identity linked to another account")
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrInvalidDeviceCode    = errors.New("invalid device code")
+)
+
+// OIDCStore is the persistence for SSO logins and linked identities.
+type OIDCStore interface {
+	CreateOIDCLogin(ctx context.Context, arg dbstore.CreateOIDCLoginParams) error
+	GetOIDCLoginByUserCode(ctx context.Context, userCode pgtype.Text) (dbstore.OidcLogin, error)
+	UseOIDCState(ctx context.Context, state string) (dbstore.OidcLogin, error)
+	CompleteOIDCLogin(ctx context.Context, arg dbstore.CompleteOIDCLoginParams) error
+	GetOIDCDeviceLogin(ctx context.Context, deviceCodeHash []byte) (dbstore.OidcLogin, error)
+	ConsumeOIDCDeviceLogin(ctx context.Context, id int64) (int64, error)
+	GetUserIdentity(ctx context.Context, arg dbstore.GetUserIdentityParams) (dbstore.UserIdentity, error)
+	CreateUserIdentity(ctx context.Context, arg dbstore.CreateUserIdentityParams) (dbstore.UserIdentity, error)
+	TouchUserIdentity(ctx context.Context, arg dbstore.TouchUserIdentityParams) error
+}
+
+// StartOIDCLogin returns the provider URL a browser is sent to. Without a
+// userCode it starts a browser login that ends with tokens in the callback
+// response; with one it continues the device login StartDeviceLogin created.
+func (s *Service) StartOIDCLogin(ctx context.Context, userCode string) (string, error) {
+	if s.auth.OIDC == nil {
+		return "", ErrOIDCDisabled
+	}
+	if userCode != "" {
+		login, err := s.store.GetOIDCLoginByUserCode(ctx, pgtype.Text{String: normalizeUserCode(userCode), Valid: true})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrInvalidUserCode
+		}
+		if err != nil {
+			return "", err
+		}
+		return s.auth.OIDC.AuthCodeURL(ctx, login.State, login.Nonce, login.CodeVerifier)
+	}
+
+	params, err := s.newOIDCLogin()
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.CreateOIDCLogin(ctx, params); err != nil {
+		return "", err
+	}
+	return s.auth.OIDC.AuthCodeURL(ctx, params.State, params.Nonce, params.CodeVerifier)
+}
+
+// OIDCOutcome tells the callback handler what FinishOIDCLogin did.
+type OIDCOutcome int
+
+const (
+	// OIDCSession is a browser login: the response has tokens, or a 2FA
+	// challenge like Login.
+	OIDCSession OIDCOutcome = iota
+	// OIDCDevice is an approved device login; PollDeviceLogin hands out the
+	// session and the response only has the user.
+	OIDCDevice
+	// OIDCLinked is a login started by StartOIDCLink; the identity is now
+	// linked and the response only has the user.
+	OIDCLinked
+)
+
+// FinishOIDCLogin handles the provider's redirect: it redeems code, verifies
+// the ID token and finds or provisions the user. A browser login gets a new
+// session, or a challenge if the account has 2FA; a device login is marked
+// approved for PollDeviceLogin; a link login links the identity to the
+// user who started it.
+func (s *Service) FinishOIDCLogin(ctx context.Context, code, state string, meta SessionMeta) (resdto.AuthRes, OIDCOutcome, error) {
+	if s.auth.OIDC == nil {
+		return resdto.AuthRes{}, OIDCSession, ErrOIDCDisabled
+	}
+	login, err := s.store.UseOIDCState(ctx, state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return resdto.AuthRes{}, OIDCSession, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return resdto.AuthRes{}, OIDCSession, err
+	}
+
+	raw, err := s.auth.OIDC.Exchange(ctx, code, login.CodeVerifier)
+	if err != nil {
+		return resdto.AuthRes{}, OIDCSession, fmt.Errorf("%w: %v", ErrSSOFailed, err)
+	}
+	claims, err := s.auth.OIDC.VerifyIDToken(ctx, raw, login.Nonce, s.now())
+	if err != nil {
+		return resdto.AuthRes{}, OIDCSession, fmt.Errorf("%w: %v", ErrSSOFailed, err)
+	}
+
+	if login.LinkUserID.Valid {
+		u, err := s.linkIdentity(ctx, claims, login.LinkUserID.Int64)
+		if err != nil {
+			return resdto.AuthRes{}, OIDCLinked, err
+		}
+		return resdto.AuthRes{User: resdto.UserRes{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt.Time}}, OIDCLinked, nil
+	}
+
+	u, err := s.linkedUser(ctx, claims)
+	if err != nil {
+		return resdto.AuthRes{}, OIDCSession, err
+	}
+
+	if login.DeviceCodeHash != nil {
+		// checked here too so the browser is told, not only the polling client
+		if err := s.checkActive(ctx, u.ID); err != nil {
+			return resdto.AuthRes{}, OIDCDevice, err
+		}
+		if err := s.store.CompleteOIDCLogin(ctx, dbstore.CompleteOIDCLoginParams{
+			ID:     login.ID,
+			UserID: pgtype.Int8{Int64: u.ID, Valid: true},
+		}); err != nil {
+			return resdto.AuthRes{}, OIDCDevice, err
+		}
+		return resdto.AuthRes{User: resdto.UserRes{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt.Time}}, OIDCDevice, nil
+	}
+	res, err := s.ssoSession(ctx, u, meta)
+	return res, OIDCSession, err
+}
+
+// StartOIDCLink returns the provider URL a logged in user opens to link
+// their account with an SSO identity. Logging in with that identity then
+// lands in this account.
+func (s *Service) StartOIDCLink(ctx context.Context, userID int64) (string, error) {
+	if s.auth.OIDC == nil {
+		return "", ErrOIDCDisabled
+	}
+	params, err := s.newOIDCLogin()
+	if err != nil {
+		return "", err
+	}
+	params.LinkUserID = pgtype.Int8{Int64: userID, Valid: true}
+	if err := s.store.CreateOIDCLogin(ctx, params); err != nil {
+		return "", err
+	}
+	return s.auth.OIDC.AuthCodeURL(ctx, params.State, params.Nonce, params.CodeVerifier)
+}
+
+// StartDeviceLogin starts an SSO login for a client without a browser, such
+// as the TUI. The user opens the verification URL and logs in with the
+// provider while the client polls PollDeviceLogin with the device code.
+func (s *Service) StartDeviceLogin(ctx context.Context) (resdto.DeviceLoginRes, error) {
+	if s.auth.OIDC == nil {
+		return resdto.DeviceLoginRes{}, ErrOIDCDisabled
+	}
+	params, err := s.newOIDCLogin()
+	if err != nil {
+		return resdto.DeviceLoginRes{}, err
+	}
+	deviceCode, err := randomToken()
+	if err != nil {
+		return resdto.DeviceLoginRes{}, err
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		return resdto.DeviceLoginRes{}, err
+	}
+	params.DeviceCodeHash = hashToken(deviceCode)
+	params.UserCode = pgtype.Text{String: userCode, Valid: true}
+	if err := s.store.CreateOIDCLogin(ctx, params); err != nil {
+		return resdto.DeviceLoginRes{}, err
+	}
+
+	display := userCode[:4] + "-" + userCode[4:]
+	loginURL := s.auth.OIDC.cfg.LoginURL
+	return resdto.DeviceLoginRes{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         loginURL,
+		VerificationURIComplete: loginURL + "?user_code=" + url.QueryEscape(display),
+		ExpiresIn:               int(oidcLoginTTL / time.Second),
+		Interval:                int(devicePollInterval / time.Second),
+	}, nil
+}
+
+// PollDeviceLogin returns ErrAuthorizationPending until the device login is
+// approved in the browser, then starts a session for it. A device code is
+// redeemed once.
+func (s *Service) PollDeviceLogin(ctx context.Context, deviceCode string, meta SessionMeta) (resdto.AuthRes, error) {
+	login, err := s.store.GetOIDCDeviceLogin(ctx, hashToken(deviceCode))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return resdto.AuthRes{}, ErrInvalidDeviceCode
+	}
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if login.ConsumedAt.Valid {
+		return resdto.AuthRes{}, ErrInvalidDeviceCode
+	}
+	if !login.UserID.Valid {
+		if !login.ExpiresAt.Time.After(s.now()) {
+			return resdto.AuthRes{}, ErrInvalidDeviceCode
+		}
+		return resdto.AuthRes{}, ErrAuthorizationPending
+	}
+	n, err := s.store.ConsumeOIDCDeviceLogin(ctx, login.ID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if n == 0 {
+		return resdto.AuthRes{}, ErrInvalidDeviceCode
+	}
+
+	u, err := s.store.GetUserByID(ctx, login.UserID.Int64)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	return s.ssoSession(ctx, dbstore.User{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt}, meta)
+}
+
+// ssoSession starts the session of an SSO login. The provider doesn't
+// stand in for the second factor: with 2FA on, the client gets a challenge
+// for LoginTwoFactor like after a password.
+func (s *Service) ssoSession(ctx context.Context, u dbstore.User, meta SessionMeta) (resdto.AuthRes, error) {
+	twoFactor, err := s.twoFactorEnabled(ctx, u.ID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if twoFactor {
+		return s.challengeResponse(u)
+	}
+	return s.startSession(ctx, u, meta)
+}
+
+// linkIdentity links the identity of an ID token to userID, the account
+// that started the login with StartOIDCLink. Linking an identity again to
+// the same account is a no-op.
+func (s *Service) linkIdentity(ctx context.Context, claims *IDTokenClaims, userID int64) (dbstore.User, error) {
+	if err := s.checkActive(ctx, userID); err != nil {
+		return dbstore.User{}, err
+	}
+	var email pgtype.Text
+	if claims.EmailVerified {
+		email, _ = normalizeEmail(claims.Email)
+	}
+
+	ident, err := s.store.GetUserIdentity(ctx, dbstore.GetUserIdentityParams{Issuer: claims.Issuer, Subject: claims.Subject})
+	switch {
+	case err == nil && ident.UserID != userID:
+		return dbstore.User{}, ErrIdentityLinked
+	case errors.Is(err, pgx.ErrNoRows):
+		if _, err := s.store.CreateUserIdentity(ctx, dbstore.CreateUserIdentityParams{
+			UserID:  userID,
+			Issuer:  claims.Issuer,
+			Subject: claims.Subject,
+			Email:   email,
+		}); err != nil {
+			return dbstore.User{}, err
+		}
+		s.logger.Info("sso identity linked", "user_id", userID, "issuer", claims.Issuer, "new_account", false)
+	case err != nil:
+		return dbstore.User{}, err
+	}
+
+	u, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return dbstore.User{}, err
+	}
+	return dbstore.User{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt}, nil
+}
+
+// linkedUser returns the user an ID token belongs to. Unknown subjects get
+// a new account when signup is allowed. They are never linked to a local
+// account by email: local emails aren't verified, so anyone could have
+// registered the address first. Existing users link with StartOIDCLink.
+func (s *Service) linkedUser(ctx context.Context, claims *IDTokenClaims) (dbstore.User, error) {
+	var email pgtype.Text
+	if claims.EmailVerified {
+		email, _ = normalizeEmail(claims.Email)
+	}
+
+	ident, err := s.store.GetUserIdentity(ctx, dbstore.GetUserIdentityParams{Issuer: claims.Issuer, Subject: claims.Subject})
+	if err == nil {
+		if err := s.store.TouchUserIdentity(ctx, dbstore.TouchUserIdentityParams{ID: ident.ID, Email: email}); err != nil {
+			return dbstore.User{}, err
+		}
+		u, err := s.store.GetUserByID(ctx, ident.UserID)
+		if err != nil {
+			return dbstore.User{}, err
+		}
+		return dbstore.User{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return dbstore.User{}, err
+	}
+
+	if !s.auth.OIDC.cfg.AllowSignup {
+		return dbstore.User{}, ErrSignupDisabled
+	}
+	u, err := s.provisionUser(ctx, claims, email)
+	if err != nil {
+		return dbstore.User{}, err
+	}
+
+	if _, err := s.store.CreateUserIdentity(ctx, dbstore.CreateUserIdentityParams{
+		UserID:  u.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   email,
+	}); err != nil {
+		return dbstore.User{}, err
+	}
+	s.logger.Info("sso identity linked", "user_id", u.ID, "issuer", claims.Issuer, "new_account", true)
+	return u, nil
+}
+
+// provisionUser creates the account for a first SSO login. The username is
+// taken from the ID token and made to fit the Policy and to be unique. The
+// account has no password, so it can only log in through SSO until one is
+// set with a reset link. If a local account already has the email, the new
+// one is created without it.
+func (s *Service) provisionUser(ctx context.Context, claims *IDTokenClaims, email pgtype.Text) (dbstore.User, error) {
+	if email.Valid {
+		_, err := s.store.GetUserByEmail(ctx, email.String)
+		if err == nil {
+			email = pgtype.Text{}
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.User{}, err
+		}
+	}
+
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameFrom(base, s.auth.Policy)
+
+	for i := 1; i <= 50; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		if s.auth.Policy.CheckUsername(name) != nil {
+			continue
+		}
+		_, err := s.store.GetUserByUsername(ctx, name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.User{}, err
+		}
+		return s.store.CreateUser(ctx, dbstore.CreateUserParams{Username: name, Email: email})
+	}
+	return dbstore.User{}, fmt.Errorf("no free username for %q", base)
+}
+
+// newOIDCLogin generates the one-time values of a login.
+func (s *Service) newOIDCLogin() (dbstore.CreateOIDCLoginParams, error) {
+	var vals [3]string
+	for i := range vals {
+		v, err := randomToken()
+		if err != nil {
+			return dbstore.CreateOIDCLoginParams{}, err
+		}
+		vals[i] = v
+	}
+	return dbstore.CreateOIDCLoginParams{
+		State:        vals[0],
+		Nonce:        vals[1],
+		CodeVerifier: vals[2],
+		ExpiresAt:    pgtype.Timestamptz{Time: s.now().UTC().Add(oidcLoginTTL), Valid: true},
+	}, nil
+}
+
+// usernameFrom turns a provider username or email local part into a
+// username candidate: unsupported characters are dropped and the result is
+// cut to leave room for a numeric suffix.
+func usernameFrom(s string, p Policy) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("_.-", r) && b.Len() > 0:
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if maxLen := p.UsernameMaxLen - 2; maxLen > 0 && len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	if len(name) < p.UsernameMinLen {
+		name = "user"
+	}
+	return name
+}
+
+// newUserCode returns a random code of userCodeLen characters.
+func newUserCode() (string, error) {
+	// bytes past the last multiple of the alphabet size are skipped so every
+	// character is equally likely
+	limit := byte(256 / len(userCodeAlphabet) * len(userCodeAlphabet))
+	code := make([]byte, 0, userCodeLen)
+	buf := make([]byte, userCodeLen)
+	for len(code) < userCodeLen {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, c := range buf {
+			if c < limit && len(code) < userCodeLen {
+				code = append(code, userCodeAlphabet[int(c)%len(userCodeAlphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts codes typed in lowercase, with or without the dash.
+func normalizeUserCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// randomToken returns 32 random bytes, base64url encoded.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
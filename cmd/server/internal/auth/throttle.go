@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login attempt scopes, as stored in login_attempts.scope.
+const (
+	ScopeUser   = "user"
+	ScopeIP     = "ip"
+	ScopeUserIP = "user_ip"
+)
+
+// Throttle describes how failed logins on one key are slowed down: after Free
+// failures each attempt must wait BaseDelay, doubling up to MaxDelay, and
+// after LockAfter failures the key is locked for LockFor.
+type Throttle struct {
+	Free      int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	LockAfter int
+	LockFor   time.Duration
+}
+
+// LoginLimits holds the throttles applied to failed logins per username,
+// per client IP and per username from one IP. Counters start over after
+// ResetAfter without failures.
+type LoginLimits struct {
+	User       Throttle
+	IP         Throttle
+	UserIP     Throttle
+	ResetAfter time.Duration
+}
+
+// DefaultLoginLimits returns the production defaults. A username alone is
+// only slowed down, never locked, so failures from unrelated IPs can't keep
+// its owner out; the lockout applies to a username from one IP. An IP gets
+// more room since several users can share one address.
+func DefaultLoginLimits() LoginLimits {
+	return LoginLimits{
+		User:       Throttle{Free: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second},
+		IP:         Throttle{Free: 10, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockAfter: 100, LockFor: 15 * time.Minute},
+		UserIP:     Throttle{Free: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockAfter: 10, LockFor: 15 * time.Minute},
+		ResetAfter: time.Hour,
+	}
+}
+
+// block returns how long a key must wait after its n-th consecutive failure.
+func (t Throttle) block(n int) time.Duration {
+	if t.LockAfter > 0 && n >= t.LockAfter {
+		return t.LockFor
+	}
+	if n <= t.Free {
+		return 0
+	}
+	d := t.BaseDelay
+	for i := t.Free + 1; i < n && d < t.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, t.MaxDelay)
+}
+
+// ThrottledError is returned by Login while the username or IP must wait
+// before trying again. It says nothing about whether the account exists.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// ThrottleStore is the persistence for failed login counters.
+type ThrottleStore interface {
+	GetLoginAttempts(ctx context.Context, arg dbstore.GetLoginAttemptsParams) ([]dbstore.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, arg dbstore.RecordLoginFailureParams) (int32, error)
+	BlockLogin(ctx context.Context, arg dbstore.BlockLoginParams) error
+	ClearLoginAttempts(ctx context.Context, arg dbstore.ClearLoginAttemptsParams) error
+	ResetLoginAttempts(ctx context.Context, username string) (int64, error)
+}
+
+// throttleSubject normalizes a username for the counters so "Alice" and
+// "alice" share one.
+func throttleSubject(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// userIPSubject is the counter of a username from one IP. The IP goes first
+// since it has no spaces, so ResetLoginAttempts can tell the username apart.
+func userIPSubject(username, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return ip + " " + throttleSubject(username)
+}
+
+// checkThrottle returns a ThrottledError if the username, the IP or the
+// username from that IP is still blocked.
+func (s *Service) checkThrottle(ctx context.Context, username, ip string) error {
+	attempts, err := s.store.GetLoginAttempts(ctx, dbstore.GetLoginAttemptsParams{
+		Username: throttleSubject(username),
+		Ip:       ip,
+		UserIp:   userIPSubject(username, ip),
+	})
+	if err != nil {
+		return err
+	}
+	var wait time.Duration
+	for _, a := range attempts {
+		if a.BlockedUntil.Valid {
+			wait = max(wait, a.BlockedUntil.Time.Sub(s.now()))
+		}
+	}
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordFailure counts a failed login for the username, the IP and the pair
+// and blocks them for as long as their throttles say.
+func (s *Service) recordFailure(ctx context.Context, username, ip string) error {
+	s.record(ctx, audit.Event{Action: audit.ActionLoginFailed, Details: map[string]any{"username": username}})
+	keys := []struct {
+		scope, subject string
+		throttle       Throttle
+	}{
+		{ScopeUser, throttleSubject(username), s.auth.Login.User},
+		{ScopeIP, ip, s.auth.Login.IP},
+		{ScopeUserIP, userIPSubject(username, ip), s.auth.Login.UserIP},
+	}
+	for _, k := range keys {
+		if k.subject == "" {
+			continue
+		}
+		n, err := s.store.RecordLoginFailure(ctx, dbstore.RecordLoginFailureParams{
+			Scope:        k.scope,
+			Subject:      k.subject,
+			ResetSeconds: int32(s.auth.Login.ResetAfter / time.Second),
+		})
+		if err != nil {
+			return err
+		}
+		d := k.throttle.block(int(n))
+		if d == 0 {
+			continue
+		}
+		if int(n) == k.throttle.LockAfter {
+			s.logger.Warn("login locked", "scope", k.scope, "subject", k.subject, "failures", n)
+		}
+		if err := s.store.BlockLogin(ctx, dbstore.BlockLoginParams{
+			Scope:        k.scope,
+			Subject:      k.subject,
+			BlockedUntil: pgtype.Timestamptz{Time: s.now().Add(d), Valid: true},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearFailures forgets the failures of a username after it logged in from
+// ip. The lockouts of the username from other IPs are kept, or its owner
+// logging in would let an attacker elsewhere start guessing again.
+func (s *Service) clearFailures(ctx context.Context, username, ip string) error {
+	return s.store.ClearLoginAttempts(ctx, dbstore.ClearLoginAttemptsParams{
+		Username: throttleSubject(username),
+		UserIp:   userIPSubject(username, ip),
+	})
+}
+
+// AdminUnlock clears the lockout of a username on behalf of an administrator.
+func (s *Service) AdminUnlock(ctx context.Context, adminID int64, username string) (bool, error) {
+	cleared, err := s.Unlock(ctx, username)
+	if err != nil || !cleared {
+		return cleared, err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionLockoutCleared, ActorID: adminID, Details: map[string]any{"username": username}})
+	return true, nil
+}
+
+// Unlock clears the failed login counters of a username, from every IP. It
+// reports whether there was anything to clear.
+func (s *Service) Unlock(ctx context.Context, username string) (bool, error) {
+	n, err := s.store.ResetLoginAttempts(ctx, throttleSubject(username))
+	return n > 0, err
+}
+
+// dummyHash is compared against when the username doesn't exist, so a
+// failed login takes as long whether or not the account exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	h, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return h
+})
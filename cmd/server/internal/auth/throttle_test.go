@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeStore keeps users and login counters in memory. Methods the tests don't
+// reach fall through to the nil embedded Store and panic.
+type fakeStore struct {
+	Store
+	users      map[string]dbstore.User
+	attempts   map[[2]string]*dbstore.LoginAttempt
+	sessions   int64
+	totp       map[int64]*dbstore.UserTotp
+	recovery   map[string]bool  // hex code hash → used
+	resets     map[string]int64 // hex token hash → user id, while unused
+	revoked    map[int64]bool   // session id → revoked
+	oidc       []*dbstore.OidcLogin
+	identities []dbstore.UserIdentity
+	audit      []dbstore.CreateAuditEventParams
+}
+
+func newFakeStore(t *testing.T) *fakeStore {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeStore{
+		users:    map[string]dbstore.User{"alice": {ID: 1, Username: "alice", Password: string(hash)}},
+		attempts: make(map[[2]string]*dbstore.LoginAttempt),
+		totp:     make(map[int64]*dbstore.UserTotp),
+		recovery: make(map[string]bool),
+		resets:   make(map[string]int64),
+		revoked:  make(map[int64]bool),
+	}
+}
+
+func (f *fakeStore) GetUserByUsername(_ context.Context, username string) (dbstore.User, error) {
+	u, ok := f.users[username]
+	if !ok {
+		return u, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeStore) GetLoginAttempts(_ context.Context, arg dbstore.GetLoginAttemptsParams) ([]dbstore.LoginAttempt, error) {
+	var out []dbstore.LoginAttempt
+	for _, k := range [][2]string{{ScopeUser, arg.Username}, {ScopeIP, arg.Ip}, {ScopeUserIP, arg.UserIp}} {
+		if a, ok := f.attempts[k]; ok {
+			out = append(out, *a)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) RecordLoginFailure(_ context.Context, arg dbstore.RecordLoginFailureParams) (int32, error) {
+	k := [2]string{arg.Scope, arg.Subject}
+	a, ok := f.attempts[k]
+	if !ok {
+		a = &dbstore.LoginAttempt{Scope: arg.Scope, Subject: arg.Subject}
+		f.attempts[k] = a
+	}
+	a.Failures++
+	return a.Failures, nil
+}
+
+func (f *fakeStore) BlockLogin(_ context.Context, arg dbstore.BlockLoginParams) error {
+	f.attempts[[2]string{arg.Scope, arg.Subject}].BlockedUntil = arg.BlockedUntil
+	return nil
+}
+
+func (f *fakeStore) ClearLoginAttempts(_ context.Context, arg dbstore.ClearLoginAttemptsParams) error {
+	delete(f.attempts, [2]string{ScopeUser, arg.Username})
+	delete(f.attempts, [2]string{ScopeUserIP, arg.UserIp})
+	return nil
+}
+
+func (f *fakeStore) ResetLoginAttempts(_ context.Context, username string) (int64, error) {
+	var n int64
+	for k := range f.attempts {
+		_, name, _ := strings.Cut(k[1], " ")
+		if k == [2]string{ScopeUser, username} || k[0] == ScopeUserIP && name == username {
+			delete(f.attempts, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) CreateSession(_ context.Context, arg dbstore.CreateSessionParams) (dbstore.Session, error) {
+	f.sessions++
+	return dbstore.Session{ID: f.sessions, UserID: arg.UserID, ExpiresAt: arg.ExpiresAt}, nil
+}
+
+func (f *fakeStore) CreateRefreshToken(context.Context, dbstore.CreateRefreshTokenParams) error {
+	return nil
+}
+
+func (f *fakeStore) CreateAuditEvent(_ context.Context, arg dbstore.CreateAuditEventParams) error {
+	f.audit = append(f.audit, arg)
+	return nil
+}
+
+// auditActions returns the actions recorded so far, oldest first.
+func (f *fakeStore) auditActions() []string {
+	var out []string
+	for _, e := range f.audit {
+		out = append(out, e.Action)
+	}
+	return out
+}
+
+func newThrottleService(t *testing.T) (*Service, *fakeStore, *time.Time) {
+	t.Helper()
+	store := newFakeStore(t)
+	cfg := &Config{
+		JWTSecret:  []byte("0123456789abcdef"),
+		Issuer:     "test",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+		Login: LoginLimits{
+			User:       Throttle{Free: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+			IP:         Throttle{Free: 100, BaseDelay: time.Second, MaxDelay: time.Second, LockAfter: 1000, LockFor: time.Hour},
+			UserIP:     Throttle{Free: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockAfter: 5, LockFor: time.Hour},
+			ResetAfter: time.Hour,
+		},
+	}
+	svc := NewService(store, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), cfg)
+	now := time.Now().Truncate(time.Second)
+	svc.now = func() time.Time { return now }
+	return svc, store, &now
+}
+
+func TestThrottle_Block(t *testing.T) {
+	th := Throttle{Free: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second, LockAfter: 6, LockFor: time.Hour}
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, time.Hour, time.Hour}
+	for n := 1; n <= len(want); n++ {
+		if got := th.block(n); got != want[n-1] {
+			t.Errorf("block(%d) = %s, want %s", n, got, want[n-1])
+		}
+	}
+}
+
+func TestLogin_NoUserEnumeration(t *testing.T) {
+	svc, _, _ := newThrottleService(t)
+	meta := SessionMeta{IP: "10.0.0.1"}
+
+	_, errUnknown := svc.Login(context.Background(), reqdto.LoginReq{Username: "mallory", Password: "x"}, meta)
+	_, errWrong := svc.Login(context.Background(), reqdto.LoginReq{Username: "alice", Password: "x"}, meta)
+	if !errors.Is(errUnknown, ErrInvalidCreds) || !errors.Is(errWrong, ErrInvalidCreds) {
+		t.Fatalf("expected ErrInvalidCreds for both, got %v and %v", errUnknown, errWrong)
+	}
+}
+
+func TestLogin_Audited(t *testing.T) {
+	svc, store, _ := newThrottleService(t)
+	ctx := context.Background()
+	meta := SessionMeta{IP: "10.0.0.1"}
+
+	if _, err := svc.Login(ctx, reqdto.LoginReq{Username: "alice", Password: "nope"}, meta); !errors.Is(err, ErrInvalidCreds) {
+		t.Fatalf("expected ErrInvalidCreds, got %v", err)
+	}
+	if _, err := svc.Login(ctx, reqdto.LoginReq{Username: "alice", Password: "correct horse"}, meta); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := svc.AdminUnlock(ctx, 9, "alice"); err != nil || ok {
+		t.Fatalf("nothing to unlock after a good login, got %v %v", ok, err)
+	}
+
+	want := []string{audit.ActionLoginFailed, audit.ActionLogin}
+	if got := store.auditActions(); !slices.Equal(got, want) {
+		t.Fatalf("audit actions = %v, want %v", got, want)
+	}
+	if failed := store.audit[0]; failed.ActorID.Valid || !strings.Contains(string(failed.Details), `"alice"`) {
+		t.Errorf("failed login event = %+v", failed)
+	}
+	if login := store.audit[1]; login.ActorID.Int64 != 1 || login.TargetType != audit.TargetSession || login.TargetID.Int64 != store.sessions {
+		t.Errorf("login event = %+v", login)
+	}
+}
+
+func TestLogin_ProgressiveDelayAndLockout(t *testing.T) {
+	svc, store, now := newThrottleService(t)
+	ctx := context.Background()
+	meta := SessionMeta{IP: "10.0.0.1"}
+	wrong := reqdto.LoginReq{Username: "Alice", Password: "nope"}
+	right := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+
+	// two free failures, then the third blocks for a second
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Login(ctx, wrong, meta); !errors.Is(err, ErrInvalidCreds) {
+			t.Fatalf("attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+	var throttled *ThrottledError
+	if _, err := svc.Login(ctx, right, meta); !errors.As(err, &throttled) || throttled.RetryAfter != time.Second {
+		t.Fatalf("expected a 1s block even with the right password, got %v", err)
+	}
+
+	// keep failing after each block until the account locks
+	for i := 0; i < 2; i++ {
+		*now = now.Add(time.Minute)
+		if _, err := svc.Login(ctx, wrong, meta); !errors.Is(err, ErrInvalidCreds) {
+			t.Fatalf("expected ErrInvalidCreds, got %v", err)
+		}
+	}
+	if _, err := svc.Login(ctx, right, meta); !errors.As(err, &throttled) || throttled.RetryAfter != time.Hour {
+		t.Fatalf("expected the account to be locked for an hour, got %v", err)
+	}
+
+	// an admin unlock lets the right password in again and clears the counter
+	if ok, err := svc.Unlock(ctx, "ALICE"); err != nil || !ok {
+		t.Fatalf("unlock: %v %v", ok, err)
+	}
+	if _, err := svc.Login(ctx, right, meta); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+	if _, ok := store.attempts[[2]string{ScopeUser, "alice"}]; ok {
+		t.Fatal("expected a successful login to clear the username counter")
+	}
+}
+
+func TestLogin_OtherIPsDontLockOut(t *testing.T) {
+	svc, _, now := newThrottleService(t)
+	ctx := context.Background()
+	wrong := reqdto.LoginReq{Username: "alice", Password: "nope"}
+
+	// an attacker spreads failures over many addresses, waiting out each delay
+	for i := range 20 {
+		*now = now.Add(time.Minute)
+		meta := SessionMeta{IP: fmt.Sprintf("203.0.113.%d", i)}
+		if _, err := svc.Login(ctx, wrong, meta); !errors.Is(err, ErrInvalidCreds) {
+			t.Fatalf("attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+
+	// the username is only delayed, so alice gets in once the delay is over
+	var throttled *ThrottledError
+	right := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+	if _, err := svc.Login(ctx, right, SessionMeta{IP: "10.0.0.1"}); !errors.As(err, &throttled) || throttled.RetryAfter > 4*time.Second {
+		t.Fatalf("expected at most the max delay, got %v", err)
+	}
+	*now = now.Add(4 * time.Second)
+	if _, err := svc.Login(ctx, right, SessionMeta{IP: "10.0.0.1"}); err != nil {
+		t.Fatalf("expected alice to log in, got %v", err)
+	}
+}
+
+func TestLogin_OwnIPDoesntUnlockOthers(t *testing.T) {
+	svc, store, now := newThrottleService(t)
+	ctx := context.Background()
+	wrong := reqdto.LoginReq{Username: "alice", Password: "nope"}
+	right := reqdto.LoginReq{Username: "alice", Password: "correct horse"}
+	attacker, owner := SessionMeta{IP: "203.0.113.7"}, SessionMeta{IP: "10.0.0.1"}
+
+	// lock alice from the attacker's address, waiting out each delay
+	for i := range 5 {
+		*now = now.Add(time.Minute)
+		if _, err := svc.Login(ctx, wrong, attacker); !errors.Is(err, ErrInvalidCreds) {
+			t.Fatalf("attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+	var throttled *ThrottledError
+	if _, err := svc.Login(ctx, right, attacker); !errors.As(err, &throttled) || throttled.RetryAfter != time.Hour {
+		t.Fatalf("expected alice locked from the attacker's address, got %v", err)
+	}
+
+	// alice logs in from their own address once the username delay is over
+	*now = now.Add(time.Minute)
+	if _, err := svc.Login(ctx, right, owner); err != nil {
+		t.Fatalf("expected alice to log in, got %v", err)
+	}
+	if _, ok := store.attempts[[2]string{ScopeUser, "alice"}]; ok {
+		t.Fatal("expected the login to clear the username counter")
+	}
+
+	// the attacker's address stays locked
+	if _, err := svc.Login(ctx, wrong, attacker); !errors.As(err, &throttled) || throttled.RetryAfter != time.Hour-time.Minute {
+		t.Fatalf("expected the attacker's address to stay locked, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+const (
+	// challengeAudience marks the short-lived token Login returns when the
+	// account has 2FA; it is only accepted by LoginTwoFactor.
+	challengeAudience = "2fa-challenge"
+	challengeTTL      = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+// ErrTwoFactorEnabled indicates 2FA is already on for the account.
+// ErrTwoFactorNotEnrolled indicates there is no 2FA enrollment to act on.
+// ErrInvalidCode indicates a wrong, expired or already used code.
+// ErrInvalidChallenge indicates an unknown or expired login challenge.
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication already enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication not enrolled")
+	ErrInvalidCode          = errors.New("invalid code")
+	ErrInvalidChallenge     = errors.New("invalid login challenge")
+)
+
+// TwoFactorStore is the persistence for TOTP secrets and recovery codes.
+type TwoFactorStore interface {
+	GetUserTOTP(ctx context.Context, userID int64) (dbstore.UserTotp, error)
+	UpsertPendingTOTP(ctx context.Context, arg dbstore.UpsertPendingTOTPParams) (int64, error)
+	EnableTOTP(ctx context.Context, userID int64) (int64, error)
+	AdvanceTOTPStep(ctx context.Context, arg dbstore.AdvanceTOTPStepParams) (int64, error)
+	DeleteTOTP(ctx context.Context, userID int64) error
+	CreateRecoveryCode(ctx context.Context, arg dbstore.CreateRecoveryCodeParams) error
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
+	UseRecoveryCode(ctx context.Context, arg dbstore.UseRecoveryCodeParams) (int64, error)
+}
+
+// EnrollTOTP creates a new TOTP secret for the user. 2FA only turns on once
+// ConfirmTOTP sees a valid code; enrolling again before that replaces the secret.
+func (s *Service) EnrollTOTP(ctx context.Context, userID int64, username string) (resdto.TOTPEnrollRes, error) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return resdto.TOTPEnrollRes{}, err
+	}
+	n, err := s.store.UpsertPendingTOTP(ctx, dbstore.UpsertPendingTOTPParams{UserID: userID, Secret: secret})
+	if err != nil {
+		return resdto.TOTPEnrollRes{}, err
+	}
+	if n == 0 {
+		return resdto.TOTPEnrollRes{}, ErrTwoFactorEnabled
+	}
+	return resdto.TOTPEnrollRes{
+		Secret:     secret,
+		OtpauthURI: otpauthURI(s.auth.Issuer, username, secret),
+	}, nil
+}
+
+// ConfirmTOTP checks the first code from the authenticator app, turns 2FA on
+// and returns a fresh set of recovery codes. They are only shown here.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID int64, code string) (resdto.RecoveryCodesRes, error) {
+	t, err := s.store.GetUserTOTP(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return resdto.RecoveryCodesRes{}, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return resdto.RecoveryCodesRes{}, err
+	}
+	if t.EnabledAt.Valid {
+		return resdto.RecoveryCodesRes{}, ErrTwoFactorEnabled
+	}
+	ok, err := s.checkTOTP(ctx, t, normalizeCode(code))
+	if err != nil {
+		return resdto.RecoveryCodesRes{}, err
+	}
+	if !ok {
+		return resdto.RecoveryCodesRes{}, ErrInvalidCode
+	}
+	if _, err := s.store.EnableTOTP(ctx, userID); err != nil {
+		return resdto.RecoveryCodesRes{}, err
+	}
+
+	codes, err := s.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		return resdto.RecoveryCodesRes{}, err
+	}
+	s.record(ctx, audit.Event{Action: audit.ActionTwoFactorEnabled, ActorID: userID, TargetType: audit.TargetUser, TargetID: userID})
+	return resdto.RecoveryCodesRes{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns 2FA off. It takes a current code or a recovery code so a
+// stolen access token alone can't remove the second factor. Wrong codes count
+// as failed logins.
+func (s *Service) DisableTOTP(ctx context.Context, claims *Claims, code string, meta SessionMeta) error {
+	if err := s.checkThrottle(ctx, claims.Username, meta.IP); err != nil {
+		return err
+	}
+	userID := claims.UserID
+	t, err := s.store.GetUserTOTP(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if t.EnabledAt.Valid {
+		ok, err := s.verifySecondFactor(ctx, t, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := s.recordFailure(ctx, claims.Username, meta.IP); err != nil {
+				return err
+			}
+			return ErrInvalidCode
+		}
+	}
+	if err := s.store.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.store.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	// dropping an enrollment that was never confirmed isn't worth auditing
+	if t.EnabledAt.Valid {
+		s.record(ctx, audit.Event{Action: audit.ActionTwoFactorDisabled, ActorID: userID, TargetType: audit.TargetUser, TargetID: userID})
+	}
+	return nil
+}
+
+// LoginTwoFactor completes a login started by Login: it checks the challenge
+// token and a TOTP or recovery code, then starts the session. Wrong codes
+// count as failed logins.
+func (s *Service) LoginTwoFactor(ctx context.Context, challenge, code string, meta SessionMeta) (resdto.AuthRes, error) {
+	claims, err := s.parseChallenge(challenge)
+	if err != nil {
+		return resdto.AuthRes{}, ErrInvalidChallenge
+	}
+	if err := s.checkThrottle(ctx, claims.Username, meta.IP); err != nil {
+		return resdto.AuthRes{}, err
+	}
+
+	t, err := s.store.GetUserTOTP(ctx, claims.UserID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return resdto.AuthRes{}, err
+	}
+	if err != nil || !t.EnabledAt.Valid {
+		// 2FA was turned off after the challenge was issued
+		return resdto.AuthRes{}, ErrInvalidChallenge
+	}
+	ok, err := s.verifySecondFactor(ctx, t, code)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if !ok {
+		if err := s.recordFailure(ctx, claims.Username, meta.IP); err != nil {
+			return resdto.AuthRes{}, err
+		}
+		return resdto.AuthRes{}, ErrInvalidCode
+	}
+
+	u, err := s.store.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	if err := s.clearFailures(ctx, u.Username, meta.IP); err != nil {
+		return resdto.AuthRes{}, err
+	}
+	return s.startSession(ctx, dbstore.User{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt}, meta)
+}
+
+// twoFactorEnabled reports whether logging in as userID needs a second factor.
+func (s *Service) twoFactorEnabled(ctx context.Context, userID int64) (bool, error) {
+	t, err := s.store.GetUserTOTP(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.EnabledAt.Valid, nil
+}
+
+// challengeResponse is what Login returns instead of tokens when 2FA is on.
+func (s *Service) challengeResponse(u dbstore.User) (resdto.AuthRes, error) {
+	now := s.now().UTC()
+	exp := now.Add(challengeTTL)
+	token, err := SignToken(Claims{
+		UserID:   u.ID,
+		Username: u.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.auth.Issuer,
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}, s.auth)
+	if err != nil {
+		return resdto.AuthRes{}, err
+	}
+	return resdto.AuthRes{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         exp.Unix(),
+	}, nil
+}
+
+func (s *Service) parseChallenge(token string) (*Claims, error) {
+	claims, err := parseClaims(token, s.auth, jwt.WithTimeFunc(s.now))
+	if err != nil {
+		return nil, err
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != challengeAudience {
+		return nil, errors.New("not a login challenge")
+	}
+	return claims, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func (s *Service) verifySecondFactor(ctx context.Context, t dbstore.UserTotp, code string) (bool, error) {
+	code = normalizeCode(code)
+	if len(code) == totpDigits {
+		return s.checkTOTP(ctx, t, code)
+	}
+	n, err := s.store.UseRecoveryCode(ctx, dbstore.UseRecoveryCodeParams{
+		UserID:   t.UserID,
+		CodeHash: hashToken(code),
+	})
+	return n > 0, err
+}
+
+// checkTOTP validates code and marks its time step as used, so the same code
+// can't be replayed within its validity window.
+func (s *Service) checkTOTP(ctx context.Context, t dbstore.UserTotp, code string) (bool, error) {
+	step, ok := matchTOTP(t.Secret, code, s.now())
+	if !ok || step <= t.LastStep {
+		return false, nil
+	}
+	n, err := s.store.AdvanceTOTPStep(ctx, dbstore.AdvanceTOTPStepParams{UserID: t.UserID, LastStep: step})
+	return n > 0, err
+}
+
+// newRecoveryCodes replaces the user's recovery codes and returns the new ones.
+func (s *Service) newRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	if err := s.store.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(b32.EncodeToString(b)) // 8 characters
+		codes[i] = raw[:4] + "-" + raw[4:]
+		if err := s.store.CreateRecoveryCode(ctx, dbstore.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashToken(raw),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// normalizeCode strips the separators people type in codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
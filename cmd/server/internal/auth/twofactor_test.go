@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) GetUserByID(_ context.Context, id int64) (dbstore.GetUserByIDRow, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return dbstore.GetUserByIDRow{ID: u.ID, Username: u.Username, CreatedAt: u.CreatedAt}, nil
+		}
+	}
+	return dbstore.GetUserByIDRow{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) GetUserTOTP(_ context.Context, userID int64) (dbstore.UserTotp, error) {
+	t, ok := f.totp[userID]
+	if !ok {
+		return dbstore.UserTotp{}, pgx.ErrNoRows
+	}
+	return *t, nil
+}
+
+func (f *fakeStore) UpsertPendingTOTP(_ context.Context, arg dbstore.UpsertPendingTOTPParams) (int64, error) {
+	if t, ok := f.totp[arg.UserID]; ok && t.EnabledAt.Valid {
+		return 0, nil
+	}
+	f.totp[arg.UserID] = &dbstore.UserTotp{UserID: arg.UserID, Secret: arg.Secret}
+	return 1, nil
+}
+
+func (f *fakeStore) EnableTOTP(_ context.Context, userID int64) (int64, error) {
+	f.totp[userID].EnabledAt.Valid = true
+	return 1, nil
+}
+
+func (f *fakeStore) AdvanceTOTPStep(_ context.Context, arg dbstore.AdvanceTOTPStepParams) (int64, error) {
+	t := f.totp[arg.UserID]
+	if t.LastStep >= arg.LastStep {
+		return 0, nil
+	}
+	t.LastStep = arg.LastStep
+	return 1, nil
+}
+
+func (f *fakeStore) DeleteRecoveryCodes(context.Context, int64) error {
+	clear(f.recovery)
+	return nil
+}
+
+func (f *fakeStore) CreateRecoveryCode(_ context.Context, arg dbstore.CreateRecoveryCodeParams) error {
+	f.recovery[hex.EncodeToString(arg.CodeHash)] = false
+	return nil
+}
+
+func (f *fakeStore) UseRecoveryCode(_ context.Context, arg dbstore.UseRecoveryCodeParams) (int64, error) {
+	k := hex.EncodeToString(arg.CodeHash)
+	if used, ok := f.recovery[k]; !ok || used {
+		return 0, nil
+	}
+	f.recovery[k] = true
+	return 1, nil
+}
+
+// codeAt returns the TOTP code for secret at t.
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := b32.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hotp(key, totpStep(at))
+}
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 seed, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	cases := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range cases {
+		if got := hotp(key, totpStep(time.Unix(unix, 0))); got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestTwoFactor_EnrollAndLogin(t *testing.T) {
+	svc, _, now := newThrottleService(t)
+	ctx := context.Background()
+	meta := SessionMeta{IP: "10.0.0.1"}
+
+	enroll, err := svc.EnrollTOTP(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enroll.OtpauthURI, "otpauth://totp/test:alice?") || !strings.Contains(enroll.OtpauthURI, "secret="+enroll.Secret) {
+		t.Fatalf("unexpected otpauth URI %q", enroll.OtpauthURI)
+	}
+	if _, err := svc.ConfirmTOTP(ctx, 1, "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected a wrong first code to be rejected, got %v", err)
+	}
+	codes, err := svc.ConfirmTOTP(ctx, 1, codeAt(t, enroll.Secret, *now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(codes.RecoveryCodes))
+	}
+	if _, err := svc.EnrollTOTP(ctx, 1, "alice"); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Fatalf("expected re-enrolling to be refused, got %v", err)
+	}
+
+	// the password alone now only yields a challenge, not usable as an access token
+	res, err := svc.Login(ctx, reqdto.LoginReq{Username: "alice", Password: "correct horse"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TwoFactorRequired || res.Token != "" || res.ChallengeToken == "" {
+		t.Fatalf("expected a challenge, got %+v", res)
+	}
+	if _, err := ParseToken(res.ChallengeToken, svc.auth); err == nil {
+		t.Fatal("challenge token must not be accepted as an access token")
+	}
+
+	*now = now.Add(time.Minute)
+	code := codeAt(t, enroll.Secret, *now)
+	done, err := svc.LoginTwoFactor(ctx, res.ChallengeToken, code, meta)
+	if err != nil || done.Token == "" || done.RefreshToken == "" {
+		t.Fatalf("expected tokens after the second step, got %+v, %v", done, err)
+	}
+	if _, err := svc.LoginTwoFactor(ctx, res.ChallengeToken, code, meta); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected a replayed code to be rejected, got %v", err)
+	}
+
+	// recovery codes work once, with or without the dash
+	recovery := strings.ToUpper(strings.ReplaceAll(codes.RecoveryCodes[0], "-", ""))
+	if _, err := svc.LoginTwoFactor(ctx, res.ChallengeToken, recovery, meta); err != nil {
+		t.Fatalf("expected the recovery code to work: %v", err)
+	}
+	if _, err := svc.LoginTwoFactor(ctx, res.ChallengeToken, codes.RecoveryCodes[0], meta); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected a used recovery code to be rejected, got %v", err)
+	}
+
+	*now = now.Add(challengeTTL)
+	if _, err := svc.LoginTwoFactor(ctx, res.ChallengeToken, codes.RecoveryCodes[1], meta); !errors.Is(err, ErrInvalidChallenge) {
+		t.Fatalf("expected an expired challenge to be rejected, got %v", err)
+	}
+}
+
+func TestTwoFactor_DisableThrottled(t *testing.T) {
+	svc, store, now := newThrottleService(t)
+	ctx := context.Background()
+	claims := &Claims{UserID: 1, Username: "alice"}
+	meta := SessionMeta{IP: "10.0.0.1"}
+
+	enroll, err := svc.EnrollTOTP(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ConfirmTOTP(ctx, 1, codeAt(t, enroll.Secret, *now)); err != nil {
+		t.Fatal(err)
+	}
+
+	// wrong codes count as failed logins: two free, then a delay
+	for i := 0; i < 3; i++ {
+		if err := svc.DisableTOTP(ctx, claims, "000000", meta); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+	var throttled *ThrottledError
+	if err := svc.DisableTOTP(ctx, claims, "000000", meta); !errors.As(err, &throttled) || throttled.RetryAfter != time.Second {
+		t.Fatalf("expected a 1s block, got %v", err)
+	}
+
+	// keep failing after each block until the account locks
+	for i := 0; i < 2; i++ {
+		*now = now.Add(time.Minute)
+		if err := svc.DisableTOTP(ctx, claims, "000000", meta); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+	}
+	if err := svc.DisableTOTP(ctx, claims, codeAt(t, enroll.Secret, *now), meta); !errors.As(err, &throttled) || throttled.RetryAfter != time.Hour {
+		t.Fatalf("expected the right code to be locked out for an hour, got %v", err)
+	}
+	if !store.totp[1].EnabledAt.Valid {
+		t.Fatal("2FA must stay on while locked out")
+	}
+}
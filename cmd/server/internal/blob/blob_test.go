@@ -0,0 +1,112 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3 bucket. It only checks
+// that requests carry a V4 signature for the expected access key.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			http.Error(w, "missing content length", http.StatusLengthRequired)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func roundTrip(t *testing.T, s store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "uploads/ab/file.txt", strings.NewReader("hello"), -1, "text/plain"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	rc, err := s.Get(ctx, "uploads/ab/file.txt")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", got)
+	}
+
+	if err := s.Delete(ctx, "uploads/ab/file.txt"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "uploads/ab/file.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestLocal_RoundTrip(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, s)
+}
+
+func TestLocal_RejectsTraversal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "../escape", strings.NewReader("x"), 1, ""); err == nil {
+		t.Fatal("expected error for key with ..")
+	}
+}
+
+func TestS3_RoundTrip(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer srv.Close()
+
+	s := NewS3(S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "chat",
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	}, srv.Client())
+	roundTrip(t, s)
+}
@@ -0,0 +1,3 @@
+// Package blob provides storage backends for uploaded files: a local
+// filesystem store and an S3-compatible object store.
+package blob
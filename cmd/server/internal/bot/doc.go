@@ -0,0 +1,4 @@
+// Package bot contains the domain logic for bot accounts: users without a
+// password that authenticate with long-lived tokens, and the incoming
+// webhooks that post to a room as a dedicated bot.
+package bot
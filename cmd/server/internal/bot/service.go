@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Store defines the persistence methods required for bots and incoming webhooks.
+type Store interface {
+	GetUserByUsername(ctx context.Context, username string) (dbstore.User, error)
+	CreateBotUser(ctx context.Context, arg dbstore.CreateBotUserParams) (dbstore.User, error)
+	GetBot(ctx context.Context, id int64) (dbstore.User, error)
+	ListBotsByOwner(ctx context.Context, ownerID pgtype.Int8) ([]dbstore.User, error)
+	CreateBotToken(ctx context.Context, arg dbstore.CreateBotTokenParams) error
+	RevokeBotTokens(ctx context.Context, botID int64) error
+	GetBotByTokenHash(ctx context.Context, tokenHash []byte) (dbstore.GetBotByTokenHashRow, error)
+	CreateIncomingWebhook(ctx context.Context, arg dbstore.CreateIncomingWebhookParams) (dbstore.IncomingWebhook, error)
+	ListIncomingWebhooksByRoom(ctx context.Context, roomID int64) ([]dbstore.ListIncomingWebhooksByRoomRow, error)
+	DeleteIncomingWebhook(ctx context.Context, arg dbstore.DeleteIncomingWebhookParams) (int64, error)
+	GetIncomingWebhookByTokenHash(ctx context.Context, tokenHash []byte) (dbstore.GetIncomingWebhookByTokenHashRow, error)
+	GetRoomMemberRole(ctx context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error)
+	AddRoomMember(ctx context.Context, arg dbstore.AddRoomMemberParams) error
+}
+
+// Disconnector closes the live connection of a user, e.g. the ws.Hub.
+type Disconnector interface {
+	DisconnectUser(userID int64)
+}
+
+const maxUsernameRunes = 32
+
+// ErrInvalidToken is returned for unknown or revoked bot tokens.
+var ErrInvalidToken = errors.New("invalid bot token")
+
+// Service provides bot-related business logic.
+type Service struct {
+	store  Store
+	conns  Disconnector
+	logger *slog.Logger
+}
+
+// NewService creates a new bot Service. conns closes the connection of a bot
+// whose token is rotated.
+func NewService(s Store, conns Disconnector, l *slog.Logger) *Service {
+	return &Service{store: s, conns: conns, logger: l}
+}
+
+// Create registers a bot owned by ownerID and returns it with its first token.
+// The token is only ever returned here and by RotateToken.
+func (s *Service) Create(ctx context.Context, ownerID int64, username string) (dbstore.User, string, error) {
+	bot, err := s.createBotUser(ctx, ownerID, username)
+	if err != nil {
+		return dbstore.User{}, "", err
+	}
+	token, err := s.issueToken(ctx, bot.ID)
+	if err != nil {
+		return dbstore.User{}, "", err
+	}
+	return bot, token, nil
+}
+
+// List returns the bots owned by ownerID.
+func (s *Service) List(ctx context.Context, ownerID int64) ([]dbstore.User, error) {
+	return s.store.ListBotsByOwner(ctx, pgtype.Int8{Int64: ownerID, Valid: true})
+}
+
+// RotateToken revokes every token of a bot, closes its connection, which was
+// opened with one of them, and issues a new token.
+func (s *Service) RotateToken(ctx context.Context, ownerID, botID int64) (string, error) {
+	bot, err := s.store.GetBot(ctx, botID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", httpx.New(http.StatusNotFound, "not_found", "bot not found", err)
+		}
+		return "", err
+	}
+	if bot.OwnerID.Int64 != ownerID {
+		// don't reveal other users' bots
+		return "", httpx.New(http.StatusNotFound, "not_found", "bot not found", nil)
+	}
+
+	if err := s.store.RevokeBotTokens(ctx, botID); err != nil {
+		return "", err
+	}
+	s.conns.DisconnectUser(botID)
+	return s.issueToken(ctx, botID)
+}
+
+// VerifyBotToken implements auth.BotVerifier.
+func (s *Service) VerifyBotToken(ctx context.Context, token string) (*auth.Claims, error) {
+	bot, err := s.store.GetBotByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return &auth.Claims{UserID: bot.ID, Username: bot.Username, IsBot: true}, nil
+}
+
+// CreateIncomingWebhook creates a bot named username, adds it to the room and
+// returns a webhook whose secret token lets anyone holding it post as that bot.
+func (s *Service) CreateIncomingWebhook(ctx context.Context, roomID, actorID int64, username string) (dbstore.IncomingWebhook, dbstore.User, string, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return dbstore.IncomingWebhook{}, dbstore.User{}, "", err
+	}
+
+	bot, err := s.createBotUser(ctx, actorID, username)
+	if err != nil {
+		return dbstore.IncomingWebhook{}, dbstore.User{}, "", err
+	}
+	err = s.store.AddRoomMember(ctx, dbstore.AddRoomMemberParams{RoomID: roomID, UserID: bot.ID, Role: room.RoleMember})
+	if err != nil {
+		return dbstore.IncomingWebhook{}, dbstore.User{}, "", err
+	}
+
+	token, err := newToken("")
+	if err != nil {
+		return dbstore.IncomingWebhook{}, dbstore.User{}, "", err
+	}
+	hook, err := s.store.CreateIncomingWebhook(ctx, dbstore.CreateIncomingWebhookParams{
+		RoomID:    roomID,
+		BotID:     bot.ID,
+		TokenHash: hashToken(token),
+		CreatedBy: actorID,
+	})
+	if err != nil {
+		return dbstore.IncomingWebhook{}, dbstore.User{}, "", err
+	}
+	return hook, bot, token, nil
+}
+
+// ListIncomingWebhooks returns the incoming webhooks of a room.
+func (s *Service) ListIncomingWebhooks(ctx context.Context, roomID, actorID int64) ([]dbstore.ListIncomingWebhooksByRoomRow, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	return s.store.ListIncomingWebhooksByRoom(ctx, roomID)
+}
+
+// DeleteIncomingWebhook invalidates an incoming webhook. Its bot and the
+// messages it posted are kept.
+func (s *Service) DeleteIncomingWebhook(ctx context.Context, roomID, actorID, webhookID int64) error {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return err
+	}
+	n, err := s.store.DeleteIncomingWebhook(ctx, dbstore.DeleteIncomingWebhookParams{ID: webhookID, RoomID: roomID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "webhook not found", nil)
+	}
+	return nil
+}
+
+// ResolveIncomingWebhook returns the room and bot an incoming webhook token
+// posts as. Webhooks of a deactivated bot or owner are not found.
+func (s *Service) ResolveIncomingWebhook(ctx context.Context, token string) (dbstore.GetIncomingWebhookByTokenHashRow, error) {
+	hook, err := s.store.GetIncomingWebhookByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return hook, httpx.New(http.StatusNotFound, "not_found", "webhook not found", err)
+		}
+		return hook, err
+	}
+	return hook, nil
+}
+
+func (s *Service) createBotUser(ctx context.Context, ownerID int64, username string) (dbstore.User, error) {
+	username = strings.TrimSpace(content.Sanitize(username))
+	if username == "" || strings.ContainsAny(username, " \t\n") || utf8.RuneCountInString(username) > maxUsernameRunes {
+		return dbstore.User{}, httpx.BadRequest("invalid_username", "bot username must be 1-32 characters without spaces", nil)
+	}
+	if _, err := s.store.GetUserByUsername(ctx, username); err == nil {
+		return dbstore.User{}, httpx.New(http.StatusConflict, "username_taken", "username already in use", nil)
+	}
+
+	return s.store.CreateBotUser(ctx, dbstore.CreateBotUserParams{
+		Username: username,
+		OwnerID:  pgtype.Int8{Int64: ownerID, Valid: true},
+	})
+}
+
+func (s *Service) issueToken(ctx context.Context, botID int64) (string, error) {
+	token, err := newToken(auth.BotTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.CreateBotToken(ctx, dbstore.CreateBotTokenParams{BotID: botID, TokenHash: hashToken(token)}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *Service) requireModerator(ctx context.Context, roomID, userID int64) error {
+	role, err := s.store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httpx.New(http.StatusForbidden, "not_member", "not a member of this room", err)
+		}
+		return err
+	}
+	if !room.IsModerator(role) {
+		return httpx.New(http.StatusForbidden, "forbidden", "moderator role required", nil)
+	}
+	return nil
+}
+
+// newToken returns prefix followed by 256 random bits, URL-safe.
+func newToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what gets stored: tokens are random, so a plain SHA-256 is enough.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeStore keeps users, bot tokens and room 10 in memory; unused methods
+// panic through the nil embedded interface.
+type fakeStore struct {
+	Store
+	users   map[int64]*dbstore.User
+	tokens  map[string]*botToken // hex token hash → token
+	roles   map[int64]string     // userID → role in room 10
+	hooks   map[int64]dbstore.IncomingWebhook
+	members []dbstore.AddRoomMemberParams
+	nextID  int64
+}
+
+type botToken struct {
+	botID   int64
+	revoked bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users: map[int64]*dbstore.User{
+			1: {ID: 1, Username: "alice"},
+			2: {ID: 2, Username: "bob"},
+			3: {ID: 3, Username: "carol"},
+		},
+		tokens: map[string]*botToken{},
+		roles:  map[int64]string{1: room.RoleModerator, 2: room.RoleMember},
+		hooks:  map[int64]dbstore.IncomingWebhook{},
+		nextID: 100,
+	}
+}
+
+func (f *fakeStore) GetUserByUsername(_ context.Context, username string) (dbstore.User, error) {
+	for _, u := range f.users {
+		if u.Username == username {
+			return *u, nil
+		}
+	}
+	return dbstore.User{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) CreateBotUser(_ context.Context, arg dbstore.CreateBotUserParams) (dbstore.User, error) {
+	f.nextID++
+	u := &dbstore.User{ID: f.nextID, Username: arg.Username, IsBot: true, OwnerID: arg.OwnerID}
+	f.users[u.ID] = u
+	return *u, nil
+}
+
+func (f *fakeStore) GetBot(_ context.Context, id int64) (dbstore.User, error) {
+	u, ok := f.users[id]
+	if !ok || !u.IsBot {
+		return dbstore.User{}, pgx.ErrNoRows
+	}
+	return *u, nil
+}
+
+func (f *fakeStore) CreateBotToken(_ context.Context, arg dbstore.CreateBotTokenParams) error {
+	f.tokens[hex.EncodeToString(arg.TokenHash)] = &botToken{botID: arg.BotID}
+	return nil
+}
+
+func (f *fakeStore) RevokeBotTokens(_ context.Context, botID int64) error {
+	for _, t := range f.tokens {
+		if t.botID == botID {
+			t.revoked = true
+		}
+	}
+	return nil
+}
+
+// GetBotByTokenHash skips revoked tokens and deactivated bots or owners, as
+// the query does.
+func (f *fakeStore) GetBotByTokenHash(_ context.Context, tokenHash []byte) (dbstore.GetBotByTokenHashRow, error) {
+	t, ok := f.tokens[hex.EncodeToString(tokenHash)]
+	if !ok || t.revoked {
+		return dbstore.GetBotByTokenHashRow{}, pgx.ErrNoRows
+	}
+	bot := f.users[t.botID]
+	if owner, ok := f.users[bot.OwnerID.Int64]; bot.DeactivatedAt.Valid || ok && owner.DeactivatedAt.Valid {
+		return dbstore.GetBotByTokenHashRow{}, pgx.ErrNoRows
+	}
+	return dbstore.GetBotByTokenHashRow{ID: bot.ID, Username: bot.Username}, nil
+}
+
+func (f *fakeStore) GetRoomMemberRole(_ context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error) {
+	role, ok := f.roles[arg.UserID]
+	if !ok || arg.RoomID != 10 {
+		return "", pgx.ErrNoRows
+	}
+	return role, nil
+}
+
+func (f *fakeStore) AddRoomMember(_ context.Context, arg dbstore.AddRoomMemberParams) error {
+	f.members = append(f.members, arg)
+	return nil
+}
+
+func (f *fakeStore) CreateIncomingWebhook(_ context.Context, arg dbstore.CreateIncomingWebhookParams) (dbstore.IncomingWebhook, error) {
+	f.nextID++
+	hook := dbstore.IncomingWebhook{ID: f.nextID, RoomID: arg.RoomID, BotID: arg.BotID, TokenHash: arg.TokenHash, CreatedBy: arg.CreatedBy}
+	f.hooks[hook.ID] = hook
+	return hook, nil
+}
+
+// GetIncomingWebhookByTokenHash skips webhooks of deactivated bots or owners,
+// as the query does.
+func (f *fakeStore) GetIncomingWebhookByTokenHash(_ context.Context, tokenHash []byte) (dbstore.GetIncomingWebhookByTokenHashRow, error) {
+	for _, h := range f.hooks {
+		if !bytes.Equal(h.TokenHash, tokenHash) {
+			continue
+		}
+		bot := f.users[h.BotID]
+		if owner, ok := f.users[bot.OwnerID.Int64]; bot.DeactivatedAt.Valid || ok && owner.DeactivatedAt.Valid {
+			break
+		}
+		return dbstore.GetIncomingWebhookByTokenHashRow{ID: h.ID, RoomID: h.RoomID, BotID: h.BotID, BotUsername: bot.Username}, nil
+	}
+	return dbstore.GetIncomingWebhookByTokenHashRow{}, pgx.ErrNoRows
+}
+
+func (f *fakeStore) ListIncomingWebhooksByRoom(_ context.Context, roomID int64) ([]dbstore.ListIncomingWebhooksByRoomRow, error) {
+	var out []dbstore.ListIncomingWebhooksByRoomRow
+	for _, h := range f.hooks {
+		if h.RoomID == roomID {
+			out = append(out, dbstore.ListIncomingWebhooksByRoomRow{ID: h.ID, RoomID: h.RoomID, BotID: h.BotID})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) DeleteIncomingWebhook(_ context.Context, arg dbstore.DeleteIncomingWebhookParams) (int64, error) {
+	h, ok := f.hooks[arg.ID]
+	if !ok || h.RoomID != arg.RoomID {
+		return 0, nil
+	}
+	delete(f.hooks, arg.ID)
+	return 1, nil
+}
+
+type fakeConns struct{ users []int64 }
+
+func (f *fakeConns) DisconnectUser(userID int64) { f.users = append(f.users, userID) }
+
+func newTestService() (*Service, *fakeStore, *fakeConns) {
+	store, conns := newFakeStore(), &fakeConns{}
+	return NewService(store, conns, slog.New(slog.NewTextHandler(io.Discard, nil))), store, conns
+}
+
+func statusOf(err error) int {
+	var he *httpx.HTTPError
+	if errors.As(err, &he) {
+		return he.Status
+	}
+	return 0
+}
+
+func codeOf(err error) string {
+	var he *httpx.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return ""
+}
+
+func TestVerifyBotToken(t *testing.T) {
+	svc, store, _ := newTestService()
+	ctx := context.Background()
+
+	bot, token, err := svc.Create(ctx, 1, "deploy")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	claims, err := svc.VerifyBotToken(ctx, token)
+	if err != nil || claims.UserID != bot.ID || claims.Username != "deploy" || !claims.IsBot {
+		t.Fatalf("VerifyBotToken = %+v, %v", claims, err)
+	}
+	if _, err := svc.VerifyBotToken(ctx, token+"x"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an unknown token to be refused, got %v", err)
+	}
+
+	// a deactivated owner takes their bots down with them
+	store.users[1].DeactivatedAt = pgtype.Timestamptz{Valid: true}
+	if _, err := svc.VerifyBotToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the token of a deactivated owner's bot to be refused, got %v", err)
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	svc, _, conns := newTestService()
+	ctx := context.Background()
+
+	bot, old, err := svc.Create(ctx, 1, "deploy")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.RotateToken(ctx, 2, bot.ID); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 rotating someone else's bot, got %v", err)
+	}
+	if len(conns.users) != 0 {
+		t.Fatalf("a refused rotation must not disconnect the bot, got %v", conns.users)
+	}
+
+	token, err := svc.RotateToken(ctx, 1, bot.ID)
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if _, err := svc.VerifyBotToken(ctx, old); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the revoked token to be refused, got %v", err)
+	}
+	if claims, err := svc.VerifyBotToken(ctx, token); err != nil || claims.UserID != bot.ID {
+		t.Fatalf("expected the new token to work, got %+v, %v", claims, err)
+	}
+	if !slices.Equal(conns.users, []int64{bot.ID}) {
+		t.Fatalf("expected the bot disconnected, got %v", conns.users)
+	}
+}
+
+func TestIncomingWebhooks_RequireModerator(t *testing.T) {
+	svc, store, _ := newTestService()
+	ctx := context.Background()
+
+	// bob is a plain member, carol isn't in the room
+	for actor, want := range map[int64]string{2: "forbidden", 3: "not_member"} {
+		if _, _, _, err := svc.CreateIncomingWebhook(ctx, 10, actor, "ci"); statusOf(err) != http.StatusForbidden || codeOf(err) != want {
+			t.Fatalf("CreateIncomingWebhook by %d: expected 403 %s, got %v", actor, want, err)
+		}
+		if _, err := svc.ListIncomingWebhooks(ctx, 10, actor); statusOf(err) != http.StatusForbidden || codeOf(err) != want {
+			t.Fatalf("ListIncomingWebhooks by %d: expected 403 %s, got %v", actor, want, err)
+		}
+	}
+	if len(store.hooks) != 0 || len(store.members) != 0 {
+		t.Fatal("refused requests must not create a webhook or its bot")
+	}
+
+	hook, bot, _, err := svc.CreateIncomingWebhook(ctx, 10, 1, "ci")
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+	if !bot.IsBot || len(store.members) != 1 || store.members[0].UserID != bot.ID || store.members[0].Role != room.RoleMember {
+		t.Fatalf("expected the bot added to the room as a member, got %+v", store.members)
+	}
+	if hooks, err := svc.ListIncomingWebhooks(ctx, 10, 1); err != nil || len(hooks) != 1 || hooks[0].BotID != bot.ID {
+		t.Fatalf("ListIncomingWebhooks: %+v, %v", hooks, err)
+	}
+
+	if err := svc.DeleteIncomingWebhook(ctx, 10, 2, hook.ID); statusOf(err) != http.StatusForbidden {
+		t.Fatalf("expected a member's delete to be refused, got %v", err)
+	}
+	if err := svc.DeleteIncomingWebhook(ctx, 10, 1, hook.ID); err != nil {
+		t.Fatalf("DeleteIncomingWebhook: %v", err)
+	}
+	if err := svc.DeleteIncomingWebhook(ctx, 10, 1, hook.ID); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting twice, got %v", err)
+	}
+}
+
+func TestResolveIncomingWebhook_DeactivatedOwner(t *testing.T) {
+	svc, store, _ := newTestService()
+	ctx := context.Background()
+
+	hook, bot, token, err := svc.CreateIncomingWebhook(ctx, 10, 1, "ci")
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook: %v", err)
+	}
+	if got, err := svc.ResolveIncomingWebhook(ctx, token); err != nil || got.ID != hook.ID || got.BotID != bot.ID || got.RoomID != 10 {
+		t.Fatalf("ResolveIncomingWebhook = %+v, %v", got, err)
+	}
+
+	// deactivating the creator takes the webhook down with their bots
+	store.users[1].DeactivatedAt = pgtype.Timestamptz{Valid: true}
+	if _, err := svc.ResolveIncomingWebhook(ctx, token); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deactivated owner's webhook, got %v", err)
+	}
+
+	store.users[1].DeactivatedAt = pgtype.Timestamptz{}
+	store.users[bot.ID].DeactivatedAt = pgtype.Timestamptz{Valid: true}
+	if _, err := svc.ResolveIncomingWebhook(ctx, token); statusOf(err) != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deactivated bot's webhook, got %v", err)
+	}
+}
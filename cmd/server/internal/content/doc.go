@@ -0,0 +1,3 @@
+// Package content validates and sanitizes user-provided message text before
+// it is persisted or broadcast to other clients.
+package content
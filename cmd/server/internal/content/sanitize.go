@@ -0,0 +1,106 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrEmpty indicates that nothing is left of the message after sanitizing.
+// ErrTooLong indicates that the message exceeds the configured rune limit.
+var (
+	ErrEmpty   = errors.New("message is empty")
+	ErrTooLong = errors.New("message is too long")
+)
+
+const esc = 0x1b
+
+// Sanitize strips terminal escape sequences, control characters and
+// bidirectional overrides from s, so that a message cannot inject ANSI codes
+// into other users' terminals. Newlines and tabs are kept.
+func Sanitize(s string) string {
+	s = strings.ToValidUTF8(s, string(utf8.RuneError))
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == esc:
+			i += escapeLen(s[i:])
+			continue
+		case r == 0x9b: // C1 CSI, same as ESC [
+			i += size + csiLen(s[i+size:])
+			continue
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case unicode.IsControl(r), isBidiControl(r):
+			// drop
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// Validate sanitizes s, trims surrounding whitespace and enforces maxRunes
+// (0 disables the length check).
+func Validate(s string, maxRunes int) (string, error) {
+	s = strings.TrimSpace(Sanitize(s))
+	if s == "" {
+		return "", ErrEmpty
+	}
+	if maxRunes > 0 && utf8.RuneCountInString(s) > maxRunes {
+		return "", ErrTooLong
+	}
+	return s, nil
+}
+
+// escapeLen returns the length of the escape sequence at the start of s,
+// which must begin with ESC.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[': // CSI
+		return 2 + csiLen(s[2:])
+	case ']', 'P', '_', '^', 'X': // OSC, DCS, APC, PM, SOS: terminated by BEL or ST
+		return 2 + stringSeqLen(s[2:])
+	default: // two-byte sequence (ESC c, ESC 7, ...)
+		_, size := utf8.DecodeRuneInString(s[1:])
+		return 1 + size
+	}
+}
+
+// csiLen returns the length of CSI parameters up to and including the final byte.
+func csiLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// stringSeqLen returns the length of a control string up to and including its terminator.
+func stringSeqLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x07 {
+			return i + 1
+		}
+		if s[i] == esc && i+1 < len(s) && s[i+1] == '\\' {
+			return i + 2
+		}
+	}
+	return len(s)
+}
+
+// isBidiControl reports whether r is an explicit bidirectional formatting
+// character, which can be used to visually reorder text.
+func isBidiControl(r rune) bool {
+	return (r >= 0x202a && r <= 0x202e) || (r >= 0x2066 && r <= 0x2069) || r == 0x200e || r == 0x200f
+}
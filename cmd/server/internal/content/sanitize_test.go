@@ -0,0 +1,52 @@
+package content
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"unicode", "héllo 👋 世界", "héllo 👋 世界"},
+		{"keeps newline and tab", "a\nb\tc", "a\nb\tc"},
+		{"sgr color", "\x1b[31mred\x1b[0m", "red"},
+		{"clear screen", "\x1b[2J\x1b[Hgotcha", "gotcha"},
+		{"osc title bel", "\x1b]0;pwned\x07text", "text"},
+		{"osc hyperlink st", "\x1b]8;;http://evil\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"c1 csi", "\u009b31mred", "red"},
+		{"two byte escape", "\x1bcreset", "reset"},
+		{"bare controls", "a\x00b\x07c\rd\x7f", "abcd"},
+		{"bidi override", "abc\u202edcba", "abcdcba"},
+		{"trailing esc", "abc\x1b", "abc"},
+		{"invalid utf8", "a\xffb", "a�b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Fatalf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if _, err := Validate("  \x1b[31m  ", 10); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("expected ErrEmpty, got %v", err)
+	}
+	if _, err := Validate(strings.Repeat("é", 11), 10); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	got, err := Validate(" "+strings.Repeat("é", 10)+" ", 10)
+	if err != nil || got != strings.Repeat("é", 10) {
+		t.Fatalf("expected 10 runes accepted, got %q %v", got, err)
+	}
+	if _, err := Validate(strings.Repeat("a", 5000), 0); err != nil {
+		t.Fatalf("zero limit must disable the check, got %v", err)
+	}
+}
@@ -2,15 +2,25 @@ package conversation
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/forward"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 )
 
+// MaxTTLSeconds caps how long a disappearing message may live, 7 days. It
+// applies to a message's own ttl_seconds and to conversation defaults.
+const MaxTTLSeconds = 7 * 24 * 60 * 60
+
 type Store interface {
 	ListConversationsByUser(ctx context.Context, params dbstore.ListConversationsByUserParams) ([]dbstore.ListConversationsByUserRow, error)
 	ListMessagesByConversation(ctx context.Context, arg dbstore.ListMessagesByConversationParams) ([]dbstore.Message, error)
+	SetConversationTTL(ctx context.Context, arg dbstore.SetConversationTTLParams) (int64, error)
+	forward.RefStore
 }
 
 type Service struct {
@@ -37,3 +47,32 @@ func (s *Service) ListMessages(ctx context.Context,
 			Limit:          limit,
 		})
 }
+
+// SetDefaultTTL sets how many seconds new DMs in the conversation live when
+// they don't carry their own ttl_seconds. Zero turns it off. Either
+// participant can change it.
+func (s *Service) SetDefaultTTL(ctx context.Context, conversationID, userID int64, seconds int32) error {
+	if seconds < 0 || seconds > MaxTTLSeconds {
+		return httpx.BadRequest("invalid_ttl", fmt.Sprintf("ttl must be between 0 and %d seconds", MaxTTLSeconds), nil)
+	}
+
+	n, err := s.store.SetConversationTTL(ctx, dbstore.SetConversationTTLParams{
+		Seconds: seconds,
+		ID:      conversationID,
+		UserID:  userID,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "conversation not found", nil)
+	}
+	s.logger.Info("conversation ttl changed", "conversation_id", conversationID, "user_id", userID, "seconds", seconds)
+	return nil
+}
+
+// RefsForMessages returns the forward origins and quotes of those of
+// messageIDs that have any, keyed by message ID.
+func (s *Service) RefsForMessages(ctx context.Context, messageIDs []int64) (map[int64]forward.Refs, error) {
+	return forward.ForMessages(ctx, s.store, messageIDs)
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TryAdvisoryLock takes the session-level advisory lock key on a connection
+// of its own, without waiting. When ok, the connection is held until release
+// unlocks it; only one holder across every replica gets the lock at a time.
+func TryAdvisoryLock(ctx context.Context, p *pgxpool.Pool, key int64) (release func(), ok bool, err error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+	if !ok {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	return func() {
+		// ctx may be cancelled by now; the lock must still be given back
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// a connection that can't unlock must not go back to the pool holding the lock
+			_ = conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, true, nil
+}
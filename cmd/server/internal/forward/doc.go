@@ -0,0 +1,6 @@
+// Package forward contains the domain logic for messages that point at
+// another message: forwards, which copy a message into another room or DM
+// and keep its original author and room, and quotes, which show a message
+// as an indented block above a reply. Either one is only allowed for
+// messages the sender can see.
+package forward
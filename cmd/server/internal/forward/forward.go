@@ -0,0 +1,148 @@
+package forward
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// kindPoll is the kind of poll messages, which can't be forwarded: the votes
+// stay with the poll, so a copy of its question would be a dead poll.
+const kindPoll = "poll"
+
+// ErrNotFound is returned for messages that don't exist, expired or that the
+// user can't see. ErrNotForwardable is returned for polls, for messages with
+// no text, e.g. a bare attachment, and for disappearing messages, whose copy
+// would outlive them.
+var (
+	ErrNotFound       = errors.New("message not found")
+	ErrNotForwardable = errors.New("this message can't be forwarded")
+)
+
+// Store loads the messages to forward or quote, e.g. the sqlc Queries.
+type Store interface {
+	GetVisibleMessage(ctx context.Context, arg dbstore.GetVisibleMessageParams) (dbstore.GetVisibleMessageRow, error)
+}
+
+// RefStore loads what stored messages point at, e.g. the sqlc Queries.
+type RefStore interface {
+	ListMessageRefs(ctx context.Context, messageIds []int64) ([]dbstore.ListMessageRefsRow, error)
+}
+
+// Origin is where a forwarded message first came from.
+type Origin struct {
+	// MessageID is the original message, 0 once it is gone.
+	MessageID      int64
+	SenderID       int64
+	SenderUsername string
+	// RoomID and RoomName are zero for messages forwarded from a DM and once
+	// the room is deleted.
+	RoomID   int64
+	RoomName string
+}
+
+// Quote is a message quoted by a reply.
+type Quote struct {
+	MessageID      int64
+	SenderID       int64
+	SenderUsername string
+	Body           string
+}
+
+// Resolve loads the message userID wants to forward and returns its text and
+// origin. Forwarding a forward keeps the first origin.
+func Resolve(ctx context.Context, s Store, messageID, userID int64) (string, Origin, error) {
+	m, err := visible(ctx, s, messageID, userID)
+	if err != nil {
+		return "", Origin{}, err
+	}
+	if m.Kind == kindPoll || m.Body == "" || m.ExpiresAt.Valid {
+		return "", Origin{}, ErrNotForwardable
+	}
+
+	if m.ForwardedFromUserID.Valid {
+		return m.Body, Origin{
+			MessageID:      m.ForwardedFromID.Int64,
+			SenderID:       m.ForwardedFromUserID.Int64,
+			SenderUsername: m.ForwardedFromUsername.String,
+			RoomID:         m.ForwardedFromRoomID.Int64,
+			RoomName:       m.ForwardedFromRoomName.String,
+		}, nil
+	}
+	return m.Body, Origin{
+		MessageID:      m.ID,
+		SenderID:       m.SenderID,
+		SenderUsername: m.SenderUsername,
+		RoomID:         m.RoomID.Int64,
+		RoomName:       m.RoomName.String,
+	}, nil
+}
+
+// LoadQuote loads a message userID wants to quote.
+func LoadQuote(ctx context.Context, s Store, messageID, userID int64) (Quote, error) {
+	m, err := visible(ctx, s, messageID, userID)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{
+		MessageID:      m.ID,
+		SenderID:       m.SenderID,
+		SenderUsername: m.SenderUsername,
+		Body:           m.Body,
+	}, nil
+}
+
+func visible(ctx context.Context, s Store, messageID, userID int64) (dbstore.GetVisibleMessageRow, error) {
+	if messageID <= 0 {
+		return dbstore.GetVisibleMessageRow{}, ErrNotFound
+	}
+	m, err := s.GetVisibleMessage(ctx, dbstore.GetVisibleMessageParams{ID: messageID, UserID: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return m, ErrNotFound
+	}
+	return m, err
+}
+
+// Refs is what a message points at; either may be nil.
+type Refs struct {
+	Forward *Origin
+	Quote   *Quote
+}
+
+// ForMessages returns the forward origins and quotes of those of messageIDs
+// that have any, keyed by message ID.
+func ForMessages(ctx context.Context, s RefStore, messageIDs []int64) (map[int64]Refs, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := s.ListMessageRefs(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int64]Refs, len(rows))
+	for _, r := range rows {
+		var refs Refs
+		if r.ForwardedFromUserID.Valid {
+			refs.Forward = &Origin{
+				MessageID:      r.ForwardedFromID.Int64,
+				SenderID:       r.ForwardedFromUserID.Int64,
+				SenderUsername: r.ForwardedFromUsername.String,
+				RoomID:         r.ForwardedFromRoomID.Int64,
+				RoomName:       r.ForwardedFromRoomName.String,
+			}
+		}
+		if r.QuoteID.Valid {
+			refs.Quote = &Quote{
+				MessageID:      r.QuoteID.Int64,
+				SenderID:       r.QuoteSenderID.Int64,
+				SenderUsername: r.QuoteSenderUsername.String,
+				Body:           r.QuoteBody.String,
+			}
+		}
+		res[r.ID] = refs
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+type fakeStore struct {
+	msgs map[int64]dbstore.GetVisibleMessageRow
+	refs []dbstore.ListMessageRefsRow
+}
+
+func (f *fakeStore) GetVisibleMessage(_ context.Context, arg dbstore.GetVisibleMessageParams) (dbstore.GetVisibleMessageRow, error) {
+	m, ok := f.msgs[arg.ID]
+	if !ok {
+		return m, pgx.ErrNoRows
+	}
+	return m, nil
+}
+
+func (f *fakeStore) ListMessageRefs(_ context.Context, _ []int64) ([]dbstore.ListMessageRefsRow, error) {
+	return f.refs, nil
+}
+
+func TestResolve(t *testing.T) {
+	s := &fakeStore{msgs: map[int64]dbstore.GetVisibleMessageRow{
+		1: {ID: 1, SenderID: 7, SenderUsername: "ann", RoomID: pgtype.Int8{Int64: 3, Valid: true}, RoomName: pgtype.Text{String: "general", Valid: true}, Body: "hi", Kind: "text"},
+		2: {
+			ID: 2, SenderID: 8, SenderUsername: "bob", ConversationID: pgtype.Int8{Int64: 4, Valid: true}, Body: "hi", Kind: "text",
+			ForwardedFromUserID:   pgtype.Int8{Int64: 7, Valid: true},
+			ForwardedFromUsername: pgtype.Text{String: "ann", Valid: true},
+		},
+		3: {ID: 3, SenderID: 7, Body: "lunch?", Kind: "poll"},
+		4: {ID: 4, SenderID: 7, Kind: "text"},
+		5: {ID: 5, SenderID: 7, Body: "gone soon", Kind: "text", ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}},
+	}}
+
+	body, o, err := Resolve(context.Background(), s, 1, 9)
+	if err != nil || body != "hi" || o != (Origin{MessageID: 1, SenderID: 7, SenderUsername: "ann", RoomID: 3, RoomName: "general"}) {
+		t.Fatalf("Resolve(1) = %q, %+v, %v", body, o, err)
+	}
+	// the original of 2 is gone: its author stays, the message ID doesn't
+	_, o, err = Resolve(context.Background(), s, 2, 9)
+	if err != nil || o != (Origin{SenderID: 7, SenderUsername: "ann"}) {
+		t.Fatalf("Resolve(2) = %+v, %v", o, err)
+	}
+
+	for id, want := range map[int64]error{3: ErrNotForwardable, 4: ErrNotForwardable, 5: ErrNotForwardable, 6: ErrNotFound, 0: ErrNotFound} {
+		if _, _, err := Resolve(context.Background(), s, id, 9); !errors.Is(err, want) {
+			t.Fatalf("Resolve(%d): expected %v, got %v", id, want, err)
+		}
+	}
+}
+
+func TestForMessages(t *testing.T) {
+	s := &fakeStore{refs: []dbstore.ListMessageRefsRow{
+		{ID: 10, ForwardedFromUserID: pgtype.Int8{Int64: 7, Valid: true}, ForwardedFromUsername: pgtype.Text{String: "ann", Valid: true}},
+		{ID: 11, QuoteID: pgtype.Int8{Int64: 1, Valid: true}, QuoteSenderUsername: pgtype.Text{String: "bob", Valid: true}, QuoteBody: pgtype.Text{String: "hi", Valid: true}},
+	}}
+
+	refs, err := ForMessages(context.Background(), s, []int64{10, 11, 12})
+	if err != nil {
+		t.Fatalf("ForMessages: %v", err)
+	}
+	if r := refs[10]; r.Forward == nil || r.Forward.SenderUsername != "ann" || r.Quote != nil {
+		t.Fatalf("refs of 10 = %+v", r)
+	}
+	if r := refs[11]; r.Quote == nil || r.Quote.Body != "hi" || r.Forward != nil {
+		t.Fatalf("refs of 11 = %+v", r)
+	}
+	if _, ok := refs[12]; ok {
+		t.Fatalf("unexpected refs for 12")
+	}
+}
@@ -0,0 +1,4 @@
+// Package mail sends transactional email, such as password reset links,
+// through a Mailer. The log and file mailers are meant for local setups
+// without an SMTP relay.
+package mail
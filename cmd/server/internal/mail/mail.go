@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// LogMailer writes messages to the logger instead of sending them.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+// NewLogMailer creates a LogMailer that logs to l.
+func NewLogMailer(l *slog.Logger) *LogMailer {
+	return &LogMailer{logger: l}
+}
+
+// Send logs m, body included.
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	m.logger.Info("mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer writes each message as an .eml file under a directory, where a
+// mail client or a test can pick it up.
+type FileMailer struct {
+	dir string
+	seq atomic.Int64
+	now func() time.Time
+}
+
+// NewFileMailer creates a FileMailer writing to dir, creating it if needed.
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating mail dir: %w", err)
+	}
+	return &FileMailer{dir: dir, now: time.Now}, nil
+}
+
+// Send writes msg to a new file named after the time it was sent.
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	now := m.now().UTC()
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102T150405.000000000"), m.seq.Add(1))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(b.String()), 0o640)
+}
+
+// headerValue drops line breaks so a value can't inject extra headers.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
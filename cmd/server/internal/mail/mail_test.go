@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Reset", Body: "hello"}
+	for range 2 {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 .eml files, got %v (%v)", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(raw)
+	if !strings.Contains(got, "Subject: Reset\r\n") || !strings.HasSuffix(got, "\r\n\r\nhello") {
+		t.Fatalf("unexpected message:\n%s", got)
+	}
+	if strings.Contains(got, "\r\nBcc:") {
+		t.Fatal("expected line breaks in headers to be stripped")
+	}
+}
@@ -0,0 +1,6 @@
+// Package poll contains the domain logic for polls: room messages of kind
+// poll whose body is the question, with 2 to 10 options, single or multiple
+// choice, an optional deadline and an anonymous mode that hides who voted
+// for what. Polls are created and voted on over the WebSocket; this package
+// validates them and counts the votes.
+package poll
@@ -0,0 +1,165 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Limits of a poll.
+const (
+	MinOptions     = 2
+	MaxOptions     = 10
+	MaxOptionRunes = 100
+	MaxDuration    = 30 * 24 * time.Hour
+)
+
+// ErrInvalid is returned for polls and votes that break the rules above;
+// the wrapped message says which one. ErrClosed is returned for votes after
+// the deadline.
+var (
+	ErrInvalid = errors.New("invalid poll")
+	ErrClosed  = errors.New("this poll is closed")
+)
+
+// ValidateOptions sanitizes the options of a new poll. They must be distinct
+// once trimmed.
+func ValidateOptions(options []string) ([]string, error) {
+	if len(options) < MinOptions || len(options) > MaxOptions {
+		return nil, fmt.Errorf("%w: a poll needs %d to %d options", ErrInvalid, MinOptions, MaxOptions)
+	}
+	res := make([]string, len(options))
+	for i, o := range options {
+		o, err := content.Validate(o, MaxOptionRunes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: options must have 1 to %d characters", ErrInvalid, MaxOptionRunes)
+		}
+		if slices.Contains(res[:i], o) {
+			return nil, fmt.Errorf("%w: option %q is repeated", ErrInvalid, o)
+		}
+		res[i] = o
+	}
+	return res, nil
+}
+
+// ValidateDeadline checks that a deadline, if any, is in the future and at
+// most MaxDuration away.
+func ValidateDeadline(closesAt *time.Time, now time.Time) error {
+	if closesAt == nil {
+		return nil
+	}
+	if !closesAt.After(now) || closesAt.After(now.Add(MaxDuration)) {
+		return fmt.Errorf("%w: closes_at must be in the future and at most %s ahead", ErrInvalid, MaxDuration)
+	}
+	return nil
+}
+
+// Closed reports whether the deadline of p has passed.
+func Closed(p dbstore.Poll, now time.Time) bool {
+	return p.ClosesAt.Valid && !now.Before(p.ClosesAt.Time)
+}
+
+// CheckVote validates the options a user votes for and returns them sorted
+// and without duplicates. An empty list retracts the vote.
+func CheckVote(p dbstore.Poll, options []int, now time.Time) ([]int32, error) {
+	if Closed(p, now) {
+		return nil, ErrClosed
+	}
+	res := make([]int32, 0, len(options))
+	for _, o := range options {
+		if o < 0 || o >= len(p.Options) {
+			return nil, fmt.Errorf("%w: option %d doesn't exist", ErrInvalid, o)
+		}
+		if !slices.Contains(res, int32(o)) {
+			res = append(res, int32(o))
+		}
+	}
+	if !p.Multiple && len(res) > 1 {
+		return nil, fmt.Errorf("%w: this poll takes a single choice", ErrInvalid)
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// Tally is the state of the votes of a poll.
+type Tally struct {
+	// Counts holds the votes of each option.
+	Counts []int
+	// Voters holds the usernames who voted each option, nil for anonymous polls.
+	Voters [][]string
+	// TotalVoters counts users, who may vote several options.
+	TotalVoters int
+	// Mine holds the options voted by the user the tally was made for.
+	Mine []int
+}
+
+// Count tallies the votes of p. votes may hold other polls' votes too; they
+// are skipped.
+func Count(p dbstore.Poll, votes []dbstore.ListPollVotesByMessageIDsRow, userID int64) Tally {
+	t := Tally{Counts: make([]int, len(p.Options)), Mine: []int{}}
+	if !p.Anonymous {
+		t.Voters = make([][]string, len(p.Options))
+		for i := range t.Voters {
+			t.Voters[i] = []string{}
+		}
+	}
+	voters := make(map[int64]bool)
+	for _, v := range votes {
+		if v.MessageID != p.MessageID || int(v.OptionIndex) >= len(p.Options) {
+			continue
+		}
+		t.Counts[v.OptionIndex]++
+		if t.Voters != nil {
+			t.Voters[v.OptionIndex] = append(t.Voters[v.OptionIndex], v.Username)
+		}
+		if v.UserID == userID {
+			t.Mine = append(t.Mine, int(v.OptionIndex))
+		}
+		voters[v.UserID] = true
+	}
+	t.TotalVoters = len(voters)
+	return t
+}
+
+// Store loads polls and their votes, e.g. the sqlc Queries.
+type Store interface {
+	ListPollsByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.Poll, error)
+	ListPollVotesByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.ListPollVotesByMessageIDsRow, error)
+}
+
+// Result is a poll with its tally.
+type Result struct {
+	dbstore.Poll
+	Tally
+}
+
+// ForMessages returns the polls among messageIDs, keyed by message ID and
+// tallied for userID.
+func ForMessages(ctx context.Context, s Store, messageIDs []int64, userID int64) (map[int64]Result, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	polls, err := s.ListPollsByMessageIDs(ctx, messageIDs)
+	if err != nil || len(polls) == 0 {
+		return nil, err
+	}
+	ids := make([]int64, len(polls))
+	for i, p := range polls {
+		ids[i] = p.MessageID
+	}
+	votes, err := s.ListPollVotesByMessageIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int64]Result, len(polls))
+	for _, p := range polls {
+		res[p.MessageID] = Result{Poll: p, Tally: Count(p, votes, userID)}
+	}
+	return res, nil
+}
@@ -0,0 +1,143 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func TestValidateOptions(t *testing.T) {
+	got, err := ValidateOptions([]string{" yes ", "\x1b[31mno"})
+	if err != nil || !slices.Equal(got, []string{"yes", "no"}) {
+		t.Fatalf("ValidateOptions = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name    string
+		options []string
+	}{
+		{"too few", []string{"a"}},
+		{"too many", strings.Split("a b c d e f g h i j k", " ")},
+		{"empty option", []string{"a", "  "}},
+		{"too long", []string{"a", strings.Repeat("x", MaxOptionRunes+1)}},
+		{"repeated", []string{"a", " a "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateOptions(tt.options); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateDeadline(t *testing.T) {
+	now := time.Now()
+	past, soon, far := now.Add(-time.Second), now.Add(time.Hour), now.Add(MaxDuration+time.Hour)
+
+	if err := ValidateDeadline(nil, now); err != nil {
+		t.Fatalf("no deadline: %v", err)
+	}
+	if err := ValidateDeadline(&soon, now); err != nil {
+		t.Fatalf("deadline in an hour: %v", err)
+	}
+	for _, d := range []*time.Time{&past, &far} {
+		if err := ValidateDeadline(d, now); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("deadline %v: expected ErrInvalid, got %v", d, err)
+		}
+	}
+}
+
+func TestCheckVote(t *testing.T) {
+	now := time.Now()
+	single := dbstore.Poll{MessageID: 1, Options: []string{"a", "b", "c"}}
+	multi := single
+	multi.Multiple = true
+	closed := single
+	closed.ClosesAt = pgtype.Timestamptz{Time: now, Valid: true}
+
+	got, err := CheckVote(multi, []int{2, 0, 2}, now)
+	if err != nil || !slices.Equal(got, []int32{0, 2}) {
+		t.Fatalf("multiple choice = %v, %v", got, err)
+	}
+	got, err = CheckVote(single, nil, now)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("retraction = %v, %v", got, err)
+	}
+	if _, err := CheckVote(single, []int{0, 1}, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("two choices on a single choice poll: expected ErrInvalid, got %v", err)
+	}
+	if _, err := CheckVote(multi, []int{3}, now); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("missing option: expected ErrInvalid, got %v", err)
+	}
+	if _, err := CheckVote(closed, []int{0}, now); !errors.Is(err, ErrClosed) {
+		t.Fatalf("closed poll: expected ErrClosed, got %v", err)
+	}
+}
+
+type fakeStore struct {
+	polls []dbstore.Poll
+	votes []dbstore.ListPollVotesByMessageIDsRow
+}
+
+func (f *fakeStore) ListPollsByMessageIDs(_ context.Context, ids []int64) ([]dbstore.Poll, error) {
+	var res []dbstore.Poll
+	for _, p := range f.polls {
+		if slices.Contains(ids, p.MessageID) {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeStore) ListPollVotesByMessageIDs(_ context.Context, ids []int64) ([]dbstore.ListPollVotesByMessageIDsRow, error) {
+	var res []dbstore.ListPollVotesByMessageIDsRow
+	for _, v := range f.votes {
+		if slices.Contains(ids, v.MessageID) {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func TestForMessages(t *testing.T) {
+	s := &fakeStore{
+		polls: []dbstore.Poll{
+			{MessageID: 1, Options: []string{"a", "b"}, Multiple: true},
+			{MessageID: 2, Options: []string{"a", "b"}, Anonymous: true},
+		},
+		votes: []dbstore.ListPollVotesByMessageIDsRow{
+			{MessageID: 1, OptionIndex: 0, UserID: 7, Username: "ann"},
+			{MessageID: 1, OptionIndex: 1, UserID: 7, Username: "ann"},
+			{MessageID: 1, OptionIndex: 1, UserID: 8, Username: "bob"},
+			{MessageID: 2, OptionIndex: 0, UserID: 8, Username: "bob"},
+		},
+	}
+
+	res, err := ForMessages(context.Background(), s, []int64{1, 2, 3}, 7)
+	if err != nil {
+		t.Fatalf("ForMessages: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 polls, got %d", len(res))
+	}
+
+	open := res[1]
+	if !slices.Equal(open.Counts, []int{1, 2}) || open.TotalVoters != 2 || !slices.Equal(open.Mine, []int{0, 1}) {
+		t.Fatalf("poll 1 tally = %+v", open.Tally)
+	}
+	if !slices.Equal(open.Voters[1], []string{"ann", "bob"}) {
+		t.Fatalf("poll 1 voters = %v", open.Voters)
+	}
+
+	anon := res[2]
+	if anon.Voters != nil || !slices.Equal(anon.Counts, []int{1, 0}) || len(anon.Mine) != 0 {
+		t.Fatalf("anonymous poll tally = %+v", anon.Tally)
+	}
+}
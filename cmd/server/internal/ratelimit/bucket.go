@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Rate describes a token bucket: PerSecond tokens are refilled every second,
+// up to Burst tokens. A zero Rate means unlimited.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// Unlimited reports whether the rate disables limiting.
+func (r Rate) Unlimited() bool {
+	return r.PerSecond <= 0 || r.Burst <= 0
+}
+
+// Bucket is a single token bucket. It is not safe for concurrent use.
+type Bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket for the given rate.
+func NewBucket(r Rate) *Bucket {
+	return &Bucket{rate: r, tokens: float64(r.Burst)}
+}
+
+// Allow takes one token if available. When it is not, it returns false and
+// how long the caller has to wait until a token is refilled.
+func (b *Bucket) Allow(now time.Time) (bool, time.Duration) {
+	if b.rate.Unlimited() {
+		return true, 0
+	}
+
+	if !b.last.IsZero() {
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = min(float64(b.rate.Burst), b.tokens+elapsed*b.rate.PerSecond)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	missing := 1 - b.tokens
+	return false, time.Duration(missing / b.rate.PerSecond * float64(time.Second))
+}
+
+// full reports whether the bucket will have refilled to Burst by now, i.e.
+// it is indistinguishable from a new one.
+func (b *Bucket) full(now time.Time) bool {
+	if b.last.IsZero() {
+		return true
+	}
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate.PerSecond >= float64(b.rate.Burst)
+}
+
+// Keyed holds one bucket per key, all sharing the same rate. It is safe for concurrent use.
+type Keyed[K comparable] struct {
+	mu      sync.Mutex
+	rate    Rate
+	buckets map[K]*Bucket
+}
+
+// NewKeyed creates a Keyed limiter for the given rate.
+func NewKeyed[K comparable](r Rate) *Keyed[K] {
+	return &Keyed[K]{rate: r, buckets: make(map[K]*Bucket)}
+}
+
+// Allow takes one token from the bucket of key.
+func (k *Keyed[K]) Allow(key K, now time.Time) (bool, time.Duration) {
+	if k.rate.Unlimited() {
+		return true, 0
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	b, ok := k.buckets[key]
+	if !ok {
+		b = NewBucket(k.rate)
+		k.buckets[key] = b
+	}
+	return b.Allow(now)
+}
+
+// Sweep drops the buckets that have refilled, so keys that went quiet don't
+// hold memory forever. It returns how many were dropped.
+func (k *Keyed[K]) Sweep(now time.Time) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	n := 0
+	for key, b := range k.buckets {
+		if b.full(now) {
+			delete(k.buckets, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Cooldown allows one event per key every interval. It is safe for concurrent use.
+type Cooldown[K comparable] struct {
+	mu   sync.Mutex
+	last map[K]cooldownEvent
+}
+
+type cooldownEvent struct {
+	at       time.Time
+	interval time.Duration
+}
+
+// NewCooldown creates an empty Cooldown.
+func NewCooldown[K comparable]() *Cooldown[K] {
+	return &Cooldown[K]{last: make(map[K]cooldownEvent)}
+}
+
+// Allow records an event for key if at least interval has passed since the
+// previous one, otherwise it returns the remaining wait.
+func (c *Cooldown[K]) Allow(key K, interval time.Duration, now time.Time) (bool, time.Duration) {
+	if interval <= 0 {
+		return true, 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last, ok := c.last[key]; ok {
+		if wait := interval - now.Sub(last.at); wait > 0 {
+			return false, wait
+		}
+	}
+	c.last[key] = cooldownEvent{at: now, interval: interval}
+	return true, 0
+}
+
+// Sweep drops the keys whose interval has elapsed since their last event. It
+// returns how many were dropped.
+func (c *Cooldown[K]) Sweep(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key, last := range c.last {
+		if now.Sub(last.at) >= last.interval {
+			delete(c.last, key)
+			n++
+		}
+	}
+	return n
+}
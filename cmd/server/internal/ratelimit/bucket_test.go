@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket_BurstThenRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBucket(Rate{PerSecond: 2, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(now); !ok {
+			t.Fatalf("call %d: expected allow within burst", i)
+		}
+	}
+
+	ok, wait := b.Allow(now)
+	if ok {
+		t.Fatal("expected deny once burst is spent")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("expected 500ms wait, got %s", wait)
+	}
+
+	if ok, _ := b.Allow(now.Add(500 * time.Millisecond)); !ok {
+		t.Fatal("expected allow after refill")
+	}
+}
+
+func TestBucket_Unlimited(t *testing.T) {
+	b := NewBucket(Rate{})
+	for i := 0; i < 1000; i++ {
+		if ok, _ := b.Allow(time.Unix(0, 0)); !ok {
+			t.Fatal("zero rate must never deny")
+		}
+	}
+}
+
+func TestKeyed_SeparateKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	k := NewKeyed[int64](Rate{PerSecond: 1, Burst: 1})
+
+	if ok, _ := k.Allow(1, now); !ok {
+		t.Fatal("key 1: expected first allow")
+	}
+	if ok, _ := k.Allow(1, now); ok {
+		t.Fatal("key 1: expected deny")
+	}
+	if ok, _ := k.Allow(2, now); !ok {
+		t.Fatal("key 2 must have its own bucket")
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewCooldown[string]()
+
+	if ok, _ := c.Allow("a", 5*time.Second, now); !ok {
+		t.Fatal("expected first event allowed")
+	}
+	ok, wait := c.Allow("a", 5*time.Second, now.Add(2*time.Second))
+	if ok || wait != 3*time.Second {
+		t.Fatalf("expected deny with 3s wait, got ok=%v wait=%s", ok, wait)
+	}
+	if ok, _ := c.Allow("a", 5*time.Second, now.Add(5*time.Second)); !ok {
+		t.Fatal("expected allow after interval")
+	}
+}
+
+func TestKeyed_Sweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	k := NewKeyed[int](Rate{PerSecond: 1, Burst: 2})
+
+	k.Allow(1, now)
+	k.Allow(2, now)
+	k.Allow(2, now)
+
+	// key 1 is full again after 1s, key 2 needs 2s
+	if n := k.Sweep(now.Add(time.Second)); n != 1 {
+		t.Fatalf("expected 1 bucket dropped, got %d", n)
+	}
+	if _, ok := k.buckets[2]; !ok {
+		t.Fatal("expected the bucket of key 2 kept")
+	}
+	if n := k.Sweep(now.Add(2 * time.Second)); n != 1 || len(k.buckets) != 0 {
+		t.Fatalf("expected every bucket dropped, got %d, %v", n, k.buckets)
+	}
+
+	// a dropped key starts over with a full bucket
+	for range 2 {
+		if ok, _ := k.Allow(2, now.Add(2*time.Second)); !ok {
+			t.Fatal("expected a fresh burst after the sweep")
+		}
+	}
+}
+
+func TestCooldown_Sweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewCooldown[string]()
+
+	c.Allow("a", 5*time.Second, now)
+	c.Allow("b", 10*time.Second, now)
+
+	if n := c.Sweep(now.Add(5 * time.Second)); n != 1 {
+		t.Fatalf("expected 1 key dropped, got %d", n)
+	}
+	if ok, _ := c.Allow("b", 10*time.Second, now.Add(5*time.Second)); ok {
+		t.Fatal("expected b still cooling down")
+	}
+	if n := c.Sweep(now.Add(10 * time.Second)); n != 1 || len(c.last) != 0 {
+		t.Fatalf("expected every key dropped, got %d, %v", n, c.last)
+	}
+}
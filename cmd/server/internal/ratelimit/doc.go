@@ -0,0 +1,3 @@
+// Package ratelimit provides in-memory token buckets and cooldowns used to
+// throttle WebSocket traffic per connection, per user and per room.
+package ratelimit
@@ -0,0 +1,8 @@
+// Package retention deletes messages older than the retention of their room,
+// or than the server default for rooms without one and for direct messages.
+// A background Janitor does it in batches, holding a Postgres advisory lock
+// so only one replica runs at a time.
+//
+// A Sweeper deletes disappearing messages, those sent with a TTL, once their
+// expires_at passes and sends message_expired to whoever could see them.
+package retention
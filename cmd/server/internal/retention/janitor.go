@@ -0,0 +1,214 @@
+package retention
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// LockKey is the Postgres advisory lock key the janitor runs under.
+const LockKey int64 = 0x72657465 // "rete"
+
+// Store defines the persistence methods the Janitor needs.
+type Store interface {
+	ListExpiredRoomMessages(ctx context.Context, arg dbstore.ListExpiredRoomMessagesParams) ([]int64, error)
+	ListExpiredDirectMessages(ctx context.Context, arg dbstore.ListExpiredDirectMessagesParams) ([]int64, error)
+	CountExpiredRoomMessages(ctx context.Context, defaultBefore pgtype.Timestamptz) (int64, error)
+	CountExpiredDirectMessages(ctx context.Context, before pgtype.Timestamptz) (int64, error)
+	ListAttachmentsByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.Attachment, error)
+	DeleteMessagesByIDs(ctx context.Context, ids []int64) (int64, error)
+}
+
+// LockFunc tries to take the lock that keeps other replicas from running the
+// janitor at the same time. ok is false when someone else holds it; release
+// is only called when ok.
+type LockFunc func(ctx context.Context) (release func(), ok bool, err error)
+
+// Config tunes the janitor. Default is the retention of direct messages and
+// of rooms without their own; zero keeps them forever. With DryRun set the
+// janitor only logs how many messages it would delete.
+type Config struct {
+	Interval  time.Duration
+	Default   time.Duration
+	BatchSize int32
+	DryRun    bool
+}
+
+// DefaultConfig returns the settings used in production.
+func DefaultConfig() Config {
+	return Config{Interval: time.Hour, BatchSize: 500}
+}
+
+// Janitor periodically deletes expired messages and their uploads.
+type Janitor struct {
+	store   Store
+	blobs   attachment.BlobDeleter
+	lock    LockFunc
+	cfg     Config
+	logger  *slog.Logger
+	now     func() time.Time
+	metrics *expvar.Map
+}
+
+// NewJanitor creates a Janitor. blobs may be nil to leave upload contents in
+// place and lock nil when a single replica runs.
+func NewJanitor(s Store, blobs attachment.BlobDeleter, lock LockFunc, cfg Config, l *slog.Logger) *Janitor {
+	return &Janitor{
+		store:   s,
+		blobs:   blobs,
+		lock:    lock,
+		cfg:     cfg,
+		logger:  l,
+		now:     time.Now,
+		metrics: new(expvar.Map).Init(),
+	}
+}
+
+// Metrics returns the counters of the janitor, to be published with
+// expvar.Publish: runs, runs_skipped (another replica had the lock),
+// messages_deleted, attachments_deleted, errors, pending (the last dry-run
+// count) and last_run (unix seconds).
+func (j *Janitor) Metrics() expvar.Var {
+	return j.metrics
+}
+
+// Run deletes expired messages until ctx is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil {
+			j.metrics.Add("errors", 1)
+			j.logger.Warn("message retention failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes every expired message, or only counts them in dry-run
+// mode, and returns how many. It does nothing when another replica holds
+// the lock.
+func (j *Janitor) RunOnce(ctx context.Context) (int64, error) {
+	if j.lock != nil {
+		release, ok, err := j.lock(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			j.metrics.Add("runs_skipped", 1)
+			j.logger.Debug("message retention running on another replica")
+			return 0, nil
+		}
+		defer release()
+	}
+	j.metrics.Add("runs", 1)
+	defer j.setGauge("last_run", j.now().Unix())
+
+	var defaultBefore pgtype.Timestamptz
+	if j.cfg.Default > 0 {
+		defaultBefore = pgtype.Timestamptz{Time: j.now().Add(-j.cfg.Default), Valid: true}
+	}
+
+	if j.cfg.DryRun {
+		return j.count(ctx, defaultBefore)
+	}
+
+	rooms, err := j.deleteAll(ctx, func() ([]int64, error) {
+		return j.store.ListExpiredRoomMessages(ctx, dbstore.ListExpiredRoomMessagesParams{DefaultBefore: defaultBefore, Lim: j.cfg.BatchSize})
+	})
+	if err != nil {
+		return rooms, err
+	}
+	var direct int64
+	if defaultBefore.Valid {
+		direct, err = j.deleteAll(ctx, func() ([]int64, error) {
+			return j.store.ListExpiredDirectMessages(ctx, dbstore.ListExpiredDirectMessagesParams{Before: defaultBefore, Lim: j.cfg.BatchSize})
+		})
+		if err != nil {
+			return rooms + direct, err
+		}
+	}
+
+	if rooms+direct > 0 {
+		j.logger.Info("expired messages deleted", "room_messages", rooms, "direct_messages", direct)
+	}
+	return rooms + direct, nil
+}
+
+// count logs how many messages a real run would delete.
+func (j *Janitor) count(ctx context.Context, defaultBefore pgtype.Timestamptz) (int64, error) {
+	rooms, err := j.store.CountExpiredRoomMessages(ctx, defaultBefore)
+	if err != nil {
+		return 0, err
+	}
+	var direct int64
+	if defaultBefore.Valid {
+		if direct, err = j.store.CountExpiredDirectMessages(ctx, defaultBefore); err != nil {
+			return 0, err
+		}
+	}
+
+	j.setGauge("pending", rooms+direct)
+	j.logger.Info("message retention dry run", "room_messages", rooms, "direct_messages", direct)
+	return rooms + direct, nil
+}
+
+// deleteAll deletes the batches list returns until one comes back short.
+func (j *Janitor) deleteAll(ctx context.Context, list func() ([]int64, error)) (int64, error) {
+	var total int64
+	for {
+		ids, err := list()
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		n, err := j.deleteBatch(ctx, ids)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if len(ids) < int(j.cfg.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// deleteBatch deletes the messages and then the blobs of their uploads.
+func (j *Janitor) deleteBatch(ctx context.Context, ids []int64) (int64, error) {
+	atts, err := j.store.ListAttachmentsByMessageIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	n, err := j.store.DeleteMessagesByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	j.metrics.Add("messages_deleted", n)
+
+	keys := make([]string, len(atts))
+	for i, a := range atts {
+		keys[i] = a.StorageKey
+	}
+	attachment.DeleteBlobs(ctx, j.blobs, keys, j.logger)
+	j.metrics.Add("attachments_deleted", int64(len(atts)))
+	return n, nil
+}
+
+func (j *Janitor) setGauge(key string, v int64) {
+	g := new(expvar.Int)
+	g.Set(v)
+	j.metrics.Set(key, g)
+}
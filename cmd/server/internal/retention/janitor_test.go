@@ -0,0 +1,209 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+var now = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+type fakeMessage struct {
+	id        int64
+	roomID    int64 // 0 for direct messages
+	createdAt time.Time
+	key       string // storage key of its upload, if any
+}
+
+// fakeStore applies the retention rules of the real queries to a slice.
+type fakeStore struct {
+	messages  []fakeMessage
+	retention map[int64]*int32 // room → retention_days
+	lists     int
+}
+
+func (f *fakeStore) expired(m fakeMessage, defaultBefore pgtype.Timestamptz) bool {
+	if m.roomID == 0 {
+		return defaultBefore.Valid && m.createdAt.Before(defaultBefore.Time)
+	}
+	days := f.retention[m.roomID]
+	switch {
+	case days == nil:
+		return defaultBefore.Valid && m.createdAt.Before(defaultBefore.Time)
+	case *days == 0:
+		return false
+	default:
+		return m.createdAt.Before(now.AddDate(0, 0, -int(*days)))
+	}
+}
+
+func (f *fakeStore) list(lim int32, match func(fakeMessage) bool) []int64 {
+	f.lists++
+	var ids []int64
+	for _, m := range f.messages {
+		if len(ids) < int(lim) && match(m) {
+			ids = append(ids, m.id)
+		}
+	}
+	return ids
+}
+
+func (f *fakeStore) ListExpiredRoomMessages(_ context.Context, arg dbstore.ListExpiredRoomMessagesParams) ([]int64, error) {
+	return f.list(arg.Lim, func(m fakeMessage) bool { return m.roomID != 0 && f.expired(m, arg.DefaultBefore) }), nil
+}
+
+func (f *fakeStore) ListExpiredDirectMessages(_ context.Context, arg dbstore.ListExpiredDirectMessagesParams) ([]int64, error) {
+	return f.list(arg.Lim, func(m fakeMessage) bool { return m.roomID == 0 && f.expired(m, arg.Before) }), nil
+}
+
+func (f *fakeStore) CountExpiredRoomMessages(_ context.Context, defaultBefore pgtype.Timestamptz) (int64, error) {
+	return int64(len(f.list(1<<30, func(m fakeMessage) bool { return m.roomID != 0 && f.expired(m, defaultBefore) }))), nil
+}
+
+func (f *fakeStore) CountExpiredDirectMessages(_ context.Context, before pgtype.Timestamptz) (int64, error) {
+	return int64(len(f.list(1<<30, func(m fakeMessage) bool { return m.roomID == 0 && f.expired(m, before) }))), nil
+}
+
+func (f *fakeStore) ListAttachmentsByMessageIDs(_ context.Context, ids []int64) ([]dbstore.Attachment, error) {
+	var out []dbstore.Attachment
+	for _, m := range f.messages {
+		if m.key != "" && slices.Contains(ids, m.id) {
+			out = append(out, dbstore.Attachment{StorageKey: m.key})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) DeleteMessagesByIDs(_ context.Context, ids []int64) (int64, error) {
+	before := len(f.messages)
+	f.messages = slices.DeleteFunc(f.messages, func(m fakeMessage) bool { return slices.Contains(ids, m.id) })
+	return int64(before - len(f.messages)), nil
+}
+
+type fakeBlobs struct{ deleted []string }
+
+func (f *fakeBlobs) Delete(_ context.Context, key string) error {
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func days(n int32) *int32 { return &n }
+
+// newTestStore has rooms 1 (30 days), 2 (server default) and 3 (forever),
+// each with an old and a recent message, plus an old and a recent DM.
+func newTestStore() *fakeStore {
+	old, recent := now.AddDate(0, 0, -100), now.AddDate(0, 0, -1)
+	return &fakeStore{
+		messages: []fakeMessage{
+			{id: 1, roomID: 1, createdAt: old, key: "a"},
+			{id: 2, roomID: 1, createdAt: recent},
+			{id: 3, roomID: 2, createdAt: old},
+			{id: 4, roomID: 2, createdAt: recent},
+			{id: 5, roomID: 3, createdAt: old},
+			{id: 6, roomID: 3, createdAt: recent},
+			{id: 7, createdAt: old, key: "b"},
+			{id: 8, createdAt: recent},
+		},
+		retention: map[int64]*int32{1: days(30), 3: days(0)},
+	}
+}
+
+func newTestJanitor(store *fakeStore, blobs *fakeBlobs, cfg Config) *Janitor {
+	j := NewJanitor(store, blobs, nil, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	j.now = func() time.Time { return now }
+	return j
+}
+
+func remaining(f *fakeStore) []int64 {
+	var ids []int64
+	for _, m := range f.messages {
+		ids = append(ids, m.id)
+	}
+	return ids
+}
+
+func TestRunOnce_RoomRetentionOnly(t *testing.T) {
+	store, blobs := newTestStore(), &fakeBlobs{}
+	j := newTestJanitor(store, blobs, Config{BatchSize: 10})
+
+	n, err := j.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a server default only room 1 has a retention
+	if n != 1 || !slices.Equal(remaining(store), []int64{2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatalf("deleted %d, left %v", n, remaining(store))
+	}
+	if !slices.Equal(blobs.deleted, []string{"a"}) {
+		t.Fatalf("expected the upload of the deleted message removed, got %v", blobs.deleted)
+	}
+}
+
+func TestRunOnce_DefaultInBatches(t *testing.T) {
+	store, blobs := newTestStore(), &fakeBlobs{}
+	j := newTestJanitor(store, blobs, Config{BatchSize: 1, Default: 90 * 24 * time.Hour})
+
+	n, err := j.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// room 3 keeps everything; recent messages stay everywhere
+	if n != 3 || !slices.Equal(remaining(store), []int64{2, 4, 5, 6, 8}) {
+		t.Fatalf("deleted %d, left %v", n, remaining(store))
+	}
+	if !slices.Equal(blobs.deleted, []string{"a", "b"}) {
+		t.Fatalf("expected both uploads removed, got %v", blobs.deleted)
+	}
+	// one message per batch, plus an empty batch for each kind
+	if store.lists != 5 {
+		t.Fatalf("expected 5 batches, got %d", store.lists)
+	}
+
+	var metrics map[string]int64
+	if err := json.Unmarshal([]byte(j.Metrics().String()), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics["messages_deleted"] != 3 || metrics["attachments_deleted"] != 2 || metrics["runs"] != 1 {
+		t.Fatalf("unexpected metrics %v", metrics)
+	}
+}
+
+func TestRunOnce_DryRun(t *testing.T) {
+	store, blobs := newTestStore(), &fakeBlobs{}
+	j := newTestJanitor(store, blobs, Config{BatchSize: 10, Default: 90 * 24 * time.Hour, DryRun: true})
+
+	n, err := j.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(store.messages) != 8 || len(blobs.deleted) != 0 {
+		t.Fatalf("dry run must only count: n=%d, left %d, blobs %v", n, len(store.messages), blobs.deleted)
+	}
+}
+
+func TestRunOnce_LockHeldElsewhere(t *testing.T) {
+	store := newTestStore()
+	j := newTestJanitor(store, &fakeBlobs{}, Config{BatchSize: 10, Default: time.Hour})
+	j.lock = func(context.Context) (func(), bool, error) { return nil, false, nil }
+
+	n, err := j.RunOnce(context.Background())
+	if err != nil || n != 0 || len(store.messages) != 8 {
+		t.Fatalf("expected nothing done without the lock, got n=%d err=%v", n, err)
+	}
+
+	released := false
+	j.lock = func(context.Context) (func(), bool, error) { return func() { released = true }, true, nil }
+	if _, err := j.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Fatal("expected the lock released after the run")
+	}
+}
@@ -0,0 +1,110 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// SweepStore defines the persistence methods the Sweeper needs.
+type SweepStore interface {
+	DeleteExpiredMessages(ctx context.Context, lim int32) ([]dbstore.DeleteExpiredMessagesRow, error)
+}
+
+// Broadcaster delivers frames to connected clients, e.g. the ws Hub.
+type Broadcaster interface {
+	BroadcastToRoom(roomID int64, msg ws.Message)
+	BroadcastToUsers(userIDs []int64, msg ws.Message)
+}
+
+// SweeperConfig tunes the sweeper. The interval bounds how long an expired
+// message stays on open screens; history queries hide it right away.
+type SweeperConfig struct {
+	Interval  time.Duration
+	BatchSize int32
+}
+
+// DefaultSweeperConfig returns the settings used in production.
+func DefaultSweeperConfig() SweeperConfig {
+	return SweeperConfig{Interval: 5 * time.Second, BatchSize: 500}
+}
+
+// Sweeper deletes disappearing messages once they expire and tells whoever
+// could see them with a message_expired frame.
+type Sweeper struct {
+	store  SweepStore
+	hub    Broadcaster
+	blobs  attachment.BlobDeleter
+	cfg    SweeperConfig
+	logger *slog.Logger
+}
+
+// NewSweeper creates a Sweeper. blobs may be nil to leave upload contents in
+// place. Replicas can all run one: each batch is claimed with SKIP LOCKED.
+func NewSweeper(s SweepStore, hub Broadcaster, blobs attachment.BlobDeleter, cfg SweeperConfig, l *slog.Logger) *Sweeper {
+	return &Sweeper{store: s, hub: hub, blobs: blobs, cfg: cfg, logger: l}
+}
+
+// Run deletes expired messages until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SweepOnce(ctx); err != nil {
+			s.logger.Warn("sweeping expired messages failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepOnce deletes every message past its expires_at and returns how many.
+func (s *Sweeper) SweepOnce(ctx context.Context) (int, error) {
+	var total int
+	for {
+		rows, err := s.store.DeleteExpiredMessages(ctx, s.cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, r := range rows {
+			s.notify(r)
+			attachment.DeleteBlobs(ctx, s.blobs, r.StorageKeys, s.logger)
+		}
+		total += len(rows)
+		if len(rows) < int(s.cfg.BatchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Debug("expired messages swept", "count", total)
+	}
+	return total, nil
+}
+
+// notify sends message_expired to the room, or to both DM participants.
+func (s *Sweeper) notify(r dbstore.DeleteExpiredMessagesRow) {
+	msg, err := ws.NewMessage(ws.TypeMessageExpired, ws.MessageExpiredPayload{
+		MessageID:      r.ID,
+		RoomID:         r.RoomID.Int64,
+		ConversationID: r.ConversationID.Int64,
+	})
+	if err != nil {
+		s.logger.Warn("building message_expired frame", "message_id", r.ID, "error", err)
+		return
+	}
+	if r.RoomID.Valid {
+		s.hub.BroadcastToRoom(r.RoomID.Int64, msg)
+	} else {
+		s.hub.BroadcastToUsers(r.ParticipantIds, msg)
+	}
+}
@@ -0,0 +1,78 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// fakeSweepStore hands out the expired rows lim at a time.
+type fakeSweepStore struct {
+	expired []dbstore.DeleteExpiredMessagesRow
+	calls   int
+}
+
+func (f *fakeSweepStore) DeleteExpiredMessages(_ context.Context, lim int32) ([]dbstore.DeleteExpiredMessagesRow, error) {
+	f.calls++
+	n := min(int(lim), len(f.expired))
+	batch := f.expired[:n]
+	f.expired = f.expired[n:]
+	return batch, nil
+}
+
+type sent struct {
+	roomID  int64
+	userIDs []int64
+	payload ws.MessageExpiredPayload
+}
+
+type fakeHub struct{ sent []sent }
+
+func (f *fakeHub) record(roomID int64, userIDs []int64, msg ws.Message) {
+	var p ws.MessageExpiredPayload
+	_ = json.Unmarshal(msg.Payload, &p)
+	f.sent = append(f.sent, sent{roomID: roomID, userIDs: userIDs, payload: p})
+}
+
+func (f *fakeHub) BroadcastToRoom(roomID int64, msg ws.Message) { f.record(roomID, nil, msg) }
+
+func (f *fakeHub) BroadcastToUsers(userIDs []int64, msg ws.Message) { f.record(0, userIDs, msg) }
+
+func TestSweepOnce_NotifiesAndRemovesUploads(t *testing.T) {
+	store := &fakeSweepStore{expired: []dbstore.DeleteExpiredMessagesRow{
+		{ID: 1, RoomID: pgtype.Int8{Int64: 10, Valid: true}, StorageKeys: []string{"a"}},
+		{ID: 2, ConversationID: pgtype.Int8{Int64: 7, Valid: true}, ParticipantIds: []int64{3, 4}},
+		{ID: 3, RoomID: pgtype.Int8{Int64: 10, Valid: true}},
+	}}
+	hub, blobs := &fakeHub{}, &fakeBlobs{}
+	s := NewSweeper(store, hub, blobs, SweeperConfig{BatchSize: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	n, err := s.SweepOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a full batch asks for another one
+	if n != 3 || store.calls != 2 {
+		t.Fatalf("swept %d in %d batches, want 3 in 2", n, store.calls)
+	}
+	if !slices.Equal(blobs.deleted, []string{"a"}) {
+		t.Fatalf("expected the upload removed, got %v", blobs.deleted)
+	}
+
+	if len(hub.sent) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(hub.sent))
+	}
+	if got := hub.sent[0]; got.roomID != 10 || got.payload.MessageID != 1 || got.payload.RoomID != 10 {
+		t.Fatalf("room frame = %+v", got)
+	}
+	if got := hub.sent[1]; !slices.Equal(got.userIDs, []int64{3, 4}) || got.payload.ConversationID != 7 {
+		t.Fatalf("dm frame = %+v", got)
+	}
+}
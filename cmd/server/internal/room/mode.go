@@ -0,0 +1,51 @@
+package room
+
+import "errors"
+
+// Room modes control who is allowed to post in a room.
+const (
+	ModeNormal       = "normal"
+	ModeAnnouncement = "announcement"
+	ModeArchived     = "archived"
+)
+
+// Member roles inside a room. Owners and moderators can manage the room.
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleOwner     = "owner"
+)
+
+// ErrRoomArchived indicates that the room no longer accepts messages.
+// ErrRoomReadOnly indicates that only moderators can post in the room.
+var (
+	ErrRoomArchived = errors.New("room is archived")
+	ErrRoomReadOnly = errors.New("only moderators can post in this room")
+)
+
+// ValidMode reports whether mode is one of the known room modes.
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeNormal, ModeAnnouncement, ModeArchived:
+		return true
+	}
+	return false
+}
+
+// IsModerator reports whether role grants moderation rights in a room.
+func IsModerator(role string) bool {
+	return role == RoleModerator || role == RoleOwner
+}
+
+// CanPost checks whether a member with the given role can post in a room with the given mode.
+func CanPost(mode, role string) error {
+	switch mode {
+	case ModeArchived:
+		return ErrRoomArchived
+	case ModeAnnouncement:
+		if !IsModerator(role) {
+			return ErrRoomReadOnly
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package room
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// MaxPins caps how many messages a room can have pinned at once.
+const MaxPins = 50
+
+// Pin pins a message of the room. Only owners and moderators can do it.
+// Pinning a message twice is not an error.
+func (s *Service) Pin(ctx context.Context, roomID, actorID, messageID int64) error {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return err
+	}
+
+	n, err := s.store.CountPinnedMessages(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if n >= MaxPins {
+		return httpx.New(http.StatusConflict, "too_many_pins", fmt.Sprintf("a room can have at most %d pinned messages", MaxPins), nil)
+	}
+
+	n, err = s.store.PinMessage(ctx, dbstore.PinMessageParams{RoomID: roomID, PinnedBy: actorID, MessageID: messageID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "message not found in this room", nil)
+	}
+	audit.Record(ctx, s.store, s.logger, audit.Event{
+		Action:     audit.ActionMessagePinned,
+		ActorID:    actorID,
+		TargetType: audit.TargetMessage,
+		TargetID:   messageID,
+		Details:    map[string]any{"room_id": roomID},
+	})
+	return nil
+}
+
+// Unpin removes a pin. Only owners and moderators can do it.
+func (s *Service) Unpin(ctx context.Context, roomID, actorID, messageID int64) error {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return err
+	}
+
+	n, err := s.store.UnpinMessage(ctx, dbstore.UnpinMessageParams{RoomID: roomID, MessageID: messageID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "message is not pinned", nil)
+	}
+	audit.Record(ctx, s.store, s.logger, audit.Event{
+		Action:     audit.ActionMessageUnpinned,
+		ActorID:    actorID,
+		TargetType: audit.TargetMessage,
+		TargetID:   messageID,
+		Details:    map[string]any{"room_id": roomID},
+	})
+	return nil
+}
+
+// ListPins returns the pinned messages of a room, most recently pinned first.
+func (s *Service) ListPins(ctx context.Context, roomID int64) ([]dbstore.ListPinnedMessagesRow, error) {
+	return s.store.ListPinnedMessages(ctx, roomID)
+}
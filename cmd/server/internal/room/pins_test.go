@@ -0,0 +1,170 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeStore keeps the members, messages and pins of rooms in memory; unused
+// methods panic through the nil embedded interface.
+type fakeStore struct {
+	Store
+	roles    map[[2]int64]string // {room, user} → role
+	messages map[int64]int64     // message → room
+	pins     map[int64]int64     // message → room
+	audit    []dbstore.CreateAuditEventParams
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		roles: map[[2]int64]string{
+			{10, 1}: RoleOwner,
+			{10, 2}: RoleModerator,
+			{10, 3}: RoleMember,
+		},
+		messages: map[int64]int64{100: 10, 101: 10, 200: 20},
+		pins:     map[int64]int64{},
+	}
+}
+
+func (f *fakeStore) GetRoomMemberRole(_ context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error) {
+	role, ok := f.roles[[2]int64{arg.RoomID, arg.UserID}]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return role, nil
+}
+
+func (f *fakeStore) CountPinnedMessages(_ context.Context, roomID int64) (int64, error) {
+	var n int64
+	for _, r := range f.pins {
+		if r == roomID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PinMessage only pins messages of the given room, as the query does.
+func (f *fakeStore) PinMessage(_ context.Context, arg dbstore.PinMessageParams) (int64, error) {
+	if f.messages[arg.MessageID] != arg.RoomID {
+		return 0, nil
+	}
+	f.pins[arg.MessageID] = arg.RoomID
+	return 1, nil
+}
+
+func (f *fakeStore) UnpinMessage(_ context.Context, arg dbstore.UnpinMessageParams) (int64, error) {
+	if r, ok := f.pins[arg.MessageID]; !ok || r != arg.RoomID {
+		return 0, nil
+	}
+	delete(f.pins, arg.MessageID)
+	return 1, nil
+}
+
+func (f *fakeStore) CreateAuditEvent(_ context.Context, arg dbstore.CreateAuditEventParams) error {
+	f.audit = append(f.audit, arg)
+	return nil
+}
+
+func newTestService() (*Service, *fakeStore) {
+	store := newFakeStore()
+	return NewService(store, slog.New(slog.NewTextHandler(io.Discard, nil))), store
+}
+
+func errCode(err error) string {
+	var httpErr *httpx.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return ""
+}
+
+func TestPin_ModeratorsOnly(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	if err := svc.Pin(ctx, 10, 3, 100); errCode(err) != "forbidden" {
+		t.Errorf("pin by a member: err = %v", err)
+	}
+	if err := svc.Pin(ctx, 10, 4, 100); errCode(err) != "not_member" {
+		t.Errorf("pin by a non-member: err = %v", err)
+	}
+	if len(store.pins) != 0 {
+		t.Fatalf("pins = %v", store.pins)
+	}
+
+	for _, actor := range []int64{1, 2} {
+		if err := svc.Pin(ctx, 10, actor, 100); err != nil {
+			t.Fatalf("pin by %d: %v", actor, err)
+		}
+	}
+	if len(store.pins) != 1 || store.pins[100] != 10 {
+		t.Fatalf("pins = %v", store.pins)
+	}
+
+	if err := svc.Unpin(ctx, 10, 3, 100); errCode(err) != "forbidden" {
+		t.Errorf("unpin by a member: err = %v", err)
+	}
+	if err := svc.Unpin(ctx, 10, 2, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Unpin(ctx, 10, 2, 100); errCode(err) != "not_found" {
+		t.Errorf("unpinning twice: err = %v", err)
+	}
+
+	var actions []string
+	for _, e := range store.audit {
+		actions = append(actions, e.Action)
+	}
+	if want := []string{audit.ActionMessagePinned, audit.ActionMessagePinned, audit.ActionMessageUnpinned}; !slices.Equal(actions, want) {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestPin_MessageOfAnotherRoom(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	// 200 is in room 20, 300 doesn't exist
+	for _, id := range []int64{200, 300} {
+		if err := svc.Pin(ctx, 10, 1, id); errCode(err) != "not_found" {
+			t.Errorf("pin of message %d: err = %v", id, err)
+		}
+	}
+	if len(store.pins) != 0 || len(store.audit) != 0 {
+		t.Fatalf("pins = %v, audit = %v", store.pins, store.audit)
+	}
+}
+
+func TestPin_Cap(t *testing.T) {
+	svc, store := newTestService()
+	ctx := context.Background()
+
+	for id := int64(1000); id < 1000+MaxPins; id++ {
+		store.messages[id] = 10
+		store.pins[id] = 10
+	}
+	if err := svc.Pin(ctx, 10, 1, 100); errCode(err) != "too_many_pins" {
+		t.Fatalf("pin over the cap: err = %v", err)
+	}
+	if _, ok := store.pins[100]; ok {
+		t.Fatal("expected the message not to be pinned")
+	}
+
+	// pins of other rooms don't count
+	delete(store.pins, 1000)
+	store.pins[200] = 20
+	if err := svc.Pin(ctx, 10, 1, 100); err != nil {
+		t.Fatalf("pin under the cap: %v", err)
+	}
+}
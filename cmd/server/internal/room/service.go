@@ -9,17 +9,34 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/forward"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/poll"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 )
 
 type Store interface {
 	GetRoomBySlug(ctx context.Context, slug string) (dbstore.Room, error)
 	CreateRoom(ctx context.Context, params dbstore.CreateRoomParams) (dbstore.Room, error)
-	ListRooms(ctx context.Context) ([]dbstore.Room, error)
+	ListRooms(ctx context.Context, includeArchived bool) ([]dbstore.Room, error)
+	SetRoomMode(ctx context.Context, params dbstore.SetRoomModeParams) (dbstore.Room, error)
+	SetRoomSlowMode(ctx context.Context, params dbstore.SetRoomSlowModeParams) (dbstore.Room, error)
+	SetRoomRetention(ctx context.Context, params dbstore.SetRoomRetentionParams) (dbstore.Room, error)
 	JoinRoom(ctx context.Context, params dbstore.JoinRoomParams) error
+	AddRoomMember(ctx context.Context, params dbstore.AddRoomMemberParams) error
 	LeaveRoom(ctx context.Context, params dbstore.LeaveRoomParams) error
+	GetRoomMemberRole(ctx context.Context, params dbstore.GetRoomMemberRoleParams) (string, error)
+	SetRoomMemberRole(ctx context.Context, params dbstore.SetRoomMemberRoleParams) (int64, error)
 	ListMessagesByRoom(ctx context.Context, params dbstore.ListMessagesByRoomParams) ([]dbstore.ListMessagesByRoomRow, error)
+	PinMessage(ctx context.Context, params dbstore.PinMessageParams) (int64, error)
+	UnpinMessage(ctx context.Context, params dbstore.UnpinMessageParams) (int64, error)
+	CountPinnedMessages(ctx context.Context, roomID int64) (int64, error)
+	ListPinnedMessages(ctx context.Context, roomID int64) ([]dbstore.ListPinnedMessagesRow, error)
+	poll.Store
+	forward.RefStore
+	audit.Writer
 }
 
 type Service struct {
@@ -31,7 +48,12 @@ func NewService(s Store, l *slog.Logger) *Service {
 	return &Service{store: s, logger: l}
 }
 
-func (s *Service) Create(ctx context.Context, name string) (dbstore.Room, error) {
+// Create creates a room and makes the creator its owner.
+func (s *Service) Create(ctx context.Context, name string, creatorID int64) (dbstore.Room, error) {
+	name = strings.TrimSpace(content.Sanitize(name))
+	if name == "" {
+		return dbstore.Room{}, httpx.BadRequest("missing_name", "room name is required", nil)
+	}
 	slug := slugify(name)
 	room, err := s.store.CreateRoom(ctx, dbstore.CreateRoomParams{
 		Name: name,
@@ -40,6 +62,22 @@ func (s *Service) Create(ctx context.Context, name string) (dbstore.Room, error)
 	if err != nil {
 		return dbstore.Room{}, err
 	}
+
+	err = s.store.AddRoomMember(ctx, dbstore.AddRoomMemberParams{
+		RoomID: room.ID,
+		UserID: creatorID,
+		Role:   RoleOwner,
+	})
+	if err != nil {
+		return dbstore.Room{}, err
+	}
+	audit.Record(ctx, s.store, s.logger, audit.Event{
+		Action:     audit.ActionRoomCreated,
+		ActorID:    creatorID,
+		TargetType: audit.TargetRoom,
+		TargetID:   room.ID,
+		Details:    map[string]any{"name": room.Name, "slug": room.Slug},
+	})
 	return room, nil
 }
 
@@ -61,8 +99,9 @@ func slugify(s string) string {
 	return s
 }
 
-func (s *Service) ListRooms(ctx context.Context) ([]dbstore.Room, error) {
-	rooms, err := s.store.ListRooms(ctx)
+// ListRooms returns all rooms, hiding archived ones unless includeArchived is set.
+func (s *Service) ListRooms(ctx context.Context, includeArchived bool) ([]dbstore.Room, error) {
+	rooms, err := s.store.ListRooms(ctx, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +109,149 @@ func (s *Service) ListRooms(ctx context.Context) ([]dbstore.Room, error) {
 	return rooms, nil
 }
 
+// SetMode changes the room mode. Only owners and moderators can do it.
+func (s *Service) SetMode(ctx context.Context, roomID, actorID int64, mode string) (dbstore.Room, error) {
+	if !ValidMode(mode) {
+		return dbstore.Room{}, httpx.BadRequest("invalid_mode", "mode must be normal, announcement or archived", nil)
+	}
+
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return dbstore.Room{}, err
+	}
+
+	room, err := s.store.SetRoomMode(ctx, dbstore.SetRoomModeParams{ID: roomID, Mode: mode})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.Room{}, httpx.New(http.StatusNotFound, "not_found", "room not found", err)
+		}
+		return dbstore.Room{}, err
+	}
+	return room, nil
+}
+
+// MaxSlowModeSeconds caps the slow mode interval a room can be configured with.
+const MaxSlowModeSeconds = 6 * 60 * 60
+
+// SetSlowMode sets the minimum interval between messages of each member. Zero disables it.
+func (s *Service) SetSlowMode(ctx context.Context, roomID, actorID int64, seconds int32) (dbstore.Room, error) {
+	if seconds < 0 || seconds > MaxSlowModeSeconds {
+		return dbstore.Room{}, httpx.BadRequest("invalid_slow_mode", "slow mode must be between 0 and 21600 seconds", nil)
+	}
+
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return dbstore.Room{}, err
+	}
+
+	room, err := s.store.SetRoomSlowMode(ctx, dbstore.SetRoomSlowModeParams{ID: roomID, SlowModeSeconds: seconds})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.Room{}, httpx.New(http.StatusNotFound, "not_found", "room not found", err)
+		}
+		return dbstore.Room{}, err
+	}
+	return room, nil
+}
+
+// MaxRetentionDays caps the message retention a room can be configured with.
+const MaxRetentionDays = 3650
+
+// SetRetention sets how many days the room keeps its messages. Nil falls
+// back to the server default and zero keeps them forever. Only the owner
+// can do it.
+func (s *Service) SetRetention(ctx context.Context, roomID, actorID int64, days *int32) (dbstore.Room, error) {
+	if days != nil && (*days < 0 || *days > MaxRetentionDays) {
+		return dbstore.Room{}, httpx.BadRequest("invalid_retention", "retention must be between 0 and 3650 days", nil)
+	}
+
+	role, err := s.memberRole(ctx, roomID, actorID)
+	if err != nil {
+		return dbstore.Room{}, err
+	}
+	if role != RoleOwner {
+		return dbstore.Room{}, httpx.New(http.StatusForbidden, "forbidden", "only the room owner can change the retention", nil)
+	}
+
+	param := pgtype.Int4{}
+	if days != nil {
+		param = pgtype.Int4{Int32: *days, Valid: true}
+	}
+	room, err := s.store.SetRoomRetention(ctx, dbstore.SetRoomRetentionParams{ID: roomID, RetentionDays: param})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.Room{}, httpx.New(http.StatusNotFound, "not_found", "room not found", err)
+		}
+		return dbstore.Room{}, err
+	}
+	audit.Record(ctx, s.store, s.logger, audit.Event{
+		Action:     audit.ActionRetentionChanged,
+		ActorID:    actorID,
+		TargetType: audit.TargetRoom,
+		TargetID:   roomID,
+		Details:    map[string]any{"days": days},
+	})
+	return room, nil
+}
+
+// SetMemberRole promotes or demotes a room member. Only the owner can do it.
+func (s *Service) SetMemberRole(ctx context.Context, roomID, actorID, targetID int64, role string) error {
+	if role != RoleMember && role != RoleModerator {
+		return httpx.BadRequest("invalid_role", "role must be member or moderator", nil)
+	}
+
+	actorRole, err := s.memberRole(ctx, roomID, actorID)
+	if err != nil {
+		return err
+	}
+	if actorRole != RoleOwner {
+		return httpx.New(http.StatusForbidden, "forbidden", "only the room owner can change roles", nil)
+	}
+	if targetID == actorID {
+		return httpx.BadRequest("invalid_target", "the owner role cannot be changed", nil)
+	}
+
+	n, err := s.store.SetRoomMemberRole(ctx, dbstore.SetRoomMemberRoleParams{
+		RoomID: roomID,
+		UserID: targetID,
+		Role:   role,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "member not found", nil)
+	}
+	audit.Record(ctx, s.store, s.logger, audit.Event{
+		Action:     audit.ActionRoleChanged,
+		ActorID:    actorID,
+		TargetType: audit.TargetUser,
+		TargetID:   targetID,
+		Details:    map[string]any{"room_id": roomID, "role": role},
+	})
+	return nil
+}
+
+func (s *Service) requireModerator(ctx context.Context, roomID, userID int64) error {
+	role, err := s.memberRole(ctx, roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !IsModerator(role) {
+		return httpx.New(http.StatusForbidden, "forbidden", "moderator role required", nil)
+	}
+	return nil
+}
+
+func (s *Service) memberRole(ctx context.Context, roomID, userID int64) (string, error) {
+	role, err := s.store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", httpx.New(http.StatusForbidden, "not_member", "not a member of this room", err)
+		}
+		return "", err
+	}
+	return role, nil
+}
+
 func (s *Service) Join(ctx context.Context, roomID int64, userID int64) error {
 	return s.store.JoinRoom(ctx, dbstore.JoinRoomParams{
 		RoomID: roomID,
@@ -95,3 +277,15 @@ func (s *Service) GetMessagesByRoomID(ctx context.Context, roomID int64, limit i
 
 	return msgs, nil
 }
+
+// PollsForMessages returns the polls among messageIDs with their tallies,
+// keyed by message ID. userID's own votes are reported in each tally.
+func (s *Service) PollsForMessages(ctx context.Context, messageIDs []int64, userID int64) (map[int64]poll.Result, error) {
+	return poll.ForMessages(ctx, s.store, messageIDs, userID)
+}
+
+// RefsForMessages returns the forward origins and quotes of those of
+// messageIDs that have any, keyed by message ID.
+func (s *Service) RefsForMessages(ctx context.Context, messageIDs []int64) (map[int64]forward.Refs, error) {
+	return forward.ForMessages(ctx, s.store, messageIDs)
+}
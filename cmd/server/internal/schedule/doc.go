@@ -0,0 +1,5 @@
+// Package schedule contains the domain logic for scheduled messages: a room
+// message or DM written now and sent at send_at. A background Scheduler
+// claims due messages with SKIP LOCKED and publishes them through the same
+// path as a live message, at most once even with several replicas.
+package schedule
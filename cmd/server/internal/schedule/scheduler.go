@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+// SchedulerStore defines the persistence methods the Scheduler needs. It is
+// also handed to the Poster, which persists the messages.
+type SchedulerStore interface {
+	ws.Store
+	ClaimDueScheduledMessages(ctx context.Context, batchSize int32) ([]dbstore.ClaimDueScheduledMessagesRow, error)
+	SetScheduledMessageSent(ctx context.Context, arg dbstore.SetScheduledMessageSentParams) error
+	MarkScheduledMessageFailed(ctx context.Context, arg dbstore.MarkScheduledMessageFailedParams) error
+}
+
+// Poster publishes messages through the live persist-and-broadcast path,
+// e.g. the ws Hub.
+type Poster interface {
+	PostRoomMessage(ctx context.Context, s ws.Store, l *slog.Logger, sender ws.Sender, roomID int64, body string) (ws.RoomMessagePayload, error)
+	PostDirectMessage(ctx context.Context, s ws.Store, l *slog.Logger, sender ws.Sender, toUserID int64, body string) (ws.DirectMessagePayload, error)
+}
+
+// SchedulerConfig tunes how often due messages are looked for.
+type SchedulerConfig struct {
+	Interval  time.Duration
+	BatchSize int32
+}
+
+// DefaultSchedulerConfig returns the settings used in production.
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{Interval: time.Second, BatchSize: 50}
+}
+
+const maxErrorLen = 500
+
+// Scheduler sends scheduled messages once their send_at passes.
+type Scheduler struct {
+	store  SchedulerStore
+	hub    Poster
+	cfg    SchedulerConfig
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewScheduler creates a Scheduler. Replicas can all run one: each message
+// is claimed by exactly one of them.
+func NewScheduler(s SchedulerStore, hub Poster, cfg SchedulerConfig, l *slog.Logger) *Scheduler {
+	return &Scheduler{store: s, hub: hub, cfg: cfg, logger: l, now: time.Now}
+}
+
+// Run sends due messages until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.sendDue(ctx)
+			if err != nil {
+				s.logger.Warn("sending scheduled messages failed", "error", err)
+			}
+			// a full batch means there may be more waiting
+			if err != nil || n < int(s.cfg.BatchSize) {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue claims one batch of due messages and publishes each of them.
+func (s *Scheduler) sendDue(ctx context.Context) (int, error) {
+	rows, err := s.store.ClaimDueScheduledMessages(ctx, s.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range rows {
+		s.publish(ctx, m)
+	}
+	return len(rows), nil
+}
+
+// publish posts a claimed message as its sender. A message hitting a rate
+// limit or slow mode goes back to the queue for when it may be sent; any
+// other error fails it for good, with the reason shown to the sender.
+func (s *Scheduler) publish(ctx context.Context, m dbstore.ClaimDueScheduledMessagesRow) {
+	if m.SenderDeactivated {
+		s.fail(ctx, m.ID, StatusFailed, s.now(), "account deactivated")
+		return
+	}
+
+	sender := ws.Sender{ID: m.SenderID, Username: m.SenderUsername, IsBot: m.SenderIsBot}
+	var (
+		messageID int64
+		err       error
+	)
+	if m.RoomID.Valid {
+		var p ws.RoomMessagePayload
+		p, err = s.hub.PostRoomMessage(ctx, s.store, s.logger, sender, m.RoomID.Int64, m.Body)
+		messageID = p.MessageID
+	} else {
+		var p ws.DirectMessagePayload
+		p, err = s.hub.PostDirectMessage(ctx, s.store, s.logger, sender, m.ToUserID.Int64, m.Body)
+		messageID = p.MessageID
+	}
+
+	var retry *ws.RetryError
+	switch {
+	case errors.As(err, &retry):
+		s.fail(ctx, m.ID, StatusPending, s.now().Add(retry.Wait), err.Error())
+	case err != nil:
+		s.logger.Info("scheduled message failed", "scheduled_id", m.ID, "sender_id", m.SenderID, "error", err)
+		s.fail(ctx, m.ID, StatusFailed, s.now(), err.Error())
+	case messageID != 0:
+		if err := s.store.SetScheduledMessageSent(ctx, dbstore.SetScheduledMessageSentParams{
+			ID:        m.ID,
+			MessageID: pgtype.Int8{Int64: messageID, Valid: true},
+		}); err != nil {
+			s.logger.Warn("recording sent scheduled message", "scheduled_id", m.ID, "error", err)
+		}
+	}
+}
+
+func (s *Scheduler) fail(ctx context.Context, id int64, status string, sendAt time.Time, reason string) {
+	if len(reason) > maxErrorLen {
+		reason = reason[:maxErrorLen]
+	}
+	if err := s.store.MarkScheduledMessageFailed(ctx, dbstore.MarkScheduledMessageFailedParams{
+		ID:        id,
+		Status:    status,
+		SendAt:    pgtype.Timestamptz{Time: sendAt, Valid: true},
+		LastError: pgtype.Text{String: reason, Valid: true},
+	}); err != nil {
+		s.logger.Warn("recording failed scheduled message", "scheduled_id", id, "error", err)
+	}
+}
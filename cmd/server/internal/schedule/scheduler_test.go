@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
+)
+
+var testNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+var nopLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeQueue hands out due rows once, like the SKIP LOCKED claim, and records
+// what happened to each of them.
+type fakeQueue struct {
+	ws.Store
+	due    []dbstore.ClaimDueScheduledMessagesRow
+	sent   map[int64]int64 // scheduled id → message id
+	failed map[int64]dbstore.MarkScheduledMessageFailedParams
+}
+
+func (q *fakeQueue) ClaimDueScheduledMessages(_ context.Context, batchSize int32) ([]dbstore.ClaimDueScheduledMessagesRow, error) {
+	n := min(int(batchSize), len(q.due))
+	rows := q.due[:n]
+	q.due = q.due[n:]
+	return rows, nil
+}
+
+func (q *fakeQueue) SetScheduledMessageSent(_ context.Context, arg dbstore.SetScheduledMessageSentParams) error {
+	q.sent[arg.ID] = arg.MessageID.Int64
+	return nil
+}
+
+func (q *fakeQueue) MarkScheduledMessageFailed(_ context.Context, arg dbstore.MarkScheduledMessageFailedParams) error {
+	q.failed[arg.ID] = arg
+	return nil
+}
+
+// fakePoster answers with the error set for a room or recipient, or posts
+// the message with the next id.
+type fakePoster struct {
+	errs   map[int64]error
+	posted []string
+}
+
+func (p *fakePoster) post(target int64, body string) (int64, error) {
+	if err := p.errs[target]; err != nil {
+		return 0, err
+	}
+	p.posted = append(p.posted, body)
+	return int64(100 + len(p.posted)), nil
+}
+
+func (p *fakePoster) PostRoomMessage(_ context.Context, _ ws.Store, _ *slog.Logger, _ ws.Sender, roomID int64, body string) (ws.RoomMessagePayload, error) {
+	id, err := p.post(roomID, body)
+	return ws.RoomMessagePayload{MessageID: id}, err
+}
+
+func (p *fakePoster) PostDirectMessage(_ context.Context, _ ws.Store, _ *slog.Logger, _ ws.Sender, toUserID int64, body string) (ws.DirectMessagePayload, error) {
+	id, err := p.post(toUserID, body)
+	return ws.DirectMessagePayload{MessageID: id}, err
+}
+
+func roomMsg(id, roomID int64, body string) dbstore.ClaimDueScheduledMessagesRow {
+	return dbstore.ClaimDueScheduledMessagesRow{ID: id, SenderID: 1, SenderUsername: "ana", RoomID: pgtype.Int8{Int64: roomID, Valid: true}, Body: body}
+}
+
+func newTestScheduler(q *fakeQueue, p *fakePoster, batch int32) *Scheduler {
+	s := NewScheduler(q, p, SchedulerConfig{BatchSize: batch}, nopLogger)
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+func TestSendDue_PublishesRoomsAndDMs(t *testing.T) {
+	dm := dbstore.ClaimDueScheduledMessagesRow{ID: 2, SenderID: 1, ToUserID: pgtype.Int8{Int64: 5, Valid: true}, Body: "psst"}
+	q := &fakeQueue{
+		due:    []dbstore.ClaimDueScheduledMessagesRow{roomMsg(1, 10, "standup"), dm},
+		sent:   map[int64]int64{},
+		failed: map[int64]dbstore.MarkScheduledMessageFailedParams{},
+	}
+	p := &fakePoster{}
+
+	n, err := newTestScheduler(q, p, 10).sendDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(p.posted) != 2 || p.posted[0] != "standup" || p.posted[1] != "psst" {
+		t.Fatalf("sent %d, posted %v", n, p.posted)
+	}
+	if q.sent[1] != 101 || q.sent[2] != 102 {
+		t.Fatalf("expected message ids recorded, got %v", q.sent)
+	}
+}
+
+func TestSendDue_Failures(t *testing.T) {
+	deactivated := roomMsg(4, 10, "bye")
+	deactivated.SenderDeactivated = true
+	q := &fakeQueue{
+		due:    []dbstore.ClaimDueScheduledMessagesRow{roomMsg(1, 20, "archived"), roomMsg(2, 30, "slow"), roomMsg(3, 10, "fine"), deactivated},
+		sent:   map[int64]int64{},
+		failed: map[int64]dbstore.MarkScheduledMessageFailedParams{},
+	}
+	p := &fakePoster{errs: map[int64]error{
+		20: room.ErrRoomArchived,
+		30: &ws.RetryError{Code: ws.ErrCodeSlowMode, Wait: 40 * time.Second},
+	}}
+
+	if _, err := newTestScheduler(q, p, 10).sendDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := q.failed[1]; got.Status != StatusFailed || got.LastError.String != room.ErrRoomArchived.Error() {
+		t.Fatalf("archived room: %+v", got)
+	}
+	// slow mode only delays the message
+	if got := q.failed[2]; got.Status != StatusPending || !got.SendAt.Time.Equal(testNow.Add(40*time.Second)) {
+		t.Fatalf("slow mode: %+v", got)
+	}
+	if got := q.failed[4]; got.Status != StatusFailed || got.LastError.String != "account deactivated" {
+		t.Fatalf("deactivated sender: %+v", got)
+	}
+	if len(p.posted) != 1 || q.sent[3] == 0 {
+		t.Fatalf("only the fine message should go out, posted %v", p.posted)
+	}
+}
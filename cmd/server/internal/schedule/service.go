@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Scheduled message statuses stored in scheduled_messages.status.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// Store defines the persistence methods required for managing scheduled messages.
+type Store interface {
+	CreateScheduledMessage(ctx context.Context, arg dbstore.CreateScheduledMessageParams) (dbstore.ScheduledMessage, error)
+	ListScheduledMessagesBySender(ctx context.Context, arg dbstore.ListScheduledMessagesBySenderParams) ([]dbstore.ScheduledMessage, error)
+	CountPendingScheduledMessages(ctx context.Context, senderID int64) (int64, error)
+	UpdateScheduledMessage(ctx context.Context, arg dbstore.UpdateScheduledMessageParams) (dbstore.ScheduledMessage, error)
+	DeleteScheduledMessage(ctx context.Context, arg dbstore.DeleteScheduledMessageParams) (int64, error)
+	GetRoomMemberRole(ctx context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error)
+	GetUserByID(ctx context.Context, id int64) (dbstore.GetUserByIDRow, error)
+	CanDirectMessage(ctx context.Context, arg dbstore.CanDirectMessageParams) (bool, error)
+}
+
+// Config bounds what can be scheduled.
+type Config struct {
+	// MaxRunes is the longest body accepted, as for live messages.
+	MaxRunes int
+	// MaxAhead is how far in the future send_at may be.
+	MaxAhead time.Duration
+	// MaxPending caps the messages a user can have waiting.
+	MaxPending int64
+}
+
+// DefaultConfig returns the limits used when none are configured.
+func DefaultConfig() Config {
+	return Config{MaxRunes: 2000, MaxAhead: 30 * 24 * time.Hour, MaxPending: 100}
+}
+
+// Service manages the scheduled messages of their senders.
+type Service struct {
+	store  Store
+	cfg    Config
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewService creates a new schedule Service.
+func NewService(s Store, cfg Config, l *slog.Logger) *Service {
+	return &Service{store: s, cfg: cfg, logger: l, now: time.Now}
+}
+
+// Create schedules body to be sent at sendAt, either to a room the sender is
+// a member of or as a DM to toUserID. Exactly one of them must be set.
+func (s *Service) Create(ctx context.Context, senderID, roomID, toUserID int64, body string, sendAt time.Time) (dbstore.ScheduledMessage, error) {
+	if (roomID == 0) == (toUserID == 0) {
+		return dbstore.ScheduledMessage{}, httpx.BadRequest("invalid_target", "exactly one of room_id and to_user_id is required", nil)
+	}
+	body, err := s.checkBody(body)
+	if err != nil {
+		return dbstore.ScheduledMessage{}, err
+	}
+	if err := s.checkSendAt(sendAt); err != nil {
+		return dbstore.ScheduledMessage{}, err
+	}
+	if err := s.checkTarget(ctx, senderID, roomID, toUserID); err != nil {
+		return dbstore.ScheduledMessage{}, err
+	}
+
+	pending, err := s.store.CountPendingScheduledMessages(ctx, senderID)
+	if err != nil {
+		return dbstore.ScheduledMessage{}, err
+	}
+	if pending >= s.cfg.MaxPending {
+		return dbstore.ScheduledMessage{}, httpx.New(http.StatusConflict, "too_many_scheduled",
+			fmt.Sprintf("at most %d messages can be scheduled at a time", s.cfg.MaxPending), nil)
+	}
+
+	params := dbstore.CreateScheduledMessageParams{
+		SenderID: senderID,
+		Body:     body,
+		SendAt:   pgtype.Timestamptz{Time: sendAt, Valid: true},
+	}
+	if roomID != 0 {
+		params.RoomID = pgtype.Int8{Int64: roomID, Valid: true}
+	} else {
+		params.ToUserID = pgtype.Int8{Int64: toUserID, Valid: true}
+	}
+	return s.store.CreateScheduledMessage(ctx, params)
+}
+
+// List returns the pending and failed messages of a sender, soonest first.
+func (s *Service) List(ctx context.Context, senderID int64, limit int32) ([]dbstore.ScheduledMessage, error) {
+	return s.store.ListScheduledMessagesBySender(ctx, dbstore.ListScheduledMessagesBySenderParams{SenderID: senderID, Lim: limit})
+}
+
+// Update changes the body or the send time of a message that wasn't sent
+// yet; nil leaves a field as is. A failed message is queued again.
+func (s *Service) Update(ctx context.Context, senderID, id int64, body *string, sendAt *time.Time) (dbstore.ScheduledMessage, error) {
+	if body == nil && sendAt == nil {
+		return dbstore.ScheduledMessage{}, httpx.BadRequest("nothing_to_update", "body or send_at is required", nil)
+	}
+
+	params := dbstore.UpdateScheduledMessageParams{ID: id, SenderID: senderID}
+	if body != nil {
+		b, err := s.checkBody(*body)
+		if err != nil {
+			return dbstore.ScheduledMessage{}, err
+		}
+		params.Body = pgtype.Text{String: b, Valid: true}
+	}
+	if sendAt != nil {
+		if err := s.checkSendAt(*sendAt); err != nil {
+			return dbstore.ScheduledMessage{}, err
+		}
+		params.SendAt = pgtype.Timestamptz{Time: *sendAt, Valid: true}
+	}
+
+	msg, err := s.store.UpdateScheduledMessage(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.ScheduledMessage{}, notFound(err)
+		}
+		return dbstore.ScheduledMessage{}, err
+	}
+	return msg, nil
+}
+
+// Cancel deletes a message that wasn't sent yet.
+func (s *Service) Cancel(ctx context.Context, senderID, id int64) error {
+	n, err := s.store.DeleteScheduledMessage(ctx, dbstore.DeleteScheduledMessageParams{ID: id, SenderID: senderID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound(nil)
+	}
+	return nil
+}
+
+func (s *Service) checkBody(body string) (string, error) {
+	body, err := content.Validate(body, s.cfg.MaxRunes)
+	switch {
+	case errors.Is(err, content.ErrEmpty):
+		return "", httpx.BadRequest("missing_body", "body is required", err)
+	case errors.Is(err, content.ErrTooLong):
+		return "", httpx.BadRequest("message_too_long", fmt.Sprintf("body is longer than %d characters", s.cfg.MaxRunes), err)
+	}
+	return body, err
+}
+
+func (s *Service) checkSendAt(sendAt time.Time) error {
+	now := s.now()
+	if !sendAt.After(now) || sendAt.After(now.Add(s.cfg.MaxAhead)) {
+		return httpx.BadRequest("invalid_send_at", fmt.Sprintf("send_at must be in the future and at most %s ahead", s.cfg.MaxAhead), nil)
+	}
+	return nil
+}
+
+// checkTarget refuses rooms the sender is not in and recipients who don't
+// take DMs from them. Both are checked again when the message goes out.
+func (s *Service) checkTarget(ctx context.Context, senderID, roomID, toUserID int64) error {
+	if roomID != 0 {
+		if _, err := s.store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: senderID}); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return httpx.New(http.StatusForbidden, "not_member", "not a member of this room", err)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if toUserID == senderID {
+		return httpx.BadRequest("invalid_target", "can't schedule a message to yourself", nil)
+	}
+	if _, err := s.store.GetUserByID(ctx, toUserID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httpx.New(http.StatusNotFound, "user_not_found", "user not found", err)
+		}
+		return err
+	}
+	allowed, err := s.store.CanDirectMessage(ctx, dbstore.CanDirectMessageParams{RecipientID: toUserID, SenderID: senderID})
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return httpx.New(http.StatusForbidden, "dm_not_allowed", "this user doesn't accept direct messages from you", nil)
+	}
+	return nil
+}
+
+func notFound(err error) error {
+	return httpx.New(http.StatusNotFound, "not_found", "scheduled message not found", err)
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeStore knows one room with user 1 as its only member.
+type fakeStore struct {
+	Store
+	created []dbstore.CreateScheduledMessageParams
+}
+
+func (f *fakeStore) GetRoomMemberRole(_ context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error) {
+	if arg.RoomID != 10 || arg.UserID != 1 {
+		return "", pgx.ErrNoRows
+	}
+	return "member", nil
+}
+
+func (f *fakeStore) CountPendingScheduledMessages(context.Context, int64) (int64, error) {
+	return int64(len(f.created)), nil
+}
+
+func (f *fakeStore) CreateScheduledMessage(_ context.Context, arg dbstore.CreateScheduledMessageParams) (dbstore.ScheduledMessage, error) {
+	f.created = append(f.created, arg)
+	return dbstore.ScheduledMessage{ID: int64(len(f.created)), SenderID: arg.SenderID, RoomID: arg.RoomID, Body: arg.Body, SendAt: arg.SendAt}, nil
+}
+
+func TestCreate(t *testing.T) {
+	store := &fakeStore{}
+	cfg := DefaultConfig()
+	cfg.MaxPending = 1
+	svc := NewService(store, cfg, nopLogger)
+	svc.now = func() time.Time { return testNow }
+	later := testNow.Add(15 * time.Minute)
+
+	tests := []struct {
+		name     string
+		senderID int64
+		roomID   int64
+		toUserID int64
+		body     string
+		sendAt   time.Time
+		code     string
+	}{
+		{"no target", 1, 0, 0, "hi", later, "invalid_target"},
+		{"both targets", 1, 10, 2, "hi", later, "invalid_target"},
+		{"empty body", 1, 10, 0, " ", later, "missing_body"},
+		{"in the past", 1, 10, 0, "hi", testNow.Add(-time.Minute), "invalid_send_at"},
+		{"too far ahead", 1, 10, 0, "hi", testNow.Add(cfg.MaxAhead + time.Minute), "invalid_send_at"},
+		{"not a member", 2, 10, 0, "hi", later, "not_member"},
+		{"ok", 1, 10, 0, "hi", later, ""},
+		{"over the cap", 1, 10, 0, "again", later, "too_many_scheduled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Create(context.Background(), tt.senderID, tt.roomID, tt.toUserID, tt.body, tt.sendAt)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var he *httpx.HTTPError
+			if !errors.As(err, &he) || he.Code != tt.code {
+				t.Fatalf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+	if len(store.created) != 1 || !store.created[0].SendAt.Time.Equal(later) {
+		t.Fatalf("expected one message stored for %v, got %+v", later, store.created)
+	}
+}
@@ -13,7 +13,7 @@ const getOrCreateConversation = `-- name: GetOrCreateConversation :one
 INSERT INTO conversations (user_a, user_b)
 VALUES (LEAST($1, $2), GREATEST($1, $2))
 ON CONFLICT (user_a, user_b) DO UPDATE SET user_a = EXCLUDED.user_a
-RETURNING id, user_a, user_b
+RETURNING id, user_a, user_b, default_ttl_seconds
 `
 
 type GetOrCreateConversationParams struct {
@@ -24,12 +24,17 @@ type GetOrCreateConversationParams struct {
 func (q *Queries) GetOrCreateConversation(ctx context.Context, arg GetOrCreateConversationParams) (Conversation, error) {
 	row := q.db.QueryRow(ctx, getOrCreateConversation, arg.Column1, arg.Column2)
 	var i Conversation
-	err := row.Scan(&i.ID, &i.UserA, &i.UserB)
+	err := row.Scan(
+		&i.ID,
+		&i.UserA,
+		&i.UserB,
+		&i.DefaultTtlSeconds,
+	)
 	return i, err
 }
 
 const listConversationsByUser = `-- name: ListConversationsByUser :many
-SELECT conversations.id, peer.id as peer_id, peer.username AS peer_username
+SELECT conversations.id, peer.id as peer_id, peer.username AS peer_username, conversations.default_ttl_seconds
 FROM conversations
 JOIN users peer ON (CASE WHEN user_a = $1 THEN user_b ELSE user_a END) = peer.id
 WHERE user_a = $1 OR user_b = $1
@@ -42,9 +47,10 @@ type ListConversationsByUserParams struct {
 }
 
 type ListConversationsByUserRow struct {
-	ID           int64
-	PeerID       int64
-	PeerUsername string
+	ID                int64
+	PeerID            int64
+	PeerUsername      string
+	DefaultTtlSeconds int32
 }
 
 func (q *Queries) ListConversationsByUser(ctx context.Context, arg ListConversationsByUserParams) ([]ListConversationsByUserRow, error) {
@@ -56,7 +62,12 @@ func (q *Queries) ListConversationsByUser(ctx context.Context, arg ListConversat
 	var items []ListConversationsByUserRow
 	for rows.Next() {
 		var i ListConversationsByUserRow
-		if err := rows.Scan(&i.ID, &i.PeerID, &i.PeerUsername); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.PeerID,
+			&i.PeerUsername,
+			&i.DefaultTtlSeconds,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -66,3 +77,25 @@ func (q *Queries) ListConversationsByUser(ctx context.Context, arg ListConversat
 	}
 	return items, nil
 }
+
+const setConversationTTL = `-- name: SetConversationTTL :execrows
+UPDATE conversations
+SET default_ttl_seconds = $1
+WHERE id = $2 AND (user_a = $3 OR user_b = $3)
+`
+
+type SetConversationTTLParams struct {
+	Seconds int32
+	ID      int64
+	UserID  int64
+}
+
+// Only a participant can change it; no rows means the conversation does not
+// exist or the user is not in it.
+func (q *Queries) SetConversationTTL(ctx context.Context, arg SetConversationTTLParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setConversationTTL, arg.Seconds, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -18,21 +18,42 @@ WITH conv AS (
         greatest($1::bigint, $3::bigint))
         ON CONFLICT (user_a, user_b)
         DO UPDATE SET user_a = EXCLUDED.user_a
-    RETURNING id
+    RETURNING id, default_ttl_seconds
 )
-INSERT INTO messages (conversation_id, sender_id, body)
-    SELECT id, $1, $2 FROM conv
-RETURNING id, room_id, conversation_id, sender_id, body, created_at
+INSERT INTO messages (conversation_id, sender_id, body, expires_at,
+    forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id)
+    SELECT id, $1, $2,
+        now() + make_interval(secs => NULLIF(COALESCE(NULLIF($4::int, 0), default_ttl_seconds), 0)),
+        $5::bigint, $6::bigint,
+        $7::bigint, $8::bigint
+    FROM conv
+RETURNING id, room_id, conversation_id, sender_id, body, created_at, kind, expires_at, forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id
 `
 
 type CreateDirectMessageParams struct {
-	SenderID int64
-	Body     string
-	ToUserID int64
+	SenderID            int64
+	Body                string
+	ToUserID            int64
+	TtlSeconds          int32
+	ForwardedFromID     pgtype.Int8
+	ForwardedFromUserID pgtype.Int8
+	ForwardedFromRoomID pgtype.Int8
+	QuoteMessageID      pgtype.Int8
 }
 
+// A ttl_seconds of 0 falls back to the conversation default, which is 0 (no
+// expiry) unless a participant set one.
 func (q *Queries) CreateDirectMessage(ctx context.Context, arg CreateDirectMessageParams) (Message, error) {
-	row := q.db.QueryRow(ctx, createDirectMessage, arg.SenderID, arg.Body, arg.ToUserID)
+	row := q.db.QueryRow(ctx, createDirectMessage,
+		arg.SenderID,
+		arg.Body,
+		arg.ToUserID,
+		arg.TtlSeconds,
+		arg.ForwardedFromID,
+		arg.ForwardedFromUserID,
+		arg.ForwardedFromRoomID,
+		arg.QuoteMessageID,
+	)
 	var i Message
 	err := row.Scan(
 		&i.ID,
@@ -41,21 +62,34 @@ func (q *Queries) CreateDirectMessage(ctx context.Context, arg CreateDirectMessa
 		&i.SenderID,
 		&i.Body,
 		&i.CreatedAt,
+		&i.Kind,
+		&i.ExpiresAt,
+		&i.ForwardedFromID,
+		&i.ForwardedFromUserID,
+		&i.ForwardedFromRoomID,
+		&i.QuoteMessageID,
 	)
 	return i, err
 }
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (room_id, conversation_id, sender_id, body)
-VALUES ($1, $2, $3, $4)
-RETURNING id, room_id, conversation_id, sender_id, body, created_at
+INSERT INTO messages (room_id, conversation_id, sender_id, body, kind, expires_at,
+    forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id, room_id, conversation_id, sender_id, body, created_at, kind, expires_at, forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id
 `
 
 type CreateMessageParams struct {
-	RoomID         pgtype.Int8
-	ConversationID pgtype.Int8
-	SenderID       int64
-	Body           string
+	RoomID              pgtype.Int8
+	ConversationID      pgtype.Int8
+	SenderID            int64
+	Body                string
+	Kind                string
+	ExpiresAt           pgtype.Timestamptz
+	ForwardedFromID     pgtype.Int8
+	ForwardedFromUserID pgtype.Int8
+	ForwardedFromRoomID pgtype.Int8
+	QuoteMessageID      pgtype.Int8
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -64,6 +98,12 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.ConversationID,
 		arg.SenderID,
 		arg.Body,
+		arg.Kind,
+		arg.ExpiresAt,
+		arg.ForwardedFromID,
+		arg.ForwardedFromUserID,
+		arg.ForwardedFromRoomID,
+		arg.QuoteMessageID,
 	)
 	var i Message
 	err := row.Scan(
@@ -73,14 +113,223 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.SenderID,
 		&i.Body,
 		&i.CreatedAt,
+		&i.Kind,
+		&i.ExpiresAt,
+		&i.ForwardedFromID,
+		&i.ForwardedFromUserID,
+		&i.ForwardedFromRoomID,
+		&i.QuoteMessageID,
+	)
+	return i, err
+}
+
+const deleteExpiredMessages = `-- name: DeleteExpiredMessages :many
+DELETE FROM messages m
+WHERE m.id IN (
+    SELECT id FROM messages
+    WHERE expires_at <= now()
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING m.id, m.room_id, m.conversation_id,
+  COALESCE((SELECT ARRAY[c.user_a, c.user_b] FROM conversations c WHERE c.id = m.conversation_id), '{}')::bigint[] AS participant_ids,
+  ARRAY(SELECT a.storage_key FROM attachments a WHERE a.message_id = m.id)::text[] AS storage_keys
+`
+
+type DeleteExpiredMessagesRow struct {
+	ID             int64
+	RoomID         pgtype.Int8
+	ConversationID pgtype.Int8
+	ParticipantIds []int64
+	StorageKeys    []string
+}
+
+// Deletes up to lim messages past their expires_at. Returns where each one
+// was, the DM participants and the upload keys, so the caller can tell open
+// clients and remove the blobs.
+func (q *Queries) DeleteExpiredMessages(ctx context.Context, lim int32) ([]DeleteExpiredMessagesRow, error) {
+	rows, err := q.db.Query(ctx, deleteExpiredMessages, lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteExpiredMessagesRow
+	for rows.Next() {
+		var i DeleteExpiredMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.ConversationID,
+			&i.ParticipantIds,
+			&i.StorageKeys,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMessage = `-- name: DeleteMessage :one
+DELETE FROM messages m
+WHERE m.id = $1
+RETURNING m.id, m.room_id, m.conversation_id,
+  COALESCE((SELECT ARRAY[c.user_a, c.user_b] FROM conversations c WHERE c.id = m.conversation_id), '{}')::bigint[] AS participant_ids
+`
+
+type DeleteMessageRow struct {
+	ID             int64
+	RoomID         pgtype.Int8
+	ConversationID pgtype.Int8
+	ParticipantIds []int64
+}
+
+// Returns where the message was, and for DMs both participants, so they can
+// be told about it.
+func (q *Queries) DeleteMessage(ctx context.Context, id int64) (DeleteMessageRow, error) {
+	row := q.db.QueryRow(ctx, deleteMessage, id)
+	var i DeleteMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.ConversationID,
+		&i.ParticipantIds,
 	)
 	return i, err
 }
 
+const getVisibleMessage = `-- name: GetVisibleMessage :one
+SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username,
+  r.name AS room_name, m.body, m.kind, m.expires_at,
+  m.forwarded_from_id, m.forwarded_from_user_id, fu.username AS forwarded_from_username,
+  m.forwarded_from_room_id, fr.name AS forwarded_from_room_name
+FROM messages m
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN rooms r ON r.id = m.room_id
+LEFT JOIN users fu ON fu.id = m.forwarded_from_user_id
+LEFT JOIN rooms fr ON fr.id = m.forwarded_from_room_id
+WHERE m.id = $1
+  AND (m.expires_at IS NULL OR m.expires_at > now())
+  AND (
+    EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $2)
+    OR EXISTS (SELECT 1 FROM conversations c WHERE c.id = m.conversation_id AND $2::bigint IN (c.user_a, c.user_b))
+  )
+`
+
+type GetVisibleMessageParams struct {
+	ID     int64
+	UserID int64
+}
+
+type GetVisibleMessageRow struct {
+	ID                    int64
+	RoomID                pgtype.Int8
+	ConversationID        pgtype.Int8
+	SenderID              int64
+	SenderUsername        string
+	RoomName              pgtype.Text
+	Body                  string
+	Kind                  string
+	ExpiresAt             pgtype.Timestamptz
+	ForwardedFromID       pgtype.Int8
+	ForwardedFromUserID   pgtype.Int8
+	ForwardedFromUsername pgtype.Text
+	ForwardedFromRoomID   pgtype.Int8
+	ForwardedFromRoomName pgtype.Text
+}
+
+// Returns a message user_id can see: one of a room they are a member of or
+// of one of their conversations, and not expired. Forwarded messages come
+// with their origin.
+func (q *Queries) GetVisibleMessage(ctx context.Context, arg GetVisibleMessageParams) (GetVisibleMessageRow, error) {
+	row := q.db.QueryRow(ctx, getVisibleMessage, arg.ID, arg.UserID)
+	var i GetVisibleMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.SenderUsername,
+		&i.RoomName,
+		&i.Body,
+		&i.Kind,
+		&i.ExpiresAt,
+		&i.ForwardedFromID,
+		&i.ForwardedFromUserID,
+		&i.ForwardedFromUsername,
+		&i.ForwardedFromRoomID,
+		&i.ForwardedFromRoomName,
+	)
+	return i, err
+}
+
+const listMessageRefs = `-- name: ListMessageRefs :many
+SELECT m.id, m.forwarded_from_id, m.forwarded_from_user_id, fu.username AS forwarded_from_username,
+  m.forwarded_from_room_id, fr.name AS forwarded_from_room_name,
+  q.id AS quote_id, q.sender_id AS quote_sender_id, qu.username AS quote_sender_username, q.body AS quote_body
+FROM messages m
+LEFT JOIN users fu ON fu.id = m.forwarded_from_user_id
+LEFT JOIN rooms fr ON fr.id = m.forwarded_from_room_id
+LEFT JOIN messages q ON q.id = m.quote_message_id AND (q.expires_at IS NULL OR q.expires_at > now())
+LEFT JOIN users qu ON qu.id = q.sender_id
+WHERE m.id = ANY($1::bigint[])
+  AND (m.forwarded_from_user_id IS NOT NULL OR q.id IS NOT NULL)
+`
+
+type ListMessageRefsRow struct {
+	ID                    int64
+	ForwardedFromID       pgtype.Int8
+	ForwardedFromUserID   pgtype.Int8
+	ForwardedFromUsername pgtype.Text
+	ForwardedFromRoomID   pgtype.Int8
+	ForwardedFromRoomName pgtype.Text
+	QuoteID               pgtype.Int8
+	QuoteSenderID         pgtype.Int8
+	QuoteSenderUsername   pgtype.Text
+	QuoteBody             pgtype.Text
+}
+
+// Returns the forward origin and the quoted message of those of the given
+// messages that have either. Quotes of expired messages are left out.
+func (q *Queries) ListMessageRefs(ctx context.Context, messageIds []int64) ([]ListMessageRefsRow, error) {
+	rows, err := q.db.Query(ctx, listMessageRefs, messageIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMessageRefsRow
+	for rows.Next() {
+		var i ListMessageRefsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ForwardedFromID,
+			&i.ForwardedFromUserID,
+			&i.ForwardedFromUsername,
+			&i.ForwardedFromRoomID,
+			&i.ForwardedFromRoomName,
+			&i.QuoteID,
+			&i.QuoteSenderID,
+			&i.QuoteSenderUsername,
+			&i.QuoteBody,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listMessagesByConversation = `-- name: ListMessagesByConversation :many
-SELECT id, room_id, conversation_id, sender_id, body, created_at
+SELECT id, room_id, conversation_id, sender_id, body, created_at, kind, expires_at, forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id
 FROM messages
-WHERE conversation_id = $1
+WHERE conversation_id = $1 AND (expires_at IS NULL OR expires_at > now())
 ORDER BY created_at DESC
 LIMIT $2
 `
@@ -106,6 +355,12 @@ func (q *Queries) ListMessagesByConversation(ctx context.Context, arg ListMessag
 			&i.SenderID,
 			&i.Body,
 			&i.CreatedAt,
+			&i.Kind,
+			&i.ExpiresAt,
+			&i.ForwardedFromID,
+			&i.ForwardedFromUserID,
+			&i.ForwardedFromRoomID,
+			&i.QuoteMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -118,10 +373,10 @@ func (q *Queries) ListMessagesByConversation(ctx context.Context, arg ListMessag
 }
 
 const listMessagesByRoom = `-- name: ListMessagesByRoom :many
-SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username, m.body, m.created_at
+SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username, u.is_bot AS sender_is_bot, m.body, m.created_at, m.kind, m.expires_at
 FROM messages m
 JOIN users u ON u.id = m.sender_id
-WHERE m.room_id = $1
+WHERE m.room_id = $1 AND (m.expires_at IS NULL OR m.expires_at > now())
 ORDER BY m.created_at DESC
 LIMIT $2
 `
@@ -137,8 +392,11 @@ type ListMessagesByRoomRow struct {
 	ConversationID pgtype.Int8
 	SenderID       int64
 	SenderUsername string
+	SenderIsBot    bool
 	Body           string
 	CreatedAt      pgtype.Timestamptz
+	Kind           string
+	ExpiresAt      pgtype.Timestamptz
 }
 
 func (q *Queries) ListMessagesByRoom(ctx context.Context, arg ListMessagesByRoomParams) ([]ListMessagesByRoomRow, error) {
@@ -156,8 +414,62 @@ func (q *Queries) ListMessagesByRoom(ctx context.Context, arg ListMessagesByRoom
 			&i.ConversationID,
 			&i.SenderID,
 			&i.SenderUsername,
+			&i.SenderIsBot,
+			&i.Body,
+			&i.CreatedAt,
+			&i.Kind,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessagesBySender = `-- name: ListMessagesBySender :many
+SELECT id, room_id, conversation_id, body, created_at, kind
+FROM messages
+WHERE sender_id = $1 AND id > $2
+  AND (expires_at IS NULL OR expires_at > now())
+ORDER BY id
+LIMIT $3
+`
+
+type ListMessagesBySenderParams struct {
+	SenderID int64
+	AfterID  int64
+	Lim      int32
+}
+
+type ListMessagesBySenderRow struct {
+	ID             int64
+	RoomID         pgtype.Int8
+	ConversationID pgtype.Int8
+	Body           string
+	CreatedAt      pgtype.Timestamptz
+	Kind           string
+}
+
+func (q *Queries) ListMessagesBySender(ctx context.Context, arg ListMessagesBySenderParams) ([]ListMessagesBySenderRow, error) {
+	rows, err := q.db.Query(ctx, listMessagesBySender, arg.SenderID, arg.AfterID, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMessagesBySenderRow
+	for rows.Next() {
+		var i ListMessagesBySenderRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.ConversationID,
 			&i.Body,
 			&i.CreatedAt,
+			&i.Kind,
 		); err != nil {
 			return nil, err
 		}
@@ -8,37 +8,258 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Attachment struct {
+	ID          int64
+	UploaderID  int64
+	MessageID   pgtype.Int8
+	StorageKey  string
+	Filename    string
+	ContentType string
+	SizeBytes   int64
+	CreatedAt   pgtype.Timestamptz
+}
+
+type AuditEvent struct {
+	ID         int64
+	Action     string
+	ActorID    pgtype.Int8
+	TargetType string
+	TargetID   pgtype.Int8
+	Ip         string
+	RequestID  string
+	Details    []byte
+	CreatedAt  pgtype.Timestamptz
+}
+
+type BotToken struct {
+	ID        int64
+	BotID     int64
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	RevokedAt pgtype.Timestamptz
+}
+
 type Conversation struct {
-	ID    int64
-	UserA int64
-	UserB int64
+	ID                int64
+	UserA             int64
+	UserB             int64
+	DefaultTtlSeconds int32
+}
+
+type IncomingWebhook struct {
+	ID        int64
+	RoomID    int64
+	BotID     int64
+	TokenHash []byte
+	CreatedBy int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type LoginAttempt struct {
+	Scope         string
+	Subject       string
+	Failures      int32
+	LastFailureAt pgtype.Timestamptz
+	BlockedUntil  pgtype.Timestamptz
 }
 
 type Message struct {
+	ID                  int64
+	RoomID              pgtype.Int8
+	ConversationID      pgtype.Int8
+	SenderID            int64
+	Body                string
+	CreatedAt           pgtype.Timestamptz
+	Kind                string
+	ExpiresAt           pgtype.Timestamptz
+	ForwardedFromID     pgtype.Int8
+	ForwardedFromUserID pgtype.Int8
+	ForwardedFromRoomID pgtype.Int8
+	QuoteMessageID      pgtype.Int8
+}
+
+type OidcLogin struct {
 	ID             int64
-	RoomID         pgtype.Int8
-	ConversationID pgtype.Int8
-	SenderID       int64
-	Body           string
+	State          string
+	Nonce          string
+	CodeVerifier   string
+	DeviceCodeHash []byte
+	UserCode       pgtype.Text
+	UserID         pgtype.Int8
 	CreatedAt      pgtype.Timestamptz
+	ExpiresAt      pgtype.Timestamptz
+	StateUsedAt    pgtype.Timestamptz
+	ConsumedAt     pgtype.Timestamptz
+	LinkUserID     pgtype.Int8
 }
 
-type Room struct {
+type PasswordReset struct {
+	TokenHash []byte
+	UserID    int64
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type PinnedMessage struct {
+	MessageID int64
+	RoomID    int64
+	PinnedBy  int64
+	PinnedAt  pgtype.Timestamptz
+}
+
+type Poll struct {
+	MessageID int64
+	RoomID    int64
+	Options   []string
+	Multiple  bool
+	Anonymous bool
+	ClosesAt  pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type PollVote struct {
+	MessageID   int64
+	UserID      int64
+	OptionIndex int32
+	VotedAt     pgtype.Timestamptz
+}
+
+type RecoveryCode struct {
 	ID        int64
-	Name      string
-	Slug      string
+	UserID    int64
+	CodeHash  []byte
+	UsedAt    pgtype.Timestamptz
 	CreatedAt pgtype.Timestamptz
 }
 
+type RefreshToken struct {
+	TokenHash []byte
+	SessionID int64
+	CreatedAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type Room struct {
+	ID              int64
+	Name            string
+	Slug            string
+	CreatedAt       pgtype.Timestamptz
+	Mode            string
+	SlowModeSeconds int32
+	Topic           string
+	RetentionDays   pgtype.Int4
+}
+
 type RoomMember struct {
 	RoomID   int64
 	UserID   int64
 	JoinedAt pgtype.Timestamptz
+	Role     string
 }
 
-type User struct {
+type SavedMessage struct {
+	UserID    int64
+	MessageID int64
+	SavedAt   pgtype.Timestamptz
+}
+
+type ScheduledMessage struct {
 	ID        int64
-	Username  string
-	Password  string
+	SenderID  int64
+	RoomID    pgtype.Int8
+	ToUserID  pgtype.Int8
+	Body      string
+	SendAt    pgtype.Timestamptz
+	Status    string
+	MessageID pgtype.Int8
+	LastError pgtype.Text
+	SentAt    pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+type Session struct {
+	ID         int64
+	UserID     int64
+	UserAgent  string
+	Ip         string
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
+
+type User struct {
+	ID            int64
+	Username      string
+	Password      string
+	CreatedAt     pgtype.Timestamptz
+	IsBot         bool
+	OwnerID       pgtype.Int8
+	IsAdmin       bool
+	Email         pgtype.Text
+	DeactivatedAt pgtype.Timestamptz
+	DeleteAfter   pgtype.Timestamptz
+	DeletedAt     pgtype.Timestamptz
+}
+
+type UserBlock struct {
+	BlockerID int64
+	BlockedID int64
+	CreatedAt pgtype.Timestamptz
+}
+
+type UserIdentity struct {
+	ID          int64
+	UserID      int64
+	Issuer      string
+	Subject     string
+	Email       pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	LastLoginAt pgtype.Timestamptz
+}
+
+type UserProfile struct {
+	UserID             int64
+	DisplayName        string
+	Bio                string
+	AvatarAttachmentID pgtype.Int8
+	AvatarUrl          string
+	StatusText         string
+	StatusEmoji        string
+	UpdatedAt          pgtype.Timestamptz
+	DmPolicy           string
+}
+
+type UserTotp struct {
+	UserID    int64
+	Secret    string
+	EnabledAt pgtype.Timestamptz
+	LastStep  int64
 	CreatedAt pgtype.Timestamptz
 }
+
+type Webhook struct {
+	ID         int64
+	RoomID     int64
+	Url        string
+	Secret     string
+	EventTypes []string
+	CreatedBy  int64
+	CreatedAt  pgtype.Timestamptz
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	WebhookID      int64
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  pgtype.Timestamptz
+	LastStatusCode pgtype.Int4
+	LastError      pgtype.Text
+	CreatedAt      pgtype.Timestamptz
+	DeliveredAt    pgtype.Timestamptz
+}
@@ -7,10 +7,47 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addRoomMember = `-- name: AddRoomMember :exec
+INSERT INTO room_members (room_id, user_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (room_id, user_id) DO UPDATE SET role = EXCLUDED.role
+`
+
+type AddRoomMemberParams struct {
+	RoomID int64
+	UserID int64
+	Role   string
+}
+
+func (q *Queries) AddRoomMember(ctx context.Context, arg AddRoomMemberParams) error {
+	_, err := q.db.Exec(ctx, addRoomMember, arg.RoomID, arg.UserID, arg.Role)
+	return err
+}
+
+const getRoomMemberRole = `-- name: GetRoomMemberRole :one
+SELECT role
+FROM room_members
+WHERE room_id = $1 AND user_id = $2
+`
+
+type GetRoomMemberRoleParams struct {
+	RoomID int64
+	UserID int64
+}
+
+func (q *Queries) GetRoomMemberRole(ctx context.Context, arg GetRoomMemberRoleParams) (string, error) {
+	row := q.db.QueryRow(ctx, getRoomMemberRole, arg.RoomID, arg.UserID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
 const getRoomsForUser = `-- name: GetRoomsForUser :many
-SELECT r.id, r.name, r.slug, r.created_at
+SELECT r.id, r.name, r.slug, r.created_at, r.mode, r.slow_mode_seconds, r.topic, r.retention_days
 FROM rooms r
 JOIN room_members rm ON rm.room_id = r.id
 WHERE rm.user_id = $1
@@ -31,6 +68,10 @@ func (q *Queries) GetRoomsForUser(ctx context.Context, userID int64) ([]Room, er
 			&i.Name,
 			&i.Slug,
 			&i.CreatedAt,
+			&i.Mode,
+			&i.SlowModeSeconds,
+			&i.Topic,
+			&i.RetentionDays,
 		); err != nil {
 			return nil, err
 		}
@@ -93,7 +134,7 @@ func (q *Queries) LeaveRoom(ctx context.Context, arg LeaveRoomParams) error {
 }
 
 const listRoomMembers = `-- name: ListRoomMembers :many
-SELECT u.id, u.username
+SELECT u.id, u.username, rm.role
 FROM room_members rm
 JOIN users u ON u.id = rm.user_id
 WHERE rm.room_id = $1
@@ -103,6 +144,7 @@ ORDER BY rm.joined_at
 type ListRoomMembersRow struct {
 	ID       int64
 	Username string
+	Role     string
 }
 
 func (q *Queries) ListRoomMembers(ctx context.Context, roomID int64) ([]ListRoomMembersRow, error) {
@@ -114,7 +156,49 @@ func (q *Queries) ListRoomMembers(ctx context.Context, roomID int64) ([]ListRoom
 	var items []ListRoomMembersRow
 	for rows.Next() {
 		var i ListRoomMembersRow
-		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+		if err := rows.Scan(&i.ID, &i.Username, &i.Role); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoomMembershipsByUser = `-- name: ListRoomMembershipsByUser :many
+SELECT r.id, r.name, r.slug, rm.role, rm.joined_at
+FROM room_members rm
+JOIN rooms r ON r.id = rm.room_id
+WHERE rm.user_id = $1
+ORDER BY rm.joined_at
+`
+
+type ListRoomMembershipsByUserRow struct {
+	ID       int64
+	Name     string
+	Slug     string
+	Role     string
+	JoinedAt pgtype.Timestamptz
+}
+
+func (q *Queries) ListRoomMembershipsByUser(ctx context.Context, userID int64) ([]ListRoomMembershipsByUserRow, error) {
+	rows, err := q.db.Query(ctx, listRoomMembershipsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRoomMembershipsByUserRow
+	for rows.Next() {
+		var i ListRoomMembershipsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.Role,
+			&i.JoinedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -124,3 +208,23 @@ func (q *Queries) ListRoomMembers(ctx context.Context, roomID int64) ([]ListRoom
 	}
 	return items, nil
 }
+
+const setRoomMemberRole = `-- name: SetRoomMemberRole :execrows
+UPDATE room_members
+SET role = $3
+WHERE room_id = $1 AND user_id = $2
+`
+
+type SetRoomMemberRoleParams struct {
+	RoomID int64
+	UserID int64
+	Role   string
+}
+
+func (q *Queries) SetRoomMemberRole(ctx context.Context, arg SetRoomMemberRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setRoomMemberRole, arg.RoomID, arg.UserID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
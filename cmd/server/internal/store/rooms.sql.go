@@ -7,12 +7,14 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createRoom = `-- name: CreateRoom :one
 INSERT INTO rooms (name, slug)
 VALUES ($1, $2)
-RETURNING id, name, slug, created_at
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 `
 
 type CreateRoomParams struct {
@@ -28,12 +30,30 @@ func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, e
 		&i.Name,
 		&i.Slug,
 		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
+const deleteRoom = `-- name: DeleteRoom :execrows
+DELETE FROM rooms
+WHERE id = $1
+`
+
+// Members, messages, attachments and webhooks go with it.
+func (q *Queries) DeleteRoom(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRoom, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getRoomByID = `-- name: GetRoomByID :one
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
 WHERE id = $1
 `
@@ -46,12 +66,16 @@ func (q *Queries) GetRoomByID(ctx context.Context, id int64) (Room, error) {
 		&i.Name,
 		&i.Slug,
 		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
 const getRoomBySlug = `-- name: GetRoomBySlug :one
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
 WHERE slug = $1
 `
@@ -64,18 +88,23 @@ func (q *Queries) GetRoomBySlug(ctx context.Context, slug string) (Room, error)
 		&i.Name,
 		&i.Slug,
 		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
 	)
 	return i, err
 }
 
 const listRooms = `-- name: ListRooms :many
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
+WHERE $1::boolean OR mode <> 'archived'
 ORDER BY created_at DESC
 `
 
-func (q *Queries) ListRooms(ctx context.Context) ([]Room, error) {
-	rows, err := q.db.Query(ctx, listRooms)
+func (q *Queries) ListRooms(ctx context.Context, includeArchived bool) ([]Room, error) {
+	rows, err := q.db.Query(ctx, listRooms, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +117,10 @@ func (q *Queries) ListRooms(ctx context.Context) ([]Room, error) {
 			&i.Name,
 			&i.Slug,
 			&i.CreatedAt,
+			&i.Mode,
+			&i.SlowModeSeconds,
+			&i.Topic,
+			&i.RetentionDays,
 		); err != nil {
 			return nil, err
 		}
@@ -98,3 +131,115 @@ func (q *Queries) ListRooms(ctx context.Context) ([]Room, error) {
 	}
 	return items, nil
 }
+
+const setRoomMode = `-- name: SetRoomMode :one
+UPDATE rooms
+SET mode = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
+`
+
+type SetRoomModeParams struct {
+	ID   int64
+	Mode string
+}
+
+func (q *Queries) SetRoomMode(ctx context.Context, arg SetRoomModeParams) (Room, error) {
+	row := q.db.QueryRow(ctx, setRoomMode, arg.ID, arg.Mode)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const setRoomRetention = `-- name: SetRoomRetention :one
+UPDATE rooms
+SET retention_days = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
+`
+
+type SetRoomRetentionParams struct {
+	ID            int64
+	RetentionDays pgtype.Int4
+}
+
+func (q *Queries) SetRoomRetention(ctx context.Context, arg SetRoomRetentionParams) (Room, error) {
+	row := q.db.QueryRow(ctx, setRoomRetention, arg.ID, arg.RetentionDays)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const setRoomSlowMode = `-- name: SetRoomSlowMode :one
+UPDATE rooms
+SET slow_mode_seconds = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
+`
+
+type SetRoomSlowModeParams struct {
+	ID              int64
+	SlowModeSeconds int32
+}
+
+func (q *Queries) SetRoomSlowMode(ctx context.Context, arg SetRoomSlowModeParams) (Room, error) {
+	row := q.db.QueryRow(ctx, setRoomSlowMode, arg.ID, arg.SlowModeSeconds)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
+	)
+	return i, err
+}
+
+const setRoomTopic = `-- name: SetRoomTopic :one
+UPDATE rooms
+SET topic = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
+`
+
+type SetRoomTopicParams struct {
+	ID    int64
+	Topic string
+}
+
+func (q *Queries) SetRoomTopic(ctx context.Context, arg SetRoomTopicParams) (Room, error) {
+	row := q.db.QueryRow(ctx, setRoomTopic, arg.ID, arg.Topic)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.CreatedAt,
+		&i.Mode,
+		&i.SlowModeSeconds,
+		&i.Topic,
+		&i.RetentionDays,
+	)
+	return i, err
+}
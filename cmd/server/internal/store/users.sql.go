@@ -11,25 +11,131 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const anonymizeUser = `-- name: AnonymizeUser :exec
+UPDATE users
+SET username = 'deleted user #' || id, password = '', email = NULL,
+    deactivated_at = COALESCE(deactivated_at, now()), delete_after = NULL, deleted_at = now()
+WHERE id = $1
+`
+
+// Turns the account into the placeholder its messages keep pointing to. The
+// username can't collide with a real one: those can't contain spaces.
+func (q *Queries) AnonymizeUser(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, anonymizeUser, id)
+	return err
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :execrows
+UPDATE users
+SET deactivated_at = NULL, delete_after = NULL
+WHERE id = $1 AND delete_after > now()
+`
+
+// Reactivates an account whose grace period hasn't run out.
+func (q *Queries) CancelUserDeletion(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, cancelUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (username, password)
-VALUES ($1, $2)
-RETURNING id, username, password, created_at
+INSERT INTO users (username, password, email)
+VALUES ($1, $2, $3)
+RETURNING id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
 `
 
 type CreateUserParams struct {
 	Username string
 	Password string
+	Email    pgtype.Text
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Password)
+	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Password, arg.Email)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.Username,
 		&i.Password,
 		&i.CreatedAt,
+		&i.IsBot,
+		&i.OwnerID,
+		&i.IsAdmin,
+		&i.Email,
+		&i.DeactivatedAt,
+		&i.DeleteAfter,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const deactivateUser = `-- name: DeactivateUser :execrows
+UPDATE users
+SET deactivated_at = now(), delete_after = NULL
+WHERE id = $1 AND deactivated_at IS NULL
+`
+
+// Deactivation by an admin: no delete_after, so logging in doesn't undo it.
+func (q *Queries) DeactivateUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, deactivateUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getAccount = `-- name: GetAccount :one
+SELECT id, username, email, created_at, deactivated_at, delete_after
+FROM users
+WHERE id = $1
+`
+
+type GetAccountRow struct {
+	ID            int64
+	Username      string
+	Email         pgtype.Text
+	CreatedAt     pgtype.Timestamptz
+	DeactivatedAt pgtype.Timestamptz
+	DeleteAfter   pgtype.Timestamptz
+}
+
+func (q *Queries) GetAccount(ctx context.Context, id int64) (GetAccountRow, error) {
+	row := q.db.QueryRow(ctx, getAccount, id)
+	var i GetAccountRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.CreatedAt,
+		&i.DeactivatedAt,
+		&i.DeleteAfter,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE lower(email) = lower($1) AND NOT is_bot
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.CreatedAt,
+		&i.IsBot,
+		&i.OwnerID,
+		&i.IsAdmin,
+		&i.Email,
+		&i.DeactivatedAt,
+		&i.DeleteAfter,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -54,7 +160,7 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (GetUserByIDRow, er
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, password, created_at
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
 FROM users
 WHERE username = $1
 `
@@ -67,6 +173,220 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Username,
 		&i.Password,
 		&i.CreatedAt,
+		&i.IsBot,
+		&i.OwnerID,
+		&i.IsAdmin,
+		&i.Email,
+		&i.DeactivatedAt,
+		&i.DeleteAfter,
+		&i.DeletedAt,
 	)
 	return i, err
 }
+
+const getUserPasswordHash = `-- name: GetUserPasswordHash :one
+SELECT password
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserPasswordHash(ctx context.Context, id int64) (string, error) {
+	row := q.db.QueryRow(ctx, getUserPasswordHash, id)
+	var password string
+	err := row.Scan(&password)
+	return password, err
+}
+
+const isUserAdmin = `-- name: IsUserAdmin :one
+SELECT is_admin
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) IsUserAdmin(ctx context.Context, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, isUserAdmin, id)
+	var is_admin bool
+	err := row.Scan(&is_admin)
+	return is_admin, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, email, is_bot, is_admin, created_at, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE NOT $1::boolean OR deactivated_at IS NOT NULL
+ORDER BY id
+LIMIT $2 OFFSET $3
+`
+
+type ListUsersParams struct {
+	DeactivatedOnly bool
+	Lim             int32
+	Off             int32
+}
+
+type ListUsersRow struct {
+	ID            int64
+	Username      string
+	Email         pgtype.Text
+	IsBot         bool
+	IsAdmin       bool
+	CreatedAt     pgtype.Timestamptz
+	DeactivatedAt pgtype.Timestamptz
+	DeleteAfter   pgtype.Timestamptz
+	DeletedAt     pgtype.Timestamptz
+}
+
+// Every account, including bots and deleted ones, for the admin API.
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.DeactivatedOnly, arg.Lim, arg.Off)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.IsBot,
+			&i.IsAdmin,
+			&i.CreatedAt,
+			&i.DeactivatedAt,
+			&i.DeleteAfter,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersDueForDeletion = `-- name: ListUsersDueForDeletion :many
+SELECT id
+FROM users
+WHERE delete_after <= now()
+ORDER BY delete_after
+LIMIT $1
+`
+
+func (q *Queries) ListUsersDueForDeletion(ctx context.Context, limit int32) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listUsersDueForDeletion, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeUserData = `-- name: PurgeUserData :exec
+WITH del_sessions AS (
+    DELETE FROM sessions WHERE user_id = $1
+), del_totp AS (
+    DELETE FROM user_totp WHERE user_id = $1
+), del_recovery AS (
+    DELETE FROM recovery_codes WHERE user_id = $1
+), del_resets AS (
+    DELETE FROM password_resets WHERE user_id = $1
+), del_identities AS (
+    DELETE FROM user_identities WHERE user_id = $1
+), del_logins AS (
+    DELETE FROM oidc_logins WHERE user_id = $1
+), del_blocks AS (
+    DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1
+), pass_ownership AS (
+    UPDATE room_members rm SET role = 'owner'
+    FROM (
+        SELECT DISTINCT ON (m.room_id) m.room_id, m.user_id
+        FROM room_members m
+        JOIN room_members o ON o.room_id = m.room_id AND o.user_id = $1 AND o.role = 'owner'
+        JOIN users u ON u.id = m.user_id
+        WHERE m.user_id <> $1
+        ORDER BY m.room_id, u.is_bot, u.deactivated_at IS NOT NULL, m.role <> 'moderator', m.joined_at, m.user_id
+    ) heir
+    WHERE rm.room_id = heir.room_id AND rm.user_id = heir.user_id
+), del_members AS (
+    DELETE FROM room_members WHERE user_id = $1
+), revoke_bots AS (
+    UPDATE bot_tokens SET revoked_at = now()
+    WHERE revoked_at IS NULL AND bot_id IN (SELECT id FROM users WHERE owner_id = $1)
+)
+INSERT INTO user_profiles (user_id, display_name, dm_policy)
+VALUES ($1, 'Deleted user', 'nobody')
+ON CONFLICT (user_id) DO UPDATE
+SET display_name = EXCLUDED.display_name, bio = '', avatar_attachment_id = NULL, avatar_url = '',
+    status_text = '', status_emoji = '', dm_policy = EXCLUDED.dm_policy, updated_at = now()
+`
+
+// Deletes everything a user logs in with or is listed by, revokes the tokens
+// of their bots and replaces the profile with the "Deleted user" one.
+// Messages and conversations are kept. Rooms they own pass to their oldest
+// moderator, else their oldest member, people before bots.
+func (q *Queries) PurgeUserData(ctx context.Context, userID int64) error {
+	_, err := q.db.Exec(ctx, purgeUserData, userID)
+	return err
+}
+
+const reactivateUser = `-- name: ReactivateUser :execrows
+UPDATE users
+SET deactivated_at = NULL, delete_after = NULL
+WHERE id = $1 AND deactivated_at IS NOT NULL AND deleted_at IS NULL
+`
+
+// Lifts an admin deactivation or a pending deletion. Deleted accounts stay.
+func (q *Queries) ReactivateUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, reactivateUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET deactivated_at = now(), delete_after = $2
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type ScheduleUserDeletionParams struct {
+	ID          int64
+	DeleteAfter pgtype.Timestamptz
+}
+
+// Deactivates the account; the purger anonymizes it once delete_after passes.
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.Exec(ctx, scheduleUserDeletion, arg.ID, arg.DeleteAfter)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = $2
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID       int64
+	Password string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.Password)
+	return err
+}
@@ -0,0 +1,185 @@
+package user
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// exportStore adds alice's history to fakeStore.
+type exportStore struct {
+	*fakeStore
+	messages []dbstore.Message
+	pages    int
+}
+
+func (f *exportStore) GetAccount(_ context.Context, id int64) (dbstore.GetAccountRow, error) {
+	return dbstore.GetAccountRow{ID: id, Email: pgtype.Text{String: "alice@example.com", Valid: true}}, nil
+}
+
+func (f *exportStore) ListMessagesBySender(_ context.Context, arg dbstore.ListMessagesBySenderParams) ([]dbstore.ListMessagesBySenderRow, error) {
+	f.pages++
+	var out []dbstore.ListMessagesBySenderRow
+	for _, m := range f.messages {
+		if m.SenderID == arg.SenderID && m.ID > arg.AfterID && len(out) < int(arg.Lim) {
+			out = append(out, dbstore.ListMessagesBySenderRow{ID: m.ID, RoomID: m.RoomID, ConversationID: m.ConversationID, Body: m.Body, Kind: m.Kind})
+		}
+	}
+	return out, nil
+}
+
+func (f *exportStore) ListAttachmentsByMessageIDs(_ context.Context, ids []int64) ([]dbstore.Attachment, error) {
+	var out []dbstore.Attachment
+	for _, a := range f.attachments {
+		if a.MessageID.Valid && slices.Contains(ids, a.MessageID.Int64) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (f *exportStore) ListRoomMembershipsByUser(context.Context, int64) ([]dbstore.ListRoomMembershipsByUserRow, error) {
+	return []dbstore.ListRoomMembershipsByUserRow{{ID: 7, Name: "General", Slug: "general", Role: "owner"}}, nil
+}
+
+func (f *exportStore) ListConversationsByUser(context.Context, dbstore.ListConversationsByUserParams) ([]dbstore.ListConversationsByUserRow, error) {
+	return []dbstore.ListConversationsByUserRow{{ID: 3, PeerID: 2, PeerUsername: "bob"}}, nil
+}
+
+func TestExport_Zip(t *testing.T) {
+	store := &exportStore{fakeStore: newFakeStore()}
+	store.profiles[1] = dbstore.GetUserProfileRow{ID: 1, Username: "alice", DisplayName: "Alice"}
+	// one more than a page, and one message of bob's that must be left out
+	for i := int64(1); i <= exportPageSize+1; i++ {
+		store.messages = append(store.messages, dbstore.Message{ID: i, SenderID: 1, RoomID: pgtype.Int8{Int64: 7, Valid: true}, Body: fmt.Sprint("hi ", i), Kind: "text"})
+	}
+	store.messages = append(store.messages, dbstore.Message{ID: 1000, SenderID: 2, Body: "not alice's"})
+	store.attachments[13] = dbstore.Attachment{ID: 13, UploaderID: 1, MessageID: pgtype.Int8{Int64: 2, Valid: true}, Filename: "cat.png"}
+	svc := NewService(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var buf bytes.Buffer
+	if err := svc.Export(context.Background(), 1, &buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	read := func(name string, v any) {
+		t.Helper()
+		f, ok := files[name]
+		if !ok {
+			t.Fatalf("%s missing from the export", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		if err := json.NewDecoder(rc).Decode(v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	var profile resdto.ExportProfileRes
+	read("profile.json", &profile)
+	if profile.DisplayName != "Alice" || profile.Email != "alice@example.com" || profile.DMPolicy != DMPolicyEveryone {
+		t.Errorf("profile = %+v", profile)
+	}
+
+	var messages []resdto.ExportMessageRes
+	read("messages.json", &messages)
+	if len(messages) != exportPageSize+1 || store.pages != 2 {
+		t.Fatalf("expected %d messages in 2 pages, got %d in %d", exportPageSize+1, len(messages), store.pages)
+	}
+	if m := messages[1]; m.ID != 2 || m.RoomID != 7 || len(m.Attachments) != 1 || m.Attachments[0].Filename != "cat.png" {
+		t.Errorf("message 2 = %+v", m)
+	}
+
+	var rooms []resdto.ExportRoomRes
+	read("rooms.json", &rooms)
+	var convs []resdto.ConversationRes
+	read("conversations.json", &convs)
+	if len(rooms) != 1 || rooms[0].Role != "owner" || len(convs) != 1 || convs[0].PeerUsername != "bob" {
+		t.Errorf("rooms = %+v, conversations = %+v", rooms, convs)
+	}
+}
+
+// fakePurgeStore records the purge steps taken per user.
+type fakePurgeStore struct {
+	due     []int64
+	uploads map[int64][]string
+	failOn  int64
+	steps   []string
+}
+
+func (f *fakePurgeStore) ListUsersDueForDeletion(context.Context, int32) ([]int64, error) {
+	return f.due, nil
+}
+
+func (f *fakePurgeStore) DeleteUnlinkedAttachmentsByUploader(_ context.Context, id int64) ([]string, error) {
+	f.steps = append(f.steps, fmt.Sprint("uploads ", id))
+	return f.uploads[id], nil
+}
+
+func (f *fakePurgeStore) PurgeUserData(_ context.Context, id int64) error {
+	if id == f.failOn {
+		return errors.New("boom")
+	}
+	f.steps = append(f.steps, fmt.Sprint("data ", id))
+	return nil
+}
+
+func (f *fakePurgeStore) AnonymizeUser(_ context.Context, id int64) error {
+	f.steps = append(f.steps, fmt.Sprint("anonymize ", id))
+	return nil
+}
+
+type fakeBlobs struct{ deleted []string }
+
+func (b *fakeBlobs) Delete(_ context.Context, key string) error {
+	b.deleted = append(b.deleted, key)
+	return nil
+}
+
+func TestPurger_PurgeDue(t *testing.T) {
+	store := &fakePurgeStore{due: []int64{4, 5}, uploads: map[int64][]string{4: {"a", "b"}}}
+	blobs := &fakeBlobs{}
+	p := NewPurger(store, blobs, DefaultPurgerConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	n, err := p.PurgeDue(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("PurgeDue = %d, %v", n, err)
+	}
+	want := []string{"uploads 4", "data 4", "anonymize 4", "uploads 5", "data 5", "anonymize 5"}
+	if !slices.Equal(store.steps, want) {
+		t.Fatalf("steps = %v, want %v", store.steps, want)
+	}
+	if !slices.Equal(blobs.deleted, []string{"a", "b"}) {
+		t.Fatalf("deleted blobs = %v", blobs.deleted)
+	}
+
+	// a failure stops before the account is anonymized, so it's retried
+	store.steps, store.due, store.failOn = nil, []int64{6}, 6
+	if _, err := p.PurgeDue(context.Background()); err == nil {
+		t.Fatal("expected the failure to be returned")
+	}
+	if slices.Contains(store.steps, "anonymize 6") {
+		t.Fatalf("expected account 6 to stay due, steps = %v", store.steps)
+	}
+}
@@ -0,0 +1,58 @@
+package user
+
+import (
+	"context"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// DM policies: who may send a user direct messages. Blocks apply on top of
+// any policy.
+const (
+	DMPolicyEveryone = "everyone"
+	DMPolicyRooms    = "rooms" // only people sharing a room with the user
+	DMPolicyNobody   = "nobody"
+)
+
+// Block stops blockedID from sending blockerID direct messages, and hides
+// each from the other's searches. Blocking twice is not an error.
+func (s *Service) Block(ctx context.Context, blockerID, blockedID int64) error {
+	if blockerID == blockedID {
+		return httpx.BadRequest("invalid_user_id", "you can't block yourself", nil)
+	}
+	// 404 for unknown users rather than a foreign key error
+	if _, err := s.GetProfile(ctx, blockedID); err != nil {
+		return err
+	}
+	if err := s.store.BlockUser(ctx, dbstore.BlockUserParams{BlockerID: blockerID, BlockedID: blockedID}); err != nil {
+		return err
+	}
+	s.logger.Info("user blocked", "user_id", blockerID, "blocked_id", blockedID)
+	return nil
+}
+
+// Unblock lifts a block. Unblocking someone who isn't blocked is not an error.
+func (s *Service) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	return s.store.UnblockUser(ctx, dbstore.UnblockUserParams{BlockerID: blockerID, BlockedID: blockedID})
+}
+
+// ListBlocked returns the users blockerID blocked, most recent first.
+func (s *Service) ListBlocked(ctx context.Context, blockerID int64) ([]dbstore.ListBlockedUsersRow, error) {
+	return s.store.ListBlockedUsers(ctx, blockerID)
+}
+
+// DMPolicy returns the DM policy of a user.
+func (s *Service) DMPolicy(ctx context.Context, userID int64) (string, error) {
+	return s.store.GetDMPolicy(ctx, userID)
+}
+
+// SetDMPolicy changes who may send userID direct messages.
+func (s *Service) SetDMPolicy(ctx context.Context, userID int64, policy string) error {
+	switch policy {
+	case DMPolicyEveryone, DMPolicyRooms, DMPolicyNobody:
+	default:
+		return httpx.BadRequest("invalid_dm_policy", "dm_policy must be everyone, rooms or nobody", nil)
+	}
+	return s.store.SetDMPolicy(ctx, dbstore.SetDMPolicyParams{UserID: userID, DmPolicy: policy})
+}
@@ -0,0 +1,58 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) BlockUser(_ context.Context, arg dbstore.BlockUserParams) error {
+	f.blocks[[2]int64{arg.BlockerID, arg.BlockedID}] = true
+	return nil
+}
+
+func (f *fakeStore) SetDMPolicy(_ context.Context, arg dbstore.SetDMPolicyParams) error {
+	f.dmPolicies[arg.UserID] = arg.DmPolicy
+	return nil
+}
+
+func (f *fakeStore) GetDMPolicy(_ context.Context, userID int64) (string, error) {
+	if p, ok := f.dmPolicies[userID]; ok {
+		return p, nil
+	}
+	return DMPolicyEveryone, nil
+}
+
+func TestBlock(t *testing.T) {
+	svc, store := newProfileService()
+	ctx := context.Background()
+
+	if err := svc.Block(ctx, 1, 1); errCode(err) != "invalid_user_id" {
+		t.Errorf("blocking yourself: err = %v", err)
+	}
+	if err := svc.Block(ctx, 1, 42); errCode(err) != "not_found" {
+		t.Errorf("blocking a missing user: err = %v", err)
+	}
+	if err := svc.Block(ctx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.blocks) != 1 || !store.blocks[[2]int64{1, 2}] {
+		t.Fatalf("blocks = %v", store.blocks)
+	}
+}
+
+func TestSetDMPolicy(t *testing.T) {
+	svc, store := newProfileService()
+	ctx := context.Background()
+
+	if err := svc.SetDMPolicy(ctx, 1, "friends"); errCode(err) != "invalid_dm_policy" {
+		t.Errorf("err = %v, want invalid_dm_policy", err)
+	}
+	if err := svc.SetDMPolicy(ctx, 1, DMPolicyRooms); err != nil {
+		t.Fatal(err)
+	}
+	if store.dmPolicies[1] != DMPolicyRooms {
+		t.Fatalf("policy = %q", store.dmPolicies[1])
+	}
+}
@@ -0,0 +1,168 @@
+package user
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+
+	resdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/response"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// exportPageSize is how many messages are read per query while exporting.
+const exportPageSize = 500
+
+// Export writes a zip with the personal data of userID to w: profile.json,
+// messages.json (every message they sent, with attachment metadata),
+// rooms.json and conversations.json.
+func (s *Service) Export(ctx context.Context, userID int64, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	profile, err := s.exportProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	messages, err := s.exportMessages(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(zw, "messages.json", messages); err != nil {
+		return err
+	}
+
+	memberships, err := s.store.ListRoomMembershipsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	rooms := make([]resdto.ExportRoomRes, 0, len(memberships))
+	for _, m := range memberships {
+		rooms = append(rooms, resdto.ExportRoomRes{
+			ID:       m.ID,
+			Name:     m.Name,
+			Slug:     m.Slug,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt.Time,
+		})
+	}
+	if err := writeJSON(zw, "rooms.json", rooms); err != nil {
+		return err
+	}
+
+	convs, err := s.store.ListConversationsByUser(ctx, dbstore.ListConversationsByUserParams{UserID: userID, Lim: math.MaxInt32})
+	if err != nil {
+		return err
+	}
+	conversations := make([]resdto.ConversationRes, 0, len(convs))
+	for _, c := range convs {
+		conversations = append(conversations, resdto.ConversationRes{ID: c.ID, PeerID: c.PeerID, PeerUsername: c.PeerUsername})
+	}
+	if err := writeJSON(zw, "conversations.json", conversations); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func (s *Service) exportProfile(ctx context.Context, userID int64) (resdto.ExportProfileRes, error) {
+	acc, err := s.store.GetAccount(ctx, userID)
+	if err != nil {
+		return resdto.ExportProfileRes{}, err
+	}
+	p, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return resdto.ExportProfileRes{}, err
+	}
+	policy, err := s.store.GetDMPolicy(ctx, userID)
+	if err != nil {
+		return resdto.ExportProfileRes{}, err
+	}
+	return resdto.ExportProfileRes{
+		ProfileRes: resdto.ProfileRes{
+			ID:                 p.ID,
+			Username:           p.Username,
+			IsBot:              p.IsBot,
+			DisplayName:        p.DisplayName,
+			Bio:                p.Bio,
+			AvatarAttachmentID: p.AvatarAttachmentID.Int64,
+			AvatarURL:          p.AvatarUrl,
+			StatusText:         p.StatusText,
+			StatusEmoji:        p.StatusEmoji,
+			CreatedAt:          p.CreatedAt.Time,
+		},
+		Email:    acc.Email.String,
+		DMPolicy: policy,
+	}, nil
+}
+
+// exportMessages reads every message userID sent, oldest first.
+func (s *Service) exportMessages(ctx context.Context, userID int64) ([]resdto.ExportMessageRes, error) {
+	out := []resdto.ExportMessageRes{}
+	var after int64
+	for {
+		page, err := s.store.ListMessagesBySender(ctx, dbstore.ListMessagesBySenderParams{
+			SenderID: userID,
+			AfterID:  after,
+			Lim:      exportPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return out, nil
+		}
+
+		ids := make([]int64, 0, len(page))
+		for _, m := range page {
+			ids = append(ids, m.ID)
+		}
+		atts, err := s.store.ListAttachmentsByMessageIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		byMessage := make(map[int64][]resdto.AttachmentRes)
+		for _, a := range atts {
+			byMessage[a.MessageID.Int64] = append(byMessage[a.MessageID.Int64], resdto.AttachmentRes{
+				ID:          a.ID,
+				Filename:    a.Filename,
+				ContentType: a.ContentType,
+				SizeBytes:   a.SizeBytes,
+				CreatedAt:   a.CreatedAt.Time,
+			})
+		}
+
+		for _, m := range page {
+			out = append(out, resdto.ExportMessageRes{
+				MessageRes: resdto.MessageRes{
+					ID:          m.ID,
+					SenderID:    userID,
+					Body:        m.Body,
+					Attachments: byMessage[m.ID],
+					CreatedAt:   m.CreatedAt.Time,
+				},
+				RoomID:         m.RoomID.Int64,
+				ConversationID: m.ConversationID.Int64,
+				Kind:           m.Kind,
+			})
+		}
+		if len(page) < exportPageSize {
+			return out, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
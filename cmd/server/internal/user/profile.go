@@ -0,0 +1,150 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Profile field limits, in runes (bytes for the emoji).
+const (
+	MaxDisplayName = 64
+	MaxBio         = 500
+	MaxStatusText  = 100
+	MaxStatusEmoji = 32
+	MaxAvatarURL   = 2048
+)
+
+// GetProfile returns the public profile of a user.
+func (s *Service) GetProfile(ctx context.Context, id int64) (dbstore.GetUserProfileRow, error) {
+	p, err := s.store.GetUserProfile(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dbstore.GetUserProfileRow{}, httpx.New(http.StatusNotFound, "not_found", "user not found", err)
+		}
+		return dbstore.GetUserProfileRow{}, err
+	}
+	return p, nil
+}
+
+// UpdateProfile applies the fields set in req to the profile of userID and
+// returns the result. Text is sanitized so it can't inject escape codes into
+// terminals; an avatar upload must be an image the user uploaded.
+func (s *Service) UpdateProfile(ctx context.Context, userID int64, req reqdto.UpdateProfileReq) (dbstore.GetUserProfileRow, error) {
+	p, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return dbstore.GetUserProfileRow{}, err
+	}
+
+	if req.DisplayName != nil {
+		if p.DisplayName, err = cleanLine(*req.DisplayName, MaxDisplayName); err != nil {
+			return dbstore.GetUserProfileRow{}, httpx.BadRequest("invalid_display_name", "display name is too long", err)
+		}
+	}
+	if req.Bio != nil {
+		p.Bio = strings.TrimSpace(content.Sanitize(*req.Bio))
+		if utf8.RuneCountInString(p.Bio) > MaxBio {
+			return dbstore.GetUserProfileRow{}, httpx.BadRequest("invalid_bio", "bio is too long", content.ErrTooLong)
+		}
+	}
+	if req.StatusText != nil {
+		if p.StatusText, err = cleanLine(*req.StatusText, MaxStatusText); err != nil {
+			return dbstore.GetUserProfileRow{}, httpx.BadRequest("invalid_status", "status text is too long", err)
+		}
+	}
+	if req.StatusEmoji != nil {
+		emoji := strings.TrimSpace(content.Sanitize(*req.StatusEmoji))
+		if len(emoji) > MaxStatusEmoji || strings.ContainsFunc(emoji, unicode.IsSpace) {
+			return dbstore.GetUserProfileRow{}, httpx.BadRequest("invalid_status", "status emoji must be a single emoji or :shortcode:", nil)
+		}
+		p.StatusEmoji = emoji
+	}
+
+	if req.AvatarAttachmentID != nil && req.AvatarURL != nil && *req.AvatarAttachmentID != 0 && *req.AvatarURL != "" {
+		return dbstore.GetUserProfileRow{}, httpx.BadRequest("invalid_avatar", "set either avatar_attachment_id or avatar_url, not both", nil)
+	}
+	if req.AvatarAttachmentID != nil {
+		p.AvatarAttachmentID = pgtype.Int8{}
+		if id := *req.AvatarAttachmentID; id != 0 {
+			if err := s.checkAvatarUpload(ctx, userID, id); err != nil {
+				return dbstore.GetUserProfileRow{}, err
+			}
+			p.AvatarAttachmentID = pgtype.Int8{Int64: id, Valid: true}
+			p.AvatarUrl = ""
+		}
+	}
+	if req.AvatarURL != nil {
+		p.AvatarUrl = strings.TrimSpace(*req.AvatarURL)
+		if p.AvatarUrl != "" {
+			if err := checkAvatarURL(p.AvatarUrl); err != nil {
+				return dbstore.GetUserProfileRow{}, err
+			}
+			p.AvatarAttachmentID = pgtype.Int8{}
+		}
+	}
+
+	saved, err := s.store.UpsertUserProfile(ctx, dbstore.UpsertUserProfileParams{
+		UserID:             userID,
+		DisplayName:        p.DisplayName,
+		Bio:                p.Bio,
+		AvatarAttachmentID: p.AvatarAttachmentID,
+		AvatarUrl:          p.AvatarUrl,
+		StatusText:         p.StatusText,
+		StatusEmoji:        p.StatusEmoji,
+	})
+	if err != nil {
+		return dbstore.GetUserProfileRow{}, err
+	}
+	p.UpdatedAt = saved.UpdatedAt
+
+	s.logger.Info("profile updated", "user_id", userID)
+	return p, nil
+}
+
+// Peers returns the users who should hear about profile changes of userID:
+// everyone sharing a room or a conversation with them, and userID itself.
+func (s *Service) Peers(ctx context.Context, userID int64) ([]int64, error) {
+	return s.store.ListUserPeers(ctx, userID)
+}
+
+func (s *Service) checkAvatarUpload(ctx context.Context, userID, attachmentID int64) error {
+	a, err := s.store.GetAttachment(ctx, attachmentID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && a.UploaderID != userID) {
+		return httpx.BadRequest("invalid_avatar", "avatar must be one of your uploads", err)
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(a.ContentType, "image/") {
+		return httpx.BadRequest("invalid_avatar", "avatar must be an image", nil)
+	}
+	return nil
+}
+
+func checkAvatarURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(raw) > MaxAvatarURL {
+		return httpx.BadRequest("invalid_avatar", "avatar_url must be an http(s) URL", err)
+	}
+	return nil
+}
+
+// cleanLine sanitizes a single-line field, folding newlines and tabs into spaces.
+func cleanLine(s string, maxRunes int) (string, error) {
+	s = strings.Join(strings.Fields(content.Sanitize(s)), " ")
+	if utf8.RuneCountInString(s) > maxRunes {
+		return "", content.ErrTooLong
+	}
+	return s, nil
+}
@@ -0,0 +1,180 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	reqdto "github.com/sleklere/realtime-chat/cmd/server/internal/api/dto/request"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeStore keeps profiles and uploads in memory. Methods the tests don't
+// reach fall through to the nil embedded Store and panic.
+type fakeStore struct {
+	Store
+	profiles    map[int64]dbstore.GetUserProfileRow
+	attachments map[int64]dbstore.Attachment
+	searches    []dbstore.SearchUsersParams
+	blocks      map[[2]int64]bool // {blocker, blocked}
+	dmPolicies  map[int64]string
+	messages    map[int64]bool    // messages SaveMessage accepts
+	saved       map[[2]int64]bool // {user, message}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		profiles: map[int64]dbstore.GetUserProfileRow{
+			1: {ID: 1, Username: "alice"},
+			2: {ID: 2, Username: "bob"},
+		},
+		attachments: map[int64]dbstore.Attachment{
+			10: {ID: 10, UploaderID: 1, ContentType: "image/png"},
+			11: {ID: 11, UploaderID: 2, ContentType: "image/png"},
+			12: {ID: 12, UploaderID: 1, ContentType: "application/pdf"},
+		},
+		blocks:     make(map[[2]int64]bool),
+		dmPolicies: make(map[int64]string),
+		messages:   map[int64]bool{100: true},
+		saved:      make(map[[2]int64]bool),
+	}
+}
+
+func (f *fakeStore) GetUserProfile(_ context.Context, id int64) (dbstore.GetUserProfileRow, error) {
+	p, ok := f.profiles[id]
+	if !ok {
+		return p, pgx.ErrNoRows
+	}
+	return p, nil
+}
+
+func (f *fakeStore) UpsertUserProfile(_ context.Context, arg dbstore.UpsertUserProfileParams) (dbstore.UserProfile, error) {
+	p := f.profiles[arg.UserID]
+	p.DisplayName, p.Bio, p.StatusText, p.StatusEmoji = arg.DisplayName, arg.Bio, arg.StatusText, arg.StatusEmoji
+	p.AvatarAttachmentID, p.AvatarUrl = arg.AvatarAttachmentID, arg.AvatarUrl
+	f.profiles[arg.UserID] = p
+	return dbstore.UserProfile{UserID: arg.UserID}, nil
+}
+
+func (f *fakeStore) GetAttachment(_ context.Context, id int64) (dbstore.Attachment, error) {
+	a, ok := f.attachments[id]
+	if !ok {
+		return a, pgx.ErrNoRows
+	}
+	return a, nil
+}
+
+func newProfileService() (*Service, *fakeStore) {
+	store := newFakeStore()
+	return NewService(store, slog.New(slog.NewTextHandler(io.Discard, nil))), store
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func errCode(err error) string {
+	var httpErr *httpx.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return ""
+}
+
+func TestUpdateProfile_PartialAndSanitized(t *testing.T) {
+	svc, store := newProfileService()
+	ctx := context.Background()
+
+	if _, err := svc.UpdateProfile(ctx, 1, reqdto.UpdateProfileReq{
+		DisplayName: ptr("  Alice \x1b[31mLiddell\n"),
+		Bio:         ptr("line one\nline two"),
+		StatusEmoji: ptr("☕"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := svc.UpdateProfile(ctx, 1, reqdto.UpdateProfileReq{StatusText: ptr("brewing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.DisplayName != "Alice Liddell" {
+		t.Errorf("display name = %q", p.DisplayName)
+	}
+	if p.Bio != "line one\nline two" || p.StatusEmoji != "☕" || p.StatusText != "brewing" {
+		t.Errorf("fields not kept across updates: %+v", p)
+	}
+	if store.profiles[1] != p {
+		t.Errorf("stored %+v, returned %+v", store.profiles[1], p)
+	}
+}
+
+func TestUpdateProfile_Limits(t *testing.T) {
+	svc, _ := newProfileService()
+	cases := []struct {
+		name string
+		req  reqdto.UpdateProfileReq
+		code string
+	}{
+		{"display name", reqdto.UpdateProfileReq{DisplayName: ptr(strings.Repeat("a", MaxDisplayName+1))}, "invalid_display_name"},
+		{"bio", reqdto.UpdateProfileReq{Bio: ptr(strings.Repeat("é", MaxBio+1))}, "invalid_bio"},
+		{"status", reqdto.UpdateProfileReq{StatusText: ptr(strings.Repeat("a", MaxStatusText+1))}, "invalid_status"},
+		{"emoji with spaces", reqdto.UpdateProfileReq{StatusEmoji: ptr("not an emoji")}, "invalid_status"},
+	}
+	for _, tc := range cases {
+		if _, err := svc.UpdateProfile(context.Background(), 1, tc.req); errCode(err) != tc.code {
+			t.Errorf("%s: err = %v, want %s", tc.name, err, tc.code)
+		}
+	}
+}
+
+func TestUpdateProfile_Avatar(t *testing.T) {
+	svc, _ := newProfileService()
+	ctx := context.Background()
+
+	for name, req := range map[string]reqdto.UpdateProfileReq{
+		"someone else's upload": {AvatarAttachmentID: ptr(int64(11))},
+		"missing upload":        {AvatarAttachmentID: ptr(int64(99))},
+		"not an image":          {AvatarAttachmentID: ptr(int64(12))},
+		"javascript url":        {AvatarURL: ptr("javascript:alert(1)")},
+		"both":                  {AvatarAttachmentID: ptr(int64(10)), AvatarURL: ptr("https://example.com/a.png")},
+	} {
+		if _, err := svc.UpdateProfile(ctx, 1, req); errCode(err) != "invalid_avatar" {
+			t.Errorf("%s: err = %v, want invalid_avatar", name, err)
+		}
+	}
+
+	p, err := svc.UpdateProfile(ctx, 1, reqdto.UpdateProfileReq{AvatarURL: ptr("https://example.com/a.png")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AvatarUrl != "https://example.com/a.png" || p.AvatarAttachmentID.Valid {
+		t.Fatalf("avatar = %q / %v", p.AvatarUrl, p.AvatarAttachmentID)
+	}
+
+	// an upload replaces the URL
+	p, err = svc.UpdateProfile(ctx, 1, reqdto.UpdateProfileReq{AvatarAttachmentID: ptr(int64(10))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AvatarUrl != "" || p.AvatarAttachmentID.Int64 != 10 {
+		t.Fatalf("avatar = %q / %v", p.AvatarUrl, p.AvatarAttachmentID)
+	}
+
+	p, err = svc.UpdateProfile(ctx, 1, reqdto.UpdateProfileReq{AvatarAttachmentID: ptr(int64(0))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AvatarAttachmentID.Valid {
+		t.Fatalf("avatar not cleared: %v", p.AvatarAttachmentID)
+	}
+}
+
+func TestGetProfile_NotFound(t *testing.T) {
+	svc, _ := newProfileService()
+	if _, err := svc.GetProfile(context.Background(), 42); errCode(err) != "not_found" {
+		t.Fatalf("err = %v, want not_found", err)
+	}
+}
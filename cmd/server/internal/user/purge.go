@@ -0,0 +1,97 @@
+package user
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+)
+
+// PurgeStore defines the persistence methods the Purger needs.
+type PurgeStore interface {
+	ListUsersDueForDeletion(ctx context.Context, limit int32) ([]int64, error)
+	DeleteUnlinkedAttachmentsByUploader(ctx context.Context, uploaderID int64) ([]string, error)
+	PurgeUserData(ctx context.Context, userID int64) error
+	AnonymizeUser(ctx context.Context, id int64) error
+}
+
+// PurgerConfig tunes how often deleted accounts are looked for.
+type PurgerConfig struct {
+	Interval  time.Duration
+	BatchSize int32
+}
+
+// DefaultPurgerConfig returns the settings used in production.
+func DefaultPurgerConfig() PurgerConfig {
+	return PurgerConfig{Interval: 10 * time.Minute, BatchSize: 50}
+}
+
+// Purger deletes accounts whose grace period is over. The users row stays
+// as a "deleted user" placeholder so the messages and conversations of the
+// account survive; everything else about the user is removed.
+type Purger struct {
+	store  PurgeStore
+	blobs  attachment.BlobDeleter
+	cfg    PurgerConfig
+	logger *slog.Logger
+}
+
+// NewPurger creates a Purger. blobs may be nil to leave upload contents in place.
+func NewPurger(s PurgeStore, blobs attachment.BlobDeleter, cfg PurgerConfig, l *slog.Logger) *Purger {
+	return &Purger{store: s, blobs: blobs, cfg: cfg, logger: l}
+}
+
+// Run purges due accounts until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := p.PurgeDue(ctx)
+			if err != nil {
+				p.logger.Warn("account purge failed", "error", err)
+			}
+			if err != nil || n < int(p.cfg.BatchSize) {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDue purges one batch of accounts whose deletion is due and returns
+// how many it handled.
+func (p *Purger) PurgeDue(ctx context.Context) (int, error) {
+	ids, err := p.store.ListUsersDueForDeletion(ctx, p.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := p.purge(ctx, id); err != nil {
+			return 0, err
+		}
+		p.logger.Info("account deleted", "user_id", id)
+	}
+	return len(ids), nil
+}
+
+// purge removes the data of one account. AnonymizeUser goes last and clears
+// delete_after, so an account that fails halfway is picked up again.
+func (p *Purger) purge(ctx context.Context, userID int64) error {
+	keys, err := p.store.DeleteUnlinkedAttachmentsByUploader(ctx, userID)
+	if err != nil {
+		return err
+	}
+	attachment.DeleteBlobs(ctx, p.blobs, keys, p.logger.With("user_id", userID))
+	if err := p.store.PurgeUserData(ctx, userID); err != nil {
+		return err
+	}
+	return p.store.AnonymizeUser(ctx, userID)
+}
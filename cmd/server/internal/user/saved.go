@@ -0,0 +1,35 @@
+package user
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// SaveMessage adds a message to the user's saved list. Any room message can
+// be saved, and DMs of the user's own conversations. Saving twice is not an
+// error.
+func (s *Service) SaveMessage(ctx context.Context, userID, messageID int64) error {
+	n, err := s.store.SaveMessage(ctx, dbstore.SaveMessageParams{UserID: userID, MessageID: messageID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "message not found", nil)
+	}
+	return nil
+}
+
+// UnsaveMessage removes a message from the user's saved list. Removing one
+// that isn't saved is not an error.
+func (s *Service) UnsaveMessage(ctx context.Context, userID, messageID int64) error {
+	_, err := s.store.UnsaveMessage(ctx, dbstore.UnsaveMessageParams{UserID: userID, MessageID: messageID})
+	return err
+}
+
+// ListSaved returns the user's saved messages, most recently saved first.
+func (s *Service) ListSaved(ctx context.Context, userID int64, limit int32) ([]dbstore.ListSavedMessagesRow, error) {
+	return s.store.ListSavedMessages(ctx, dbstore.ListSavedMessagesParams{UserID: userID, Lim: limit})
+}
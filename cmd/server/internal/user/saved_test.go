@@ -0,0 +1,53 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) SaveMessage(_ context.Context, arg dbstore.SaveMessageParams) (int64, error) {
+	if !f.messages[arg.MessageID] {
+		return 0, nil
+	}
+	f.saved[[2]int64{arg.UserID, arg.MessageID}] = true
+	return 1, nil
+}
+
+func (f *fakeStore) UnsaveMessage(_ context.Context, arg dbstore.UnsaveMessageParams) (int64, error) {
+	key := [2]int64{arg.UserID, arg.MessageID}
+	if !f.saved[key] {
+		return 0, nil
+	}
+	delete(f.saved, key)
+	return 1, nil
+}
+
+func TestSaveMessage(t *testing.T) {
+	svc, store := newProfileService()
+	ctx := context.Background()
+
+	if err := svc.SaveMessage(ctx, 1, 42); errCode(err) != "not_found" {
+		t.Errorf("saving a message the user can't see: err = %v", err)
+	}
+	for range 2 {
+		if err := svc.SaveMessage(ctx, 1, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(store.saved) != 1 || !store.saved[[2]int64{1, 100}] {
+		t.Fatalf("saved = %v", store.saved)
+	}
+
+	if err := svc.UnsaveMessage(ctx, 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	// unsaving again is not an error
+	if err := svc.UnsaveMessage(ctx, 1, 100); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.saved) != 0 {
+		t.Fatalf("saved = %v", store.saved)
+	}
+}
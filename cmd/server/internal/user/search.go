@@ -0,0 +1,35 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// MaxSearchQuery caps the length of a user search, in runes.
+const MaxSearchQuery = 64
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search finds users whose username or display name starts with query or
+// resembles it, best matches first. The viewer is left out of the results.
+func (s *Service) Search(ctx context.Context, viewerID int64, query string, limit, offset int32) ([]dbstore.SearchUsersRow, error) {
+	query = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return nil, httpx.BadRequest("missing_query", "q query param is required", nil)
+	}
+	if utf8.RuneCountInString(query) > MaxSearchQuery {
+		return nil, httpx.BadRequest("invalid_query", "search query is too long", nil)
+	}
+
+	return s.store.SearchUsers(ctx, dbstore.SearchUsersParams{
+		UserID:       viewerID,
+		Prefix:       likeEscaper.Replace(strings.ToLower(query)) + "%",
+		Query:        query,
+		ResultLimit:  limit,
+		ResultOffset: offset,
+	})
+}
@@ -0,0 +1,46 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) SearchUsers(_ context.Context, arg dbstore.SearchUsersParams) ([]dbstore.SearchUsersRow, error) {
+	f.searches = append(f.searches, arg)
+	return nil, nil
+}
+
+func TestSearch_Params(t *testing.T) {
+	svc, store := newProfileService()
+
+	if _, err := svc.Search(context.Background(), 1, "  @Al_i%ce\\ ", 21, 40); err != nil {
+		t.Fatal(err)
+	}
+	got := store.searches[0]
+	want := dbstore.SearchUsersParams{
+		UserID:       1,
+		Prefix:       `al\_i\%ce\\%`,
+		Query:        `Al_i%ce\`,
+		ResultLimit:  21,
+		ResultOffset: 40,
+	}
+	if got != want {
+		t.Fatalf("params = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearch_InvalidQuery(t *testing.T) {
+	svc, store := newProfileService()
+
+	for _, q := range []string{"", "  ", "@", strings.Repeat("a", MaxSearchQuery+1)} {
+		if _, err := svc.Search(context.Background(), 1, q, 20, 0); errCode(err) == "" {
+			t.Errorf("Search(%q) err = %v, want a 400", q, err)
+		}
+	}
+	if len(store.searches) != 0 {
+		t.Fatalf("store was queried for invalid input: %+v", store.searches)
+	}
+}
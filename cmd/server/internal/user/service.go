@@ -14,6 +14,25 @@ import (
 
 type Store interface {
 	GetUserByUsername(ctx context.Context, username string) (dbstore.User, error)
+	GetUserProfile(ctx context.Context, id int64) (dbstore.GetUserProfileRow, error)
+	UpsertUserProfile(ctx context.Context, arg dbstore.UpsertUserProfileParams) (dbstore.UserProfile, error)
+	ListUserPeers(ctx context.Context, userID int64) ([]int64, error)
+	SearchUsers(ctx context.Context, arg dbstore.SearchUsersParams) ([]dbstore.SearchUsersRow, error)
+	BlockUser(ctx context.Context, arg dbstore.BlockUserParams) error
+	UnblockUser(ctx context.Context, arg dbstore.UnblockUserParams) error
+	ListBlockedUsers(ctx context.Context, blockerID int64) ([]dbstore.ListBlockedUsersRow, error)
+	GetDMPolicy(ctx context.Context, userID int64) (string, error)
+	SetDMPolicy(ctx context.Context, arg dbstore.SetDMPolicyParams) error
+	GetAttachment(ctx context.Context, id int64) (dbstore.Attachment, error)
+	SaveMessage(ctx context.Context, arg dbstore.SaveMessageParams) (int64, error)
+	UnsaveMessage(ctx context.Context, arg dbstore.UnsaveMessageParams) (int64, error)
+	ListSavedMessages(ctx context.Context, arg dbstore.ListSavedMessagesParams) ([]dbstore.ListSavedMessagesRow, error)
+
+	GetAccount(ctx context.Context, id int64) (dbstore.GetAccountRow, error)
+	ListMessagesBySender(ctx context.Context, arg dbstore.ListMessagesBySenderParams) ([]dbstore.ListMessagesBySenderRow, error)
+	ListAttachmentsByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.Attachment, error)
+	ListRoomMembershipsByUser(ctx context.Context, userID int64) ([]dbstore.ListRoomMembershipsByUserRow, error)
+	ListConversationsByUser(ctx context.Context, arg dbstore.ListConversationsByUserParams) ([]dbstore.ListConversationsByUserRow, error)
 }
 
 type Service struct {
@@ -33,6 +52,9 @@ func (s *Service) GetByUsername(ctx context.Context, username string) (dbstore.U
 		}
 		return dbstore.User{}, err
 	}
+	if user.DeactivatedAt.Valid {
+		return dbstore.User{}, httpx.New(http.StatusNotFound, "not_found", "user not found", nil)
+	}
 
 	return user, nil
 }
@@ -0,0 +1,4 @@
+// Package webhook delivers room events to external HTTP endpoints. Events are
+// queued in Postgres, signed with the subscription secret and retried with
+// exponential backoff by a background Worker.
+package webhook
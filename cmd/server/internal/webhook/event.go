@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Event types a webhook can subscribe to.
+const (
+	EventMessageCreated = "message.created"
+	// EventMessageEdited is accepted in subscriptions ahead of message edits
+	// landing on the server; nothing emits it yet.
+	EventMessageEdited = "message.edited"
+	EventMemberJoined  = "member.joined"
+)
+
+// ValidEvent reports whether t is a known event type.
+func ValidEvent(t string) bool {
+	switch t {
+	case EventMessageCreated, EventMessageEdited, EventMemberJoined:
+		return true
+	}
+	return false
+}
+
+// Event is the JSON body POSTed to subscribers.
+type Event struct {
+	Type       string          `json:"type"`
+	RoomID     int64           `json:"room_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// MemberJoinedData is the Data of a member.joined event.
+type MemberJoinedData struct {
+	RoomID   int64  `json:"room_id"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the X-Webhook-Signature value for body sent at unix time ts:
+// "sha256=" followed by the hex HMAC-SHA256 of "<ts>.<body>" keyed with secret.
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
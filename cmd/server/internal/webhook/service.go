@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/httpx"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Store defines the persistence methods required for managing and publishing webhooks.
+type Store interface {
+	CreateWebhook(ctx context.Context, arg dbstore.CreateWebhookParams) (dbstore.Webhook, error)
+	GetWebhook(ctx context.Context, arg dbstore.GetWebhookParams) (dbstore.Webhook, error)
+	ListWebhooksByRoom(ctx context.Context, roomID int64) ([]dbstore.Webhook, error)
+	ListWebhooksForEvent(ctx context.Context, arg dbstore.ListWebhooksForEventParams) ([]dbstore.Webhook, error)
+	DeleteWebhook(ctx context.Context, arg dbstore.DeleteWebhookParams) (int64, error)
+	CreateWebhookDelivery(ctx context.Context, arg dbstore.CreateWebhookDeliveryParams) error
+	ListWebhookDeliveries(ctx context.Context, arg dbstore.ListWebhookDeliveriesParams) ([]dbstore.WebhookDelivery, error)
+	GetRoomMemberRole(ctx context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error)
+}
+
+const minSecretLen = 16
+
+// Service manages webhook subscriptions and enqueues events for delivery.
+type Service struct {
+	store        Store
+	worker       *Worker
+	allowPrivate bool
+	logger       *slog.Logger
+}
+
+// NewService creates a new webhook Service. worker is woken up whenever
+// deliveries are enqueued and may be nil; its AllowPrivate setting also
+// decides whether URLs with an internal IP address can be registered.
+func NewService(s Store, w *Worker, l *slog.Logger) *Service {
+	return &Service{store: s, worker: w, allowPrivate: w != nil && w.cfg.AllowPrivate, logger: l}
+}
+
+// Create subscribes rawURL to events in a room. If secret is empty one is
+// generated; either way it's returned on the webhook so the caller can show it once.
+func (s *Service) Create(ctx context.Context, roomID, actorID int64, rawURL, secret string, events []string) (dbstore.Webhook, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return dbstore.Webhook{}, err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return dbstore.Webhook{}, httpx.BadRequest("invalid_url", "url must be an absolute http(s) URL", err)
+	}
+	// names are checked again when the worker connects, after resolving
+	if !s.allowPrivate && internalHost(u.Hostname()) {
+		return dbstore.Webhook{}, httpx.BadRequest("invalid_url", "url must not point to a loopback or private address", nil)
+	}
+
+	events = dedupe(events)
+	if len(events) == 0 {
+		return dbstore.Webhook{}, httpx.BadRequest("missing_events", "at least one event type is required", nil)
+	}
+	for _, e := range events {
+		if !ValidEvent(e) {
+			return dbstore.Webhook{}, httpx.BadRequest("invalid_event", "unknown event type: "+e, nil)
+		}
+	}
+
+	if secret == "" {
+		if secret, err = newSecret(); err != nil {
+			return dbstore.Webhook{}, err
+		}
+	} else if len(secret) < minSecretLen {
+		return dbstore.Webhook{}, httpx.BadRequest("weak_secret", "secret must be at least 16 characters", nil)
+	}
+
+	return s.store.CreateWebhook(ctx, dbstore.CreateWebhookParams{
+		RoomID:     roomID,
+		Url:        u.String(),
+		Secret:     secret,
+		EventTypes: events,
+		CreatedBy:  actorID,
+	})
+}
+
+// List returns the webhooks of a room.
+func (s *Service) List(ctx context.Context, roomID, actorID int64) ([]dbstore.Webhook, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	return s.store.ListWebhooksByRoom(ctx, roomID)
+}
+
+// Delete removes a webhook and its delivery log.
+func (s *Service) Delete(ctx context.Context, roomID, actorID, webhookID int64) error {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return err
+	}
+	n, err := s.store.DeleteWebhook(ctx, dbstore.DeleteWebhookParams{ID: webhookID, RoomID: roomID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return httpx.New(http.StatusNotFound, "not_found", "webhook not found", nil)
+	}
+	return nil
+}
+
+// Deliveries returns the most recent deliveries of a webhook, newest first.
+func (s *Service) Deliveries(ctx context.Context, roomID, actorID, webhookID int64, limit int32) ([]dbstore.WebhookDelivery, error) {
+	if err := s.requireModerator(ctx, roomID, actorID); err != nil {
+		return nil, err
+	}
+	if _, err := s.store.GetWebhook(ctx, dbstore.GetWebhookParams{ID: webhookID, RoomID: roomID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, httpx.New(http.StatusNotFound, "not_found", "webhook not found", err)
+		}
+		return nil, err
+	}
+	return s.store.ListWebhookDeliveries(ctx, dbstore.ListWebhookDeliveriesParams{WebhookID: webhookID, Limit: limit})
+}
+
+// PublishRoomEvent enqueues a delivery for every webhook of the room subscribed
+// to eventType. Failures are logged, never returned: webhooks must not break chat.
+func (s *Service) PublishRoomEvent(ctx context.Context, roomID int64, eventType string, data any) {
+	hooks, err := s.store.ListWebhooksForEvent(ctx, dbstore.ListWebhooksForEventParams{RoomID: roomID, EventType: eventType})
+	if err != nil {
+		s.logger.Warn("failed to list webhooks", "room_id", roomID, "event", eventType, "error", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		s.logger.Warn("failed to marshal webhook data", "event", eventType, "error", err)
+		return
+	}
+	body, err := json.Marshal(Event{Type: eventType, RoomID: roomID, OccurredAt: time.Now().UTC(), Data: raw})
+	if err != nil {
+		s.logger.Warn("failed to marshal webhook event", "event", eventType, "error", err)
+		return
+	}
+
+	for _, h := range hooks {
+		err := s.store.CreateWebhookDelivery(ctx, dbstore.CreateWebhookDeliveryParams{
+			WebhookID: h.ID,
+			EventType: eventType,
+			Payload:   body,
+		})
+		if err != nil {
+			s.logger.Warn("failed to enqueue webhook delivery", "webhook_id", h.ID, "event", eventType, "error", err)
+		}
+	}
+	if s.worker != nil {
+		s.worker.Notify()
+	}
+}
+
+func (s *Service) requireModerator(ctx context.Context, roomID, userID int64) error {
+	role, err := s.store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return httpx.New(http.StatusForbidden, "not_member", "not a member of this room", err)
+		}
+		return err
+	}
+	if !room.IsModerator(role) {
+		return httpx.New(http.StatusForbidden, "forbidden", "moderator role required", nil)
+	}
+	return nil
+}
+
+// internalHost reports whether host is localhost or an IP address that isn't public.
+func internalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !publicAddr(addr)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func dedupe(events []string) []string {
+	seen := make(map[string]bool, len(events))
+	out := events[:0:0]
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
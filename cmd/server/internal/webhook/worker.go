@@ -0,0 +1,263 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Delivery statuses stored in webhook_deliveries.status.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// WorkerStore defines the persistence methods the delivery Worker needs.
+type WorkerStore interface {
+	ClaimWebhookDeliveries(ctx context.Context, arg dbstore.ClaimWebhookDeliveriesParams) ([]dbstore.ClaimWebhookDeliveriesRow, error)
+	MarkWebhookDelivered(ctx context.Context, arg dbstore.MarkWebhookDeliveredParams) error
+	MarkWebhookAttemptFailed(ctx context.Context, arg dbstore.MarkWebhookAttemptFailedParams) error
+}
+
+// WorkerConfig tunes delivery polling and retries.
+type WorkerConfig struct {
+	// PollInterval is how often the queue is checked when nothing wakes the worker.
+	PollInterval time.Duration
+	// BatchSize is the number of deliveries claimed per poll.
+	BatchSize int32
+	// Timeout bounds a single HTTP attempt.
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is marked failed.
+	MaxAttempts int32
+	// BaseBackoff is the delay after the first failure; it doubles every attempt up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// AllowPrivate lets webhooks reach loopback, private and link-local
+	// addresses, e.g. a receiver on the same host. Off by default so room
+	// moderators can't make the server call internal services.
+	AllowPrivate bool
+}
+
+// DefaultWorkerConfig returns the settings used in production.
+func DefaultWorkerConfig() WorkerConfig {
+	return WorkerConfig{
+		PollInterval: 5 * time.Second,
+		BatchSize:    20,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+	}
+}
+
+const maxErrorLen = 500
+
+// Worker POSTs queued deliveries to their webhook URLs.
+type Worker struct {
+	store  WorkerStore
+	client *http.Client
+	cfg    WorkerConfig
+	logger *slog.Logger
+	wake   chan struct{}
+	now    func() time.Time
+}
+
+// errPrivateAddress is returned when a webhook URL points to a loopback,
+// private or link-local address and WorkerConfig.AllowPrivate is off.
+var errPrivateAddress = errors.New("webhook receiver address is not public")
+
+// NewWorker creates a Worker. client may be nil to use one with cfg.Timeout
+// that doesn't follow redirects and, unless cfg.AllowPrivate is set, only
+// connects to public addresses.
+func NewWorker(s WorkerStore, client *http.Client, cfg WorkerConfig, l *slog.Logger) *Worker {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: cfg.Timeout}
+		if !cfg.AllowPrivate {
+			// checked on the resolved address, so a public name that
+			// resolves to an internal one is refused too
+			dialer.Control = func(_, address string, _ syscall.RawConn) error {
+				ap, err := netip.ParseAddrPort(address)
+				if err != nil || !publicAddr(ap.Addr()) {
+					return errPrivateAddress
+				}
+				return nil
+			}
+		}
+		client = &http.Client{
+			Timeout: cfg.Timeout,
+			// no proxy: the receiver is dialed directly so its address is the one checked
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return &Worker{
+		store:  s,
+		client: client,
+		cfg:    cfg,
+		logger: l,
+		wake:   make(chan struct{}, 1),
+		now:    time.Now,
+	}
+}
+
+// sharedAddressSpace is 100.64.0.0/10, used by carrier-grade NAT and some
+// cloud networks but not covered by netip.Addr.IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether addr is a public unicast address, i.e. not
+// loopback, private, link-local, multicast or unspecified.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Notify wakes the worker so new deliveries go out without waiting for the next poll.
+func (w *Worker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers queued events until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := w.deliverDue(ctx)
+			if err != nil {
+				w.logger.Warn("webhook delivery poll failed", "error", err)
+			}
+			// a full batch means there may be more waiting
+			if err != nil || n < int(w.cfg.BatchSize) {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// deliverDue claims one batch of due deliveries and attempts each of them.
+func (w *Worker) deliverDue(ctx context.Context) (int, error) {
+	// the batch is sent one delivery after another, so the lease must outlive
+	// every attempt in it or another worker could claim and send the last
+	// ones a second time
+	lease := time.Duration(w.cfg.BatchSize)*w.cfg.Timeout + 30*time.Second
+	rows, err := w.store.ClaimWebhookDeliveries(ctx, dbstore.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: int32(lease / time.Second),
+		BatchSize:    w.cfg.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range rows {
+		w.attempt(ctx, d)
+	}
+	return len(rows), nil
+}
+
+func (w *Worker) attempt(ctx context.Context, d dbstore.ClaimWebhookDeliveriesRow) {
+	status, err := w.post(ctx, d)
+	if err == nil {
+		if err := w.store.MarkWebhookDelivered(ctx, dbstore.MarkWebhookDeliveredParams{
+			ID:             d.ID,
+			LastStatusCode: pgtype.Int4{Int32: int32(status), Valid: true},
+		}); err != nil {
+			w.logger.Warn("failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+		}
+		return
+	}
+
+	next := StatusPending
+	if d.Attempts >= w.cfg.MaxAttempts {
+		next = StatusFailed
+	}
+	w.logger.Info("webhook delivery failed", "delivery_id", d.ID, "webhook_id", d.WebhookID,
+		"attempt", d.Attempts, "status", next, "error", err)
+
+	msg := err.Error()
+	if len(msg) > maxErrorLen {
+		msg = msg[:maxErrorLen]
+	}
+	if err := w.store.MarkWebhookAttemptFailed(ctx, dbstore.MarkWebhookAttemptFailedParams{
+		ID:             d.ID,
+		Status:         next,
+		NextAttemptAt:  pgtype.Timestamptz{Time: w.now().Add(w.backoff(d.Attempts)), Valid: true},
+		LastStatusCode: pgtype.Int4{Int32: int32(status), Valid: status != 0},
+		LastError:      pgtype.Text{String: msg, Valid: true},
+	}); err != nil {
+		w.logger.Warn("failed to record webhook failure", "delivery_id", d.ID, "error", err)
+	}
+}
+
+// post sends one signed attempt. It returns the response status (0 if none)
+// and an error unless the receiver answered 2xx.
+func (w *Worker) post(ctx context.Context, d dbstore.ClaimWebhookDeliveriesRow) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := w.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "realtime-chat-webhooks/1")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, ts, d.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	// drain a little so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after attempt failures.
+func (w *Worker) backoff(attempt int32) time.Duration {
+	d := w.cfg.BaseBackoff
+	for i := int32(1); i < attempt && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, w.cfg.MaxBackoff)
+}
@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// fakeQueue is an in-memory webhook_deliveries table driven by a fake clock.
+type fakeQueue struct {
+	mu         sync.Mutex
+	now        time.Time
+	url        string
+	secret     string
+	deliveries map[int64]*dbstore.WebhookDelivery
+}
+
+func newFakeQueue(url string, payloads ...string) *fakeQueue {
+	q := &fakeQueue{
+		now:        time.Unix(1_700_000_000, 0),
+		url:        url,
+		secret:     "0123456789abcdef",
+		deliveries: make(map[int64]*dbstore.WebhookDelivery),
+	}
+	for i, p := range payloads {
+		id := int64(i + 1)
+		q.deliveries[id] = &dbstore.WebhookDelivery{ID: id, WebhookID: 1, EventType: EventMessageCreated, Payload: []byte(p), Status: StatusPending}
+		q.deliveries[id].NextAttemptAt.Time = q.now
+	}
+	return q
+}
+
+func (q *fakeQueue) clock() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.now
+}
+
+func (q *fakeQueue) advance(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.now = q.now.Add(d)
+}
+
+func (q *fakeQueue) ClaimWebhookDeliveries(_ context.Context, arg dbstore.ClaimWebhookDeliveriesParams) ([]dbstore.ClaimWebhookDeliveriesRow, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var rows []dbstore.ClaimWebhookDeliveriesRow
+	for id := int64(1); id <= int64(len(q.deliveries)) && len(rows) < int(arg.BatchSize); id++ {
+		d := q.deliveries[id]
+		if d.Status != StatusPending || d.NextAttemptAt.Time.After(q.now) {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt.Time = q.now.Add(time.Duration(arg.LeaseSeconds) * time.Second)
+		rows = append(rows, dbstore.ClaimWebhookDeliveriesRow{
+			ID: d.ID, WebhookID: d.WebhookID, EventType: d.EventType, Payload: d.Payload,
+			Attempts: d.Attempts, Url: q.url, Secret: q.secret,
+		})
+	}
+	return rows, nil
+}
+
+func (q *fakeQueue) MarkWebhookDelivered(_ context.Context, arg dbstore.MarkWebhookDeliveredParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d := q.deliveries[arg.ID]
+	d.Status = StatusDelivered
+	d.LastStatusCode = arg.LastStatusCode
+	return nil
+}
+
+func (q *fakeQueue) MarkWebhookAttemptFailed(_ context.Context, arg dbstore.MarkWebhookAttemptFailedParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d := q.deliveries[arg.ID]
+	d.Status = arg.Status
+	d.NextAttemptAt = arg.NextAttemptAt
+	d.LastStatusCode = arg.LastStatusCode
+	d.LastError = arg.LastError
+	return nil
+}
+
+func (q *fakeQueue) get(id int64) dbstore.WebhookDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.deliveries[id]
+}
+
+func newTestWorker(q *fakeQueue, cfg WorkerConfig) *Worker {
+	// test receivers listen on loopback
+	cfg.AllowPrivate = true
+	w := NewWorker(q, nil, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.now = q.clock
+	return w
+}
+
+func TestWorker_DeliversSignedEvent(t *testing.T) {
+	const body = `{"type":"message.created","room_id":10}`
+
+	var got atomic.Int32
+	var q *fakeQueue
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("bad timestamp header: %v", err)
+		}
+		if want := Sign(q.secret, ts, payload); r.Header.Get(HeaderSignature) != want {
+			t.Errorf("signature mismatch: got %q want %q", r.Header.Get(HeaderSignature), want)
+		}
+		if r.Header.Get(HeaderEvent) != EventMessageCreated || r.Header.Get(HeaderDelivery) != "1" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if string(payload) != body {
+			t.Errorf("unexpected body %q", payload)
+		}
+		got.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	q = newFakeQueue(srv.URL, body)
+	w := newTestWorker(q, DefaultWorkerConfig())
+
+	n, err := w.deliverDue(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("deliverDue = %d, %v; want 1, nil", n, err)
+	}
+	if got.Load() != 1 {
+		t.Fatalf("receiver called %d times, want 1", got.Load())
+	}
+	if d := q.get(1); d.Status != StatusDelivered || d.LastStatusCode.Int32 != http.StatusNoContent {
+		t.Fatalf("unexpected delivery state: %+v", d)
+	}
+}
+
+func TestWorker_RetriesWithBackoffThenFails(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultWorkerConfig()
+	cfg.MaxAttempts = 3
+	q := newFakeQueue(srv.URL, `{}`)
+	w := newTestWorker(q, cfg)
+	ctx := context.Background()
+
+	if _, err := w.deliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d := q.get(1)
+	if d.Status != StatusPending || d.LastStatusCode.Int32 != http.StatusInternalServerError {
+		t.Fatalf("after first failure: %+v", d)
+	}
+	if wait := d.NextAttemptAt.Time.Sub(q.clock()); wait != cfg.BaseBackoff {
+		t.Fatalf("first retry in %v, want %v", wait, cfg.BaseBackoff)
+	}
+
+	// not due yet: nothing is sent
+	if n, _ := w.deliverDue(ctx); n != 0 {
+		t.Fatalf("claimed %d deliveries before backoff elapsed", n)
+	}
+
+	q.advance(cfg.BaseBackoff)
+	_, _ = w.deliverDue(ctx)
+	if wait := q.get(1).NextAttemptAt.Time.Sub(q.clock()); wait != 2*cfg.BaseBackoff {
+		t.Fatalf("second retry in %v, want %v", wait, 2*cfg.BaseBackoff)
+	}
+
+	q.advance(2 * cfg.BaseBackoff)
+	_, _ = w.deliverDue(ctx)
+	if d := q.get(1); d.Status != StatusFailed || d.Attempts != 3 {
+		t.Fatalf("expected failed after 3 attempts, got %+v", d)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("receiver called %d times, want 3", calls.Load())
+	}
+}
+
+func TestWorker_SlowBatchNotClaimedTwice(t *testing.T) {
+	cfg := DefaultWorkerConfig()
+	payloads := make([]string, cfg.BatchSize)
+	for i := range payloads {
+		payloads[i] = `{}`
+	}
+
+	var (
+		mu      sync.Mutex
+		seen    = map[string]int{}
+		q       *fakeQueue
+		other   *Worker
+		inOther atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get(HeaderDelivery)]++
+		mu.Unlock()
+		// each receipt takes almost the whole timeout
+		q.advance(cfg.Timeout - time.Second)
+		// meanwhile a second worker polls the queue
+		if inOther.CompareAndSwap(false, true) {
+			if _, err := other.deliverDue(context.Background()); err != nil {
+				t.Error(err)
+			}
+			inOther.Store(false)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	q = newFakeQueue(srv.URL, payloads...)
+	w := newTestWorker(q, cfg)
+	other = newTestWorker(q, cfg)
+	if n, err := w.deliverDue(context.Background()); err != nil || n != len(payloads) {
+		t.Fatalf("deliverDue = %d, %v", n, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != len(payloads) {
+		t.Fatalf("expected %d deliveries, got %v", len(payloads), seen)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("delivery %s arrived %d times", id, n)
+		}
+	}
+}
+
+func TestWorker_UnreachableReceiver(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	q := newFakeQueue(url, `{}`)
+	w := newTestWorker(q, DefaultWorkerConfig())
+	if _, err := w.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d := q.get(1)
+	if d.Status != StatusPending || d.LastStatusCode.Valid || d.LastError.String == "" {
+		t.Fatalf("expected pending with an error and no status code, got %+v", d)
+	}
+}
+
+func TestWorker_DoesNotFollowRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	q := newFakeQueue(srv.URL, `{}`)
+	w := newTestWorker(q, DefaultWorkerConfig())
+	_, _ = w.deliverDue(context.Background())
+	if d := q.get(1); d.Status != StatusPending || d.LastStatusCode.Int32 != http.StatusFound {
+		t.Fatalf("expected redirect to count as a failure, got %+v", d)
+	}
+}
+
+func TestWorker_RefusesPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	q := newFakeQueue(srv.URL, `{}`)
+	w := NewWorker(q, nil, DefaultWorkerConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.now = q.clock
+	if _, err := w.deliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d := q.get(1)
+	if calls.Load() != 0 || d.Status != StatusPending || !strings.Contains(d.LastError.String, errPrivateAddress.Error()) {
+		t.Fatalf("expected the loopback receiver to be refused, got %d calls and %+v", calls.Load(), d)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestBackoff_Caps(t *testing.T) {
+	w := NewWorker(nil, nil, WorkerConfig{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}, nil)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, d := range want {
+		if got := w.backoff(int32(i + 1)); got != d {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, d)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ws
+
+import "testing"
+
+// Test 40 – BroadcastToAll reaches every connected client, in a room or not
+func TestHub_BroadcastToAll(t *testing.T) {
+	h := startHub(t)
+	a := newTestClient(h, 1, map[int64]bool{10: true})
+	b := newTestClient(h, 2, map[int64]bool{})
+	registerAll(t, h, a, b)
+
+	h.BroadcastToAll(Message{Type: TypeAnnouncement})
+
+	for _, c := range []*Client{a, b} {
+		if got := expectMessage(t, c.send); got.Type != TypeAnnouncement {
+			t.Fatalf("client %d: expected %s, got %s", c.userID, TypeAnnouncement, got.Type)
+		}
+	}
+}
+
+// Test 41 – CloseRoom delivers the notice, then the room is forgotten so
+// later room broadcasts reach nobody
+func TestHub_CloseRoom(t *testing.T) {
+	h := startHub(t)
+	member := newTestClient(h, 1, map[int64]bool{10: true, 20: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, member, sync)
+
+	h.CloseRoom(10, Message{Type: TypeRoomDeleted})
+	if got := expectMessage(t, member.send); got.Type != TypeRoomDeleted {
+		t.Fatalf("expected %s, got %s", TypeRoomDeleted, got.Type)
+	}
+
+	h.BroadcastToRoom(10, Message{Type: TypeRoomMessage})
+	syncHub(t, h, sync)
+	expectNoMessage(t, member.send)
+
+	h.BroadcastToRoom(20, Message{Type: TypeRoomMessage})
+	expectMessage(t, member.send)
+
+	if st := h.Stats(); st.RoomsOnline[10] != 0 {
+		t.Fatalf("expected room 10 gone from stats, got %d online", st.RoomsOnline[10])
+	}
+}
+
+// Test 42 – Stats counts connections, bots and online members per room
+func TestHub_Stats(t *testing.T) {
+	h := startHub(t)
+	a := newTestClient(h, 1, map[int64]bool{10: true, 20: true})
+	b := newTestClient(h, 2, map[int64]bool{10: true})
+	bot := newTestClient(h, 3, map[int64]bool{10: true})
+	bot.isBot = true
+	registerAll(t, h, a, b, bot)
+
+	st := h.Stats()
+	if st.Connections != 3 || st.Bots != 1 {
+		t.Fatalf("expected 3 connections and 1 bot, got %d and %d", st.Connections, st.Bots)
+	}
+	if st.RoomsOnline[10] != 3 || st.RoomsOnline[20] != 1 {
+		t.Fatalf("unexpected rooms online: %v", st.RoomsOnline)
+	}
+
+	h.unregister <- b
+	st = h.Stats()
+	if st.Connections != 2 || st.RoomsOnline[10] != 2 {
+		t.Fatalf("expected 2 connections and 2 in room 10 after unregister, got %d and %v", st.Connections, st.RoomsOnline)
+	}
+}
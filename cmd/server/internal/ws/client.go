@@ -3,24 +3,65 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/coder/websocket"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/poll"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ratelimit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 )
 
+// Store defines the persistence methods a Client needs to handle incoming frames.
+type Store interface {
+	CreateMessage(ctx context.Context, arg dbstore.CreateMessageParams) (dbstore.Message, error)
+	CreateDirectMessage(ctx context.Context, arg dbstore.CreateDirectMessageParams) (dbstore.Message, error)
+	CanDirectMessage(ctx context.Context, arg dbstore.CanDirectMessageParams) (bool, error)
+	GetRoomByID(ctx context.Context, id int64) (dbstore.Room, error)
+	GetRoomMemberRole(ctx context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error)
+	LinkAttachmentsToMessage(ctx context.Context, arg dbstore.LinkAttachmentsToMessageParams) ([]dbstore.Attachment, error)
+
+	// used by polls
+	CreatePollMessage(ctx context.Context, arg dbstore.CreatePollMessageParams) (dbstore.Message, error)
+	GetPoll(ctx context.Context, messageID int64) (dbstore.Poll, error)
+	SetPollVotes(ctx context.Context, arg dbstore.SetPollVotesParams) error
+	ListPollVotesByMessageIDs(ctx context.Context, messageIds []int64) ([]dbstore.ListPollVotesByMessageIDsRow, error)
+
+	// used by forwards and quotes
+	GetVisibleMessage(ctx context.Context, arg dbstore.GetVisibleMessageParams) (dbstore.GetVisibleMessageRow, error)
+
+	// used by slash commands
+	GetUserByUsername(ctx context.Context, username string) (dbstore.User, error)
+	HasBlockBetween(ctx context.Context, arg dbstore.HasBlockBetweenParams) (bool, error)
+	JoinRoom(ctx context.Context, arg dbstore.JoinRoomParams) error
+	LeaveRoom(ctx context.Context, arg dbstore.LeaveRoomParams) error
+	SetRoomTopic(ctx context.Context, arg dbstore.SetRoomTopicParams) (dbstore.Room, error)
+	CreateAuditEvent(ctx context.Context, arg dbstore.CreateAuditEventParams) error
+}
+
+// maxAttachmentsPerMessage caps AttachmentIDs on a single message.
+const maxAttachmentsPerMessage = 10
+
 // NewClient creates a new Client ready to be registered with the Hub.
-func NewClient(hub *Hub, conn *websocket.Conn, queries *dbstore.Queries, userID int64, username string, roomIDs map[int64]bool, logger *slog.Logger) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, queries Store, sender Sender, roomIDs map[int64]bool, logger *slog.Logger) *Client {
+	conn.SetReadLimit(hub.limiter.limits.MaxFrameBytes)
 	return &Client{
 		hub:      hub,
 		conn:     conn,
 		queries:  queries,
-		userID:   userID,
-		username: username,
-		roomIDs:  roomIDs,
+		userID:   sender.ID,
+		username: sender.Username,
+		isBot:    sender.IsBot,
+		session:  sender.SessionID,
+		roomIDs:  newRoomSet(roomIDs),
 		logger:   logger,
 		send:     make(chan Message, 256),
+		buckets:  hub.limiter.connBuckets(),
+		strikes:  ratelimit.NewBucket(hub.limiter.limits.Strikes),
 	}
 }
 
@@ -44,6 +85,12 @@ func (c *Client) ReadPump(ctx context.Context) {
 			c.logger.Warn("error while unmarshalling ws msg data")
 			continue
 		}
+		if ok, wait := c.allowFrame(msg.Type, time.Now()); !ok {
+			c.logger.Info("ws frame rate limited", "type", msg.Type, "user_id", c.userID)
+			c.sendRetryError(ErrCodeRateLimited, "too many messages, slow down", wait)
+			c.strike()
+			continue
+		}
 		// 3. switch msg.Type
 		switch msg.Type {
 		case TypeRoomMessage:
@@ -52,6 +99,10 @@ func (c *Client) ReadPump(ctx context.Context) {
 			c.dispatchDirectMessage(msg, ctx)
 		case TypeJoinRoom, TypeLeaveRoom:
 			c.dispatchUserRoomUpdate(msg, ctx)
+		case TypeRegisterCommand:
+			c.registerBotCommand(msg)
+		case TypePollVote:
+			c.dispatchPollVote(msg, ctx)
 		}
 	}
 }
@@ -89,37 +140,51 @@ func (c *Client) dispatchRoomMessage(msg Message, ctx context.Context) {
 		c.logger.Warn("error while unmarshalling room msg payload")
 		return
 	}
-	//    - validar (roomID > 0, content no vacío, que el client sea miembro del room)
-	_, clientInRoom := c.roomIDs[roomMsgPayload.RoomID]
-	if roomMsgPayload.RoomID == 0 || roomMsgPayload.Content == "" ||
-		!clientInRoom {
-		c.logger.Warn("failed TypeRoomMessage validation", "room_id", roomMsgPayload.RoomID)
+
+	roomMsgPayload.Kind = "" // only commands and polls produce other kinds
+	if name, args, escaped, ok := parseCommand(roomMsgPayload.Content); ok && roomMsgPayload.Poll == nil {
+		c.runCommand(ctx, roomMsgPayload.RoomID, name, args, msg.Timestamp)
 		return
+	} else if escaped != "" {
+		roomMsgPayload.Content = escaped
 	}
-	//    - persist to DB and broadcast
-	roomMsgPayload.SenderID = c.userID
-	roomMsgPayload.SenderUsername = c.username
 
-	dbMsg, err := c.queries.CreateMessage(ctx, dbstore.CreateMessageParams{
-		RoomID:   pgtype.Int8{Int64: roomMsgPayload.RoomID, Valid: true},
-		SenderID: c.userID,
-		Body:     roomMsgPayload.Content,
-	})
+	err = c.postRoomMessage(ctx, &roomMsgPayload, msg.Timestamp)
 	if err != nil {
-		c.logger.Warn("failed to persist room message", "error", err)
-	} else {
-		roomMsgPayload.MessageID = dbMsg.ID
+		c.handlePostError(roomMsgPayload.RoomID, err)
 	}
+}
 
-	completePayload, err := json.Marshal(roomMsgPayload)
-	if err != nil {
-		c.logger.Warn("error while marshalling complete msg payload")
-		return
+// handlePostError reports why a room message was rejected to the sender.
+func (c *Client) handlePostError(roomID int64, err error) {
+	var retry *RetryError
+	switch {
+	case errors.Is(err, ErrInvalidMessage):
+		c.logger.Warn("failed TypeRoomMessage validation", "room_id", roomID)
+	case errors.Is(err, ErrTooManyAttachments):
+		c.sendError(ErrCodeAttachments, err.Error())
+	case errors.Is(err, ErrInvalidTTL):
+		c.sendError(ErrCodeInvalidTTL, err.Error())
+	case errors.Is(err, poll.ErrInvalid):
+		c.sendError(ErrCodeInvalidPoll, err.Error())
+	case errors.Is(err, ErrCannotForward):
+		c.sendError(ErrCodeCannotForward, err.Error())
+	case errors.Is(err, ErrCannotQuote):
+		c.sendError(ErrCodeCannotQuote, err.Error())
+	case errors.Is(err, content.ErrTooLong):
+		c.sendError(ErrCodeTooLong, err.Error())
+	case errors.As(err, &retry):
+		c.logger.Info("room message rejected", "room_id", roomID, "user_id", c.userID, "error", err)
+		c.sendRetryError(retry.Code, err.Error(), retry.Wait)
+		c.strike()
+	case errors.Is(err, room.ErrRoomArchived):
+		c.sendError(ErrCodeRoomArchived, err.Error())
+	case errors.Is(err, room.ErrRoomReadOnly):
+		c.sendError(ErrCodeRoomReadOnly, err.Error())
+	default:
+		c.logger.Info("room message rejected", "room_id", roomID, "user_id", c.userID, "error", err)
+		c.sendError(ErrCodeInternal, "could not send message")
 	}
-	msgWithCompletePayload := Message{Type: msg.Type, Payload: completePayload, Timestamp: msg.Timestamp}
-
-	broadcastMsg := BroadcastMsg{msg: msgWithCompletePayload, targetRoomID: roomMsgPayload.RoomID}
-	c.hub.broadcast <- broadcastMsg
 }
 
 func (c *Client) dispatchDirectMessage(msg Message, ctx context.Context) {
@@ -130,34 +195,37 @@ func (c *Client) dispatchDirectMessage(msg Message, ctx context.Context) {
 		return
 	}
 
-	if directMsgPayload.ToUserID == 0 || directMsgPayload.Content == "" {
-		c.logger.Warn("failed TypeRoomMessage validation", "room_id", directMsgPayload.ConversationID)
-		return
-	}
-
-	directMsgPayload.SenderID = c.userID
-	directMsgPayload.SenderUsername = c.username
-
-	dbMsg, err := c.queries.CreateDirectMessage(ctx, dbstore.CreateDirectMessageParams{
-		SenderID: c.userID,
-		ToUserID: directMsgPayload.ToUserID,
-		Body:     directMsgPayload.Content,
-	})
+	err = c.postDirectMessage(ctx, &directMsgPayload, msg.Timestamp)
 	if err != nil {
-		c.logger.Warn("failed to persist dm message", "error", err)
-	} else {
-		directMsgPayload.MessageID = dbMsg.ID
+		c.handleDirectError(directMsgPayload.ToUserID, err)
 	}
+}
 
-	completePayload, err := json.Marshal(directMsgPayload)
-	if err != nil {
-		c.logger.Warn("error while marshalling complete msg payload")
-		return
+// handleDirectError reports why a direct message was rejected to the sender.
+func (c *Client) handleDirectError(toUserID int64, err error) {
+	var retry *RetryError
+	switch {
+	case errors.Is(err, ErrInvalidMessage):
+		c.logger.Warn("failed TypeDirectMessage validation", "to_user_id", toUserID)
+	case errors.Is(err, ErrTooManyAttachments):
+		c.sendError(ErrCodeAttachments, err.Error())
+	case errors.Is(err, content.ErrTooLong):
+		c.sendError(ErrCodeTooLong, err.Error())
+	case errors.Is(err, ErrInvalidTTL):
+		c.sendError(ErrCodeInvalidTTL, err.Error())
+	case errors.Is(err, ErrDMNotAllowed):
+		c.sendError(ErrCodeDMNotAllowed, err.Error())
+	case errors.Is(err, ErrCannotForward):
+		c.sendError(ErrCodeCannotForward, err.Error())
+	case errors.Is(err, ErrCannotQuote):
+		c.sendError(ErrCodeCannotQuote, err.Error())
+	case errors.As(err, &retry):
+		c.sendRetryError(retry.Code, err.Error(), retry.Wait)
+		c.strike()
+	default:
+		c.logger.Warn("direct message rejected", "to_user_id", toUserID, "user_id", c.userID, "error", err)
+		c.sendError(ErrCodeInternal, "could not send message")
 	}
-	msgWithCompletePayload := Message{Type: msg.Type, Payload: completePayload, Timestamp: msg.Timestamp}
-
-	broadcastMsg := BroadcastMsg{msg: msgWithCompletePayload, targetUserIDs: []int64{c.userID, directMsgPayload.ToUserID}}
-	c.hub.broadcast <- broadcastMsg
 }
 
 func (c *Client) dispatchUserRoomUpdate(msg Message, ctx context.Context) {
@@ -176,3 +244,110 @@ func (c *Client) dispatchUserRoomUpdate(msg Message, ctx context.Context) {
 
 	c.hub.updateUserPresenceInRoom(UserRoomPresent{userID: c.userID, roomID: roomPresencePayload.RoomID, present: msg.Type == TypeJoinRoom})
 }
+
+// validateContent sanitizes and length-checks a message body. An empty body
+// is fine when the message carries attachments.
+func (c *Client) validateContent(raw string, attachmentIDs []int64) (string, error) {
+	if len(attachmentIDs) > maxAttachmentsPerMessage {
+		return "", ErrTooManyAttachments
+	}
+	body, err := content.Validate(raw, c.hub.limiter.limits.MaxMessageRunes)
+	if errors.Is(err, content.ErrEmpty) && len(attachmentIDs) > 0 {
+		return "", nil
+	}
+	return body, err
+}
+
+// linkAttachments attaches the sender's pending uploads to messageID. IDs the
+// sender doesn't own or that are already linked are silently dropped.
+func (c *Client) linkAttachments(ctx context.Context, messageID int64, ids []int64) []AttachmentInfo {
+	if len(ids) == 0 {
+		return nil
+	}
+	atts, err := c.queries.LinkAttachmentsToMessage(ctx, dbstore.LinkAttachmentsToMessageParams{
+		MessageID:  pgtype.Int8{Int64: messageID, Valid: true},
+		Ids:        ids,
+		UploaderID: c.userID,
+	})
+	if err != nil {
+		c.logger.Warn("failed to link attachments", "message_id", messageID, "error", err)
+		return nil
+	}
+	if len(atts) < len(ids) {
+		c.logger.Info("dropped attachments not owned by sender", "message_id", messageID, "user_id", c.userID)
+	}
+
+	res := make([]AttachmentInfo, len(atts))
+	for i, a := range atts {
+		res[i] = AttachmentInfo{ID: a.ID, Filename: a.Filename, ContentType: a.ContentType, SizeBytes: a.SizeBytes}
+	}
+	return res
+}
+
+// checkCanPost loads the room mode and slow mode and, when either needs it,
+// the sender's role. Moderators are exempt from slow mode.
+func (c *Client) checkCanPost(ctx context.Context, roomID int64) error {
+	r, err := c.queries.GetRoomByID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	var role string
+	if r.Mode == room.ModeAnnouncement || r.SlowModeSeconds > 0 {
+		role, err = c.queries.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: c.userID})
+		if err != nil {
+			return err
+		}
+	}
+	if err := room.CanPost(r.Mode, role); err != nil {
+		return err
+	}
+
+	if r.SlowModeSeconds > 0 && !room.IsModerator(role) {
+		interval := time.Duration(r.SlowModeSeconds) * time.Second
+		if ok, wait := c.hub.limiter.allowSlowMode(roomID, c.userID, interval, time.Now()); !ok {
+			return &RetryError{Code: ErrCodeSlowMode, Wait: wait}
+		}
+	}
+	return nil
+}
+
+// allowFrame applies the per-connection and per-user limits for msgType.
+func (c *Client) allowFrame(msgType string, now time.Time) (bool, time.Duration) {
+	if b, ok := c.buckets[msgType]; ok {
+		if ok, wait := b.Allow(now); !ok {
+			return false, wait
+		}
+	}
+	return c.hub.limiter.allowUser(c.userID, msgType, now)
+}
+
+// strike records a rejected frame and closes the connection once the client
+// has burned through its strike budget.
+func (c *Client) strike() {
+	if c.strikes == nil {
+		return
+	}
+	if ok, _ := c.strikes.Allow(time.Now()); ok {
+		return
+	}
+	c.logger.Warn("closing ws connection for repeated rate limit violations", "user_id", c.userID)
+	if c.conn != nil {
+		_ = c.conn.Close(websocket.StatusPolicyViolation, "rate limit exceeded")
+	}
+}
+
+// sendError sends a TypeError frame back to this client only.
+func (c *Client) sendError(code, message string) {
+	c.sendRetryError(code, message, 0)
+}
+
+// sendRetryError sends a TypeError frame carrying a retry-after hint.
+func (c *Client) sendRetryError(code, message string, retryAfter time.Duration) {
+	msg, err := NewMessage(TypeError, ErrorPayload{Code: code, Message: message, RetryAfterMs: retryAfter.Milliseconds()})
+	if err != nil {
+		c.logger.Warn("error while marshalling error payload")
+		return
+	}
+	c.hub.broadcast <- BroadcastMsg{msg: msg, targetUserIDs: []int64{c.userID}}
+}
@@ -3,7 +3,19 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ratelimit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
 )
 
 // ---------------------------------------------------------------------------
@@ -64,3 +76,483 @@ func TestDispatchRoomMessage_MalformedPayload(t *testing.T) {
 
 	expectNoMessage(t, c.send)
 }
+
+// ---------------------------------------------------------------------------
+// dispatchRoomMessage room modes — fake store, no DB
+// ---------------------------------------------------------------------------
+
+// fakeStore is an in-memory Store used to test dispatch paths that hit the DB.
+type fakeStore struct {
+	rooms  map[int64]dbstore.Room
+	roles  map[int64]string // userID → role, for every room
+	msgs   []dbstore.CreateMessageParams
+	dms    []dbstore.CreateDirectMessageParams
+	atts   map[int64]dbstore.Attachment // pending uploads by id
+	users  map[string]dbstore.User
+	noDMs  map[[2]int64]bool // {recipient, sender} pairs refused by blocks or DM policy
+	blocks map[[2]int64]bool // {blocker, blocked} pairs
+	audit  []dbstore.CreateAuditEventParams
+	polls  map[int64]dbstore.Poll
+	votes  map[[2]int64][]int32                   // {message, user} → options
+	seen   map[int64]dbstore.GetVisibleMessageRow // messages every user can see
+}
+
+func (f *fakeStore) CreateMessage(_ context.Context, arg dbstore.CreateMessageParams) (dbstore.Message, error) {
+	f.msgs = append(f.msgs, arg)
+	return dbstore.Message{ID: int64(len(f.msgs)), RoomID: arg.RoomID, SenderID: arg.SenderID, Body: arg.Body, ExpiresAt: arg.ExpiresAt}, nil
+}
+
+func (f *fakeStore) CreateDirectMessage(_ context.Context, arg dbstore.CreateDirectMessageParams) (dbstore.Message, error) {
+	f.dms = append(f.dms, arg)
+	m := dbstore.Message{ID: int64(len(f.dms)), ConversationID: pgtype.Int8{Int64: 7, Valid: true}, SenderID: arg.SenderID, Body: arg.Body}
+	if arg.TtlSeconds > 0 {
+		m.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(time.Duration(arg.TtlSeconds) * time.Second), Valid: true}
+	}
+	return m, nil
+}
+
+func (f *fakeStore) GetRoomByID(_ context.Context, id int64) (dbstore.Room, error) {
+	r, ok := f.rooms[id]
+	if !ok {
+		return dbstore.Room{}, pgx.ErrNoRows
+	}
+	return r, nil
+}
+
+func (f *fakeStore) GetRoomMemberRole(_ context.Context, arg dbstore.GetRoomMemberRoleParams) (string, error) {
+	role, ok := f.roles[arg.UserID]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return role, nil
+}
+
+func (f *fakeStore) LinkAttachmentsToMessage(_ context.Context, arg dbstore.LinkAttachmentsToMessageParams) ([]dbstore.Attachment, error) {
+	var res []dbstore.Attachment
+	for _, id := range arg.Ids {
+		a, ok := f.atts[id]
+		if !ok || a.UploaderID != arg.UploaderID || a.MessageID.Valid {
+			continue
+		}
+		a.MessageID = arg.MessageID
+		f.atts[id] = a
+		res = append(res, a)
+	}
+	return res, nil
+}
+
+func (f *fakeStore) GetUserByUsername(_ context.Context, username string) (dbstore.User, error) {
+	u, ok := f.users[username]
+	if !ok {
+		return dbstore.User{}, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeStore) HasBlockBetween(_ context.Context, arg dbstore.HasBlockBetweenParams) (bool, error) {
+	return f.blocks[[2]int64{arg.UserA, arg.UserB}] || f.blocks[[2]int64{arg.UserB, arg.UserA}], nil
+}
+
+func (f *fakeStore) JoinRoom(_ context.Context, arg dbstore.JoinRoomParams) error {
+	if _, ok := f.roles[arg.UserID]; !ok {
+		f.roles[arg.UserID] = room.RoleMember
+	}
+	return nil
+}
+
+func (f *fakeStore) LeaveRoom(_ context.Context, arg dbstore.LeaveRoomParams) error {
+	delete(f.roles, arg.UserID)
+	return nil
+}
+
+func (f *fakeStore) SetRoomTopic(_ context.Context, arg dbstore.SetRoomTopicParams) (dbstore.Room, error) {
+	r := f.rooms[arg.ID]
+	r.Topic = arg.Topic
+	f.rooms[arg.ID] = r
+	return r, nil
+}
+
+func (f *fakeStore) CreateAuditEvent(_ context.Context, arg dbstore.CreateAuditEventParams) error {
+	f.audit = append(f.audit, arg)
+	return nil
+}
+
+func (f *fakeStore) CreatePollMessage(_ context.Context, arg dbstore.CreatePollMessageParams) (dbstore.Message, error) {
+	f.msgs = append(f.msgs, dbstore.CreateMessageParams{RoomID: arg.RoomID, SenderID: arg.SenderID, Body: arg.Body, Kind: MessageKindPoll, ExpiresAt: arg.ExpiresAt})
+	id := int64(len(f.msgs))
+	if f.polls == nil {
+		f.polls = make(map[int64]dbstore.Poll)
+	}
+	f.polls[id] = dbstore.Poll{
+		MessageID: id,
+		RoomID:    arg.RoomID.Int64,
+		Options:   arg.Options,
+		Multiple:  arg.Multiple,
+		Anonymous: arg.Anonymous,
+		ClosesAt:  arg.ClosesAt,
+	}
+	return dbstore.Message{ID: id, RoomID: arg.RoomID, SenderID: arg.SenderID, Body: arg.Body, Kind: MessageKindPoll, ExpiresAt: arg.ExpiresAt}, nil
+}
+
+func (f *fakeStore) GetPoll(_ context.Context, messageID int64) (dbstore.Poll, error) {
+	p, ok := f.polls[messageID]
+	if !ok {
+		return dbstore.Poll{}, pgx.ErrNoRows
+	}
+	return p, nil
+}
+
+func (f *fakeStore) SetPollVotes(_ context.Context, arg dbstore.SetPollVotesParams) error {
+	if f.votes == nil {
+		f.votes = make(map[[2]int64][]int32)
+	}
+	f.votes[[2]int64{arg.MessageID, arg.UserID}] = arg.Options
+	return nil
+}
+
+func (f *fakeStore) ListPollVotesByMessageIDs(_ context.Context, messageIds []int64) ([]dbstore.ListPollVotesByMessageIDsRow, error) {
+	var res []dbstore.ListPollVotesByMessageIDsRow
+	for k, options := range f.votes {
+		if !slices.Contains(messageIds, k[0]) {
+			continue
+		}
+		for _, o := range options {
+			res = append(res, dbstore.ListPollVotesByMessageIDsRow{MessageID: k[0], OptionIndex: o, UserID: k[1], Username: fmt.Sprintf("user%d", k[1])})
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeStore) GetVisibleMessage(_ context.Context, arg dbstore.GetVisibleMessageParams) (dbstore.GetVisibleMessageRow, error) {
+	m, ok := f.seen[arg.ID]
+	if !ok {
+		return dbstore.GetVisibleMessageRow{}, pgx.ErrNoRows
+	}
+	return m, nil
+}
+
+func newFakeStore(mode string, roles map[int64]string) *fakeStore {
+	return &fakeStore{
+		rooms: map[int64]dbstore.Room{10: {ID: 10, Mode: mode}},
+		roles: roles,
+	}
+}
+
+func expectErrorCode(t *testing.T, ch <-chan Message, code string) {
+	t.Helper()
+	got := expectMessage(t, ch)
+	if got.Type != TypeError {
+		t.Fatalf("expected %s frame, got %s", TypeError, got.Type)
+	}
+	var p ErrorPayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal error payload: %v", err)
+	}
+	if p.Code != code {
+		t.Fatalf("expected error code %s, got %s", code, p.Code)
+	}
+}
+
+// Test 17 – archived room: sender gets room_archived, nothing persisted
+func TestDispatchRoomMessage_ArchivedRoom(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeArchived, map[int64]string{1: room.RoleOwner})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, c.send, ErrCodeRoomArchived)
+	expectNoMessage(t, other.send)
+	if len(store.msgs) != 0 {
+		t.Fatalf("expected no persisted messages, got %d", len(store.msgs))
+	}
+}
+
+// Test 18 – announcement room: plain member gets room_read_only
+func TestDispatchRoomMessage_AnnouncementMember(t *testing.T) {
+	h := startHub(t)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = newFakeStore(room.ModeAnnouncement, map[int64]string{1: room.RoleMember})
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, c.send, ErrCodeRoomReadOnly)
+	expectNoMessage(t, other.send)
+}
+
+// Test 19 – announcement room: moderator message is broadcast
+func TestDispatchRoomMessage_AnnouncementModerator(t *testing.T) {
+	h := startHub(t)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = newFakeStore(room.ModeAnnouncement, map[int64]string{1: room.RoleModerator})
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, other.send)
+	if got.Type != TypeRoomMessage {
+		t.Fatalf("expected %s, got %s", TypeRoomMessage, got.Type)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rate limiting and slow mode
+// ---------------------------------------------------------------------------
+
+// Test 20 – per-connection bucket: burst spent → denied with retry-after
+func TestClient_AllowFrame_PerConnection(t *testing.T) {
+	h := NewHub()
+	c := newTestClient(h, 1, map[int64]bool{})
+	c.buckets = map[string]*ratelimit.Bucket{
+		TypeRoomMessage: ratelimit.NewBucket(ratelimit.Rate{PerSecond: 1, Burst: 2}),
+	}
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := c.allowFrame(TypeRoomMessage, now); !ok {
+			t.Fatalf("frame %d: expected allow", i)
+		}
+	}
+	ok, wait := c.allowFrame(TypeRoomMessage, now)
+	if ok || wait != time.Second {
+		t.Fatalf("expected deny with 1s wait, got ok=%v wait=%s", ok, wait)
+	}
+	// other message types have their own budget
+	if ok, _ := c.allowFrame(TypeJoinRoom, now); !ok {
+		t.Fatal("expected join_room to be allowed")
+	}
+}
+
+// Test 21 – slow mode: second message within the interval gets slow_mode error
+func TestDispatchRoomMessage_SlowMode(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	store.rooms[10] = dbstore.Room{ID: 10, Mode: room.ModeNormal, SlowModeSeconds: 30}
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	if got.Type != TypeRoomMessage {
+		t.Fatalf("expected first message broadcast, got %s", got.Type)
+	}
+	expectErrorCode(t, c.send, ErrCodeSlowMode)
+	if len(store.msgs) != 1 {
+		t.Fatalf("expected 1 persisted message, got %d", len(store.msgs))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Content validation
+// ---------------------------------------------------------------------------
+
+// Test 22 – escape sequences are stripped before broadcast and persistence
+func TestDispatchRoomMessage_SanitizesContent(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "\x1b[2J\x1b[31mhi\x1b[0m"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p.Content != "hi" || store.msgs[0].Body != "hi" {
+		t.Fatalf("expected sanitized content %q, got broadcast=%q stored=%q", "hi", p.Content, store.msgs[0].Body)
+	}
+}
+
+// Test 23 – content over MaxMessageRunes gets message_too_long
+func TestDispatchRoomMessage_TooLong(t *testing.T) {
+	h := startHub(t)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = newFakeStore(room.ModeNormal, nil)
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	long := strings.Repeat("x", h.limiter.limits.MaxMessageRunes+1)
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: long})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, c.send, ErrCodeTooLong)
+}
+
+// ---------------------------------------------------------------------------
+// Attachments
+// ---------------------------------------------------------------------------
+
+// Test 24 – a message with only attachments is accepted and carries their metadata
+func TestDispatchRoomMessage_AttachmentsOnly(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	store.atts = map[int64]dbstore.Attachment{
+		5: {ID: 5, UploaderID: 1, Filename: "cat.png", ContentType: "image/png", SizeBytes: 42},
+		6: {ID: 6, UploaderID: 2, Filename: "not-mine.txt"},
+	}
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, AttachmentIDs: []int64{5, 6}})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(p.Attachments) != 1 || p.Attachments[0].ID != 5 || p.Attachments[0].Filename != "cat.png" {
+		t.Fatalf("expected only the sender's attachment, got %+v", p.Attachments)
+	}
+	if store.atts[6].MessageID.Valid {
+		t.Fatal("another user's upload must not be linked")
+	}
+}
+
+// Test 25 – too many attachment ids are rejected before persisting
+func TestDispatchRoomMessage_TooManyAttachments(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	ids := make([]int64, maxAttachmentsPerMessage+1)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi", AttachmentIDs: ids})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, c.send, ErrCodeAttachments)
+	if len(store.msgs) != 0 {
+		t.Fatalf("expected nothing persisted, got %d messages", len(store.msgs))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Event publishing
+// ---------------------------------------------------------------------------
+
+type recordedEvent struct {
+	roomID    int64
+	eventType string
+	data      any
+}
+
+type fakePublisher struct {
+	events []recordedEvent
+}
+
+func (p *fakePublisher) PublishRoomEvent(_ context.Context, roomID int64, eventType string, data any) {
+	p.events = append(p.events, recordedEvent{roomID: roomID, eventType: eventType, data: data})
+}
+
+// Test 26 – persisted room messages are forwarded as message.created events
+func TestDispatchRoomMessage_PublishesEvent(t *testing.T) {
+	pub := &fakePublisher{}
+	h := NewHub()
+	h.SetEventPublisher(pub)
+	go h.Run()
+
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = newFakeStore(room.ModeNormal, nil)
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "deploy done"})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	if len(pub.events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(pub.events))
+	}
+	ev := pub.events[0]
+	p, ok := ev.data.(RoomMessagePayload)
+	if ev.roomID != 10 || ev.eventType != webhook.EventMessageCreated || !ok || p.Content != "deploy done" || p.MessageID == 0 {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Posting from outside a connection (bots, incoming webhooks)
+// ---------------------------------------------------------------------------
+
+// Test 27 – a bot post is persisted and broadcast flagged as a bot
+func TestHub_PostRoomMessage_Bot(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{7: room.RoleMember})
+	listener := newTestClient(h, 1, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, listener, sync)
+
+	sender := Sender{ID: 7, Username: "ci", IsBot: true}
+	if _, err := h.PostRoomMessage(context.Background(), store, nopLogger, sender, 10, "build passed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, listener.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !p.SenderIsBot || p.SenderID != 7 || p.SenderUsername != "ci" || p.Content != "build passed" {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+	if len(store.msgs) != 1 || store.msgs[0].SenderID != 7 {
+		t.Fatalf("expected message persisted as the bot, got %+v", store.msgs)
+	}
+}
+
+// Test 28 – posting to a room the sender isn't a member of fails without broadcasting
+func TestHub_PostRoomMessage_NotMember(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	listener := newTestClient(h, 1, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, listener, sync)
+
+	_, err := h.PostRoomMessage(context.Background(), store, nopLogger, Sender{ID: 7, IsBot: true}, 10, "hi")
+	if !errors.Is(err, ErrNotMember) {
+		t.Fatalf("expected ErrNotMember, got %v", err)
+	}
+	syncHub(t, h, sync)
+	expectNoMessage(t, listener.send)
+	if len(store.msgs) != 0 {
+		t.Fatal("nothing should be persisted")
+	}
+}
@@ -0,0 +1,245 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// CommandRequest describes one invocation of a slash command.
+type CommandRequest struct {
+	RoomID int64
+	Name   string
+	Args   string // everything after the command name, trimmed
+	Sender Sender
+	Store  Store
+	Logger *slog.Logger
+}
+
+// CommandResult is what a command produces. Broadcast is posted to the room
+// as the sender, going through the same checks and persistence as a typed
+// message; Reply is shown only to the sender. Either may be empty.
+type CommandResult struct {
+	Broadcast *RoomMessagePayload
+	Reply     string
+}
+
+// CommandFunc runs a command.
+type CommandFunc func(ctx context.Context, h *Hub, req CommandRequest) (CommandResult, error)
+
+// Command is a slash command that can be registered with the Hub.
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         CommandFunc
+
+	owner *Client // the bot connection that registered it, nil for built-ins
+}
+
+// CommandError is a command failure whose message is shown to the sender.
+type CommandError struct {
+	Code    string
+	Message string
+}
+
+func (e *CommandError) Error() string { return e.Message }
+
+// ErrCommandTaken is returned when registering a name that is already in use.
+var ErrCommandTaken = errors.New("command name already registered")
+
+var commandNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// CommandRegistry maps command names to commands. It is safe for concurrent use.
+type CommandRegistry struct {
+	mu   sync.RWMutex
+	cmds map[string]Command
+}
+
+// NewCommandRegistry returns an empty registry.
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{cmds: make(map[string]Command)}
+}
+
+// Register adds cmd. Names are lowercase letters, digits, '_' and '-', and
+// can't replace an existing command.
+func (r *CommandRegistry) Register(cmd Command) error {
+	if !commandNameRe.MatchString(cmd.Name) {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Run == nil {
+		return fmt.Errorf("command %q has no Run func", cmd.Name)
+	}
+	if cmd.Usage == "" {
+		cmd.Usage = "/" + cmd.Name
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cmds[cmd.Name]; ok {
+		return ErrCommandTaken
+	}
+	r.cmds[cmd.Name] = cmd
+	return nil
+}
+
+// Lookup returns the command registered under name.
+func (r *CommandRegistry) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.cmds[name]
+	return cmd, ok
+}
+
+// List returns every registered command sorted by name.
+func (r *CommandRegistry) List() []Command {
+	r.mu.RLock()
+	cmds := make([]Command, 0, len(r.cmds))
+	for _, cmd := range r.cmds {
+		cmds = append(cmds, cmd)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// unregisterOwner drops every command registered by the connection c.
+func (r *CommandRegistry) unregisterOwner(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, cmd := range r.cmds {
+		if cmd.owner == c {
+			delete(r.cmds, name)
+		}
+	}
+}
+
+// parseCommand splits "/name args" into its parts. A leading "//" escapes
+// the slash: the message is sent as text with one slash removed.
+func parseCommand(text string) (name, args, escaped string, ok bool) {
+	if rest, found := strings.CutPrefix(text, "//"); found {
+		return "", "", "/" + rest, false
+	}
+	rest, found := strings.CutPrefix(text, "/")
+	if !found || rest == "" || strings.HasPrefix(rest, " ") {
+		return "", "", "", false
+	}
+	name, args, _ = strings.Cut(rest, " ")
+	return strings.ToLower(name), strings.TrimSpace(args), "", true
+}
+
+// runCommand executes a slash command typed in a room and delivers its result.
+func (c *Client) runCommand(ctx context.Context, roomID int64, name, args string, ts time.Time) {
+	if roomID == 0 || !c.roomIDs.has(roomID) {
+		c.logger.Warn("command for a room the client is not in", "room_id", roomID, "command", name)
+		return
+	}
+	cmd, ok := c.hub.commands.Lookup(name)
+	if !ok {
+		c.sendError(ErrCodeUnknownCmd, fmt.Sprintf("unknown command /%s, try /help", name))
+		return
+	}
+
+	res, err := cmd.Run(ctx, c.hub, CommandRequest{
+		RoomID: roomID,
+		Name:   name,
+		Args:   args,
+		Sender: Sender{ID: c.userID, Username: c.username, IsBot: c.isBot},
+		Store:  c.queries,
+		Logger: c.logger,
+	})
+	var cmdErr *CommandError
+	switch {
+	case errors.As(err, &cmdErr):
+		c.sendError(cmdErr.Code, cmdErr.Message)
+		return
+	case err != nil:
+		c.handlePostError(roomID, err)
+		return
+	}
+
+	if res.Reply != "" {
+		c.sendCommandReply(roomID, name, res.Reply)
+	}
+	if res.Broadcast != nil {
+		b := *res.Broadcast
+		b.RoomID = roomID
+		if err := c.postRoomMessage(ctx, &b, ts); err != nil {
+			c.handlePostError(roomID, err)
+		}
+	}
+}
+
+// registerBotCommand handles a register_command frame. Only bots may
+// register commands; they last until the bot's connection closes.
+func (c *Client) registerBotCommand(msg Message) {
+	if !c.isBot {
+		c.sendError(ErrCodeForbidden, "only bots can register commands")
+		return
+	}
+	var p RegisterCommandPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		c.logger.Warn("error while unmarshalling register command payload")
+		return
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(p.Name, "/"))
+	cmd := Command{
+		Name:        name,
+		Usage:       p.Usage,
+		Description: p.Description,
+		Run:         forwardToBot(c.userID, c.username),
+		owner:       c,
+	}
+	if err := c.hub.commands.Register(cmd); err != nil {
+		c.sendError(ErrCodeInvalidCmd, err.Error())
+		return
+	}
+	c.sendCommandReply(0, name, "registered /"+name)
+}
+
+// forwardToBot returns a CommandFunc that hands the invocation to the bot's
+// connection. The bot answers by posting to the room like any member.
+func forwardToBot(botID int64, botName string) CommandFunc {
+	return func(ctx context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+		_, err := req.Store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: req.RoomID, UserID: botID})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return CommandResult{}, &CommandError{Code: ErrCodeInvalidCmd, Message: botName + " is not in this room"}
+			}
+			return CommandResult{}, err
+		}
+		msg, err := NewMessage(TypeCommand, CommandPayload{
+			RoomID:   req.RoomID,
+			Command:  req.Name,
+			Args:     req.Args,
+			UserID:   req.Sender.ID,
+			Username: req.Sender.Username,
+		})
+		if err != nil {
+			return CommandResult{}, err
+		}
+		h.BroadcastToUsers([]int64{botID}, msg)
+		return CommandResult{}, nil
+	}
+}
+
+func (c *Client) sendCommandReply(roomID int64, name, text string) {
+	msg, err := NewMessage(TypeCommandReply, CommandReplyPayload{RoomID: roomID, Command: name, Text: text})
+	if err != nil {
+		c.logger.Warn("error while marshalling command reply")
+		return
+	}
+	c.hub.broadcast <- BroadcastMsg{msg: msg, targetUserIDs: []int64{c.userID}}
+}
@@ -0,0 +1,287 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
+)
+
+const (
+	shrug          = `¯\_(ツ)_/¯`
+	maxTopicRunes  = 250
+	maxDice        = 20
+	maxDieSides    = 1000
+	defaultDieRoll = "1d6"
+)
+
+// builtinCommands returns the commands every Hub starts with.
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "help", Usage: "/help", Description: "list available commands", Run: runHelp},
+		{Name: "me", Usage: "/me <action>", Description: "describe what you are doing", Run: runMe},
+		{Name: "shrug", Usage: "/shrug [message]", Description: "append " + shrug + " to a message", Run: runShrug},
+		{Name: "roll", Usage: "/roll [NdM]", Description: "roll dice, 1d6 by default", Run: runRoll},
+		{Name: "topic", Usage: "/topic [new topic]", Description: "show or (moderators) set the room topic", Run: runTopic},
+		{Name: "invite", Usage: "/invite <username>", Description: "(moderators) add a user to the room", Run: runInvite},
+		{Name: "kick", Usage: "/kick <username>", Description: "(moderators) remove a member from the room", Run: runKick},
+	}
+}
+
+func runHelp(_ context.Context, h *Hub, _ CommandRequest) (CommandResult, error) {
+	var b strings.Builder
+	for i, cmd := range h.commands.List() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s — %s", cmd.Usage, cmd.Description)
+	}
+	return CommandResult{Reply: b.String()}, nil
+}
+
+func runMe(_ context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+	if req.Args == "" {
+		return CommandResult{}, usageErrorFor(h, req.Name)
+	}
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: req.Args, Kind: MessageKindAction}}, nil
+}
+
+func runShrug(_ context.Context, _ *Hub, req CommandRequest) (CommandResult, error) {
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: strings.TrimSpace(req.Args + " " + shrug)}}, nil
+}
+
+func runRoll(_ context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+	spec := req.Args
+	if spec == "" {
+		spec = defaultDieRoll
+	}
+	n, sides, ok := parseDice(spec)
+	if !ok {
+		return CommandResult{}, usageErrorFor(h, req.Name)
+	}
+
+	rolls := make([]string, n)
+	total := 0
+	for i := range rolls {
+		v := rand.IntN(sides) + 1
+		total += v
+		rolls[i] = strconv.Itoa(v)
+	}
+	text := fmt.Sprintf("rolled %dd%d: %s", n, sides, strings.Join(rolls, " + "))
+	if n > 1 {
+		text += fmt.Sprintf(" = %d", total)
+	}
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: text, Kind: MessageKindAction}}, nil
+}
+
+// parseDice parses "NdM" (or "dM", meaning 1dM) within the allowed bounds.
+func parseDice(spec string) (n, sides int, ok bool) {
+	count, faces, found := strings.Cut(strings.ToLower(spec), "d")
+	if !found {
+		return 0, 0, false
+	}
+	n = 1
+	if count != "" {
+		var err error
+		if n, err = strconv.Atoi(count); err != nil {
+			return 0, 0, false
+		}
+	}
+	sides, err := strconv.Atoi(faces)
+	if err != nil || n < 1 || n > maxDice || sides < 2 || sides > maxDieSides {
+		return 0, 0, false
+	}
+	return n, sides, true
+}
+
+func runTopic(ctx context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+	r, err := req.Store.GetRoomByID(ctx, req.RoomID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if req.Args == "" {
+		if r.Topic == "" {
+			return CommandResult{Reply: "no topic set"}, nil
+		}
+		return CommandResult{Reply: "topic: " + r.Topic}, nil
+	}
+
+	if r.Mode == room.ModeArchived {
+		return CommandResult{}, room.ErrRoomArchived
+	}
+	if err := requireModerator(ctx, req); err != nil {
+		return CommandResult{}, err
+	}
+	topic, err := content.Validate(req.Args, maxTopicRunes)
+	if err != nil {
+		return CommandResult{}, &CommandError{Code: ErrCodeInvalidCmd, Message: fmt.Sprintf("topic must be 1-%d characters", maxTopicRunes)}
+	}
+
+	r, err = req.Store.SetRoomTopic(ctx, dbstore.SetRoomTopicParams{ID: req.RoomID, Topic: topic})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	msg, err := NewMessage(TypeRoomUpdated, RoomUpdatedPayload{
+		RoomID:          r.ID,
+		Mode:            r.Mode,
+		SlowModeSeconds: r.SlowModeSeconds,
+		Topic:           r.Topic,
+	})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	h.BroadcastToRoom(r.ID, msg)
+
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: "set the topic to: " + topic, Kind: MessageKindAction}}, nil
+}
+
+func runInvite(ctx context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+	username := strings.TrimPrefix(req.Args, "@")
+	if username == "" || strings.Contains(username, " ") {
+		return CommandResult{}, usageErrorFor(h, req.Name)
+	}
+
+	r, err := req.Store.GetRoomByID(ctx, req.RoomID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if r.Mode == room.ModeArchived {
+		return CommandResult{}, room.ErrRoomArchived
+	}
+	actorRole, err := memberRole(ctx, req.Store, req.RoomID, req.Sender.ID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	// an invite makes the users share a room, which opens DMs to users with
+	// dm_policy 'rooms', so it's kept to moderators
+	if !room.IsModerator(actorRole) {
+		return CommandResult{}, &CommandError{Code: ErrCodeForbidden, Message: "only moderators can invite users"}
+	}
+	target, err := lookupUser(ctx, req.Store, username)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	blocked, err := req.Store.HasBlockBetween(ctx, dbstore.HasBlockBetweenParams{UserA: req.Sender.ID, UserB: target.ID})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if blocked || target.DeactivatedAt.Valid {
+		return CommandResult{}, &CommandError{Code: ErrCodeForbidden, Message: "you can't invite " + target.Username}
+	}
+
+	_, err = req.Store.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: req.RoomID, UserID: target.ID})
+	if err == nil {
+		return CommandResult{Reply: target.Username + " is already in this room"}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return CommandResult{}, err
+	}
+	if err := req.Store.JoinRoom(ctx, dbstore.JoinRoomParams{RoomID: req.RoomID, UserID: target.ID}); err != nil {
+		return CommandResult{}, err
+	}
+
+	h.UpdateUserRoomState(req.RoomID, target.ID, true)
+	h.PublishRoomEvent(ctx, req.RoomID, webhook.EventMemberJoined, webhook.MemberJoinedData{
+		RoomID:   req.RoomID,
+		UserID:   target.ID,
+		Username: target.Username,
+	})
+
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: "invited " + target.Username, Kind: MessageKindAction}}, nil
+}
+
+func runKick(ctx context.Context, h *Hub, req CommandRequest) (CommandResult, error) {
+	username := strings.TrimPrefix(req.Args, "@")
+	if username == "" || strings.Contains(username, " ") {
+		return CommandResult{}, usageErrorFor(h, req.Name)
+	}
+
+	actorRole, err := memberRole(ctx, req.Store, req.RoomID, req.Sender.ID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if !room.IsModerator(actorRole) {
+		return CommandResult{}, &CommandError{Code: ErrCodeForbidden, Message: "only moderators can kick members"}
+	}
+	target, err := lookupUser(ctx, req.Store, username)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if target.ID == req.Sender.ID {
+		return CommandResult{}, &CommandError{Code: ErrCodeInvalidCmd, Message: "you can't kick yourself, leave the room instead"}
+	}
+
+	targetRole, err := memberRole(ctx, req.Store, req.RoomID, target.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CommandResult{Reply: target.Username + " is not in this room"}, nil
+	}
+	if err != nil {
+		return CommandResult{}, err
+	}
+	// moderators can only kick plain members; the owner can kick anyone else
+	if targetRole == room.RoleOwner || (room.IsModerator(targetRole) && actorRole != room.RoleOwner) {
+		return CommandResult{}, &CommandError{Code: ErrCodeForbidden, Message: "you can't kick " + target.Username}
+	}
+
+	if err := req.Store.LeaveRoom(ctx, dbstore.LeaveRoomParams{RoomID: req.RoomID, UserID: target.ID}); err != nil {
+		return CommandResult{}, err
+	}
+	h.UpdateUserRoomState(req.RoomID, target.ID, false)
+	audit.Record(ctx, req.Store, req.Logger, audit.Event{
+		Action:     audit.ActionMemberKicked,
+		ActorID:    req.Sender.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   target.ID,
+		Details:    map[string]any{"room_id": req.RoomID},
+	})
+
+	notice, err := NewMessage(TypeCommandReply, CommandReplyPayload{
+		RoomID:  req.RoomID,
+		Command: req.Name,
+		Text:    "you were removed from the room by " + req.Sender.Username,
+	})
+	if err != nil {
+		return CommandResult{}, err
+	}
+	h.BroadcastToUsers([]int64{target.ID}, notice)
+
+	return CommandResult{Broadcast: &RoomMessagePayload{Content: "kicked " + target.Username, Kind: MessageKindAction}}, nil
+}
+
+func requireModerator(ctx context.Context, req CommandRequest) error {
+	role, err := memberRole(ctx, req.Store, req.RoomID, req.Sender.ID)
+	if err != nil {
+		return err
+	}
+	if !room.IsModerator(role) {
+		return &CommandError{Code: ErrCodeForbidden, Message: "/" + req.Name + " requires the moderator role"}
+	}
+	return nil
+}
+
+func memberRole(ctx context.Context, s Store, roomID, userID int64) (string, error) {
+	return s.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: userID})
+}
+
+func lookupUser(ctx context.Context, s Store, username string) (dbstore.User, error) {
+	u, err := s.GetUserByUsername(ctx, username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return u, &CommandError{Code: ErrCodeInvalidCmd, Message: "no user named " + username}
+	}
+	return u, err
+}
+
+// usageErrorFor returns a CommandError showing the usage of the named command.
+func usageErrorFor(h *Hub, name string) error {
+	cmd, _ := h.commands.Lookup(name)
+	return &CommandError{Code: ErrCodeInvalidCmd, Message: "usage: " + cmd.Usage}
+}
@@ -0,0 +1,271 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func sendRoomText(c *Client, roomID int64, text string) {
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: roomID, Content: text})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+}
+
+// Test 29 – /me is broadcast and persisted as an action message
+func TestCommand_Me(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	sendRoomText(c, 10, "/me waves")
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p.Kind != MessageKindAction || p.Content != "waves" {
+		t.Fatalf("expected action 'waves', got %+v", p)
+	}
+	if len(store.msgs) != 1 || store.msgs[0].Kind != MessageKindAction || store.msgs[0].Body != "waves" {
+		t.Fatalf("expected persisted action, got %+v", store.msgs)
+	}
+}
+
+// Test 30 – unknown commands get an error and nothing is persisted
+func TestCommand_Unknown(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	sendRoomText(c, 10, "/nope")
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, c.send, ErrCodeUnknownCmd)
+	if len(store.msgs) != 0 {
+		t.Fatal("unknown command must not be persisted")
+	}
+}
+
+// Test 31 – "//" escapes the slash and the text is sent as a normal message
+func TestCommand_EscapedSlash(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, nil)
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, sync)
+
+	sendRoomText(c, 10, "//me is not a command")
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p.Content != "/me is not a command" || p.Kind != MessageKindText {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+}
+
+// Test 32 – /topic without args replies only to the sender
+func TestCommand_TopicReply(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	store.rooms[10] = dbstore.Room{ID: 10, Mode: room.ModeNormal, Topic: "release planning"}
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	sendRoomText(c, 10, "/topic")
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, c.send)
+	var p CommandReplyPayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Type != TypeCommandReply || p.Text != "topic: release planning" {
+		t.Fatalf("unexpected reply: %s %+v", got.Type, p)
+	}
+	expectNoMessage(t, other.send)
+}
+
+// Test 33 – /kick: members are refused, moderators remove the target
+func TestCommand_Kick(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleModerator, 2: room.RoleMember, 3: room.RoleMember})
+	store.users = map[string]dbstore.User{"bob": {ID: 2, Username: "bob"}, "mod": {ID: 1, Username: "mod"}}
+	mod := newTestClient(h, 1, map[int64]bool{10: true})
+	mod.queries = store
+	member := newTestClient(h, 3, map[int64]bool{10: true})
+	member.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, mod, member, sync)
+
+	sendRoomText(member, 10, "/kick bob")
+	syncHub(t, h, sync)
+	expectErrorCode(t, member.send, ErrCodeForbidden)
+	if _, ok := store.roles[2]; !ok {
+		t.Fatal("member must not be able to kick")
+	}
+
+	sendRoomText(mod, 10, "/kick @bob")
+	syncHub(t, h, sync)
+	if _, ok := store.roles[2]; ok {
+		t.Fatal("expected bob removed from the room")
+	}
+	got := expectMessage(t, member.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p.Content != "kicked bob" || p.Kind != MessageKindAction {
+		t.Fatalf("unexpected broadcast: %+v", p)
+	}
+	if len(store.audit) != 1 || store.audit[0].Action != audit.ActionMemberKicked || store.audit[0].TargetID.Int64 != 2 {
+		t.Fatalf("expected one member_kicked audit event for bob, got %+v", store.audit)
+	}
+}
+
+// Test 34 – bot commands: only bots register, invocations are forwarded to the bot
+func TestCommand_BotRegistered(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember, 7: room.RoleMember})
+	user := newTestClient(h, 1, map[int64]bool{10: true})
+	user.queries = store
+	bot := newTestClient(h, 7, map[int64]bool{10: true})
+	bot.isBot = true
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, user, bot, sync)
+
+	reg, _ := json.Marshal(RegisterCommandPayload{Name: "deploy", Usage: "/deploy <env>", Description: "ship it"})
+	user.registerBotCommand(Message{Type: TypeRegisterCommand, Payload: reg})
+	syncHub(t, h, sync)
+	expectErrorCode(t, user.send, ErrCodeForbidden)
+
+	bot.registerBotCommand(Message{Type: TypeRegisterCommand, Payload: reg})
+	syncHub(t, h, sync)
+	expectMessage(t, bot.send) // registration confirmation
+
+	sendRoomText(user, 10, "/deploy staging")
+	syncHub(t, h, sync)
+
+	got := expectMessage(t, bot.send)
+	var p CommandPayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Type != TypeCommand || p.Command != "deploy" || p.Args != "staging" || p.UserID != 1 {
+		t.Fatalf("unexpected invocation: %s %+v", got.Type, p)
+	}
+	expectNoMessage(t, user.send)
+
+	// the command goes away with the bot's connection
+	h.unregister <- bot
+	syncHub(t, h, sync)
+	if _, ok := h.Commands().Lookup("deploy"); ok {
+		t.Fatal("bot command should be dropped on disconnect")
+	}
+}
+
+// Test 35 – dice specs are bounded
+func TestParseDice(t *testing.T) {
+	cases := map[string]bool{"1d6": true, "d20": true, "3D8": true, "0d6": false, "21d6": false, "1d1": false, "2x6": false, "d": false}
+	for spec, want := range cases {
+		if _, _, ok := parseDice(spec); ok != want {
+			t.Errorf("parseDice(%q) ok = %v, want %v", spec, ok, want)
+		}
+	}
+}
+
+// Test 53 – a member kicked while posting: the Hub drops the room from the
+// client's set while its ReadPump checks it (run with -race)
+func TestCommand_KickWhilePosting(t *testing.T) {
+	h := startHub(t)
+	modStore := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleModerator, 2: room.RoleMember})
+	modStore.users = map[string]dbstore.User{"bob": {ID: 2, Username: "bob"}}
+	mod := newTestClient(h, 1, map[int64]bool{10: true})
+	mod.queries = modStore
+	bob := newTestClient(h, 2, map[int64]bool{10: true})
+	bob.queries = newFakeStore(room.ModeNormal, map[int64]string{2: room.RoleMember})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, mod, bob, sync)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			sendRoomText(bob, 10, "still here")
+		}
+	}()
+	sendRoomText(mod, 10, "/kick bob")
+	<-done
+	syncHub(t, h, sync)
+
+	if bob.roomIDs.has(10) {
+		t.Fatal("expected room 10 dropped from bob's rooms")
+	}
+}
+
+// Test 54 – /invite: members are refused, and so are blocked or deactivated
+// users; moderators add the target
+func TestCommand_Invite(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleModerator, 3: room.RoleMember})
+	store.users = map[string]dbstore.User{
+		"bob":  {ID: 2, Username: "bob"},
+		"eve":  {ID: 4, Username: "eve"},
+		"gone": {ID: 5, Username: "gone", DeactivatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}},
+	}
+	store.blocks = map[[2]int64]bool{{4, 1}: true}
+	mod := newTestClient(h, 1, map[int64]bool{10: true})
+	mod.queries = store
+	member := newTestClient(h, 3, map[int64]bool{10: true})
+	member.queries = store
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, mod, member, sync)
+
+	sendRoomText(member, 10, "/invite bob")
+	syncHub(t, h, sync)
+	expectErrorCode(t, member.send, ErrCodeForbidden)
+
+	for _, name := range []string{"eve", "gone"} {
+		sendRoomText(mod, 10, "/invite "+name)
+		syncHub(t, h, sync)
+		expectErrorCode(t, mod.send, ErrCodeForbidden)
+	}
+	if len(store.roles) != 2 {
+		t.Fatalf("expected no one added, got %+v", store.roles)
+	}
+
+	sendRoomText(mod, 10, "/invite @bob")
+	syncHub(t, h, sync)
+	if store.roles[2] != room.RoleMember {
+		t.Fatal("expected bob added to the room")
+	}
+	got := expectMessage(t, member.send)
+	var p RoomMessagePayload
+	if err := json.Unmarshal(got.Payload, &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p.Content != "invited bob" {
+		t.Fatalf("unexpected broadcast: %+v", p)
+	}
+}
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (f *fakeStore) CanDirectMessage(_ context.Context, arg dbstore.CanDirectMessageParams) (bool, error) {
+	return !f.noDMs[[2]int64{arg.RecipientID, arg.SenderID}], nil
+}
+
+// Test 37 – a DM to someone who blocked the sender (or doesn't take DMs from
+// them) gets dm_not_allowed and reaches no one
+func TestDispatchDirectMessage_NotAllowed(t *testing.T) {
+	h := startHub(t)
+	store := &fakeStore{noDMs: map[[2]int64]bool{{2, 1}: true}}
+	sender := newTestClient(h, 1, map[int64]bool{})
+	sender.queries = store
+	recipient := newTestClient(h, 2, map[int64]bool{})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, sender, recipient, sync)
+
+	payload, _ := json.Marshal(DirectMessagePayload{ToUserID: 2, Content: "hi"})
+	sender.dispatchDirectMessage(Message{Type: TypeDirectMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, sender.send, ErrCodeDMNotAllowed)
+	expectNoMessage(t, recipient.send)
+}
+
+// Test 38 – an allowed DM is delivered to both ends
+func TestDispatchDirectMessage_Allowed(t *testing.T) {
+	h := startHub(t)
+	store := &fakeStore{noDMs: map[[2]int64]bool{{3, 1}: true}}
+	sender := newTestClient(h, 1, map[int64]bool{})
+	sender.queries = store
+	recipient := newTestClient(h, 2, map[int64]bool{})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, sender, recipient, sync)
+
+	payload, _ := json.Marshal(DirectMessagePayload{ToUserID: 2, Content: "hi"})
+	sender.dispatchDirectMessage(Message{Type: TypeDirectMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	for _, c := range []*Client{sender, recipient} {
+		if got := expectMessage(t, c.send); got.Type != TypeDirectMessage {
+			t.Fatalf("user %d got %s, want %s", c.userID, got.Type, TypeDirectMessage)
+		}
+	}
+}
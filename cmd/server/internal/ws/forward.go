@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/forward"
+)
+
+// Errors returned when a forward or a quote is rejected; the wrapped error
+// says why.
+var (
+	ErrCannotForward = errors.New("can't forward this message")
+	ErrCannotQuote   = errors.New("can't quote this message")
+)
+
+// resolveForward returns the text of the message f forwards and fills in f
+// with its origin. A forward is a copy, so it can't bring text, attachments
+// or a quote of its own.
+func (c *Client) resolveForward(ctx context.Context, f *ForwardedFrom, content string, attachmentIDs []int64, quoteID int64) (string, error) {
+	if content != "" || len(attachmentIDs) > 0 || quoteID != 0 {
+		return "", fmt.Errorf("%w: a forward has no text, attachments or quote of its own", ErrCannotForward)
+	}
+	body, origin, err := forward.Resolve(ctx, c.queries, f.MessageID, c.userID)
+	if err != nil {
+		return "", refError(ErrCannotForward, err)
+	}
+	*f = ForwardedFrom{
+		MessageID:      origin.MessageID,
+		SenderID:       origin.SenderID,
+		SenderUsername: origin.SenderUsername,
+		RoomID:         origin.RoomID,
+		RoomName:       origin.RoomName,
+	}
+	return body, nil
+}
+
+// resolveQuote loads the message a reply quotes, nil if it quotes none.
+func (c *Client) resolveQuote(ctx context.Context, messageID int64) (*QuoteInfo, error) {
+	if messageID == 0 {
+		return nil, nil
+	}
+	q, err := forward.LoadQuote(ctx, c.queries, messageID, c.userID)
+	if err != nil {
+		return nil, refError(ErrCannotQuote, err)
+	}
+	return &QuoteInfo{
+		MessageID:      q.MessageID,
+		SenderID:       q.SenderID,
+		SenderUsername: q.SenderUsername,
+		Body:           q.Body,
+	}, nil
+}
+
+// refError wraps the errors of package forward that the sender should see in
+// kind; anything else is passed through as an internal error.
+func refError(kind, err error) error {
+	if errors.Is(err, forward.ErrNotFound) || errors.Is(err, forward.ErrNotForwardable) {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+	return err
+}
+
+// forwardParams returns the forward columns of a new message.
+func forwardParams(f *ForwardedFrom) (id, userID, roomID pgtype.Int8) {
+	if f == nil {
+		return
+	}
+	return optionalID(f.MessageID), optionalID(f.SenderID), optionalID(f.RoomID)
+}
+
+// optionalID returns NULL for a zero ID.
+func optionalID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// seenMessages returns a room message by ann in #general (5), a forward of
+// it in a DM (6), a poll (7) and a disappearing DM (8).
+func seenMessages() map[int64]dbstore.GetVisibleMessageRow {
+	return map[int64]dbstore.GetVisibleMessageRow{
+		5: {
+			ID: 5, RoomID: pgtype.Int8{Int64: 20, Valid: true}, RoomName: pgtype.Text{String: "general", Valid: true},
+			SenderID: 7, SenderUsername: "ann", Body: "ship it", Kind: MessageKindText,
+		},
+		6: {
+			ID: 6, ConversationID: pgtype.Int8{Int64: 3, Valid: true}, SenderID: 8, SenderUsername: "bob", Body: "ship it", Kind: MessageKindText,
+			ForwardedFromID:       pgtype.Int8{Int64: 5, Valid: true},
+			ForwardedFromUserID:   pgtype.Int8{Int64: 7, Valid: true},
+			ForwardedFromUsername: pgtype.Text{String: "ann", Valid: true},
+			ForwardedFromRoomID:   pgtype.Int8{Int64: 20, Valid: true},
+			ForwardedFromRoomName: pgtype.Text{String: "general", Valid: true},
+		},
+		7: {ID: 7, RoomID: pgtype.Int8{Int64: 20, Valid: true}, SenderID: 7, SenderUsername: "ann", Body: "lunch?", Kind: MessageKindPoll},
+		8: {
+			ID: 8, ConversationID: pgtype.Int8{Int64: 3, Valid: true}, SenderID: 8, SenderUsername: "bob", Body: "burn after reading", Kind: MessageKindText,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		},
+	}
+}
+
+// Test 50 – forwarding copies the text, stores the origin and broadcasts it;
+// forwarding a forward keeps the first origin
+func TestDispatchRoomMessage_Forward(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	store.seen = seenMessages()
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	for _, id := range []int64{5, 6} {
+		payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, ForwardedFrom: &ForwardedFrom{MessageID: id}})
+		c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+		syncHub(t, h, sync)
+
+		var got RoomMessagePayload
+		if err := json.Unmarshal(expectMessage(t, other.send).Payload, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		want := ForwardedFrom{MessageID: 5, SenderID: 7, SenderUsername: "ann", RoomID: 20, RoomName: "general"}
+		if got.Content != "ship it" || got.ForwardedFrom == nil || *got.ForwardedFrom != want {
+			t.Fatalf("forward of %d = %q from %+v, want %+v", id, got.Content, got.ForwardedFrom, want)
+		}
+	}
+
+	for _, m := range store.msgs {
+		if m.ForwardedFromID.Int64 != 5 || m.ForwardedFromUserID.Int64 != 7 || m.ForwardedFromRoomID.Int64 != 20 {
+			t.Fatalf("stored forward = %+v", m)
+		}
+	}
+}
+
+// Test 51 – forwards of unseen, disappearing or poll messages, and forwards
+// with text of their own, get cannot_forward and are not stored
+func TestDispatchRoomMessage_ForwardRejected(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	store.seen = seenMessages()
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	cases := []RoomMessagePayload{
+		{RoomID: 10, ForwardedFrom: &ForwardedFrom{MessageID: 42}},
+		{RoomID: 10, ForwardedFrom: &ForwardedFrom{MessageID: 7}},
+		{RoomID: 10, ForwardedFrom: &ForwardedFrom{MessageID: 8}},
+		{RoomID: 10, Content: "look", ForwardedFrom: &ForwardedFrom{MessageID: 5}},
+	}
+	for _, p := range cases {
+		payload, _ := json.Marshal(p)
+		c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+		syncHub(t, h, sync)
+
+		expectErrorCode(t, c.send, ErrCodeCannotForward)
+	}
+	expectNoMessage(t, other.send)
+	if len(store.msgs) != 0 {
+		t.Fatalf("expected no persisted messages, got %d", len(store.msgs))
+	}
+}
+
+// Test 52 – a DM quoting a message carries the quote to both ends; quoting a
+// message the sender can't see gets cannot_quote
+func TestDispatchDirectMessage_Quote(t *testing.T) {
+	h := startHub(t)
+	store := &fakeStore{seen: seenMessages()}
+	sender := newTestClient(h, 1, map[int64]bool{})
+	sender.queries = store
+	recipient := newTestClient(h, 2, map[int64]bool{})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, sender, recipient, sync)
+
+	payload, _ := json.Marshal(DirectMessagePayload{ToUserID: 2, Content: "agreed", QuoteMessageID: 5})
+	sender.dispatchDirectMessage(Message{Type: TypeDirectMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	for _, c := range []*Client{sender, recipient} {
+		var got DirectMessagePayload
+		if err := json.Unmarshal(expectMessage(t, c.send).Payload, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.Quote == nil || got.Quote.MessageID != 5 || got.Quote.SenderUsername != "ann" || got.Quote.Body != "ship it" {
+			t.Fatalf("user %d got quote %+v", c.userID, got.Quote)
+		}
+	}
+	if len(store.dms) != 1 || store.dms[0].QuoteMessageID.Int64 != 5 {
+		t.Fatalf("stored dms = %+v", store.dms)
+	}
+
+	payload, _ = json.Marshal(DirectMessagePayload{ToUserID: 2, Content: "agreed", QuoteMessageID: 42})
+	sender.dispatchDirectMessage(Message{Type: TypeDirectMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	expectErrorCode(t, sender.send, ErrCodeCannotQuote)
+	expectNoMessage(t, recipient.send)
+}
@@ -1,25 +1,82 @@
 package ws
 
 import (
+	"context"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/coder/websocket"
-	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ratelimit"
 )
 
 // Client represents a single WebSocket connection.
 type Client struct {
 	hub      *Hub
 	conn     *websocket.Conn
-	queries  *dbstore.Queries
+	queries  Store
 	userID   int64
 	username string
-	roomIDs  map[int64]bool // rooms this client is a member of
-	send     chan Message   // Hub writes here, WritePump drains
+	isBot    bool
+	session  int64        // auth session the connection was opened with, 0 for bots
+	roomIDs  *roomSet     // rooms this client is a member of
+	send     chan Message // Hub writes here, WritePump drains
+	kicked   bool         // set by the Hub once send is closed, only touched by Run
+
+	// rate limiting state, only touched by the ReadPump goroutine
+	buckets map[string]*ratelimit.Bucket
+	strikes *ratelimit.Bucket
 
 	logger *slog.Logger
 }
 
+// roomSet is the set of rooms a client is a member of. The Hub goroutine
+// changes it on joins, kicks and deletions while the ReadPump checks it
+// before every room frame, so it has its own lock.
+type roomSet struct {
+	mu  sync.RWMutex
+	ids map[int64]bool
+}
+
+func newRoomSet(ids map[int64]bool) *roomSet {
+	s := &roomSet{ids: make(map[int64]bool, len(ids))}
+	for id, in := range ids {
+		if in {
+			s.ids[id] = true
+		}
+	}
+	return s
+}
+
+func (s *roomSet) has(roomID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ids[roomID]
+}
+
+func (s *roomSet) add(roomID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[roomID] = true
+}
+
+func (s *roomSet) remove(roomID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, roomID)
+}
+
+// list returns a copy of the room IDs, safe to range over while the set changes.
+func (s *roomSet) list() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 // Hub is the central message broker.
 // A single goroutine runs Hub.Run() and is the sole owner of the maps.
 type Hub struct {
@@ -30,6 +87,18 @@ type Hub struct {
 	unregister     chan *Client
 	broadcast      chan BroadcastMsg
 	userRoomUpdate chan UserRoomPresent
+	disconnect     chan int64 // session IDs whose connections must be closed
+	disconnectUser chan int64 // user IDs whose connections must be closed
+	stats          chan chan Stats
+
+	limiter  *limiter
+	events   EventPublisher
+	commands *CommandRegistry
+}
+
+// EventPublisher receives room events for delivery outside the WebSocket, e.g. webhooks.
+type EventPublisher interface {
+	PublishRoomEvent(ctx context.Context, roomID int64, eventType string, data any)
 }
 
 // BroadcastMsg wraps a message with routing info.
@@ -37,6 +106,16 @@ type BroadcastMsg struct {
 	msg           Message
 	targetRoomID  int64   // if > 0, route to room members
 	targetUserIDs []int64 // if targetRoomID == 0, route to these users (DM)
+	everyone      bool    // route to every connected client
+	closeRoom     bool    // forget the target room once msg is delivered
+}
+
+// Stats is a snapshot of the live connections.
+type Stats struct {
+	Connections int
+	Bots        int
+	// RoomsOnline maps each room with someone online to how many members are.
+	RoomsOnline map[int64]int
 }
 
 type UserRoomPresent struct {
@@ -47,6 +126,12 @@ type UserRoomPresent struct {
 
 // NewHub creates a Hub with initialized maps and channels.
 func NewHub() *Hub {
+	commands := NewCommandRegistry()
+	for _, cmd := range builtinCommands() {
+		if err := commands.Register(cmd); err != nil {
+			panic(err)
+		}
+	}
 	return &Hub{
 		clients:        make(map[int64]*Client),
 		rooms:          make(map[int64]map[int64]bool),
@@ -54,11 +139,41 @@ func NewHub() *Hub {
 		unregister:     make(chan *Client),
 		broadcast:      make(chan BroadcastMsg, 256),
 		userRoomUpdate: make(chan UserRoomPresent),
+		disconnect:     make(chan int64),
+		disconnectUser: make(chan int64),
+		stats:          make(chan chan Stats),
+		limiter:        newLimiter(DefaultLimits()),
+		commands:       commands,
+	}
+}
+
+// Commands returns the slash command registry, to register extra commands.
+func (h *Hub) Commands() *CommandRegistry {
+	return h.commands
+}
+
+// SetLimits replaces the rate limits. It must be called before any client is created.
+func (h *Hub) SetLimits(l Limits) {
+	h.limiter = newLimiter(l)
+}
+
+// SetEventPublisher registers where room events are forwarded. It must be called before Run.
+func (h *Hub) SetEventPublisher(p EventPublisher) {
+	h.events = p
+}
+
+// PublishRoomEvent forwards a room event to the registered EventPublisher, if any.
+func (h *Hub) PublishRoomEvent(ctx context.Context, roomID int64, eventType string, data any) {
+	if h.events != nil {
+		h.events.PublishRoomEvent(ctx, roomID, eventType, data)
 	}
 }
 
 // Run starts the Hub event loop. Must be called in a goroutine.
 func (h *Hub) Run() {
+	sweep := time.NewTicker(limiterSweepInterval)
+	defer sweep.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -69,22 +184,34 @@ func (h *Hub) Run() {
 			h.updateUserPresenceInRoom(userRoomUpdate)
 		case broadcastMsg := <-h.broadcast:
 			h.broadcastMessage(broadcastMsg)
+		case sessionID := <-h.disconnect:
+			h.disconnectSession(sessionID)
+		case userID := <-h.disconnectUser:
+			h.disconnectUserClient(userID)
+		case reply := <-h.stats:
+			reply <- h.snapshot()
+		case now := <-sweep.C:
+			h.limiter.sweep(now)
 		}
 	}
 }
 
 func (h *Hub) registerClient(c *Client) {
-	for roomID, isInRoom := range c.roomIDs {
+	for _, roomID := range c.roomIDs.list() {
 		if _, ok := h.rooms[roomID]; !ok {
 			h.rooms[roomID] = make(map[int64]bool)
 		}
-		h.rooms[roomID][c.userID] = isInRoom
+		h.rooms[roomID][c.userID] = true
 	}
 	h.clients[c.userID] = c
 }
 
 func (h *Hub) unregisterClient(c *Client) {
-	for roomID := range c.roomIDs {
+	if c.kicked {
+		// already removed by kickClient, send is closed
+		return
+	}
+	for _, roomID := range c.roomIDs.list() {
 		_, ok := h.rooms[roomID]
 		if !ok {
 			continue
@@ -93,6 +220,9 @@ func (h *Hub) unregisterClient(c *Client) {
 	}
 	delete(h.clients, c.userID)
 	close(c.send)
+	if c.isBot {
+		h.commands.unregisterOwner(c)
+	}
 }
 
 func (h *Hub) updateUserPresenceInRoom(userRoomUpdate UserRoomPresent) {
@@ -105,17 +235,28 @@ func (h *Hub) updateUserPresenceInRoom(userRoomUpdate UserRoomPresent) {
 			h.rooms[userRoomUpdate.roomID] = make(map[int64]bool)
 		}
 		h.rooms[userRoomUpdate.roomID][userRoomUpdate.userID] = true
-		client.roomIDs[userRoomUpdate.roomID] = true
+		client.roomIDs.add(userRoomUpdate.roomID)
 	} else {
 		if _, ok := h.rooms[userRoomUpdate.roomID]; !ok {
 			return
 		}
 		delete(h.rooms[userRoomUpdate.roomID], userRoomUpdate.userID)
-		delete(client.roomIDs, userRoomUpdate.roomID)
+		client.roomIDs.remove(userRoomUpdate.roomID)
 	}
 }
 
 func (h *Hub) broadcastMessage(broadcastMsg BroadcastMsg) {
+	if broadcastMsg.everyone {
+		for _, c := range h.clients {
+			select {
+			case c.send <- broadcastMsg.msg:
+			default:
+				// client laggeado, lo sacamos
+				h.kickClient(c)
+			}
+		}
+		return
+	}
 	// if it's a room msg
 	if broadcastMsg.targetRoomID > 0 {
 		for userID, isConnected := range h.rooms[broadcastMsg.targetRoomID] {
@@ -130,6 +271,9 @@ func (h *Hub) broadcastMessage(broadcastMsg BroadcastMsg) {
 				h.kickClient(c)
 			}
 		}
+		if broadcastMsg.closeRoom {
+			h.forgetRoom(broadcastMsg.targetRoomID)
+		}
 	} else {
 		for _, userID := range broadcastMsg.targetUserIDs {
 			// if the client exists
@@ -146,10 +290,47 @@ func (h *Hub) broadcastMessage(broadcastMsg BroadcastMsg) {
 	}
 }
 
+// forgetRoom drops a deleted room from the presence maps.
+func (h *Hub) forgetRoom(roomID int64) {
+	for userID := range h.rooms[roomID] {
+		if c, ok := h.clients[userID]; ok {
+			c.roomIDs.remove(roomID)
+		}
+	}
+	delete(h.rooms, roomID)
+}
+
+// snapshot counts the live connections. Members whose client is gone are
+// skipped.
+func (h *Hub) snapshot() Stats {
+	st := Stats{Connections: len(h.clients), RoomsOnline: make(map[int64]int)}
+	for _, c := range h.clients {
+		if c.isBot {
+			st.Bots++
+		}
+	}
+	for roomID, members := range h.rooms {
+		n := 0
+		for userID, isConnected := range members {
+			if _, ok := h.clients[userID]; ok && isConnected {
+				n++
+			}
+		}
+		if n > 0 {
+			st.RoomsOnline[roomID] = n
+		}
+	}
+	return st
+}
+
 func (h *Hub) kickClient(c *Client) {
+	c.kicked = true
 	close(c.send)
+	if c.isBot {
+		h.commands.unregisterOwner(c)
+	}
 	delete(h.clients, c.userID)
-	for roomID := range c.roomIDs {
+	for _, roomID := range c.roomIDs.list() {
 		_, ok := h.rooms[roomID]
 		if !ok {
 			continue
@@ -158,6 +339,32 @@ func (h *Hub) kickClient(c *Client) {
 	}
 }
 
+// disconnectSession drops the connections opened with a revoked session and
+// closes their sockets, which ends their ReadPump.
+func (h *Hub) disconnectSession(sessionID int64) {
+	for _, c := range h.clients {
+		if c.session != sessionID {
+			continue
+		}
+		h.kickClient(c)
+		if c.conn != nil {
+			go c.conn.Close(websocket.StatusPolicyViolation, "session revoked")
+		}
+	}
+}
+
+// disconnectUserClient drops the connection of a deactivated user.
+func (h *Hub) disconnectUserClient(userID int64) {
+	c, ok := h.clients[userID]
+	if !ok {
+		return
+	}
+	h.kickClient(c)
+	if c.conn != nil {
+		go c.conn.Close(websocket.StatusPolicyViolation, "account deactivated")
+	}
+}
+
 // Register sends a client to the Hub's register channel.
 func (h *Hub) Register(c *Client) {
 	h.register <- c
@@ -170,3 +377,44 @@ func (h *Hub) UpdateUserRoomState(roomID int64, userID int64, present bool) {
 		present: present,
 	}
 }
+
+// DisconnectSession closes every connection authenticated with the given session.
+func (h *Hub) DisconnectSession(sessionID int64) {
+	if sessionID == 0 {
+		return
+	}
+	h.disconnect <- sessionID
+}
+
+// DisconnectUser closes the connection of a user whose account was deactivated.
+func (h *Hub) DisconnectUser(userID int64) {
+	h.disconnectUser <- userID
+}
+
+// BroadcastToRoom delivers msg to every connected member of the room.
+func (h *Hub) BroadcastToRoom(roomID int64, msg Message) {
+	h.broadcast <- BroadcastMsg{msg: msg, targetRoomID: roomID}
+}
+
+// BroadcastToUsers delivers msg to the given users if they are connected.
+func (h *Hub) BroadcastToUsers(userIDs []int64, msg Message) {
+	h.broadcast <- BroadcastMsg{msg: msg, targetUserIDs: userIDs}
+}
+
+// BroadcastToAll delivers msg to every connected client.
+func (h *Hub) BroadcastToAll(msg Message) {
+	h.broadcast <- BroadcastMsg{msg: msg, everyone: true}
+}
+
+// CloseRoom delivers msg to the connected members of a deleted room and then
+// forgets the room, so they can't post to it anymore.
+func (h *Hub) CloseRoom(roomID int64, msg Message) {
+	h.broadcast <- BroadcastMsg{msg: msg, targetRoomID: roomID, closeRoom: true}
+}
+
+// Stats returns a snapshot of the live connections.
+func (h *Hub) Stats() Stats {
+	reply := make(chan Stats, 1)
+	h.stats <- reply
+	return <-reply
+}
@@ -22,7 +22,7 @@ func newTestClient(h *Hub, userID int64, roomIDs map[int64]bool) *Client {
 		hub:      h,
 		userID:   userID,
 		username: fmt.Sprintf("user_%d", userID),
-		roomIDs:  roomIDs,
+		roomIDs:  newRoomSet(roomIDs),
 		send:     make(chan Message, 256),
 		logger:   nopLogger,
 	}
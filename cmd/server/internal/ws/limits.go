@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/ratelimit"
+)
+
+// Limits bounds what clients may send: frame sizes, message lengths and
+// frame rates. Rates are keyed by message type; types without an entry are
+// not limited.
+type Limits struct {
+	// MaxFrameBytes is the largest WebSocket frame accepted from a client.
+	MaxFrameBytes int64
+	// MaxMessageRunes is the longest message content accepted, in runes.
+	MaxMessageRunes int
+
+	PerConnection map[string]ratelimit.Rate
+	PerUser       map[string]ratelimit.Rate
+	// Strikes is the budget of rejected frames a connection may accumulate
+	// before it is closed for abuse.
+	Strikes ratelimit.Rate
+}
+
+// DefaultLimits returns the limits used when none are configured.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFrameBytes:   16 << 10,
+		MaxMessageRunes: 2000,
+		PerConnection: map[string]ratelimit.Rate{
+			TypeRoomMessage:   {PerSecond: 2, Burst: 5},
+			TypeDirectMessage: {PerSecond: 2, Burst: 5},
+			TypeUserTyping:    {PerSecond: 2, Burst: 4},
+			TypeJoinRoom:      {PerSecond: 1, Burst: 5},
+			TypeLeaveRoom:     {PerSecond: 1, Burst: 5},
+			TypePollVote:      {PerSecond: 2, Burst: 5},
+
+			TypeRegisterCommand: {PerSecond: 1, Burst: 10},
+		},
+		PerUser: map[string]ratelimit.Rate{
+			TypeRoomMessage:   {PerSecond: 3, Burst: 10},
+			TypeDirectMessage: {PerSecond: 3, Burst: 10},
+		},
+		Strikes: ratelimit.Rate{PerSecond: 0.2, Burst: 10},
+	}
+}
+
+type roomUserKey struct {
+	roomID int64
+	userID int64
+}
+
+// limiterSweepInterval is how often the Hub drops idle rate-limit entries.
+const limiterSweepInterval = time.Minute
+
+// limiter holds the rate-limit state shared by every client of a Hub.
+type limiter struct {
+	limits   Limits
+	perUser  map[string]*ratelimit.Keyed[int64]
+	slowMode *ratelimit.Cooldown[roomUserKey]
+}
+
+func newLimiter(l Limits) *limiter {
+	perUser := make(map[string]*ratelimit.Keyed[int64], len(l.PerUser))
+	for msgType, rate := range l.PerUser {
+		perUser[msgType] = ratelimit.NewKeyed[int64](rate)
+	}
+	return &limiter{limits: l, perUser: perUser, slowMode: ratelimit.NewCooldown[roomUserKey]()}
+}
+
+// connBuckets creates the per-connection buckets for a new client.
+func (l *limiter) connBuckets() map[string]*ratelimit.Bucket {
+	buckets := make(map[string]*ratelimit.Bucket, len(l.limits.PerConnection))
+	for msgType, rate := range l.limits.PerConnection {
+		buckets[msgType] = ratelimit.NewBucket(rate)
+	}
+	return buckets
+}
+
+// allowUser takes a token from the user's bucket for msgType.
+func (l *limiter) allowUser(userID int64, msgType string, now time.Time) (bool, time.Duration) {
+	k, ok := l.perUser[msgType]
+	if !ok {
+		return true, 0
+	}
+	return k.Allow(userID, now)
+}
+
+// allowSlowMode enforces one message per interval for a member of a room.
+func (l *limiter) allowSlowMode(roomID, userID int64, interval time.Duration, now time.Time) (bool, time.Duration) {
+	return l.slowMode.Allow(roomUserKey{roomID: roomID, userID: userID}, interval, now)
+}
+
+// sweep drops the idle per-user buckets and slow-mode entries.
+func (l *limiter) sweep(now time.Time) {
+	for _, k := range l.perUser {
+		k.Sweep(now)
+	}
+	l.slowMode.Sweep(now)
+}
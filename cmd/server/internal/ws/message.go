@@ -11,13 +11,30 @@ const (
 	TypeRoomMessage   = "room_message"
 	TypeDirectMessage = "direct_message"
 
-	TypeJoinRoom  = "join_room"
-	TypeLeaveRoom = "leave_room"
+	TypeJoinRoom    = "join_room"
+	TypeLeaveRoom   = "leave_room"
+	TypeRoomUpdated = "room_updated"
+	TypeRoomDeleted = "room_deleted"
+
+	TypeMessageDeleted = "message_deleted"
+	TypeMessageExpired = "message_expired"
+	TypeMessagePinned  = "message_pinned"
+
+	TypePollVote    = "poll_vote"
+	TypePollUpdated = "poll_updated"
 
 	TypeUserOnline  = "user_online"
 	TypeUserOffline = "user_offline"
 	TypeUserTyping  = "user_typing"
 
+	TypeProfileUpdated = "profile_updated"
+
+	TypeAnnouncement = "announcement"
+
+	TypeCommandReply    = "command_reply"
+	TypeCommand         = "command"
+	TypeRegisterCommand = "register_command"
+
 	TypeLoadRoomHistory  = "load_room_history"
 	TypeLoadConversation = "load_conversation"
 
@@ -27,6 +44,34 @@ const (
 	TypeSuccess = "success"
 )
 
+// Error codes sent in ErrorPayload.Code.
+const (
+	ErrCodeRoomArchived  = "room_archived"
+	ErrCodeRoomReadOnly  = "room_read_only"
+	ErrCodeRateLimited   = "rate_limited"
+	ErrCodeSlowMode      = "slow_mode"
+	ErrCodeTooLong       = "message_too_long"
+	ErrCodeAttachments   = "invalid_attachments"
+	ErrCodeUnknownCmd    = "unknown_command"
+	ErrCodeInvalidCmd    = "invalid_command"
+	ErrCodeForbidden     = "forbidden"
+	ErrCodeDMNotAllowed  = "dm_not_allowed"
+	ErrCodeInvalidTTL    = "invalid_ttl"
+	ErrCodeInvalidPoll   = "invalid_poll"
+	ErrCodePollClosed    = "poll_closed"
+	ErrCodeCannotForward = "cannot_forward"
+	ErrCodeCannotQuote   = "cannot_quote"
+	ErrCodeInternal      = "internal"
+)
+
+// Message kinds, stored with each message. Action messages come from /me;
+// poll messages are room messages sent with a poll.
+const (
+	MessageKindText   = "text"
+	MessageKindAction = "action"
+	MessageKindPoll   = "poll"
+)
+
 // Message is the envelope for all WebSocket messages.
 // Type determines which payload struct to unmarshal into.
 type Message struct {
@@ -35,25 +80,115 @@ type Message struct {
 	Timestamp time.Time       `json:"timestamp"`
 }
 
+// NewMessage builds an envelope with the given type and JSON-encoded payload.
+func NewMessage(msgType string, payload any) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: msgType, Payload: data, Timestamp: time.Now().UTC()}, nil
+}
+
 // RoomMessagePayload is the payload for room messages.
 type RoomMessagePayload struct {
 	RoomID  int64  `json:"room_id"`
 	Content string `json:"content"`
+	// AttachmentIDs references files previously uploaded by the sender.
+	AttachmentIDs []int64 `json:"attachment_ids,omitempty"`
+	// TTLSeconds makes the message disappear that many seconds after it is sent.
+	TTLSeconds int32 `json:"ttl_seconds,omitempty"`
+	// Poll turns the message into a poll whose question is Content.
+	Poll *PollInfo `json:"poll,omitempty"`
+	// ForwardedFrom forwards the message with its message_id; Content stays
+	// empty and the server copies the text and fills in the origin.
+	ForwardedFrom *ForwardedFrom `json:"forwarded_from,omitempty"`
+	// QuoteMessageID quotes a message above this one.
+	QuoteMessageID int64 `json:"quote_message_id,omitempty"`
 	// fields populated by the server before broadcast
-	SenderID       int64  `json:"sender_id,omitempty"`
-	SenderUsername string `json:"sender_username,omitempty"`
-	MessageID      int64  `json:"message_id,omitempty"`
+	SenderID       int64            `json:"sender_id,omitempty"`
+	SenderUsername string           `json:"sender_username,omitempty"`
+	SenderIsBot    bool             `json:"sender_is_bot,omitempty"`
+	MessageID      int64            `json:"message_id,omitempty"`
+	Kind           string           `json:"kind,omitempty"`
+	Attachments    []AttachmentInfo `json:"attachments,omitempty"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"`
+	Quote          *QuoteInfo       `json:"quote,omitempty"`
 }
 
 // DirectMessagePayload is the payload for direct (1-to-1) messages.
 type DirectMessagePayload struct {
 	ToUserID int64  `json:"to_user_id"`
 	Content  string `json:"content"`
+	// AttachmentIDs references files previously uploaded by the sender.
+	AttachmentIDs []int64 `json:"attachment_ids,omitempty"`
+	// TTLSeconds makes the message disappear that many seconds after it is
+	// sent. Zero uses the conversation default, if any.
+	TTLSeconds int32 `json:"ttl_seconds,omitempty"`
+	// ForwardedFrom and QuoteMessageID work as in RoomMessagePayload.
+	ForwardedFrom  *ForwardedFrom `json:"forwarded_from,omitempty"`
+	QuoteMessageID int64          `json:"quote_message_id,omitempty"`
 	// fields populated by the server before broadcast
-	SenderID       int64  `json:"from_user_id,omitempty"`
-	SenderUsername string `json:"from_username,omitempty"`
-	ConversationID int64  `json:"conversation_id,omitempty"`
-	MessageID      int64  `json:"message_id,omitempty"`
+	SenderID       int64            `json:"from_user_id,omitempty"`
+	SenderUsername string           `json:"from_username,omitempty"`
+	SenderIsBot    bool             `json:"from_is_bot,omitempty"`
+	ConversationID int64            `json:"conversation_id,omitempty"`
+	MessageID      int64            `json:"message_id,omitempty"`
+	Attachments    []AttachmentInfo `json:"attachments,omitempty"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"`
+	Quote          *QuoteInfo       `json:"quote,omitempty"`
+}
+
+// ForwardedFrom is where a forwarded message first came from: its author
+// and, unless it was a DM, its room. Forwarding a forward keeps the first
+// origin. MessageID is 0 once the original is gone.
+type ForwardedFrom struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id,omitempty"`
+	SenderUsername string `json:"sender_username,omitempty"`
+	RoomID         int64  `json:"room_id,omitempty"`
+	RoomName       string `json:"room_name,omitempty"`
+}
+
+// QuoteInfo is the message quoted by a reply.
+type QuoteInfo struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	Body           string `json:"body"`
+}
+
+// PollInfo describes the poll of a room message. Votes start at zero and
+// arrive as poll_updated frames.
+type PollInfo struct {
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple,omitempty"`
+	Anonymous bool       `json:"anonymous,omitempty"`
+	ClosesAt  *time.Time `json:"closes_at,omitempty"`
+}
+
+// PollVotePayload is sent by a client to vote on a poll. It replaces the
+// user's previous votes; an empty Options retracts them.
+type PollVotePayload struct {
+	MessageID int64 `json:"message_id"`
+	Options   []int `json:"options"`
+}
+
+// PollUpdatedPayload is broadcast to the room after every vote. Voters holds
+// the usernames behind each option and is left out for anonymous polls.
+type PollUpdatedPayload struct {
+	MessageID   int64      `json:"message_id"`
+	RoomID      int64      `json:"room_id"`
+	Counts      []int      `json:"counts"`
+	Voters      [][]string `json:"voters,omitempty"`
+	TotalVoters int        `json:"total_voters"`
+}
+
+// AttachmentInfo describes a file attached to a message.
+type AttachmentInfo struct {
+	ID          int64  `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
 }
 
 // RoomPresencePayload is the payload for join/leave room events.
@@ -61,6 +196,87 @@ type RoomPresencePayload struct {
 	RoomID int64 `json:"room_id"`
 }
 
+// RoomUpdatedPayload is broadcast to room members when room settings change.
+type RoomUpdatedPayload struct {
+	RoomID          int64  `json:"room_id"`
+	Mode            string `json:"mode"`
+	SlowModeSeconds int32  `json:"slow_mode_seconds"`
+	Topic           string `json:"topic"`
+}
+
+// RoomDeletedPayload is sent to the members of a room an admin deleted.
+type RoomDeletedPayload struct {
+	RoomID int64 `json:"room_id"`
+}
+
+// MessageDeletedPayload is sent to whoever could see a deleted message. One
+// of RoomID and ConversationID is set.
+type MessageDeletedPayload struct {
+	MessageID      int64 `json:"message_id"`
+	RoomID         int64 `json:"room_id,omitempty"`
+	ConversationID int64 `json:"conversation_id,omitempty"`
+}
+
+// MessageExpiredPayload is sent to whoever could see a disappearing message
+// once it expires. One of RoomID and ConversationID is set.
+type MessageExpiredPayload struct {
+	MessageID      int64 `json:"message_id"`
+	RoomID         int64 `json:"room_id,omitempty"`
+	ConversationID int64 `json:"conversation_id,omitempty"`
+}
+
+// MessagePinnedPayload is sent to the members of a room when a moderator
+// pins a message, and with Pinned false when one is unpinned.
+type MessagePinnedPayload struct {
+	MessageID int64  `json:"message_id"`
+	RoomID    int64  `json:"room_id"`
+	Pinned    bool   `json:"pinned"`
+	By        string `json:"by"`
+}
+
+// AnnouncementPayload is a server-wide notice sent to every connected client.
+type AnnouncementPayload struct {
+	Text string `json:"text"`
+	From string `json:"from"`
+}
+
+// ProfileUpdatedPayload is sent to everyone sharing a room or a conversation
+// with a user whose profile changed. It carries the whole profile.
+type ProfileUpdatedPayload struct {
+	UserID             int64  `json:"user_id"`
+	Username           string `json:"username"`
+	DisplayName        string `json:"display_name"`
+	Bio                string `json:"bio"`
+	AvatarAttachmentID int64  `json:"avatar_attachment_id,omitempty"`
+	AvatarURL          string `json:"avatar_url,omitempty"`
+	StatusText         string `json:"status_text"`
+	StatusEmoji        string `json:"status_emoji"`
+}
+
+// CommandReplyPayload is sent only to the user who ran a slash command.
+type CommandReplyPayload struct {
+	RoomID  int64  `json:"room_id"`
+	Command string `json:"command"`
+	Text    string `json:"text"`
+}
+
+// CommandPayload is sent to a bot when someone runs one of its commands.
+type CommandPayload struct {
+	RoomID   int64  `json:"room_id"`
+	Command  string `json:"command"`
+	Args     string `json:"args"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// RegisterCommandPayload is sent by a bot to register a slash command for as
+// long as its connection stays open.
+type RegisterCommandPayload struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description"`
+}
+
 // UserTypingPayload is the payload for typing indicator events.
 type UserTypingPayload struct {
 	RoomID   *int64 `json:"room_id,omitempty"`
@@ -80,4 +296,6 @@ type LoadHistoryPayload struct {
 type ErrorPayload struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// RetryAfterMs is set on rate limit errors: how long to wait before retrying.
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
 }
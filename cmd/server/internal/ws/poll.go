@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/poll"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+func (c *Client) dispatchPollVote(msg Message, ctx context.Context) {
+	var p PollVotePayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		c.logger.Warn("error while unmarshalling poll vote payload")
+		return
+	}
+
+	if err := c.votePoll(ctx, p, time.Now()); err != nil {
+		c.handlePollError(p.MessageID, err)
+	}
+}
+
+// votePoll replaces the client's votes on a poll of one of its rooms and
+// broadcasts the new tally to the room.
+func (c *Client) votePoll(ctx context.Context, p PollVotePayload, now time.Time) error {
+	pl, err := c.queries.GetPoll(ctx, p.MessageID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !c.roomIDs.has(pl.RoomID)) {
+		return fmt.Errorf("%w: poll not found", poll.ErrInvalid)
+	}
+	if err != nil {
+		return err
+	}
+	options, err := poll.CheckVote(pl, p.Options, now)
+	if err != nil {
+		return err
+	}
+	r, err := c.queries.GetRoomByID(ctx, pl.RoomID)
+	if err != nil {
+		return err
+	}
+	if r.Mode == room.ModeArchived {
+		return room.ErrRoomArchived
+	}
+
+	err = c.queries.SetPollVotes(ctx, dbstore.SetPollVotesParams{
+		MessageID: pl.MessageID,
+		UserID:    c.userID,
+		Options:   options,
+	})
+	if err != nil {
+		return err
+	}
+	votes, err := c.queries.ListPollVotesByMessageIDs(ctx, []int64{pl.MessageID})
+	if err != nil {
+		return err
+	}
+
+	t := poll.Count(pl, votes, c.userID)
+	msg, err := NewMessage(TypePollUpdated, PollUpdatedPayload{
+		MessageID:   pl.MessageID,
+		RoomID:      pl.RoomID,
+		Counts:      t.Counts,
+		Voters:      t.Voters,
+		TotalVoters: t.TotalVoters,
+	})
+	if err != nil {
+		return err
+	}
+	c.hub.BroadcastToRoom(pl.RoomID, msg)
+	return nil
+}
+
+// handlePollError reports why a vote was rejected to the voter.
+func (c *Client) handlePollError(messageID int64, err error) {
+	switch {
+	case errors.Is(err, poll.ErrInvalid):
+		c.sendError(ErrCodeInvalidPoll, err.Error())
+	case errors.Is(err, poll.ErrClosed):
+		c.sendError(ErrCodePollClosed, err.Error())
+	case errors.Is(err, room.ErrRoomArchived):
+		c.sendError(ErrCodeRoomArchived, err.Error())
+	default:
+		c.logger.Info("poll vote rejected", "message_id", messageID, "user_id", c.userID, "error", err)
+		c.sendError(ErrCodeInternal, "could not record vote")
+	}
+}
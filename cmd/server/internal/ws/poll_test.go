@@ -0,0 +1,164 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+)
+
+// Test 46 – a room message with a poll is stored as kind poll with its
+// sanitized options and broadcast with them
+func TestDispatchRoomMessage_Poll(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "/lunch?", Poll: &PollInfo{Options: []string{" pizza ", "tacos"}, Multiple: true}})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	pl, ok := store.polls[1]
+	if !ok || len(store.msgs) != 1 {
+		t.Fatalf("expected 1 persisted poll, got %d messages and %+v", len(store.msgs), store.polls)
+	}
+	if store.msgs[0].Body != "/lunch?" {
+		t.Fatalf("question = %q, want it untouched by command parsing", store.msgs[0].Body)
+	}
+	if !slices.Equal(pl.Options, []string{"pizza", "tacos"}) || !pl.Multiple {
+		t.Fatalf("stored poll = %+v", pl)
+	}
+
+	var got RoomMessagePayload
+	if err := json.Unmarshal(expectMessage(t, other.send).Payload, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Kind != MessageKindPoll || got.Poll == nil || got.Poll.Options[0] != "pizza" {
+		t.Fatalf("broadcast = %+v, want a poll", got)
+	}
+}
+
+// Test 47 – polls with too few or repeated options, a past deadline or
+// attachments get invalid_poll and are not stored
+func TestDispatchRoomMessage_InvalidPoll(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	past := time.Now().Add(-time.Minute)
+	cases := []RoomMessagePayload{
+		{RoomID: 10, Content: "q", Poll: &PollInfo{Options: []string{"only"}}},
+		{RoomID: 10, Content: "q", Poll: &PollInfo{Options: []string{"a", " a"}}},
+		{RoomID: 10, Content: "q", Poll: &PollInfo{Options: []string{"a", "b"}, ClosesAt: &past}},
+		{RoomID: 10, Content: "q", Poll: &PollInfo{Options: []string{"a", "b"}}, AttachmentIDs: []int64{5}},
+	}
+	for _, p := range cases {
+		payload, _ := json.Marshal(p)
+		c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+		syncHub(t, h, sync)
+
+		expectErrorCode(t, c.send, ErrCodeInvalidPoll)
+	}
+	expectNoMessage(t, other.send)
+	if len(store.msgs) != 0 {
+		t.Fatalf("expected no persisted messages, got %d", len(store.msgs))
+	}
+}
+
+// Test 48 – a vote replaces the voter's previous one and the room gets the
+// new tally
+func TestDispatchPollVote(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "lunch?", Poll: &PollInfo{Options: []string{"pizza", "tacos"}}})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+	expectMessage(t, c.send)
+	expectMessage(t, other.send)
+
+	for _, option := range []int{0, 1} {
+		vote, _ := json.Marshal(PollVotePayload{MessageID: 1, Options: []int{option}})
+		c.dispatchPollVote(Message{Type: TypePollVote, Payload: vote}, context.Background())
+		syncHub(t, h, sync)
+		expectMessage(t, c.send)
+
+		got := expectMessage(t, other.send)
+		if got.Type != TypePollUpdated {
+			t.Fatalf("expected %s frame, got %s", TypePollUpdated, got.Type)
+		}
+		var p PollUpdatedPayload
+		if err := json.Unmarshal(got.Payload, &p); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		want := []int{0, 0}
+		want[option] = 1
+		if p.RoomID != 10 || !slices.Equal(p.Counts, want) || p.TotalVoters != 1 {
+			t.Fatalf("tally = %+v, want counts %v", p, want)
+		}
+		if len(p.Voters[option]) != 1 || p.Voters[option][0] != "user1" {
+			t.Fatalf("voters = %v", p.Voters)
+		}
+	}
+}
+
+// Test 49 – votes for two options of a single choice poll get invalid_poll,
+// votes after the deadline get poll_closed and votes on polls of other rooms
+// are rejected; none is stored
+func TestDispatchPollVote_Rejected(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	store.rooms[20] = store.rooms[10]
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	closed := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+	store.polls = map[int64]dbstore.Poll{
+		1: {MessageID: 1, RoomID: 10, Options: []string{"a", "b"}},
+		2: {MessageID: 2, RoomID: 10, Options: []string{"a", "b"}, ClosesAt: closed},
+		3: {MessageID: 3, RoomID: 20, Options: []string{"a", "b"}},
+	}
+
+	cases := []struct {
+		vote PollVotePayload
+		code string
+	}{
+		{PollVotePayload{MessageID: 1, Options: []int{0, 1}}, ErrCodeInvalidPoll},
+		{PollVotePayload{MessageID: 1, Options: []int{2}}, ErrCodeInvalidPoll},
+		{PollVotePayload{MessageID: 2, Options: []int{0}}, ErrCodePollClosed},
+		{PollVotePayload{MessageID: 3, Options: []int{0}}, ErrCodeInvalidPoll},
+		{PollVotePayload{MessageID: 4, Options: []int{0}}, ErrCodeInvalidPoll},
+	}
+	for _, tc := range cases {
+		vote, _ := json.Marshal(tc.vote)
+		c.dispatchPollVote(Message{Type: TypePollVote, Payload: vote}, context.Background())
+		syncHub(t, h, sync)
+
+		expectErrorCode(t, c.send, tc.code)
+	}
+	expectNoMessage(t, other.send)
+	if len(store.votes) != 0 {
+		t.Fatalf("expected no stored votes, got %v", store.votes)
+	}
+}
@@ -0,0 +1,327 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/content"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/conversation"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/poll"
+	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
+)
+
+// Sender identifies who a message is posted as.
+type Sender struct {
+	ID       int64
+	Username string
+	IsBot    bool
+	// SessionID is the auth session of a WebSocket connection, 0 otherwise.
+	SessionID int64
+}
+
+// Errors returned when a room message is rejected. Content errors from
+// package content (ErrEmpty, ErrTooLong) and room mode errors from package
+// room are passed through as well.
+var (
+	ErrInvalidMessage     = errors.New("invalid room message")
+	ErrNotMember          = errors.New("not a member of this room")
+	ErrTooManyAttachments = fmt.Errorf("at most %d attachments per message", maxAttachmentsPerMessage)
+	ErrInvalidTTL         = fmt.Errorf("ttl_seconds must be between 0 and %d", conversation.MaxTTLSeconds)
+	// ErrDMNotAllowed covers blocks and the recipient's DM policy; it doesn't
+	// say which, so senders can't tell whether they were blocked.
+	ErrDMNotAllowed = errors.New("this user doesn't accept direct messages from you")
+)
+
+// RetryError is returned when a message is rejected by a rate limit or slow mode.
+type RetryError struct {
+	Code string
+	Wait time.Duration
+}
+
+func (e *RetryError) Error() string {
+	secs := int(e.Wait.Round(time.Second).Seconds())
+	if e.Code == ErrCodeSlowMode {
+		return fmt.Sprintf("slow mode is on, wait %ds", secs)
+	}
+	return fmt.Sprintf("too many messages, wait %ds", secs)
+}
+
+// PostRoomMessage posts content to a room as sender from outside a WebSocket
+// connection, e.g. an incoming webhook. It goes through the same rate limits,
+// validation, room mode checks, persistence and broadcast as a room_message frame.
+func (h *Hub) PostRoomMessage(ctx context.Context, s Store, l *slog.Logger, sender Sender, roomID int64, body string) (RoomMessagePayload, error) {
+	p := RoomMessagePayload{RoomID: roomID, Content: body}
+
+	if _, err := s.GetRoomMemberRole(ctx, dbstore.GetRoomMemberRoleParams{RoomID: roomID, UserID: sender.ID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return p, ErrNotMember
+		}
+		return p, err
+	}
+	if ok, wait := h.limiter.allowUser(sender.ID, TypeRoomMessage, time.Now()); !ok {
+		return p, &RetryError{Code: ErrCodeRateLimited, Wait: wait}
+	}
+
+	// a detached client: it has no connection, so nothing is ever sent to it
+	c := &Client{
+		hub:      h,
+		queries:  s,
+		userID:   sender.ID,
+		username: sender.Username,
+		isBot:    sender.IsBot,
+		roomIDs:  newRoomSet(map[int64]bool{roomID: true}),
+		logger:   l,
+	}
+	err := c.postRoomMessage(ctx, &p, time.Now().UTC())
+	return p, err
+}
+
+// PostDirectMessage sends a direct message as sender from outside a
+// WebSocket connection, e.g. a scheduled message. It goes through the same
+// rate limit, validation, DM permission check, persistence and delivery as a
+// direct_message frame.
+func (h *Hub) PostDirectMessage(ctx context.Context, s Store, l *slog.Logger, sender Sender, toUserID int64, body string) (DirectMessagePayload, error) {
+	p := DirectMessagePayload{ToUserID: toUserID, Content: body}
+
+	if ok, wait := h.limiter.allowUser(sender.ID, TypeDirectMessage, time.Now()); !ok {
+		return p, &RetryError{Code: ErrCodeRateLimited, Wait: wait}
+	}
+
+	// a detached client: it has no connection, so nothing is ever sent to it
+	c := &Client{
+		hub:      h,
+		queries:  s,
+		userID:   sender.ID,
+		username: sender.Username,
+		isBot:    sender.IsBot,
+		logger:   l,
+	}
+	err := c.postDirectMessage(ctx, &p, time.Now().UTC())
+	return p, err
+}
+
+// postRoomMessage validates p, enforces the room mode, persists it as the
+// client's user, forwards it to the event publisher and broadcasts it to the room.
+func (c *Client) postRoomMessage(ctx context.Context, p *RoomMessagePayload, ts time.Time) error {
+	//    - validar (roomID > 0, content no vacío, que el client sea miembro del room)
+	if p.RoomID == 0 || !c.roomIDs.has(p.RoomID) {
+		return ErrInvalidMessage
+	}
+	if p.ForwardedFrom != nil {
+		body, err := c.resolveForward(ctx, p.ForwardedFrom, p.Content, p.AttachmentIDs, p.QuoteMessageID)
+		if err != nil {
+			return err
+		}
+		p.Content = body
+	}
+	quote, err := c.resolveQuote(ctx, p.QuoteMessageID)
+	if err != nil {
+		return err
+	}
+	body, err := c.validateContent(p.Content, p.AttachmentIDs)
+	if errors.Is(err, content.ErrEmpty) {
+		return errors.Join(ErrInvalidMessage, err)
+	}
+	if err != nil {
+		return err
+	}
+	p.Content = body
+	if !validTTL(p.TTLSeconds) {
+		return ErrInvalidTTL
+	}
+	if p.Poll != nil {
+		if err := validatePoll(p); err != nil {
+			return err
+		}
+	}
+	//    - enforce the room mode (archived / announcement)
+	if err := c.checkCanPost(ctx, p.RoomID); err != nil {
+		return err
+	}
+	//    - persist to DB and broadcast
+	p.SenderID = c.userID
+	p.SenderUsername = c.username
+	p.SenderIsBot = c.isBot
+	p.Quote = quote
+	if p.Poll != nil {
+		p.Kind = MessageKindPoll
+	} else if p.Kind == "" {
+		p.Kind = MessageKindText
+	}
+
+	params := dbstore.CreateMessageParams{
+		RoomID:         pgtype.Int8{Int64: p.RoomID, Valid: true},
+		SenderID:       c.userID,
+		Body:           p.Content,
+		Kind:           p.Kind,
+		QuoteMessageID: optionalID(p.QuoteMessageID),
+	}
+	params.ForwardedFromID, params.ForwardedFromUserID, params.ForwardedFromRoomID = forwardParams(p.ForwardedFrom)
+	if p.TTLSeconds > 0 {
+		params.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(time.Duration(p.TTLSeconds) * time.Second), Valid: true}
+	}
+	var dbMsg dbstore.Message
+	if p.Poll != nil {
+		dbMsg, err = c.queries.CreatePollMessage(ctx, pollParams(params, p.Poll))
+	} else {
+		dbMsg, err = c.queries.CreateMessage(ctx, params)
+	}
+	if err != nil {
+		c.logger.Warn("failed to persist room message", "error", err)
+	} else {
+		p.MessageID = dbMsg.ID
+		p.ExpiresAt = expiresAt(dbMsg)
+		p.Attachments = c.linkAttachments(ctx, dbMsg.ID, p.AttachmentIDs)
+	}
+	p.AttachmentIDs = nil
+	if p.MessageID != 0 {
+		c.hub.PublishRoomEvent(ctx, p.RoomID, webhook.EventMessageCreated, *p)
+	}
+
+	completePayload, err := json.Marshal(p)
+	if err != nil {
+		c.logger.Warn("error while marshalling complete msg payload")
+		return nil
+	}
+	c.hub.broadcast <- BroadcastMsg{
+		msg:          Message{Type: TypeRoomMessage, Payload: completePayload, Timestamp: ts},
+		targetRoomID: p.RoomID,
+	}
+	return nil
+}
+
+// validatePoll sanitizes the poll of p. Polls carry no attachments, forward
+// or quote.
+func validatePoll(p *RoomMessagePayload) error {
+	if len(p.AttachmentIDs) > 0 {
+		return fmt.Errorf("%w: a poll can't have attachments", poll.ErrInvalid)
+	}
+	if p.ForwardedFrom != nil || p.QuoteMessageID != 0 {
+		return fmt.Errorf("%w: a poll can't forward or quote a message", poll.ErrInvalid)
+	}
+	options, err := poll.ValidateOptions(p.Poll.Options)
+	if err != nil {
+		return err
+	}
+	if err := poll.ValidateDeadline(p.Poll.ClosesAt, time.Now()); err != nil {
+		return err
+	}
+	p.Poll.Options = options
+	if p.Poll.ClosesAt != nil {
+		t := p.Poll.ClosesAt.UTC()
+		p.Poll.ClosesAt = &t
+	}
+	return nil
+}
+
+// pollParams extends the params of a room message with its poll.
+func pollParams(m dbstore.CreateMessageParams, p *PollInfo) dbstore.CreatePollMessageParams {
+	params := dbstore.CreatePollMessageParams{
+		RoomID:    m.RoomID,
+		SenderID:  m.SenderID,
+		Body:      m.Body,
+		ExpiresAt: m.ExpiresAt,
+		Options:   p.Options,
+		Multiple:  p.Multiple,
+		Anonymous: p.Anonymous,
+	}
+	if p.ClosesAt != nil {
+		params.ClosesAt = pgtype.Timestamptz{Time: *p.ClosesAt, Valid: true}
+	}
+	return params
+}
+
+// validTTL reports whether ttl_seconds is within what a message may ask for;
+// zero means no TTL of its own.
+func validTTL(secs int32) bool {
+	return secs >= 0 && secs <= conversation.MaxTTLSeconds
+}
+
+// expiresAt returns when a stored message disappears, nil if it doesn't.
+func expiresAt(m dbstore.Message) *time.Time {
+	if !m.ExpiresAt.Valid {
+		return nil
+	}
+	t := m.ExpiresAt.Time.UTC()
+	return &t
+}
+
+// postDirectMessage validates p, checks that the recipient takes DMs from
+// the client's user, persists it and delivers it to both ends.
+func (c *Client) postDirectMessage(ctx context.Context, p *DirectMessagePayload, ts time.Time) error {
+	if p.ForwardedFrom != nil {
+		body, err := c.resolveForward(ctx, p.ForwardedFrom, p.Content, p.AttachmentIDs, p.QuoteMessageID)
+		if err != nil {
+			return err
+		}
+		p.Content = body
+	}
+	quote, err := c.resolveQuote(ctx, p.QuoteMessageID)
+	if err != nil {
+		return err
+	}
+	body, err := c.validateContent(p.Content, p.AttachmentIDs)
+	if p.ToUserID == 0 || errors.Is(err, content.ErrEmpty) {
+		return ErrInvalidMessage
+	}
+	if err != nil {
+		return err
+	}
+	p.Content = body
+	if !validTTL(p.TTLSeconds) {
+		return ErrInvalidTTL
+	}
+
+	allowed, err := c.queries.CanDirectMessage(ctx, dbstore.CanDirectMessageParams{
+		RecipientID: p.ToUserID,
+		SenderID:    c.userID,
+	})
+	if err != nil {
+		return fmt.Errorf("check dm permission: %w", err)
+	}
+	if !allowed {
+		return ErrDMNotAllowed
+	}
+
+	p.SenderID = c.userID
+	p.SenderUsername = c.username
+	p.SenderIsBot = c.isBot
+	p.Quote = quote
+
+	params := dbstore.CreateDirectMessageParams{
+		SenderID:       c.userID,
+		ToUserID:       p.ToUserID,
+		Body:           p.Content,
+		TtlSeconds:     p.TTLSeconds,
+		QuoteMessageID: optionalID(p.QuoteMessageID),
+	}
+	params.ForwardedFromID, params.ForwardedFromUserID, params.ForwardedFromRoomID = forwardParams(p.ForwardedFrom)
+	dbMsg, err := c.queries.CreateDirectMessage(ctx, params)
+	if err != nil {
+		c.logger.Warn("failed to persist dm message", "error", err)
+	} else {
+		p.MessageID = dbMsg.ID
+		p.ConversationID = dbMsg.ConversationID.Int64
+		p.ExpiresAt = expiresAt(dbMsg)
+		p.Attachments = c.linkAttachments(ctx, dbMsg.ID, p.AttachmentIDs)
+	}
+	p.AttachmentIDs = nil
+
+	completePayload, err := json.Marshal(p)
+	if err != nil {
+		c.logger.Warn("error while marshalling complete msg payload")
+		return nil
+	}
+	c.hub.broadcast <- BroadcastMsg{
+		msg:           Message{Type: TypeDirectMessage, Payload: completePayload, Timestamp: ts},
+		targetUserIDs: []int64{c.userID, p.ToUserID},
+	}
+	return nil
+}
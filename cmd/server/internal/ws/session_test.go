@@ -0,0 +1,67 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+// Test 36 – DisconnectSession drops only the connections of that session,
+// and the later unregister from their ReadPump doesn't panic
+func TestHub_DisconnectSession(t *testing.T) {
+	h := startHub(t)
+	revoked := newTestClient(h, 1, map[int64]bool{10: true})
+	revoked.session = 5
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	other.session = 6
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, revoked, other, sync)
+
+	h.DisconnectSession(5)
+	syncHub(t, h, sync)
+
+	select {
+	case _, ok := <-revoked.send:
+		if ok {
+			t.Fatal("expected the revoked client's channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("revoked client was not disconnected")
+	}
+
+	h.BroadcastToRoom(10, Message{Type: TypeRoomMessage})
+	syncHub(t, h, sync)
+	expectMessage(t, other.send)
+
+	h.unregister <- revoked
+	syncHub(t, h, sync) // would hang if the second close panicked
+}
+
+// Test 39 – DisconnectUser drops the deactivated user's connection and
+// nobody else's
+func TestHub_DisconnectUser(t *testing.T) {
+	h := startHub(t)
+	gone := newTestClient(h, 1, map[int64]bool{10: true})
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, gone, other, sync)
+
+	h.DisconnectUser(1)
+	h.DisconnectUser(42) // not connected, nothing to do
+	syncHub(t, h, sync)
+
+	select {
+	case _, ok := <-gone.send:
+		if ok {
+			t.Fatal("expected the deactivated user's channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deactivated user was not disconnected")
+	}
+
+	h.BroadcastToRoom(10, Message{Type: TypeRoomMessage})
+	syncHub(t, h, sync)
+	expectMessage(t, other.send)
+
+	h.unregister <- gone
+	syncHub(t, h, sync)
+}
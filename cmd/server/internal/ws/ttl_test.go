@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sleklere/realtime-chat/cmd/server/internal/conversation"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+)
+
+// Test 43 – a room message with ttl_seconds is stored with expires_at and
+// broadcast with it
+func TestDispatchRoomMessage_TTL(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	before := time.Now()
+	payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "gone soon", TTLSeconds: 60})
+	c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	if len(store.msgs) != 1 {
+		t.Fatalf("expected 1 persisted message, got %d", len(store.msgs))
+	}
+	exp := store.msgs[0].ExpiresAt
+	if !exp.Valid || exp.Time.Before(before.Add(59*time.Second)) || exp.Time.After(time.Now().Add(61*time.Second)) {
+		t.Fatalf("expires_at = %+v, want about a minute from now", exp)
+	}
+
+	var got RoomMessagePayload
+	if err := json.Unmarshal(expectMessage(t, other.send).Payload, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(exp.Time) {
+		t.Fatalf("broadcast expires_at = %v, want %v", got.ExpiresAt, exp.Time)
+	}
+}
+
+// Test 44 – a ttl_seconds out of range gets invalid_ttl and is not stored
+func TestDispatchRoomMessage_InvalidTTL(t *testing.T) {
+	h := startHub(t)
+	store := newFakeStore(room.ModeNormal, map[int64]string{1: room.RoleMember})
+	c := newTestClient(h, 1, map[int64]bool{10: true})
+	c.queries = store
+	other := newTestClient(h, 2, map[int64]bool{10: true})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, c, other, sync)
+
+	for _, ttl := range []int32{-1, conversation.MaxTTLSeconds + 1} {
+		payload, _ := json.Marshal(RoomMessagePayload{RoomID: 10, Content: "hi", TTLSeconds: ttl})
+		c.dispatchRoomMessage(Message{Type: TypeRoomMessage, Payload: payload}, context.Background())
+		syncHub(t, h, sync)
+
+		expectErrorCode(t, c.send, ErrCodeInvalidTTL)
+	}
+	expectNoMessage(t, other.send)
+	if len(store.msgs) != 0 {
+		t.Fatalf("expected no persisted messages, got %d", len(store.msgs))
+	}
+}
+
+// Test 45 – a DM passes its ttl_seconds to the store, which applies the
+// conversation default for zero, and both ends learn expires_at and the
+// conversation
+func TestDispatchDirectMessage_TTL(t *testing.T) {
+	h := startHub(t)
+	store := &fakeStore{}
+	sender := newTestClient(h, 1, map[int64]bool{})
+	sender.queries = store
+	recipient := newTestClient(h, 2, map[int64]bool{})
+	sync := newTestClient(h, 99, map[int64]bool{})
+	registerAll(t, h, sender, recipient, sync)
+
+	payload, _ := json.Marshal(DirectMessagePayload{ToUserID: 2, Content: "psst", TTLSeconds: 30})
+	sender.dispatchDirectMessage(Message{Type: TypeDirectMessage, Payload: payload}, context.Background())
+	syncHub(t, h, sync)
+
+	if len(store.dms) != 1 || store.dms[0].TtlSeconds != 30 {
+		t.Fatalf("stored dms = %+v, want one with ttl 30", store.dms)
+	}
+	for _, c := range []*Client{sender, recipient} {
+		var got DirectMessagePayload
+		if err := json.Unmarshal(expectMessage(t, c.send).Payload, &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.ExpiresAt == nil || got.ConversationID != 7 {
+			t.Fatalf("user %d got expires_at %v, conversation %d", c.userID, got.ExpiresAt, got.ConversationID)
+		}
+	}
+}
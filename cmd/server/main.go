@@ -3,22 +3,35 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/admin"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/api"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/attachment"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/audit"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/auth"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/blob"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/bot"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/conversation"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/db"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/mail"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/retention"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/room"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/schedule"
 	dbstore "github.com/sleklere/realtime-chat/cmd/server/internal/store"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/user"
+	"github.com/sleklere/realtime-chat/cmd/server/internal/webhook"
 	"github.com/sleklere/realtime-chat/cmd/server/internal/ws"
 )
 
@@ -46,34 +59,143 @@ func main() {
 		log.Fatalf("err pinging pool: %v", err)
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
-	}
-
 	authCfg := &auth.Config{
-		JWTSecret: []byte(jwtSecret),
-		Issuer:    "realtime-chat",
-		AccessTTL: 15 * time.Minute,
+		JWTSecret:  []byte(os.Getenv("JWT_SECRET")),
+		Issuer:     "realtime-chat",
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 30 * 24 * time.Hour,
+		Login:      auth.DefaultLoginLimits(),
+		Policy:     auth.DefaultPolicy(),
+		ResetTTL:   time.Hour,
+		ResetURL:   os.Getenv("PASSWORD_RESET_URL"),
+	}
+	if v := os.Getenv("ACCOUNT_DELETION_GRACE"); v != "" {
+		grace, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("ACCOUNT_DELETION_GRACE must be a duration: %v", err)
+		}
+		authCfg.DeletionGrace = grace
+	}
+	authCfg.Policy.PasswordMinLen = getenvInt("PASSWORD_MIN_LENGTH", authCfg.Policy.PasswordMinLen)
+	authCfg.Policy.PasswordMinClasses = getenvInt("PASSWORD_MIN_CLASSES", authCfg.Policy.PasswordMinClasses)
+	if v := os.Getenv("RESERVED_USERNAMES"); v != "" {
+		authCfg.Policy.ReservedUsernames = strings.Split(strings.ReplaceAll(v, " ", ""), ",")
+	}
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		keys, err := auth.LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+		if err != nil {
+			log.Fatalf("loading JWT keys: %v", err)
+		}
+		authCfg.Keys = keys
+	}
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		publicURL := strings.TrimSuffix(getenv("PUBLIC_URL", "http://localhost:"+getenv("PORT", "8080")), "/")
+		authCfg.OIDC = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  publicURL + "/api/v1/auth/oidc/callback",
+			LoginURL:     publicURL + "/api/v1/auth/oidc/login",
+			AllowSignup:  getenv("OIDC_ALLOW_SIGNUP", "true") == "true",
+		})
+	}
+	if authCfg.Keys == nil && len(authCfg.JWTSecret) == 0 {
+		log.Fatal("JWT_SECRET or JWT_KEYS_DIR environment variable is required")
 	}
 	queries := dbstore.New(pool)
-	authSvc := auth.NewService(queries, logger, authCfg)
 	roomSvc := room.NewService(queries, logger)
 	userSvc := user.NewService(queries, logger)
 	convSvc := conversation.NewService(queries, logger)
+
+	uploadCfg := attachment.DefaultConfig()
+	uploadCfg.MaxBytes = int64(getenvInt("UPLOAD_MAX_BYTES", int(uploadCfg.MaxBytes)))
+	blobs := newBlobStore()
+	attachmentSvc := attachment.NewService(queries, blobs, uploadCfg, logger)
+
+	limits := ws.DefaultLimits()
+	limits.MaxFrameBytes = int64(getenvInt("WS_MAX_FRAME_BYTES", int(limits.MaxFrameBytes)))
+	limits.MaxMessageRunes = getenvInt("WS_MAX_MESSAGE_RUNES", limits.MaxMessageRunes)
+
+	// background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	webhookCfg := webhook.DefaultWorkerConfig()
+	webhookCfg.AllowPrivate = getenv("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+	webhookWorker := webhook.NewWorker(queries, nil, webhookCfg, logger)
+	webhookSvc := webhook.NewService(queries, webhookWorker, logger)
+	go webhookWorker.Run(workerCtx)
+
+	// anonymizes accounts once their deletion grace period is over
+	purger := user.NewPurger(queries, blobs, user.DefaultPurgerConfig(), logger)
+	go purger.Run(workerCtx)
+
+	// deletes audit events older than AUDIT_RETENTION
+	auditCfg := audit.DefaultPrunerConfig()
+	if v := os.Getenv("AUDIT_RETENTION"); v != "" {
+		retention, err := time.ParseDuration(v)
+		if err != nil || retention <= 0 {
+			log.Fatalf("AUDIT_RETENTION must be a positive duration: %v", err)
+		}
+		auditCfg.Retention = retention
+	}
+	auditPruner := audit.NewPruner(queries, auditCfg, logger)
+	go auditPruner.Run(workerCtx)
+
+	// deletes messages past their room's retention or MESSAGE_RETENTION; the
+	// advisory lock keeps replicas from running it at the same time
+	retentionCfg := retention.DefaultConfig()
+	if v := os.Getenv("MESSAGE_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("MESSAGE_RETENTION must be a duration: %v", err)
+		}
+		retentionCfg.Default = d
+	}
+	retentionCfg.DryRun = getenv("MESSAGE_RETENTION_DRY_RUN", "false") == "true"
+	janitor := retention.NewJanitor(queries, blobs, func(ctx context.Context) (func(), bool, error) {
+		return db.TryAdvisoryLock(ctx, pool, retention.LockKey)
+	}, retentionCfg, logger)
+	expvar.Publish("retention", janitor.Metrics())
+	go janitor.Run(workerCtx)
+
 	hub := ws.NewHub()
+	hub.SetLimits(limits)
+	hub.SetEventPublisher(webhookSvc)
 	go hub.Run()
 
+	// deletes disappearing messages once they expire
+	sweeper := retention.NewSweeper(queries, hub, blobs, retention.DefaultSweeperConfig(), logger)
+	go sweeper.Run(workerCtx)
+
+	// sends scheduled messages once they are due; SKIP LOCKED lets every
+	// replica run it without sending a message twice
+	scheduleCfg := schedule.DefaultConfig()
+	scheduleCfg.MaxRunes = limits.MaxMessageRunes
+	scheduleSvc := schedule.NewService(queries, scheduleCfg, logger)
+	scheduler := schedule.NewScheduler(queries, hub, schedule.DefaultSchedulerConfig(), logger)
+	go scheduler.Run(workerCtx)
+
+	authSvc := auth.NewService(queries, hub, newMailer(logger), logger, authCfg)
+	adminSvc := admin.NewService(queries, hub, blobs, logger)
+	botSvc := bot.NewService(queries, hub, logger)
+
 	a := &api.API{
-		Logger:     logger,
-		AuthConfig: authCfg,
-		Queries:    queries,
-		Hub:        hub,
+		Logger:         logger,
+		AuthConfig:     authCfg,
+		Queries:        queries,
+		Hub:            hub,
+		TrustedProxies: trustedProxies(os.Getenv("TRUSTED_PROXIES")),
 
 		AuthService:         authSvc,
 		RoomService:         roomSvc,
 		UserService:         userSvc,
 		ConversationService: convSvc,
+		AttachmentService:   attachmentSvc,
+		WebhookService:      webhookSvc,
+		BotService:          botSvc,
+		AdminService:        adminSvc,
+		ScheduleService:     scheduleSvc,
 	}
 
 	addr := ":" + getenv("PORT", "8080")
@@ -98,15 +220,94 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
 }
 
+// newBlobStore builds the attachment blob store selected by BLOB_BACKEND.
+func newBlobStore() attachment.BlobStore {
+	switch backend := getenv("BLOB_BACKEND", "local"); backend {
+	case "local":
+		b, err := blob.NewLocal(getenv("BLOB_DIR", "data/uploads"))
+		if err != nil {
+			log.Fatalf("local blob store: %v", err)
+		}
+		return b
+	case "s3":
+		cfg := blob.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET are required when BLOB_BACKEND=s3")
+		}
+		return blob.NewS3(cfg, &http.Client{Timeout: 2 * time.Minute})
+	default:
+		log.Fatalf("unknown BLOB_BACKEND %q (want local or s3)", backend)
+		return nil
+	}
+}
+
+// newMailer builds the mailer selected by MAIL_BACKEND.
+func newMailer(logger *slog.Logger) mail.Mailer {
+	switch backend := getenv("MAIL_BACKEND", "log"); backend {
+	case "log":
+		return mail.NewLogMailer(logger)
+	case "file":
+		m, err := mail.NewFileMailer(getenv("MAIL_DIR", "data/mail"))
+		if err != nil {
+			log.Fatalf("file mailer: %v", err)
+		}
+		return m
+	default:
+		log.Fatalf("unknown MAIL_BACKEND %q (want log or file)", backend)
+		return nil
+	}
+}
+
+// trustedProxies parses TRUSTED_PROXIES, a comma separated list of
+// addresses or CIDR ranges of the reverse proxies in front of the server.
+func trustedProxies(v string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				log.Fatalf("TRUSTED_PROXIES: %q is not an address or CIDR range", s)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes
+}
+
 func getenv(k, def string) string {
 	if v := os.Getenv(k); v != "" {
 		return v
 	}
 	return def
 }
+
+func getenvInt(k string, def int) int {
+	v := os.Getenv(k)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %v", k, err)
+	}
+	return n
+}
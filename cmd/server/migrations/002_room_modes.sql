@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- normal: todos los miembros publican; announcement: solo moderadores; archived: nadie publica
+ALTER TABLE rooms
+  ADD COLUMN mode TEXT NOT NULL DEFAULT 'normal'
+  CONSTRAINT rooms_mode_valid CHECK (mode IN ('normal', 'announcement', 'archived'));
+
+ALTER TABLE room_members
+  ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+  CONSTRAINT room_members_role_valid CHECK (role IN ('member', 'moderator', 'owner'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE room_members DROP COLUMN IF EXISTS role;
+ALTER TABLE rooms DROP COLUMN IF EXISTS mode;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- slow mode: cada miembro puede enviar un mensaje cada N segundos (0 = desactivado)
+ALTER TABLE rooms
+  ADD COLUMN slow_mode_seconds INTEGER NOT NULL DEFAULT 0
+  CONSTRAINT rooms_slow_mode_non_negative CHECK (slow_mode_seconds >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE rooms DROP COLUMN IF EXISTS slow_mode_seconds;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- archivos subidos; message_id queda NULL hasta que se adjuntan a un mensaje
+CREATE TABLE attachments (
+  id           BIGSERIAL PRIMARY KEY,
+  uploader_id  BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  message_id   BIGINT REFERENCES messages(id) ON DELETE CASCADE,
+  storage_key  TEXT UNIQUE NOT NULL,
+  filename     TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes   BIGINT NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_attachments_message_id ON attachments (message_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_attachments_message_id;
+DROP TABLE IF EXISTS attachments;
+-- +goose StatementEnd
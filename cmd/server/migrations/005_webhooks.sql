@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- suscripciones salientes por room; el secret firma cada entrega (HMAC-SHA256)
+CREATE TABLE webhooks (
+  id          BIGSERIAL PRIMARY KEY,
+  room_id     BIGINT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+  url         TEXT NOT NULL,
+  secret      TEXT NOT NULL,
+  event_types TEXT[] NOT NULL,
+  created_by  BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhooks_room_id ON webhooks (room_id);
+
+-- cola de entregas y log a la vez: cada evento genera una fila por webhook
+CREATE TABLE webhook_deliveries (
+  id               BIGSERIAL PRIMARY KEY,
+  webhook_id       BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  event_type       TEXT NOT NULL,
+  payload          JSONB NOT NULL,
+  status           TEXT NOT NULL DEFAULT 'pending',
+  attempts         INT NOT NULL DEFAULT 0,
+  next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_status_code INT,
+  last_error       TEXT,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  delivered_at     TIMESTAMPTZ,
+  CONSTRAINT webhook_deliveries_status CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS idx_webhooks_room_id;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- bots: usuarios sin password que se autentican con tokens de larga duración
+ALTER TABLE users ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN owner_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+
+-- solo se guarda el sha256 del token
+CREATE TABLE bot_tokens (
+  id         BIGSERIAL PRIMARY KEY,
+  bot_id     BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash BYTEA UNIQUE NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  revoked_at TIMESTAMPTZ
+);
+
+-- webhooks entrantes: publican en un room como un bot dedicado
+CREATE TABLE incoming_webhooks (
+  id         BIGSERIAL PRIMARY KEY,
+  room_id    BIGINT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+  bot_id     BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash BYTEA UNIQUE NOT NULL,
+  created_by BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_bot_tokens_bot_id ON bot_tokens (bot_id);
+CREATE INDEX idx_incoming_webhooks_room_id ON incoming_webhooks (room_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_incoming_webhooks_room_id;
+DROP INDEX IF EXISTS idx_bot_tokens_bot_id;
+DROP TABLE IF EXISTS incoming_webhooks;
+DROP TABLE IF EXISTS bot_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
+-- +goose StatementEnd
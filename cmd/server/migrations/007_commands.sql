@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- tema de la sala, lo cambia /topic
+ALTER TABLE rooms ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+
+-- tipo de mensaje: 'text' normal o 'action' para /me
+ALTER TABLE messages
+  ADD COLUMN kind TEXT NOT NULL DEFAULT 'text'
+  CONSTRAINT messages_kind_check CHECK (kind IN ('text', 'action'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages DROP COLUMN IF EXISTS kind;
+ALTER TABLE rooms DROP COLUMN IF EXISTS topic;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- administradores de la instancia; se asigna a mano en la base
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- intentos fallidos de login por usuario ('user') y por IP ('ip').
+-- subject es el username en minúsculas, exista o no, para no filtrar qué cuentas existen.
+CREATE TABLE login_attempts (
+  scope           TEXT NOT NULL CHECK (scope IN ('user', 'ip')),
+  subject         TEXT NOT NULL,
+  failures        INT NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  blocked_until   TIMESTAMPTZ,
+  PRIMARY KEY (scope, subject)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_attempts;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- TOTP (RFC 6238). enabled_at queda en NULL hasta que se verifica el primer código.
+-- last_step es el último paso de 30s aceptado, para no aceptar el mismo código dos veces.
+CREATE TABLE user_totp (
+  user_id    BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret     TEXT NOT NULL,
+  enabled_at TIMESTAMPTZ,
+  last_step  BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- códigos de recuperación de un solo uso, solo el sha256
+CREATE TABLE recovery_codes (
+  id         BIGSERIAL PRIMARY KEY,
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash  BYTEA NOT NULL,
+  used_at    TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
+-- +goose StatementEnd
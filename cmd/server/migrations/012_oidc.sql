@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- cuentas externas (OIDC) vinculadas a un usuario, por issuer + sub
+CREATE TABLE user_identities (
+  id            BIGSERIAL PRIMARY KEY,
+  user_id       BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer        TEXT NOT NULL,
+  subject       TEXT NOT NULL,
+  email         TEXT,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_login_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- logins OIDC en curso. state, nonce y code_verifier (PKCE) se usan una vez.
+-- Los logins de dispositivo (TUI) tienen además device_code (solo el sha256)
+-- y un user_code corto que el usuario abre en el navegador; user_id se
+-- completa cuando vuelve el callback del proveedor.
+CREATE TABLE oidc_logins (
+  id               BIGSERIAL PRIMARY KEY,
+  state            TEXT NOT NULL UNIQUE,
+  nonce            TEXT NOT NULL,
+  code_verifier    TEXT NOT NULL,
+  device_code_hash BYTEA UNIQUE,
+  user_code        TEXT UNIQUE,
+  user_id          BIGINT REFERENCES users(id) ON DELETE CASCADE,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at       TIMESTAMPTZ NOT NULL,
+  state_used_at    TIMESTAMPTZ,
+  consumed_at      TIMESTAMPTZ
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oidc_logins;
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP TABLE IF EXISTS user_identities;
+-- +goose StatementEnd
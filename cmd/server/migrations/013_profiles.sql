@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- perfil público del usuario. Una fila se crea con el primer PATCH; sin fila,
+-- todos los campos valen ''. El avatar es un upload propio o una URL externa,
+-- nunca ambos.
+CREATE TABLE user_profiles (
+  user_id              BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  display_name         TEXT NOT NULL DEFAULT '',
+  bio                  TEXT NOT NULL DEFAULT '',
+  avatar_attachment_id BIGINT REFERENCES attachments(id) ON DELETE SET NULL,
+  avatar_url           TEXT NOT NULL DEFAULT '',
+  status_text          TEXT NOT NULL DEFAULT '',
+  status_emoji         TEXT NOT NULL DEFAULT '',
+  updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_profiles;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- búsqueda de usuarios: prefijo con LIKE y parecido con trigramas (pg_trgm)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_users_username_prefix ON users (lower(username) text_pattern_ops);
+CREATE INDEX idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
+CREATE INDEX idx_user_profiles_display_name_prefix ON user_profiles (lower(display_name) text_pattern_ops);
+CREATE INDEX idx_user_profiles_display_name_trgm ON user_profiles USING gin (display_name gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_profiles_display_name_trgm;
+DROP INDEX IF EXISTS idx_user_profiles_display_name_prefix;
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_username_prefix;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- usuarios bloqueados: el bloqueado no puede mandarle DMs al que bloquea (ni al revés)
+-- y no aparecen en la búsqueda del otro
+CREATE TABLE user_blocks (
+  blocker_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  blocked_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (blocker_id, blocked_id),
+  CONSTRAINT user_blocks_distinct CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+-- quién puede abrir un DM: todos, quienes comparten un room, nadie
+ALTER TABLE user_profiles
+  ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone'
+  CHECK (dm_policy IN ('everyone', 'rooms', 'nobody'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_profiles DROP COLUMN IF EXISTS dm_policy;
+DROP TABLE IF EXISTS user_blocks;
+-- +goose StatementEnd
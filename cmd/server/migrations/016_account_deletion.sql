@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deactivated_at: la cuenta no puede entrar (borrado pedido o desactivada por un admin).
+-- delete_after: fin del período de gracia de un borrado pedido por el usuario;
+-- loguearse antes lo cancela.
+-- deleted_at: la cuenta ya se anonimizó; la fila queda como "deleted user"
+-- para que sus mensajes y conversaciones no se pierdan.
+ALTER TABLE users
+  ADD COLUMN deactivated_at TIMESTAMPTZ,
+  ADD COLUMN delete_after   TIMESTAMPTZ,
+  ADD COLUMN deleted_at     TIMESTAMPTZ;
+
+CREATE INDEX idx_users_delete_after ON users (delete_after) WHERE delete_after IS NOT NULL;
+
+-- los usuarios ya no se borran: que un DELETE por error falle en vez de
+-- llevarse mensajes y conversaciones enteras
+ALTER TABLE messages
+  DROP CONSTRAINT messages_sender_id_fkey,
+  ADD CONSTRAINT messages_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE conversations
+  DROP CONSTRAINT conversations_user_a_fkey,
+  ADD CONSTRAINT conversations_user_a_fkey FOREIGN KEY (user_a) REFERENCES users(id) ON DELETE RESTRICT,
+  DROP CONSTRAINT conversations_user_b_fkey,
+  ADD CONSTRAINT conversations_user_b_fkey FOREIGN KEY (user_b) REFERENCES users(id) ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE conversations
+  DROP CONSTRAINT conversations_user_b_fkey,
+  ADD CONSTRAINT conversations_user_b_fkey FOREIGN KEY (user_b) REFERENCES users(id) ON DELETE CASCADE,
+  DROP CONSTRAINT conversations_user_a_fkey,
+  ADD CONSTRAINT conversations_user_a_fkey FOREIGN KEY (user_a) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE messages
+  DROP CONSTRAINT messages_sender_id_fkey,
+  ADD CONSTRAINT messages_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_users_delete_after;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS deleted_at,
+  DROP COLUMN IF EXISTS delete_after,
+  DROP COLUMN IF EXISTS deactivated_at;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- log de eventos de seguridad y moderación. Sin foreign keys a propósito:
+-- el registro tiene que sobrevivir a los usuarios, rooms y mensajes que nombra.
+-- actor_id es NULL cuando nadie estaba logueado (p. ej. un login fallido).
+CREATE TABLE audit_events (
+  id          BIGSERIAL PRIMARY KEY,
+  action      TEXT NOT NULL,
+  actor_id    BIGINT,
+  target_type TEXT NOT NULL DEFAULT '',
+  target_id   BIGINT,
+  ip          TEXT NOT NULL DEFAULT '',
+  request_id  TEXT NOT NULL DEFAULT '',
+  details     JSONB NOT NULL DEFAULT '{}',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id, id);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id, id);
+CREATE INDEX idx_audit_events_action ON audit_events (action, id);
+
+-- append-only: las filas no se modifican nunca, solo las borra el job de retención
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+  BEFORE UPDATE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- retención de mensajes por room, en días. NULL usa el default del servidor
+-- (MESSAGE_RETENTION); 0 guarda el historial para siempre.
+ALTER TABLE rooms
+  ADD COLUMN retention_days INTEGER
+  CONSTRAINT rooms_retention_days_non_negative CHECK (retention_days >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE rooms DROP COLUMN IF EXISTS retention_days;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- mensajes efímeros: pasado expires_at dejan de aparecer en el historial y
+-- el sweeper los borra. NULL es un mensaje normal.
+ALTER TABLE messages ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL;
+
+-- TTL por defecto de los DMs de una conversación, en segundos; 0 no expira.
+-- Un ttl_seconds explícito en el mensaje le gana.
+ALTER TABLE conversations
+  ADD COLUMN default_ttl_seconds INTEGER NOT NULL DEFAULT 0
+  CONSTRAINT conversations_default_ttl_non_negative CHECK (default_ttl_seconds >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE conversations DROP COLUMN IF EXISTS default_ttl_seconds;
+DROP INDEX IF EXISTS idx_messages_expires_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
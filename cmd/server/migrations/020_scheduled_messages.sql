@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- mensajes escritos ahora para mandarse en send_at, a un room o por DM.
+-- El scheduler los toma con SKIP LOCKED y los marca 'sent' antes de
+-- publicarlos, así ningún mensaje sale dos veces aunque haya varias réplicas.
+CREATE TABLE scheduled_messages (
+  id          BIGSERIAL PRIMARY KEY,
+  sender_id   BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  room_id     BIGINT REFERENCES rooms(id) ON DELETE CASCADE,
+  to_user_id  BIGINT REFERENCES users(id) ON DELETE CASCADE,
+  body        TEXT NOT NULL,
+  send_at     TIMESTAMPTZ NOT NULL,
+  status      TEXT NOT NULL DEFAULT 'pending',
+  message_id  BIGINT,
+  last_error  TEXT,
+  sent_at     TIMESTAMPTZ,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT scheduled_messages_one_target CHECK ((room_id IS NULL) <> (to_user_id IS NULL)),
+  CONSTRAINT scheduled_messages_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+CREATE INDEX idx_scheduled_messages_due ON scheduled_messages (send_at) WHERE status = 'pending';
+CREATE INDEX idx_scheduled_messages_sender ON scheduled_messages (sender_id, send_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_scheduled_messages_sender;
+DROP INDEX IF EXISTS idx_scheduled_messages_due;
+DROP TABLE IF EXISTS scheduled_messages;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- mensajes fijados por moderadores; un mensaje se fija una sola vez y la fila
+-- se va con el mensaje (borrado, expirado o por retención)
+CREATE TABLE pinned_messages (
+  message_id BIGINT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+  room_id    BIGINT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+  pinned_by  BIGINT NOT NULL REFERENCES users(id),
+  pinned_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_pinned_messages_room ON pinned_messages (room_id, pinned_at DESC);
+
+-- mensajes guardados: una lista privada de cada usuario
+CREATE TABLE saved_messages (
+  user_id    BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  message_id BIGINT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+  saved_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX idx_saved_messages_user ON saved_messages (user_id, saved_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS saved_messages;
+DROP TABLE IF EXISTS pinned_messages;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE messages
+  DROP CONSTRAINT messages_kind_check,
+  ADD CONSTRAINT messages_kind_check CHECK (kind IN ('text', 'action', 'poll'));
+
+-- encuestas: el body del mensaje es la pregunta; room_id repetido para
+-- validar votos sin pasar por messages
+CREATE TABLE polls (
+  message_id BIGINT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+  room_id    BIGINT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+  options    TEXT[] NOT NULL CHECK (cardinality(options) BETWEEN 2 AND 10),
+  multiple   BOOLEAN NOT NULL DEFAULT false,
+  anonymous  BOOLEAN NOT NULL DEFAULT false,
+  closes_at  TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- un voto por opción y usuario; en las de opción única el servidor deja
+-- una sola fila por usuario
+CREATE TABLE poll_votes (
+  message_id   BIGINT NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+  user_id      BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  option_index INTEGER NOT NULL CHECK (option_index >= 0),
+  voted_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (message_id, user_id, option_index)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS polls;
+DELETE FROM messages WHERE kind = 'poll';
+ALTER TABLE messages
+  DROP CONSTRAINT messages_kind_check,
+  ADD CONSTRAINT messages_kind_check CHECK (kind IN ('text', 'action'));
+-- +goose StatementEnd
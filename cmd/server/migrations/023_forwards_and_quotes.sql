@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- forwarded_from_*: de dónde viene un mensaje reenviado. El autor y el room
+-- originales se copian para que sigan ahí aunque el original se borre; al
+-- reenviar un reenvío se conserva el primer origen. forwarded_from_room_id
+-- queda NULL si el original era un DM.
+-- quote_message_id: el mensaje citado; si se borra, la cita desaparece.
+ALTER TABLE messages
+  ADD COLUMN forwarded_from_id      BIGINT REFERENCES messages(id) ON DELETE SET NULL,
+  ADD COLUMN forwarded_from_user_id BIGINT REFERENCES users(id) ON DELETE RESTRICT,
+  ADD COLUMN forwarded_from_room_id BIGINT REFERENCES rooms(id) ON DELETE SET NULL,
+  ADD COLUMN quote_message_id       BIGINT REFERENCES messages(id) ON DELETE SET NULL;
+
+-- para que borrar un mensaje no recorra toda la tabla buscando referencias
+CREATE INDEX idx_messages_forwarded_from_id ON messages (forwarded_from_id) WHERE forwarded_from_id IS NOT NULL;
+CREATE INDEX idx_messages_quote_message_id ON messages (quote_message_id) WHERE quote_message_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_quote_message_id;
+DROP INDEX IF EXISTS idx_messages_forwarded_from_id;
+ALTER TABLE messages
+  DROP COLUMN IF EXISTS quote_message_id,
+  DROP COLUMN IF EXISTS forwarded_from_room_id,
+  DROP COLUMN IF EXISTS forwarded_from_user_id,
+  DROP COLUMN IF EXISTS forwarded_from_id;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- link_user_id: login iniciado por un usuario ya autenticado para vincular
+-- su cuenta con el proveedor. Una identidad nueva nunca se vincula sola por
+-- email, porque los emails locales no están verificados.
+ALTER TABLE oidc_logins ADD COLUMN link_user_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oidc_logins DROP COLUMN IF EXISTS link_user_id;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 'user_ip': intentos por usuario desde una IP, subject es "<ip> <username>".
+-- El bloqueo largo se aplica a esta clave; la de usuario solo demora, así
+-- fallos desde otras IPs no pueden dejar a nadie afuera de su cuenta.
+ALTER TABLE login_attempts DROP CONSTRAINT login_attempts_scope_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_scope_check CHECK (scope IN ('user', 'ip', 'user_ip'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM login_attempts WHERE scope = 'user_ip';
+ALTER TABLE login_attempts DROP CONSTRAINT login_attempts_scope_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_scope_check CHECK (scope IN ('user', 'ip'));
+-- +goose StatementEnd
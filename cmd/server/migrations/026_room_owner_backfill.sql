@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 002 dejó a todos los miembros existentes como 'member', así que las salas
+-- creadas antes no tienen dueño. Se asciende a uno por sala: el moderador más
+-- antiguo si hay, si no el miembro más antiguo; las personas activas antes que
+-- las desactivadas y los bots.
+UPDATE room_members rm
+SET role = 'owner'
+FROM (
+  SELECT DISTINCT ON (m.room_id) m.room_id, m.user_id
+  FROM room_members m
+  JOIN users u ON u.id = m.user_id
+  WHERE NOT EXISTS (
+    SELECT 1 FROM room_members o WHERE o.room_id = m.room_id AND o.role = 'owner'
+  )
+  ORDER BY m.room_id, u.is_bot, u.deactivated_at IS NOT NULL, m.role <> 'moderator', m.joined_at, m.user_id
+) pick
+WHERE rm.room_id = pick.room_id AND rm.user_id = pick.user_id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- no se distingue a los dueños asignados acá de los reales: no se revierte
+SELECT 1;
+-- +goose StatementEnd
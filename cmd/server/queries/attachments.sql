@@ -0,0 +1,53 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (uploader_id, storage_key, filename, content_type, size_bytes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, uploader_id, message_id, storage_key, filename, content_type, size_bytes, created_at;
+
+-- name: GetAttachment :one
+SELECT id, uploader_id, message_id, storage_key, filename, content_type, size_bytes, created_at
+FROM attachments
+WHERE id = $1;
+
+-- name: LinkAttachmentsToMessage :many
+UPDATE attachments
+SET message_id = @message_id
+WHERE id = ANY(@ids::bigint[]) AND uploader_id = @uploader_id AND message_id IS NULL
+RETURNING id, uploader_id, message_id, storage_key, filename, content_type, size_bytes, created_at;
+
+-- name: ListAttachmentsByMessageIDs :many
+SELECT id, uploader_id, message_id, storage_key, filename, content_type, size_bytes, created_at
+FROM attachments
+WHERE message_id = ANY(@message_ids::bigint[])
+ORDER BY id;
+
+-- name: CanAccessAttachment :one
+-- el uploader, los miembros del room o los participantes de la conversación;
+-- los avatares los puede ver cualquiera
+SELECT EXISTS (
+  SELECT 1
+  FROM attachments a
+  LEFT JOIN messages m ON m.id = a.message_id
+  LEFT JOIN room_members rm ON rm.room_id = m.room_id AND rm.user_id = @user_id
+  LEFT JOIN conversations c ON c.id = m.conversation_id
+  WHERE a.id = @attachment_id
+    AND (a.uploader_id = @user_id OR rm.user_id IS NOT NULL OR c.user_a = @user_id OR c.user_b = @user_id
+      OR EXISTS (SELECT 1 FROM user_profiles p WHERE p.avatar_attachment_id = a.id))
+) AS can_access;
+
+-- name: DeleteUnlinkedAttachmentsByUploader :many
+-- Uploads never sent in a message (pending ones and avatars); the caller
+-- deletes the blobs.
+DELETE FROM attachments
+WHERE uploader_id = $1 AND message_id IS NULL
+RETURNING storage_key;
+
+-- name: ListRoomAttachmentKeys :many
+SELECT a.storage_key
+FROM attachments a
+JOIN messages m ON m.id = a.message_id
+WHERE m.room_id = $1;
+
+-- name: ListMessageAttachmentKeys :many
+SELECT storage_key
+FROM attachments
+WHERE message_id = $1;
@@ -0,0 +1,28 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (action, actor_id, target_type, target_id, ip, request_id, details)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ListAuditEvents :many
+-- Newest first. Empty filters match everything; before_id pages backwards.
+SELECT id, action, actor_id, target_type, target_id, ip, request_id, details, created_at
+FROM audit_events
+WHERE (@action::text = '' OR action = @action)
+  AND (@actor_id::bigint = 0 OR actor_id = @actor_id)
+  AND (@target_type::text = '' OR target_type = @target_type)
+  AND (@target_id::bigint = 0 OR target_id = @target_id)
+  AND (@ip::text = '' OR ip = @ip)
+  AND (sqlc.narg('since')::timestamptz IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR created_at < sqlc.narg('until'))
+  AND (@before_id::bigint = 0 OR id < @before_id)
+ORDER BY id DESC
+LIMIT @lim;
+
+-- name: PruneAuditEvents :execrows
+-- Deletes up to lim events older than before, oldest first.
+DELETE FROM audit_events
+WHERE id IN (
+    SELECT id FROM audit_events
+    WHERE created_at < @before
+    ORDER BY id
+    LIMIT @lim
+);
@@ -0,0 +1,46 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: HasBlockBetween :one
+-- True when either user blocked the other.
+SELECT EXISTS (
+  SELECT 1 FROM user_blocks
+  WHERE (blocker_id = @user_a AND blocked_id = @user_b)
+     OR (blocker_id = @user_b AND blocked_id = @user_a)
+) AS blocked;
+
+-- name: ListBlockedUsers :many
+SELECT u.id, u.username, u.is_bot, b.created_at
+FROM user_blocks b
+JOIN users u ON u.id = b.blocked_id
+WHERE b.blocker_id = $1
+ORDER BY b.created_at DESC;
+
+-- name: CanDirectMessage :one
+-- False when the recipient is deactivated, either user blocked the other or
+-- the recipient's DM policy doesn't let the sender in.
+SELECT NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE id = @recipient_id AND deactivated_at IS NOT NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = @recipient_id AND blocked_id = @sender_id)
+       OR (blocker_id = @sender_id AND blocked_id = @recipient_id)
+  )
+  AND CASE COALESCE((SELECT dm_policy FROM user_profiles WHERE user_id = @recipient_id), 'everyone')
+    WHEN 'everyone' THEN true
+    WHEN 'rooms' THEN EXISTS (
+      SELECT 1
+      FROM room_members a
+      JOIN room_members b ON b.room_id = a.room_id
+      WHERE a.user_id = @sender_id AND b.user_id = @recipient_id
+    )
+    ELSE false
+  END AS allowed;
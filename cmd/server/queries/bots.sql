@@ -0,0 +1,57 @@
+-- name: CreateBotUser :one
+INSERT INTO users (username, password, is_bot, owner_id)
+VALUES ($1, '', true, $2)
+RETURNING id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at;
+
+-- name: GetBot :one
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE id = $1 AND is_bot;
+
+-- name: ListBotsByOwner :many
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE owner_id = $1 AND is_bot
+ORDER BY id;
+
+-- name: CreateBotToken :exec
+INSERT INTO bot_tokens (bot_id, token_hash)
+VALUES ($1, $2);
+
+-- name: RevokeBotTokens :exec
+UPDATE bot_tokens
+SET revoked_at = now()
+WHERE bot_id = $1 AND revoked_at IS NULL;
+
+-- name: GetBotByTokenHash :one
+SELECT u.id, u.username
+FROM bot_tokens t
+JOIN users u ON u.id = t.bot_id
+LEFT JOIN users o ON o.id = u.owner_id
+WHERE t.token_hash = $1 AND t.revoked_at IS NULL
+  AND u.deactivated_at IS NULL AND o.deactivated_at IS NULL;
+
+-- name: CreateIncomingWebhook :one
+INSERT INTO incoming_webhooks (room_id, bot_id, token_hash, created_by)
+VALUES ($1, $2, $3, $4)
+RETURNING id, room_id, bot_id, token_hash, created_by, created_at;
+
+-- name: ListIncomingWebhooksByRoom :many
+SELECT w.id, w.room_id, w.bot_id, u.username AS bot_username, w.created_by, w.created_at
+FROM incoming_webhooks w
+JOIN users u ON u.id = w.bot_id
+WHERE w.room_id = $1
+ORDER BY w.id;
+
+-- name: DeleteIncomingWebhook :execrows
+DELETE FROM incoming_webhooks
+WHERE id = $1 AND room_id = $2;
+
+-- name: GetIncomingWebhookByTokenHash :one
+-- Skips webhooks whose bot or its owner is deactivated, like GetBotByTokenHash.
+SELECT w.id, w.room_id, w.bot_id, u.username AS bot_username
+FROM incoming_webhooks w
+JOIN users u ON u.id = w.bot_id
+LEFT JOIN users o ON o.id = u.owner_id
+WHERE w.token_hash = $1
+  AND u.deactivated_at IS NULL AND o.deactivated_at IS NULL;
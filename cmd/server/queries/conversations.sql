@@ -2,11 +2,18 @@
 INSERT INTO conversations (user_a, user_b)
 VALUES (LEAST($1, $2), GREATEST($1, $2))
 ON CONFLICT (user_a, user_b) DO UPDATE SET user_a = EXCLUDED.user_a
-RETURNING id, user_a, user_b;
+RETURNING id, user_a, user_b, default_ttl_seconds;
 
 -- name: ListConversationsByUser :many
-SELECT conversations.id, peer.id as peer_id, peer.username AS peer_username
+SELECT conversations.id, peer.id as peer_id, peer.username AS peer_username, conversations.default_ttl_seconds
 FROM conversations
 JOIN users peer ON (CASE WHEN user_a = @user_id THEN user_b ELSE user_a END) = peer.id
 WHERE user_a = @user_id OR user_b = @user_id
 ORDER BY conversations.id DESC LIMIT @lim;
+
+-- name: SetConversationTTL :execrows
+-- Only a participant can change it; no rows means the conversation does not
+-- exist or the user is not in it.
+UPDATE conversations
+SET default_ttl_seconds = @seconds
+WHERE id = @id AND (user_a = @user_id OR user_b = @user_id);
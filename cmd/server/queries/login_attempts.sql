@@ -0,0 +1,36 @@
+-- name: GetLoginAttempts :many
+SELECT scope, subject, failures, last_failure_at, blocked_until
+FROM login_attempts
+WHERE (scope = 'user' AND subject = sqlc.arg(username))
+   OR (scope = 'ip' AND subject = sqlc.arg(ip))
+   OR (scope = 'user_ip' AND subject = sqlc.arg(user_ip));
+
+-- name: RecordLoginFailure :one
+-- Counts a failure, starting over when the previous one is older than reset_seconds.
+INSERT INTO login_attempts (scope, subject, failures, last_failure_at)
+VALUES (sqlc.arg(scope), sqlc.arg(subject), 1, now())
+ON CONFLICT (scope, subject) DO UPDATE
+SET failures = CASE
+      WHEN login_attempts.last_failure_at < now() - make_interval(secs => sqlc.arg(reset_seconds)::int) THEN 1
+      ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = now()
+RETURNING failures;
+
+-- name: BlockLogin :exec
+UPDATE login_attempts
+SET blocked_until = $3
+WHERE scope = $1 AND subject = $2;
+
+-- name: ClearLoginAttempts :exec
+-- Clears the counters a successful login earns back: the username and the
+-- username from that IP. Other IPs stay blocked.
+DELETE FROM login_attempts
+WHERE (scope = 'user' AND subject = sqlc.arg(username))
+   OR (scope = 'user_ip' AND subject = sqlc.arg(user_ip));
+
+-- name: ResetLoginAttempts :execrows
+-- Clears the counters of a username, including those per (IP, username).
+DELETE FROM login_attempts
+WHERE (scope = 'user' AND subject = sqlc.arg(username))
+   OR (scope = 'user_ip' AND substr(subject, strpos(subject, ' ') + 1) = sqlc.arg(username));
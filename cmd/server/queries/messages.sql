@@ -1,32 +1,106 @@
 -- name: CreateMessage :one
-INSERT INTO messages (room_id, conversation_id, sender_id, body)
-VALUES ($1, $2, $3, $4)
-RETURNING id, room_id, conversation_id, sender_id, body, created_at;
+INSERT INTO messages (room_id, conversation_id, sender_id, body, kind, expires_at,
+    forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
 
 -- name: CreateDirectMessage :one
+-- A ttl_seconds of 0 falls back to the conversation default, which is 0 (no
+-- expiry) unless a participant set one.
 WITH conv AS (
     INSERT INTO conversations (user_a, user_b)
     VALUES (least(@sender_id::bigint, @to_user_id::bigint),
         greatest(@sender_id::bigint, @to_user_id::bigint))
         ON CONFLICT (user_a, user_b)
         DO UPDATE SET user_a = EXCLUDED.user_a
-    RETURNING id
+    RETURNING id, default_ttl_seconds
 )
-INSERT INTO messages (conversation_id, sender_id, body)
-    SELECT id, @sender_id, @body FROM conv
+INSERT INTO messages (conversation_id, sender_id, body, expires_at,
+    forwarded_from_id, forwarded_from_user_id, forwarded_from_room_id, quote_message_id)
+    SELECT id, @sender_id, @body,
+        now() + make_interval(secs => NULLIF(COALESCE(NULLIF(@ttl_seconds::int, 0), default_ttl_seconds), 0)),
+        sqlc.narg(forwarded_from_id)::bigint, sqlc.narg(forwarded_from_user_id)::bigint,
+        sqlc.narg(forwarded_from_room_id)::bigint, sqlc.narg(quote_message_id)::bigint
+    FROM conv
 RETURNING *;
 
 -- name: ListMessagesByRoom :many
-SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username, m.body, m.created_at
+SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username, u.is_bot AS sender_is_bot, m.body, m.created_at, m.kind, m.expires_at
 FROM messages m
 JOIN users u ON u.id = m.sender_id
-WHERE m.room_id = $1
+WHERE m.room_id = $1 AND (m.expires_at IS NULL OR m.expires_at > now())
 ORDER BY m.created_at DESC
 LIMIT $2;
 
 -- name: ListMessagesByConversation :many
-SELECT id, room_id, conversation_id, sender_id, body, created_at
+SELECT *
 FROM messages
-WHERE conversation_id = $1
+WHERE conversation_id = $1 AND (expires_at IS NULL OR expires_at > now())
 ORDER BY created_at DESC
 LIMIT $2;
+
+-- name: GetVisibleMessage :one
+-- Returns a message user_id can see: one of a room they are a member of or
+-- of one of their conversations, and not expired. Forwarded messages come
+-- with their origin.
+SELECT m.id, m.room_id, m.conversation_id, m.sender_id, u.username AS sender_username,
+  r.name AS room_name, m.body, m.kind, m.expires_at,
+  m.forwarded_from_id, m.forwarded_from_user_id, fu.username AS forwarded_from_username,
+  m.forwarded_from_room_id, fr.name AS forwarded_from_room_name
+FROM messages m
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN rooms r ON r.id = m.room_id
+LEFT JOIN users fu ON fu.id = m.forwarded_from_user_id
+LEFT JOIN rooms fr ON fr.id = m.forwarded_from_room_id
+WHERE m.id = @id
+  AND (m.expires_at IS NULL OR m.expires_at > now())
+  AND (
+    EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = @user_id)
+    OR EXISTS (SELECT 1 FROM conversations c WHERE c.id = m.conversation_id AND @user_id::bigint IN (c.user_a, c.user_b))
+  );
+
+-- name: ListMessageRefs :many
+-- Returns the forward origin and the quoted message of those of the given
+-- messages that have either. Quotes of expired messages are left out.
+SELECT m.id, m.forwarded_from_id, m.forwarded_from_user_id, fu.username AS forwarded_from_username,
+  m.forwarded_from_room_id, fr.name AS forwarded_from_room_name,
+  q.id AS quote_id, q.sender_id AS quote_sender_id, qu.username AS quote_sender_username, q.body AS quote_body
+FROM messages m
+LEFT JOIN users fu ON fu.id = m.forwarded_from_user_id
+LEFT JOIN rooms fr ON fr.id = m.forwarded_from_room_id
+LEFT JOIN messages q ON q.id = m.quote_message_id AND (q.expires_at IS NULL OR q.expires_at > now())
+LEFT JOIN users qu ON qu.id = q.sender_id
+WHERE m.id = ANY(@message_ids::bigint[])
+  AND (m.forwarded_from_user_id IS NOT NULL OR q.id IS NOT NULL);
+
+-- name: ListMessagesBySender :many
+SELECT id, room_id, conversation_id, body, created_at, kind
+FROM messages
+WHERE sender_id = @sender_id AND id > @after_id
+  AND (expires_at IS NULL OR expires_at > now())
+ORDER BY id
+LIMIT @lim;
+
+-- name: DeleteMessage :one
+-- Returns where the message was, and for DMs both participants, so they can
+-- be told about it.
+DELETE FROM messages m
+WHERE m.id = $1
+RETURNING m.id, m.room_id, m.conversation_id,
+  COALESCE((SELECT ARRAY[c.user_a, c.user_b] FROM conversations c WHERE c.id = m.conversation_id), '{}')::bigint[] AS participant_ids;
+
+-- name: DeleteExpiredMessages :many
+-- Deletes up to lim messages past their expires_at. Returns where each one
+-- was, the DM participants and the upload keys, so the caller can tell open
+-- clients and remove the blobs.
+DELETE FROM messages m
+WHERE m.id IN (
+    SELECT id FROM messages
+    WHERE expires_at <= now()
+    ORDER BY expires_at
+    LIMIT @lim
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING m.id, m.room_id, m.conversation_id,
+  COALESCE((SELECT ARRAY[c.user_a, c.user_b] FROM conversations c WHERE c.id = m.conversation_id), '{}')::bigint[] AS participant_ids,
+  ARRAY(SELECT a.storage_key FROM attachments a WHERE a.message_id = m.id)::text[] AS storage_keys;
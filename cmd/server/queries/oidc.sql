@@ -0,0 +1,49 @@
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins (state, nonce, code_verifier, device_code_hash, user_code, expires_at, link_user_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetOIDCLoginByUserCode :one
+-- Returns a device login that hasn't been opened in a browser yet.
+SELECT id, state, nonce, code_verifier, device_code_hash, user_code, user_id, created_at, expires_at, state_used_at, consumed_at, link_user_id
+FROM oidc_logins
+WHERE user_code = $1 AND state_used_at IS NULL AND expires_at > now();
+
+-- name: UseOIDCState :one
+-- Spends the state of a login when the provider redirects back. No rows
+-- means it is unknown, expired or already used.
+UPDATE oidc_logins
+SET state_used_at = now()
+WHERE state = $1 AND state_used_at IS NULL AND expires_at > now()
+RETURNING id, state, nonce, code_verifier, device_code_hash, user_code, user_id, created_at, expires_at, state_used_at, consumed_at, link_user_id;
+
+-- name: CompleteOIDCLogin :exec
+UPDATE oidc_logins
+SET user_id = $2
+WHERE id = $1;
+
+-- name: GetOIDCDeviceLogin :one
+SELECT id, state, nonce, code_verifier, device_code_hash, user_code, user_id, created_at, expires_at, state_used_at, consumed_at, link_user_id
+FROM oidc_logins
+WHERE device_code_hash = $1;
+
+-- name: ConsumeOIDCDeviceLogin :execrows
+-- Hands a finished device login to the polling client. Zero rows means it
+-- was already picked up.
+UPDATE oidc_logins
+SET consumed_at = now()
+WHERE id = $1 AND consumed_at IS NULL AND user_id IS NOT NULL;
+
+-- name: GetUserIdentity :one
+SELECT id, user_id, issuer, subject, email, created_at, last_login_at
+FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (user_id, issuer, subject, email)
+VALUES ($1, $2, $3, $4)
+RETURNING id, user_id, issuer, subject, email, created_at, last_login_at;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET last_login_at = now(), email = $2
+WHERE id = $1;
@@ -0,0 +1,19 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (token_hash, user_id, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: GetPasswordResetUser :one
+-- Returns the user of a reset token that is still usable.
+SELECT user_id
+FROM password_resets
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now();
+
+-- name: UsePasswordReset :execrows
+-- Spends a reset token. Zero rows means it was used or expired meanwhile.
+UPDATE password_resets
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now();
+
+-- name: DeletePasswordResets :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
@@ -0,0 +1,27 @@
+-- name: PinMessage :execrows
+-- Pinning a message twice keeps the first pin. No rows means the message is
+-- not in that room or has expired.
+INSERT INTO pinned_messages (message_id, room_id, pinned_by)
+SELECT m.id, @room_id::bigint, @pinned_by::bigint
+FROM messages m
+WHERE m.id = @message_id AND m.room_id = @room_id::bigint
+  AND (m.expires_at IS NULL OR m.expires_at > now())
+ON CONFLICT (message_id) DO UPDATE SET pinned_at = pinned_messages.pinned_at;
+
+-- name: UnpinMessage :execrows
+DELETE FROM pinned_messages
+WHERE room_id = $1 AND message_id = $2;
+
+-- name: CountPinnedMessages :one
+SELECT count(*) FROM pinned_messages
+WHERE room_id = $1;
+
+-- name: ListPinnedMessages :many
+SELECT m.id, m.sender_id, u.username AS sender_username, u.is_bot AS sender_is_bot, m.body, m.kind, m.created_at,
+  p.pinned_at, pb.username AS pinned_by_username
+FROM pinned_messages p
+JOIN messages m ON m.id = p.message_id
+JOIN users u ON u.id = m.sender_id
+JOIN users pb ON pb.id = p.pinned_by
+WHERE p.room_id = $1 AND (m.expires_at IS NULL OR m.expires_at > now())
+ORDER BY p.pinned_at DESC;
@@ -0,0 +1,41 @@
+-- name: CreatePollMessage :one
+-- Inserts a room message of kind poll together with its poll.
+WITH msg AS (
+    INSERT INTO messages (room_id, sender_id, body, kind, expires_at)
+    VALUES (@room_id, @sender_id, @body, 'poll', @expires_at)
+    RETURNING *
+), poll AS (
+    INSERT INTO polls (message_id, room_id, options, multiple, anonymous, closes_at)
+    SELECT id, room_id, @options::text[], @multiple::boolean, @anonymous::boolean, @closes_at::timestamptz
+    FROM msg
+)
+SELECT * FROM msg;
+
+-- name: GetPoll :one
+SELECT p.*
+FROM polls p
+JOIN messages m ON m.id = p.message_id
+WHERE p.message_id = $1 AND (m.expires_at IS NULL OR m.expires_at > now());
+
+-- name: ListPollsByMessageIDs :many
+SELECT * FROM polls
+WHERE message_id = ANY(@message_ids::bigint[]);
+
+-- name: ListPollVotesByMessageIDs :many
+SELECT v.message_id, v.option_index, v.user_id, u.username
+FROM poll_votes v
+JOIN users u ON u.id = v.user_id
+WHERE v.message_id = ANY(@message_ids::bigint[])
+ORDER BY v.message_id, v.option_index, v.voted_at;
+
+-- name: SetPollVotes :exec
+-- Replaces the user's votes on a poll with options; an empty list retracts
+-- them. Options already voted keep their voted_at.
+WITH removed AS (
+    DELETE FROM poll_votes
+    WHERE message_id = @message_id AND user_id = @user_id
+      AND NOT (option_index = ANY(@options::int[]))
+)
+INSERT INTO poll_votes (message_id, user_id, option_index)
+SELECT @message_id::bigint, @user_id::bigint, unnest(@options::int[])
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,77 @@
+-- name: GetUserProfile :one
+-- Users without a profile row get empty fields.
+SELECT u.id, u.username, u.is_bot, u.created_at,
+  COALESCE(p.display_name, '')::text AS display_name,
+  COALESCE(p.bio, '')::text AS bio,
+  p.avatar_attachment_id,
+  COALESCE(p.avatar_url, '')::text AS avatar_url,
+  COALESCE(p.status_text, '')::text AS status_text,
+  COALESCE(p.status_emoji, '')::text AS status_emoji,
+  p.updated_at
+FROM users u
+LEFT JOIN user_profiles p ON p.user_id = u.id
+WHERE u.id = $1;
+
+-- name: UpsertUserProfile :one
+INSERT INTO user_profiles (user_id, display_name, bio, avatar_attachment_id, avatar_url, status_text, status_emoji)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (user_id) DO UPDATE SET
+  display_name = EXCLUDED.display_name,
+  bio = EXCLUDED.bio,
+  avatar_attachment_id = EXCLUDED.avatar_attachment_id,
+  avatar_url = EXCLUDED.avatar_url,
+  status_text = EXCLUDED.status_text,
+  status_emoji = EXCLUDED.status_emoji,
+  updated_at = now()
+RETURNING user_id, display_name, bio, avatar_attachment_id, avatar_url, status_text, status_emoji, updated_at, dm_policy;
+
+-- name: GetDMPolicy :one
+SELECT COALESCE((SELECT dm_policy FROM user_profiles WHERE user_id = $1), 'everyone')::text AS dm_policy;
+
+-- name: SetDMPolicy :exec
+INSERT INTO user_profiles (user_id, dm_policy)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET dm_policy = EXCLUDED.dm_policy;
+
+-- name: ListUserPeers :many
+-- Users who share a room or a conversation with the given user, the user included.
+SELECT b.user_id
+FROM room_members a
+JOIN room_members b ON b.room_id = a.room_id
+WHERE a.user_id = $1
+UNION
+SELECT CASE WHEN c.user_a = $1 THEN c.user_b ELSE c.user_a END
+FROM conversations c
+WHERE c.user_a = $1 OR c.user_b = $1
+UNION
+SELECT $1::bigint;
+
+-- name: SearchUsers :many
+-- Prefix matches on username or display name come first, then fuzzy
+-- (trigram) matches by similarity. Users blocked either way are left out.
+-- @prefix is the lowercased query with LIKE wildcards escaped and a
+-- trailing %.
+SELECT u.id, u.username, u.is_bot, u.created_at,
+  COALESCE(p.display_name, '')::text AS display_name,
+  COALESCE(p.status_text, '')::text AS status_text,
+  COALESCE(p.status_emoji, '')::text AS status_emoji
+FROM users u
+LEFT JOIN user_profiles p ON p.user_id = u.id
+WHERE u.id <> @user_id
+  AND u.deactivated_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks b
+    WHERE (b.blocker_id = @user_id AND b.blocked_id = u.id)
+       OR (b.blocker_id = u.id AND b.blocked_id = @user_id)
+  )
+  AND (
+    lower(u.username) LIKE @prefix::text
+    OR lower(p.display_name) LIKE @prefix::text
+    OR u.username % @query::text
+    OR p.display_name % @query::text
+  )
+ORDER BY
+  (lower(u.username) LIKE @prefix::text OR COALESCE(lower(p.display_name) LIKE @prefix::text, false)) DESC,
+  greatest(similarity(u.username, @query::text), similarity(COALESCE(p.display_name, ''), @query::text)) DESC,
+  u.username
+LIMIT @result_limit OFFSET @result_offset;
@@ -0,0 +1,44 @@
+-- name: ListExpiredRoomMessages :many
+-- Oldest first within each room, walking idx_messages_room_created_at. A room
+-- without retention_days uses default_before (NULL keeps forever); 0 keeps
+-- forever.
+SELECT m.id
+FROM rooms r
+CROSS JOIN LATERAL (
+    SELECT id FROM messages
+    WHERE room_id = r.id
+      AND created_at < COALESCE(now() - make_interval(days => r.retention_days), sqlc.narg('default_before')::timestamptz)
+    ORDER BY created_at
+    LIMIT @lim
+) m
+WHERE r.retention_days IS DISTINCT FROM 0
+LIMIT @lim;
+
+-- name: ListExpiredDirectMessages :many
+-- Oldest first within each conversation, walking idx_messages_conv_created_at.
+SELECT m.id
+FROM conversations c
+CROSS JOIN LATERAL (
+    SELECT id FROM messages
+    WHERE conversation_id = c.id AND created_at < @before
+    ORDER BY created_at
+    LIMIT @lim
+) m
+LIMIT @lim;
+
+-- name: CountExpiredRoomMessages :one
+SELECT count(*)
+FROM rooms r
+JOIN messages m ON m.room_id = r.id
+WHERE r.retention_days IS DISTINCT FROM 0
+  AND m.created_at < COALESCE(now() - make_interval(days => r.retention_days), sqlc.narg('default_before')::timestamptz);
+
+-- name: CountExpiredDirectMessages :one
+SELECT count(*)
+FROM messages
+WHERE conversation_id IS NOT NULL AND created_at < @before;
+
+-- name: DeleteMessagesByIDs :execrows
+-- Attachment rows go with them; their blobs are up to the caller.
+DELETE FROM messages
+WHERE id = ANY(@ids::bigint[]);
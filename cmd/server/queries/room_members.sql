@@ -3,26 +3,48 @@ INSERT INTO room_members (room_id, user_id)
 VALUES ($1, $2)
 ON CONFLICT DO NOTHING;
 
+-- name: AddRoomMember :exec
+INSERT INTO room_members (room_id, user_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (room_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+
 -- name: LeaveRoom :exec
 DELETE FROM room_members
 WHERE room_id = $1 AND user_id = $2;
 
 -- name: ListRoomMembers :many
-SELECT u.id, u.username
+SELECT u.id, u.username, rm.role
 FROM room_members rm
 JOIN users u ON u.id = rm.user_id
 WHERE rm.room_id = $1
 ORDER BY rm.joined_at;
 
 -- name: GetRoomsForUser :many
-SELECT r.id, r.name, r.slug, r.created_at
+SELECT r.id, r.name, r.slug, r.created_at, r.mode, r.slow_mode_seconds, r.topic, r.retention_days
 FROM rooms r
 JOIN room_members rm ON rm.room_id = r.id
 WHERE rm.user_id = $1
 ORDER BY r.created_at DESC;
 
+-- name: ListRoomMembershipsByUser :many
+SELECT r.id, r.name, r.slug, rm.role, rm.joined_at
+FROM room_members rm
+JOIN rooms r ON r.id = rm.room_id
+WHERE rm.user_id = $1
+ORDER BY rm.joined_at;
+
 -- name: IsMember :one
 SELECT EXISTS (
   SELECT 1 FROM room_members
   WHERE room_id = $1 AND user_id = $2
 ) AS is_member;
+
+-- name: GetRoomMemberRole :one
+SELECT role
+FROM room_members
+WHERE room_id = $1 AND user_id = $2;
+
+-- name: SetRoomMemberRole :execrows
+UPDATE room_members
+SET role = $3
+WHERE room_id = $1 AND user_id = $2;
@@ -1,19 +1,49 @@
 -- name: CreateRoom :one
 INSERT INTO rooms (name, slug)
 VALUES ($1, $2)
-RETURNING id, name, slug, created_at;
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days;
 
 -- name: ListRooms :many
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
+WHERE @include_archived::boolean OR mode <> 'archived'
 ORDER BY created_at DESC;
 
 -- name: GetRoomBySlug :one
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
 WHERE slug = $1;
 
 -- name: GetRoomByID :one
-SELECT id, name, slug, created_at
+SELECT id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days
 FROM rooms
 WHERE id = $1;
+
+-- name: SetRoomMode :one
+UPDATE rooms
+SET mode = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days;
+
+-- name: SetRoomRetention :one
+UPDATE rooms
+SET retention_days = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days;
+
+-- name: SetRoomSlowMode :one
+UPDATE rooms
+SET slow_mode_seconds = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days;
+
+-- name: SetRoomTopic :one
+UPDATE rooms
+SET topic = $2
+WHERE id = $1
+RETURNING id, name, slug, created_at, mode, slow_mode_seconds, topic, retention_days;
+
+-- name: DeleteRoom :execrows
+-- Members, messages, attachments and webhooks go with it.
+DELETE FROM rooms
+WHERE id = $1;
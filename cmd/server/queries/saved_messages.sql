@@ -0,0 +1,26 @@
+-- name: SaveMessage :execrows
+-- Saving a message twice keeps the first save. No rows means the user can't
+-- see the message: it is a DM they are not part of, or it has expired.
+INSERT INTO saved_messages (user_id, message_id)
+SELECT @user_id::bigint, m.id
+FROM messages m
+LEFT JOIN conversations c ON c.id = m.conversation_id
+WHERE m.id = @message_id
+  AND (m.room_id IS NOT NULL OR @user_id::bigint IN (c.user_a, c.user_b))
+  AND (m.expires_at IS NULL OR m.expires_at > now())
+ON CONFLICT (user_id, message_id) DO UPDATE SET saved_at = saved_messages.saved_at;
+
+-- name: UnsaveMessage :execrows
+DELETE FROM saved_messages
+WHERE user_id = $1 AND message_id = $2;
+
+-- name: ListSavedMessages :many
+SELECT m.id, m.room_id, r.name AS room_name, m.conversation_id, m.sender_id, u.username AS sender_username,
+  m.body, m.kind, m.created_at, s.saved_at
+FROM saved_messages s
+JOIN messages m ON m.id = s.message_id
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN rooms r ON r.id = m.room_id
+WHERE s.user_id = @user_id AND (m.expires_at IS NULL OR m.expires_at > now())
+ORDER BY s.saved_at DESC
+LIMIT @lim;
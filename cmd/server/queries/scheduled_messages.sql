@@ -0,0 +1,63 @@
+-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (sender_id, room_id, to_user_id, body, send_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetScheduledMessage :one
+SELECT * FROM scheduled_messages
+WHERE id = $1 AND sender_id = $2;
+
+-- name: ListScheduledMessagesBySender :many
+-- Pending and failed ones; sent messages are in the history.
+SELECT * FROM scheduled_messages
+WHERE sender_id = @sender_id AND status <> 'sent'
+ORDER BY send_at, id
+LIMIT @lim;
+
+-- name: CountPendingScheduledMessages :one
+SELECT count(*) FROM scheduled_messages
+WHERE sender_id = $1 AND status = 'pending';
+
+-- name: UpdateScheduledMessage :one
+-- Null keeps the current value. Editing a failed message queues it again.
+UPDATE scheduled_messages
+SET body = COALESCE(sqlc.narg('body'), body),
+    send_at = COALESCE(sqlc.narg('send_at'), send_at),
+    status = 'pending',
+    last_error = NULL,
+    updated_at = now()
+WHERE id = @id AND sender_id = @sender_id AND status <> 'sent'
+RETURNING *;
+
+-- name: DeleteScheduledMessage :execrows
+DELETE FROM scheduled_messages
+WHERE id = $1 AND sender_id = $2 AND status <> 'sent';
+
+-- name: ClaimDueScheduledMessages :many
+-- Marks due messages sent before they are published: a crash in between
+-- loses them rather than sending them twice.
+UPDATE scheduled_messages s
+SET status = 'sent', sent_at = now(), updated_at = now()
+FROM users u
+WHERE u.id = s.sender_id
+  AND s.id IN (
+    SELECT id
+    FROM scheduled_messages
+    WHERE status = 'pending' AND send_at <= now()
+    ORDER BY send_at
+    LIMIT @batch_size::int
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING s.id, s.sender_id, u.username AS sender_username, u.is_bot AS sender_is_bot,
+  (u.deactivated_at IS NOT NULL)::boolean AS sender_deactivated, s.room_id, s.to_user_id, s.body;
+
+-- name: SetScheduledMessageSent :exec
+UPDATE scheduled_messages
+SET message_id = $2
+WHERE id = $1;
+
+-- name: MarkScheduledMessageFailed :exec
+-- Either failed for good, or back to pending with a later send_at.
+UPDATE scheduled_messages
+SET status = $2, send_at = $3, last_error = $4, sent_at = NULL, updated_at = now()
+WHERE id = $1;
@@ -0,0 +1,49 @@
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, user_agent, ip, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at;
+
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, session_id)
+VALUES ($1, $2);
+
+-- name: GetRefreshToken :one
+SELECT t.session_id, t.used_at, s.user_id, s.expires_at, s.revoked_at
+FROM refresh_tokens t
+JOIN sessions s ON s.id = t.session_id
+WHERE t.token_hash = $1;
+
+-- name: UseRefreshToken :execrows
+-- Marks a refresh token as spent. Zero rows means it was already used.
+UPDATE refresh_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL;
+
+-- name: ExtendSession :exec
+UPDATE sessions
+SET last_used_at = now(), expires_at = $2
+WHERE id = $1;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+  SELECT 1 FROM sessions
+  WHERE id = $1 AND revoked_at IS NULL AND expires_at > now()
+) AS active;
+
+-- name: ListActiveSessions :many
+SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at
+FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :many
+-- Revokes every active session of a user except one (pass 0 to keep none).
+UPDATE sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+RETURNING id;
@@ -0,0 +1,40 @@
+-- name: GetUserTOTP :one
+SELECT user_id, secret, enabled_at, last_step, created_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: UpsertPendingTOTP :execrows
+-- Stores a new secret unless 2FA is already enabled for the user.
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_step = 0, created_at = now()
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE user_totp
+SET enabled_at = now()
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: AdvanceTOTPStep :execrows
+-- Records the step of an accepted code. Zero rows means it was already used.
+UPDATE user_totp
+SET last_step = $2
+WHERE user_id = $1 AND last_step < $2;
+
+-- name: DeleteTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
@@ -1,10 +1,10 @@
 -- name: CreateUser :one
-INSERT INTO users (username, password)
-VALUES ($1, $2)
-RETURNING id, username, password, created_at;
+INSERT INTO users (username, password, email)
+VALUES ($1, $2, $3)
+RETURNING id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at;
 
 -- name: GetUserByUsername :one
-SELECT id, username, password, created_at
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
 FROM users
 WHERE username = $1;
 
@@ -12,3 +12,117 @@ WHERE username = $1;
 SELECT id, username, created_at
 FROM users
 WHERE id = $1;
+
+-- name: IsUserAdmin :one
+SELECT is_admin
+FROM users
+WHERE id = $1;
+
+-- name: GetUserByEmail :one
+SELECT id, username, password, created_at, is_bot, owner_id, is_admin, email, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE lower(email) = lower($1) AND NOT is_bot;
+
+-- name: GetUserPasswordHash :one
+SELECT password
+FROM users
+WHERE id = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = $2
+WHERE id = $1;
+
+-- name: GetAccount :one
+SELECT id, username, email, created_at, deactivated_at, delete_after
+FROM users
+WHERE id = $1;
+
+-- name: ScheduleUserDeletion :exec
+-- Deactivates the account; the purger anonymizes it once delete_after passes.
+UPDATE users
+SET deactivated_at = now(), delete_after = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: CancelUserDeletion :execrows
+-- Reactivates an account whose grace period hasn't run out.
+UPDATE users
+SET deactivated_at = NULL, delete_after = NULL
+WHERE id = $1 AND delete_after > now();
+
+-- name: ListUsersDueForDeletion :many
+SELECT id
+FROM users
+WHERE delete_after <= now()
+ORDER BY delete_after
+LIMIT $1;
+
+-- name: PurgeUserData :exec
+-- Deletes everything a user logs in with or is listed by, revokes the tokens
+-- of their bots and replaces the profile with the "Deleted user" one.
+-- Messages and conversations are kept. Rooms they own pass to their oldest
+-- moderator, else their oldest member, people before bots.
+WITH del_sessions AS (
+    DELETE FROM sessions WHERE user_id = $1
+), del_totp AS (
+    DELETE FROM user_totp WHERE user_id = $1
+), del_recovery AS (
+    DELETE FROM recovery_codes WHERE user_id = $1
+), del_resets AS (
+    DELETE FROM password_resets WHERE user_id = $1
+), del_identities AS (
+    DELETE FROM user_identities WHERE user_id = $1
+), del_logins AS (
+    DELETE FROM oidc_logins WHERE user_id = $1
+), del_blocks AS (
+    DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1
+), pass_ownership AS (
+    UPDATE room_members rm SET role = 'owner'
+    FROM (
+        SELECT DISTINCT ON (m.room_id) m.room_id, m.user_id
+        FROM room_members m
+        JOIN room_members o ON o.room_id = m.room_id AND o.user_id = $1 AND o.role = 'owner'
+        JOIN users u ON u.id = m.user_id
+        WHERE m.user_id <> $1
+        ORDER BY m.room_id, u.is_bot, u.deactivated_at IS NOT NULL, m.role <> 'moderator', m.joined_at, m.user_id
+    ) heir
+    WHERE rm.room_id = heir.room_id AND rm.user_id = heir.user_id
+), del_members AS (
+    DELETE FROM room_members WHERE user_id = $1
+), revoke_bots AS (
+    UPDATE bot_tokens SET revoked_at = now()
+    WHERE revoked_at IS NULL AND bot_id IN (SELECT id FROM users WHERE owner_id = $1)
+)
+INSERT INTO user_profiles (user_id, display_name, dm_policy)
+VALUES ($1, 'Deleted user', 'nobody')
+ON CONFLICT (user_id) DO UPDATE
+SET display_name = EXCLUDED.display_name, bio = '', avatar_attachment_id = NULL, avatar_url = '',
+    status_text = '', status_emoji = '', dm_policy = EXCLUDED.dm_policy, updated_at = now();
+
+-- name: AnonymizeUser :exec
+-- Turns the account into the placeholder its messages keep pointing to. The
+-- username can't collide with a real one: those can't contain spaces.
+UPDATE users
+SET username = 'deleted user #' || id, password = '', email = NULL,
+    deactivated_at = COALESCE(deactivated_at, now()), delete_after = NULL, deleted_at = now()
+WHERE id = $1;
+
+-- name: ListUsers :many
+-- Every account, including bots and deleted ones, for the admin API.
+SELECT id, username, email, is_bot, is_admin, created_at, deactivated_at, delete_after, deleted_at
+FROM users
+WHERE NOT @deactivated_only::boolean OR deactivated_at IS NOT NULL
+ORDER BY id
+LIMIT @lim OFFSET @off;
+
+-- name: DeactivateUser :execrows
+-- Deactivation by an admin: no delete_after, so logging in doesn't undo it.
+UPDATE users
+SET deactivated_at = now(), delete_after = NULL
+WHERE id = $1 AND deactivated_at IS NULL;
+
+-- name: ReactivateUser :execrows
+-- Lifts an admin deactivation or a pending deletion. Deleted accounts stay.
+UPDATE users
+SET deactivated_at = NULL, delete_after = NULL
+WHERE id = $1 AND deactivated_at IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,63 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (room_id, url, secret, event_types, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, room_id, url, secret, event_types, created_by, created_at;
+
+-- name: GetWebhook :one
+SELECT id, room_id, url, secret, event_types, created_by, created_at
+FROM webhooks
+WHERE id = $1 AND room_id = $2;
+
+-- name: ListWebhooksByRoom :many
+SELECT id, room_id, url, secret, event_types, created_by, created_at
+FROM webhooks
+WHERE room_id = $1
+ORDER BY id;
+
+-- name: ListWebhooksForEvent :many
+SELECT id, room_id, url, secret, event_types, created_by, created_at
+FROM webhooks
+WHERE room_id = @room_id AND @event_type::text = ANY(event_types);
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND room_id = $2;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+VALUES ($1, $2, $3);
+
+-- name: ClaimWebhookDeliveries :many
+-- reserva entregas vencidas; el lease debe cubrir el envío de todo el lote para que otra
+-- instancia no las tome mientras se envían
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1,
+    next_attempt_at = now() + make_interval(secs => @lease_seconds::int)
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT @batch_size::int
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.attempts, w.url, w.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = now()
+WHERE id = $1;
+
+-- name: MarkWebhookAttemptFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2;